@@ -0,0 +1,49 @@
+package collector
+
+import (
+	"context"
+	"time"
+)
+
+// runs a task in the background right away, then once per interval, until stopped;
+// each run gets a context expiring after the interval, so that a hanging command, run with CommandContext, can't stall the loop,
+// and the heartbeat of the loop is recorded after each run, whether it succeeded or not
+type BackgroundRunner struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// starts running the task in the background; loop is the name of the loop in the heartbeat metric
+func RunInBackground(loop string, interval time.Duration, task func(ctx context.Context)) *BackgroundRunner {
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &BackgroundRunner{
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+
+	go func() {
+		defer close(r.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			runCtx, runCancel := context.WithTimeout(ctx, interval)
+			task(runCtx)
+			runCancel()
+			Heartbeat(loop, time.Now())
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return r
+}
+
+// stops the loop, killing the commands of the current run, if any, and waits for it to return
+func (r *BackgroundRunner) Stop() {
+	r.cancel()
+	<-r.done
+}
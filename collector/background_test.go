@@ -0,0 +1,80 @@
+package collector
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// the heartbeats are global, so the ones of the test loops are dropped not to leak into the other tests
+func forgetHeartbeat(t *testing.T, loop string) {
+	t.Cleanup(func() {
+		heartbeats.Lock()
+		defer heartbeats.Unlock()
+		delete(heartbeats.beats, loop)
+	})
+}
+
+func TestRunInBackground(t *testing.T) {
+	forgetHeartbeat(t, "test_background")
+	var runs int32
+	runner := RunInBackground("test_background", 10*time.Millisecond, func(ctx context.Context) {
+		atomic.AddInt32(&runs, 1)
+	})
+
+	// the task runs right away, then once per interval
+	assert.Eventually(t, func() bool { return atomic.LoadInt32(&runs) >= 3 }, 5*time.Second, time.Millisecond)
+
+	runner.Stop()
+	stoppedRuns := atomic.LoadInt32(&runs)
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, stoppedRuns, atomic.LoadInt32(&runs))
+
+	heartbeats.Lock()
+	defer heartbeats.Unlock()
+	assert.False(t, heartbeats.beats["test_background"].IsZero())
+}
+
+func TestRunInBackgroundTimeout(t *testing.T) {
+	forgetHeartbeat(t, "test_timeout")
+	done := make(chan error, 1)
+	runner := RunInBackground("test_timeout", 20*time.Millisecond, func(ctx context.Context) {
+		select {
+		case done <- CommandContext(ctx, "sleep", "10").Run():
+		default:
+		}
+	})
+	defer runner.Stop()
+
+	// a command hanging for longer than the interval is killed
+	select {
+	case err := <-done:
+		assert.Error(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the command was not killed")
+	}
+}
+
+func TestStopBackgroundRunnerKillsTheCurrentRun(t *testing.T) {
+	forgetHeartbeat(t, "test_stop")
+	started := make(chan struct{})
+	runner := RunInBackground("test_stop", time.Hour, func(ctx context.Context) {
+		close(started)
+		<-ctx.Done()
+	})
+	<-started
+
+	stopped := make(chan struct{})
+	go func() {
+		runner.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the runner didn't stop")
+	}
+}
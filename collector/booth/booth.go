@@ -0,0 +1,237 @@
+package booth
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/ClusterLabs/ha_cluster_exporter/collector"
+)
+
+const subsystem = "booth"
+
+// the LSB exit code of `booth status` when the daemon is not running
+const notRunningExitCode = 7
+
+// the format of the times printed by booth, in the local time of the host it runs on, which is UTC when run by the collector, see command
+const timeLayout = "2006-01-02 15:04:05"
+
+// the states of a ticket as seen from the local booth instance
+var ticketStates = []string{"granted", "granted_elsewhere", "revoked"}
+
+// NewCollector creates a new booth collector
+// it works on both the cluster sites and the arbitrators, since they all run the same booth daemon
+func NewCollector(boothPath string, timestamps bool, logger log.Logger) (*boothCollector, error) {
+	err := collector.CheckExecutables(boothPath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not initialize '%s' collector", subsystem)
+	}
+
+	c := &boothCollector{
+		collector.NewDefaultCollector(subsystem, timestamps, logger),
+		boothPath,
+	}
+
+	c.SetDescriptor("active", "Whether the booth daemon is running; 1 means active, 0 otherwise", nil)
+	c.SetDescriptor("ticket_state", "The state of each ticket as seen from the local booth; one line per ticket and known state, the current one having value 1", []string{"ticket", "state"})
+	c.SetDescriptor("ticket_expires_timestamp_seconds", "The time the grant of each granted ticket expires unless it's renewed", []string{"ticket"})
+	c.SetDescriptor("peer_last_recv_timestamp_seconds", "The last time the local booth received a message from each peer; one line per site and arbitrator", []string{"peer", "type"})
+
+	c.SetVolatile("ticket_expires_timestamp_seconds", "peer_last_recv_timestamp_seconds")
+
+	return c, nil
+}
+
+type boothCollector struct {
+	collector.DefaultCollector
+	boothPath string
+}
+
+// a ticket as listed by booth
+type ticket struct {
+	Name string
+	// the address of the site the ticket is granted to; empty if it's not granted
+	Leader  string
+	Expires time.Time
+}
+
+// a booth site or arbitrator the local booth talks to
+type peer struct {
+	Address string
+	Type    string
+	// zero if no message was ever received
+	LastRecv time.Time
+}
+
+func (c *boothCollector) CollectWithError(ch chan<- prometheus.Metric) error {
+	level.Debug(c.Logger).Log("msg", "Collecting booth metrics...")
+
+	output, err := c.command("status").Output()
+	if err != nil {
+		var exitError *exec.ExitError
+		if errors.As(err, &exitError) && exitError.ExitCode() == notRunningExitCode && !collector.IsRemoteFailure(err) {
+			ch <- c.MakeGaugeMetric("active", 0)
+			return nil
+		}
+		return errors.Wrap(err, "booth status command failed")
+	}
+	status := parseBoothStatus(output)
+	ch <- c.MakeGaugeMetric("active", 1)
+
+	output, err = c.command("list").Output()
+	if err != nil {
+		return errors.Wrap(err, "booth list command failed")
+	}
+	for _, t := range parseBoothList(output) {
+		c.recordTicket(t, status["booth_addr_string"], ch)
+	}
+
+	output, err = c.command("peers").Output()
+	if err != nil {
+		return errors.Wrap(err, "booth peers command failed")
+	}
+	for _, p := range parseBoothPeers(output) {
+		if p.LastRecv.IsZero() {
+			continue
+		}
+		ch <- c.MakeGaugeMetric("peer_last_recv_timestamp_seconds", float64(p.LastRecv.Unix()), p.Address, p.Type)
+	}
+
+	return nil
+}
+
+func (c *boothCollector) Collect(ch chan<- prometheus.Metric) {
+	level.Debug(c.Logger).Log("msg", "Collecting booth metrics...")
+
+	err := c.CollectWithError(ch)
+	if err != nil {
+		level.Warn(c.Logger).Log("msg", c.GetSubsystem()+" collector scrape failed", "err", err)
+	}
+}
+
+// runs a booth command with TZ=UTC, so that its times don't depend on the time zone of the host it runs on, which may be a remote one;
+// the variable is set with env, since ssh doesn't pass it on
+func (c *boothCollector) command(command string) *collector.Cmd {
+	return collector.Command("env", "TZ=UTC", c.boothPath, command)
+}
+
+func (c *boothCollector) CollectRawOutput(ctx context.Context, w io.Writer) error {
+	for _, command := range []string{"status", "list", "peers"} {
+		err := collector.WriteCommandOutput(ctx, w, c.boothPath, command)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *boothCollector) recordTicket(t ticket, localAddress string, ch chan<- prometheus.Metric) {
+	state := "revoked"
+	if t.Leader != "" {
+		state = "granted_elsewhere"
+		if t.Leader == localAddress {
+			state = "granted"
+		}
+	}
+	for _, s := range ticketStates {
+		var value float64
+		if s == state {
+			value = 1
+		}
+		ch <- c.MakeGaugeMetric("ticket_state", value, t.Name, s)
+	}
+
+	if t.Leader != "" && !t.Expires.IsZero() {
+		ch <- c.MakeGaugeMetric("ticket_expires_timestamp_seconds", float64(t.Expires.Unix()), t.Name)
+	}
+}
+
+// parses the output of `booth status`, which is a single line of shell variables like:
+/*
+	booth_state="started" booth_type="site" booth_addr_string="192.168.1.10" booth_port="9929"
+*/
+func parseBoothStatus(output []byte) map[string]string {
+	status := make(map[string]string)
+	for _, field := range strings.Fields(string(output)) {
+		parts := strings.SplitN(field, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		status[parts[0]] = strings.Trim(parts[1], `"`)
+	}
+	return status
+}
+
+// parses the output of `booth list`, which has one line per ticket like:
+/*
+	ticket: ticket-nfs, leader: 192.168.1.10, expires: 2021-03-01 11:33:40
+	ticket: ticket-db, leader: NONE
+*/
+// newer versions append more fields, like the commit time, which are ignored
+func parseBoothList(output []byte) []ticket {
+	var tickets []ticket
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		fields := parseFields(scanner.Text())
+		name, ok := fields["ticket"]
+		if !ok {
+			continue
+		}
+		t := ticket{Name: name}
+		if leader := fields["leader"]; leader != "NONE" {
+			t.Leader = leader
+		}
+		if expires, err := time.ParseInLocation(timeLayout, fields["expires"], time.UTC); err == nil {
+			t.Expires = expires
+		}
+		tickets = append(tickets, t)
+	}
+	return tickets
+}
+
+// parses the output of `booth peers`, which has one line per peer, followed by the packet statistics, like:
+/*
+	site       192.168.1.10, last recv: 2021-03-01 11:33:12
+		Sent pkts:121 error:0 resends:0 (0%)
+		Recv pkts:121 error:0 authfail:0 invalid:0
+	arbitrator 192.168.3.10, last recv: never
+*/
+func parseBoothPeers(output []byte) []peer {
+	var peers []peer
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		line := scanner.Text()
+		fields := strings.Fields(line)
+		if len(fields) < 2 || (fields[0] != "site" && fields[0] != "arbitrator") {
+			continue
+		}
+		p := peer{Address: strings.TrimSuffix(fields[1], ","), Type: fields[0]}
+		if lastRecv, err := time.ParseInLocation(timeLayout, parseFields(line)["last recv"], time.UTC); err == nil {
+			p.LastRecv = lastRecv
+		}
+		peers = append(peers, p)
+	}
+	return peers
+}
+
+// splits a line of comma separated "key: value" fields; the values can contain colons, like times do
+func parseFields(line string) map[string]string {
+	fields := make(map[string]string)
+	for _, field := range strings.Split(line, ",") {
+		parts := strings.SplitN(field, ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		fields[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+	return fields
+}
@@ -0,0 +1,91 @@
+package booth
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+
+	assertcustom "github.com/ClusterLabs/ha_cluster_exporter/internal/assert"
+)
+
+func TestNewBoothCollector(t *testing.T) {
+	_, err := NewCollector("../../test/fake_booth.sh", false, log.NewNopLogger())
+
+	assert.Nil(t, err)
+}
+
+func TestNewBoothCollectorChecksBoothExistence(t *testing.T) {
+	_, err := NewCollector("../../test/nonexistent", false, log.NewNopLogger())
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "'../../test/nonexistent' does not exist")
+}
+
+func TestBoothCollector(t *testing.T) {
+	collector, err := NewCollector("../../test/fake_booth.sh", false, log.NewNopLogger())
+	assert.Nil(t, err)
+	// the times don't depend on the time zone of the exporter, nor on the one of booth
+	t.Setenv("TZ", "Asia/Tokyo")
+
+	assertcustom.Metrics(t, collector, "booth.metrics")
+}
+
+func TestBoothCollectorInactive(t *testing.T) {
+	collector, err := NewCollector("../../test/fake_booth_inactive.sh", false, log.NewNopLogger())
+	assert.Nil(t, err)
+
+	expect := `
+	# HELP ha_cluster_booth_active Whether the booth daemon is running; 1 means active, 0 otherwise
+	# TYPE ha_cluster_booth_active gauge
+	ha_cluster_booth_active 0
+	`
+
+	err = testutil.CollectAndCompare(collector, strings.NewReader(expect))
+	assert.NoError(t, err)
+}
+
+func TestParseBoothList(t *testing.T) {
+	output := []byte(`
+ticket: ticket-nfs, leader: 192.168.1.10, expires: 2021-03-01 11:33:40
+ticket: ticket-db, leader: 192.168.2.10, expires: 2021-03-01 11:34:05, commit: 2021-03-01 11:23:05
+ticket: ticket-web, leader: NONE
+`)
+
+	tickets := parseBoothList(output)
+
+	assert.Equal(t, []ticket{
+		{Name: "ticket-nfs", Leader: "192.168.1.10", Expires: time.Date(2021, 3, 1, 11, 33, 40, 0, time.UTC)},
+		{Name: "ticket-db", Leader: "192.168.2.10", Expires: time.Date(2021, 3, 1, 11, 34, 5, 0, time.UTC)},
+		{Name: "ticket-web"},
+	}, tickets)
+	assert.Empty(t, parseBoothList([]byte{}))
+}
+
+func TestParseBoothPeers(t *testing.T) {
+	output := []byte(`
+site       192.168.2.10, last recv: 2021-03-01 11:33:12
+	Sent pkts:121 error:0 resends:0 (0%)
+	Recv pkts:121 error:0 authfail:0 invalid:0
+arbitrator 192.168.3.10, last recv: never
+	Sent pkts:98 error:0 resends:0 (0%)
+	Recv pkts:0 error:0 authfail:0 invalid:0
+`)
+
+	peers := parseBoothPeers(output)
+
+	assert.Equal(t, []peer{
+		{Address: "192.168.2.10", Type: "site", LastRecv: time.Date(2021, 3, 1, 11, 33, 12, 0, time.UTC)},
+		{Address: "192.168.3.10", Type: "arbitrator"},
+	}, peers)
+}
+
+func TestParseBoothStatus(t *testing.T) {
+	status := parseBoothStatus([]byte(`booth_state="started" booth_type="arbitrator" booth_addr_string="192.168.3.10" booth_port="9929"` + "\n"))
+
+	assert.Equal(t, "arbitrator", status["booth_type"])
+	assert.Equal(t, "192.168.3.10", status["booth_addr_string"])
+}
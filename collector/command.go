@@ -0,0 +1,185 @@
+package collector
+
+import (
+	"context"
+	"math"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ssh exits with this code when it fails on its own, e.g. when the host can't be reached or the authentication fails,
+// as opposed to passing through the exit code of the remote command
+const sshFailureExitCode = 255
+
+// a host the external commands are run on via SSH, instead of the local one
+type RemoteHost struct {
+	Host    string
+	User    string
+	KeyFile string
+	// how long to wait for the connection to be established, and for each command to complete
+	Timeout time.Duration
+	SshPath string
+}
+
+// the host the collectors run their commands on; nil means the local one
+var remoteHost *RemoteHost
+
+// makes all the subsequent commands run on the given host; nil restores local execution.
+// it is meant to be called once at startup, before any collector is created.
+func SetRemoteHost(host *RemoteHost) {
+	remoteHost = host
+}
+
+// tells whether the commands are run on a remote host
+func IsRemote() bool {
+	return remoteHost != nil
+}
+
+// how long a command waits for another one to complete when the maximum number of parallel commands is reached,
+// so that a scrape fails instead of piling up behind hanging commands
+const maxCommandWait = 10 * time.Second
+
+var (
+	commandSlotsMutex sync.RWMutex
+	// the semaphore bounding how many commands run at the same time; nil means no limit
+	commandSlots chan struct{}
+)
+
+// bounds how many external commands, run by the collectors or in the background, can run at the same time;
+// maxParallel <= 0 means no limit. Unlike the remote host, it can be set after the collectors are created.
+func SetMaxParallelCommands(maxParallel int) {
+	commandSlotsMutex.Lock()
+	defer commandSlotsMutex.Unlock()
+	if maxParallel <= 0 {
+		commandSlots = nil
+		return
+	}
+	commandSlots = make(chan struct{}, maxParallel)
+}
+
+// an external command that waits for its turn to run, when the number of parallel commands is bounded
+type Cmd struct {
+	*exec.Cmd
+	ctx context.Context
+	// the command run, which is not the path of the executable on remote hosts
+	path string
+}
+
+// like exec.Command, but runs the command on the remote host, if one is set
+func Command(path string, args ...string) *Cmd {
+	return CommandContext(context.Background(), path, args...)
+}
+
+// like exec.CommandContext, but runs the command on the remote host, if one is set;
+// remote commands are killed when they don't complete within the timeout of the host
+func CommandContext(ctx context.Context, path string, args ...string) *Cmd {
+	if remoteHost == nil {
+		return &Cmd{exec.CommandContext(ctx, path, args...), ctx, path}
+	}
+	if remoteHost.Timeout > 0 {
+		// the caller runs the command, so the context can't be cancelled once it's done: the timer releases it when the timeout expires
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, remoteHost.Timeout)
+		time.AfterFunc(remoteHost.Timeout, cancel)
+	}
+	return &Cmd{exec.CommandContext(ctx, remoteHost.SshPath, remoteHost.sshArgs(path, args...)...), ctx, path}
+}
+
+// like exec.Cmd.Run, but waits for a free slot first
+func (c *Cmd) Run() error {
+	release, err := c.acquireSlot()
+	if err != nil {
+		return err
+	}
+	defer release()
+	return c.Cmd.Run()
+}
+
+// like exec.Cmd.Output, but waits for a free slot first
+func (c *Cmd) Output() ([]byte, error) {
+	release, err := c.acquireSlot()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return c.Cmd.Output()
+}
+
+// like exec.Cmd.CombinedOutput, but waits for a free slot first
+func (c *Cmd) CombinedOutput() ([]byte, error) {
+	release, err := c.acquireSlot()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return c.Cmd.CombinedOutput()
+}
+
+// waits until the command can run, for at most maxCommandWait, or until its context is done
+func (c *Cmd) acquireSlot() (release func(), err error) {
+	commandSlotsMutex.RLock()
+	slots := commandSlots
+	commandSlotsMutex.RUnlock()
+	if slots == nil {
+		return func() {}, nil
+	}
+
+	timer := time.NewTimer(maxCommandWait)
+	defer timer.Stop()
+	select {
+	case slots <- struct{}{}:
+		return func() { <-slots }, nil
+	case <-c.ctx.Done():
+		return nil, errors.Wrapf(c.ctx.Err(), "could not run '%s'", c.path)
+	case <-timer.C:
+		return nil, errors.Errorf("could not run '%s': the maximum number of parallel commands (%d) was reached for %s", c.path, cap(slots), maxCommandWait)
+	}
+}
+
+// tells whether err means that the command could not be run on the remote host at all, or didn't complete in time,
+// which would otherwise be indistinguishable from the command itself exiting with a non-zero code
+func IsRemoteFailure(err error) bool {
+	var exitError *exec.ExitError
+	if remoteHost == nil || !errors.As(err, &exitError) {
+		return false
+	}
+	// ssh is killed by a signal when the context is done, e.g. on timeout
+	return exitError.ExitCode() == sshFailureExitCode || exitError.ExitCode() == -1
+}
+
+func (h *RemoteHost) sshArgs(path string, args ...string) []string {
+	// never prompt for passwords or unknown host keys, so that authentication failures make ssh exit instead of hanging
+	sshArgs := []string{"-o", "BatchMode=yes"}
+	if h.Timeout > 0 {
+		seconds := strconv.Itoa(int(math.Ceil(h.Timeout.Seconds())))
+		sshArgs = append(sshArgs,
+			"-o", "ConnectTimeout="+seconds,
+			"-o", "ServerAliveInterval="+seconds,
+			"-o", "ServerAliveCountMax=1",
+		)
+	}
+	if h.KeyFile != "" {
+		sshArgs = append(sshArgs, "-i", h.KeyFile)
+	}
+	if h.User != "" {
+		sshArgs = append(sshArgs, "-l", h.User)
+	}
+
+	// ssh joins the command and its arguments with spaces and passes them to the remote shell, so they must be quoted
+	command := []string{shellQuote(path)}
+	for _, arg := range args {
+		command = append(command, shellQuote(arg))
+	}
+
+	return append(sshArgs, "--", h.Host, strings.Join(command, " "))
+}
+
+// quotes s so that a POSIX shell interprets it as a single literal word
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,129 @@
+package collector
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCommandLocal(t *testing.T) {
+	SetRemoteHost(nil)
+
+	cmd := Command("../test/fake_systemctl.sh", "show")
+
+	assert.Equal(t, []string{"../test/fake_systemctl.sh", "show"}, cmd.Args)
+	assert.False(t, IsRemote())
+}
+
+func TestCommandRemote(t *testing.T) {
+	SetRemoteHost(&RemoteHost{Host: "node01", SshPath: "../test/fake_ssh.sh"})
+	defer SetRemoteHost(nil)
+
+	output, err := Command("echo", "-n", "it's", "$HOME").Output()
+
+	assert.NoError(t, err)
+	assert.Equal(t, "it's $HOME", string(output))
+	assert.True(t, IsRemote())
+}
+
+func TestCommandRemoteFailure(t *testing.T) {
+	SetRemoteHost(&RemoteHost{Host: "unreachable", SshPath: "../test/fake_ssh.sh"})
+	defer SetRemoteHost(nil)
+
+	_, err := Command("echo").Output()
+	assert.Error(t, err)
+	assert.True(t, IsRemoteFailure(err))
+
+	var output bytes.Buffer
+	err = WriteCommandOutput(context.Background(), &output, "echo")
+	assert.Error(t, err)
+	assert.Empty(t, output.String())
+}
+
+func TestCommandRemoteTimeout(t *testing.T) {
+	SetRemoteHost(&RemoteHost{Host: "node01", Timeout: 100 * time.Millisecond, SshPath: "../test/fake_ssh.sh"})
+	defer SetRemoteHost(nil)
+
+	start := time.Now()
+	_, err := Command("sleep", "5").Output()
+	assert.Error(t, err)
+	assert.True(t, IsRemoteFailure(err))
+	assert.Less(t, int64(time.Since(start)), int64(5*time.Second))
+}
+
+func TestIsRemoteFailure(t *testing.T) {
+	SetRemoteHost(&RemoteHost{Host: "node01", SshPath: "../test/fake_ssh.sh"})
+	defer SetRemoteHost(nil)
+
+	// the remote command's own exit code is not a failure to run it
+	_, err := Command("../test/fake_sbd.sh", "-d", "/dev/vdd", "dump").Output()
+	assert.Error(t, err)
+	assert.False(t, IsRemoteFailure(err))
+}
+
+func TestSshArgs(t *testing.T) {
+	host := RemoteHost{Host: "node01", User: "hacluster", KeyFile: "/etc/key", Timeout: 1500 * time.Millisecond, SshPath: "ssh"}
+
+	assert.Equal(t, []string{
+		"-o", "BatchMode=yes",
+		"-o", "ConnectTimeout=2",
+		"-o", "ServerAliveInterval=2",
+		"-o", "ServerAliveCountMax=1",
+		"-i", "/etc/key",
+		"-l", "hacluster",
+		"--", "node01", "'/usr/sbin/crm_mon' '-X' '--inactive'",
+	}, host.sshArgs("/usr/sbin/crm_mon", "-X", "--inactive"))
+
+	host = RemoteHost{Host: "node01", SshPath: "ssh"}
+
+	assert.Equal(t, []string{"-o", "BatchMode=yes", "--", "node01", "'echo'"}, host.sshArgs("echo"))
+}
+
+func TestShellQuote(t *testing.T) {
+	assert.Equal(t, "''", shellQuote(""))
+	assert.Equal(t, "'a b'", shellQuote("a b"))
+	assert.Equal(t, `'it'\''s'`, shellQuote("it's"))
+}
+
+func TestCommandMaxParallel(t *testing.T) {
+	SetMaxParallelCommands(1)
+	defer SetMaxParallelCommands(0)
+
+	// the slot is released once the command completes, so the second one doesn't wait
+	for i := 0; i < 2; i++ {
+		_, err := Command("echo").Output()
+		assert.NoError(t, err)
+	}
+	assert.Len(t, commandSlots, 0)
+}
+
+func TestCommandMaxParallelWaitsForContext(t *testing.T) {
+	SetMaxParallelCommands(1)
+	defer SetMaxParallelCommands(0)
+
+	// take the only slot, as a running command would
+	commandSlots <- struct{}{}
+	defer func() { <-commandSlots }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err := CommandContext(ctx, "echo").Run()
+	assert.Error(t, err)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, int64(time.Since(start)), int64(maxCommandWait))
+}
+
+func TestSetMaxParallelCommands(t *testing.T) {
+	defer SetMaxParallelCommands(0)
+
+	SetMaxParallelCommands(2)
+	assert.Equal(t, 2, cap(commandSlots))
+
+	SetMaxParallelCommands(0)
+	assert.Nil(t, commandSlots)
+}
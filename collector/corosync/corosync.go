@@ -1,7 +1,15 @@
 package corosync
 
 import (
-	"os/exec"
+	"context"
+	"hash/fnv"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
@@ -13,23 +21,50 @@ import (
 
 const subsystem = "corosync"
 
-func NewCollector(cfgToolPath string, quorumToolPath string, timestamps bool, logger log.Logger) (*corosyncCollector, error) {
+// NewCollector creates a new corosync collector
+// the corosync-cmapctl executable is optional: if it can't be found, the crypto settings and the config reload check are simply not exposed
+// whether the corosync service is enabled at boot is checked only if services is not nil
+func NewCollector(cfgToolPath string, quorumToolPath string, cmapCtlPath string, configPath string, services *collector.ServiceChecker, timestamps bool, logger log.Logger) (*corosyncCollector, error) {
 	err := collector.CheckExecutables(cfgToolPath, quorumToolPath)
 	if err != nil {
 		return nil, errors.Wrapf(err, "could not initialize '%s' collector", subsystem)
 	}
 
 	c := &corosyncCollector{
-		collector.NewDefaultCollector(subsystem, timestamps, logger),
-		cfgToolPath,
-		quorumToolPath,
-		NewParser(),
+		DefaultCollector: collector.NewDefaultCollector(subsystem, timestamps, logger),
+		cfgToolPath:      cfgToolPath,
+		quorumToolPath:   quorumToolPath,
+		cmapCtlPath:      cmapCtlPath,
+		configPath:       configPath,
+		parser:           NewParser(),
+		services:         services,
+		toolVersion:      collector.NewToolVersion("Corosync", supportedVersions),
 	}
+
 	c.SetDescriptor("quorate", "Whether or not the cluster is quorate", nil)
+	c.SetDescriptor("service_enabled", "Whether the corosync systemd service is enabled to start at boot; 1 means enabled, 0 otherwise", []string{"service"})
 	c.SetDescriptor("rings", "The status of each Corosync ring; 1 means healthy, 0 means faulty.", []string{"ring_id", "node_id", "number", "address"})
 	c.SetDescriptor("ring_errors", "The total number of faulty corosync rings", nil)
 	c.SetDescriptor("member_votes", "How many votes each member node has contributed with to the current quorum", []string{"node_id", "node", "local"})
 	c.SetDescriptor("quorum_votes", "Cluster quorum votes; one line per type", []string{"type"})
+	c.SetDescriptor("crypto_cipher", "The cipher Corosync uses to encrypt the cluster traffic; value is always 1", []string{"cipher"})
+	c.SetDescriptor("transport", "The transport Corosync uses for the cluster traffic; value is always 1", []string{"transport"})
+	c.SetDescriptor("knet_compression", "The compression model the kronosnet transport uses for the cluster traffic; value is always 1", []string{"model"})
+	c.SetDescriptor("crypto_hash", "The hash Corosync uses to authenticate the cluster traffic; value is always 1", []string{"hash"})
+	c.SetDescriptor("config_reload_needed", "Whether the corosync configuration file differs from the running configuration, i.e. it was changed but not reloaded; 1 means a reload is needed, 0 otherwise", nil)
+	c.SetDescriptor("configured_nodes", "The number of nodes in the corosync nodelist", nil)
+	c.SetDescriptor("active_members", "The number of nodes that joined the corosync membership", nil)
+	c.SetDescriptor("config_version", "The totem.config_version of the configuration each member of the corosync membership runs with", []string{"node_id", "node"})
+	c.SetDescriptor("token_retransmits_total", "The number of messages retransmitted because other nodes reported them as missing in the token", nil)
+	c.SetDescriptor("token_lost_total", "The number of times the token was lost while the ring was operational, causing the membership to reform", nil)
+	c.SetDescriptor("consensus_timeouts_total", "The number of times the consensus about a new membership was not reached in time", nil)
+	c.SetDescriptor("link_rx_packets_total", "The number of packets received on each kronosnet link, by node and link", []string{"node_id", "link"})
+	c.SetDescriptor("link_tx_packets_total", "The number of packets sent on each kronosnet link, by node and link", []string{"node_id", "link"})
+	c.SetDescriptor("link_tx_errors_total", "The number of packets that could not be sent on each kronosnet link, by node and link", []string{"node_id", "link"})
+	c.SetDescriptor("membership_changes_total", "The number of times the Ring ID changed since the exporter started, i.e. how many times the cluster membership reformed", nil)
+	c.SetDescriptor("partition_id", "The Ring ID of the membership the local node is part of, which is the same on all the nodes of a partition; value is always 1", []string{"ring_id"})
+	c.SetDescriptor("tool_version_supported", "Whether the collector is known to parse the output of the running Corosync version correctly; 1 means supported, 0 otherwise", []string{"version"})
+	c.SetDescriptor("member_set_hash", "A hash of the node ids of the members of the membership the local node is part of, which is the same on all the nodes of a partition", nil)
 
 	return c, nil
 }
@@ -38,17 +73,40 @@ type corosyncCollector struct {
 	collector.DefaultCollector
 	cfgToolPath    string
 	quorumToolPath string
+	cmapCtlPath    string
+	configPath     string
 	parser         Parser
+
+	// nil when the service is not checked
+	services *collector.ServiceChecker
+
+	toolVersion *collector.ToolVersion
+
+	// the Ring ID seen in the previous scrape, used to detect membership changes across scrapes
+	membershipMutex   sync.Mutex
+	lastRingId        string
+	membershipChanges float64
 }
 
 func (c *corosyncCollector) CollectWithError(ch chan<- prometheus.Metric) error {
 	level.Debug(c.Logger).Log("msg", "Collecting corosync metrics...")
 
+	c.services.Record(&c.DefaultCollector, ch)
+	c.toolVersion.Record(&c.DefaultCollector, ch, c.getToolVersion)
+
 	// We suppress the exec errors because if any interface is faulty the tools will exit with code 1, but we still want to parse the output.
-	cfgToolOutput, _ := exec.Command(c.cfgToolPath, "-s").Output()
-	quorumToolOutput, _ := exec.Command(c.quorumToolPath, "-p").Output()
+	cfgToolOutput, _ := collector.Command(c.cfgToolPath, "-s").Output()
+	quorumToolOutput, _ := collector.Command(c.quorumToolPath, "-p").Output()
+
+	var cmapKeys map[string]string
+	cmapCtlOutput, cmapErr := c.getCmap()
+	if cmapErr != nil {
+		level.Debug(c.Logger).Log("msg", "Could not read the corosync running configuration", "err", cmapErr)
+	} else {
+		cmapKeys = parseCmapKeys(cmapCtlOutput)
+	}
 
-	status, err := c.parser.Parse(cfgToolOutput, quorumToolOutput)
+	status, err := c.parser.Parse(cfgToolOutput, quorumToolOutput, cmapKeys)
 	if err != nil {
 		return errors.Wrap(err, "corosync parser error")
 	}
@@ -58,6 +116,38 @@ func (c *corosyncCollector) CollectWithError(ch chan<- prometheus.Metric) error
 	c.collectQuorate(status, ch)
 	c.collectQuorumVotes(status, ch)
 	c.collectMemberVotes(status, ch)
+	c.collectMembershipChanges(status, ch)
+	c.collectPartition(status, ch)
+
+	if cmapErr != nil {
+		return nil
+	}
+
+	ch <- c.MakeGaugeMetric("configured_nodes", float64(countConfiguredNodes(cmapKeys)))
+	ch <- c.MakeGaugeMetric("active_members", float64(countActiveMembers(cmapKeys)))
+	c.collectConfigVersions(status, cmapKeys, ch)
+
+	// the stats map is only there since Corosync 3
+	statsOutput, err := collector.Command(c.cmapCtlPath, statsArgs...).Output()
+	if err != nil {
+		level.Debug(c.Logger).Log("msg", "Could not read the corosync stats map", "err", err)
+	}
+	statsKeys := parseCmapKeys(statsOutput)
+	c.collectTotemStats(statsKeys, cmapKeys, ch)
+
+	crypto := parseCrypto(cmapCtlOutput, isKnet(cfgToolOutput))
+	ch <- c.MakeGaugeMetric("crypto_cipher", 1, crypto.Cipher)
+	ch <- c.MakeGaugeMetric("crypto_hash", 1, crypto.Hash)
+
+	// the compression is a feature of kronosnet, so it doesn't apply to the other transports
+	transport := parseTransport(cmapKeys, isKnet(cfgToolOutput))
+	ch <- c.MakeGaugeMetric("transport", 1, transport)
+	if transport == "knet" {
+		ch <- c.MakeGaugeMetric("knet_compression", 1, parseKnetCompression(cmapKeys))
+		c.collectLinkStats(statsKeys, ch)
+	}
+
+	c.collectConfigReloadNeeded(cmapCtlOutput, ch)
 
 	return nil
 }
@@ -71,6 +161,158 @@ func (c *corosyncCollector) Collect(ch chan<- prometheus.Metric) {
 	}
 }
 
+func (c *corosyncCollector) CollectRawOutput(ctx context.Context, w io.Writer) error {
+	err := collector.WriteCommandOutput(ctx, w, c.cfgToolPath, "-s")
+	if err != nil {
+		return err
+	}
+	err = collector.WriteCommandOutput(ctx, w, c.quorumToolPath, "-p")
+	if err != nil {
+		return err
+	}
+	if collector.CheckExecutables(c.cmapCtlPath) != nil {
+		return nil
+	}
+	err = collector.WriteCommandOutput(ctx, w, c.cmapCtlPath, cmapPrefixes...)
+	if err != nil {
+		return err
+	}
+	return collector.WriteCommandOutput(ctx, w, c.cmapCtlPath, statsArgs...)
+}
+
+// the prefixes of the corosync-cmapctl keys the collector is interested in: the ones mirroring the sections of the configuration file,
+// the runtime state of votequorum and of the membership, and the statistics of the totem protocol
+var cmapPrefixes = []string{"totem.", "quorum.", "nodelist.", "runtime.votequorum.", "runtime.members.", totemStatsPrefix}
+
+// the prefix of the totem protocol statistics in the stats map of Corosync 3, e.g. stats.srp.mcast_retx
+const srpStatsPrefix = "stats.srp."
+
+// the prefix of the totem protocol statistics in the cmap, where Corosync 2 keeps them
+const totemStatsPrefix = "runtime.totem.pg.mrp.srp."
+
+// the totem statistics exposed as counters, which corosync keeps since it started
+var totemCounters = []struct {
+	key    string
+	metric string
+}{
+	{"mcast_retx", "token_retransmits_total"},
+	{"operational_token_lost", "token_lost_total"},
+	{"consensus_timeouts", "consensus_timeouts_total"},
+}
+
+// the statistics in the stats map: the totem ones, and the ones of the kronosnet links, under keys like stats.knet.node2.link0.rx_total_packets
+var statsArgs = []string{"-m", "stats", srpStatsPrefix, "stats.knet."}
+
+// the link statistics exposed as counters, which kronosnet keeps since corosync started
+var knetLinkCounters = []struct {
+	key    string
+	metric string
+}{
+	{"rx_total_packets", "link_rx_packets_total"},
+	{"tx_total_packets", "link_tx_packets_total"},
+	{"tx_total_errors", "link_tx_errors_total"},
+}
+
+// retrieves the running configuration and the votequorum state via corosync-cmapctl
+func (c *corosyncCollector) getCmap() ([]byte, error) {
+	if err := collector.CheckExecutables(c.cmapCtlPath); err != nil {
+		return nil, err
+	}
+
+	cmapCtlOutput, err := collector.Command(c.cmapCtlPath, cmapPrefixes...).Output()
+	if err != nil {
+		return nil, errors.Wrap(err, "corosync-cmapctl command failed")
+	}
+
+	return cmapCtlOutput, nil
+}
+
+// rising values mean the network is under stress, well before a ring is marked as faulty;
+// they are read from the stats map, falling back to the cmap for Corosync 2
+func (c *corosyncCollector) collectTotemStats(statsKeys map[string]string, cmapKeys map[string]string, ch chan<- prometheus.Metric) {
+	for _, counter := range totemCounters {
+		value, ok := statsKeys[srpStatsPrefix+counter.key]
+		if !ok {
+			value, ok = cmapKeys[totemStatsPrefix+counter.key]
+		}
+		if !ok {
+			continue
+		}
+		total, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			level.Warn(c.Logger).Log("msg", "Could not parse the corosync statistic "+counter.key, "err", err)
+			continue
+		}
+		ch <- c.MakeCounterMetric(counter.metric, total)
+	}
+}
+
+// members running different configuration versions are a sign of an incomplete rollout of the configuration file
+func (c *corosyncCollector) collectConfigVersions(status *Status, cmapKeys map[string]string, ch chan<- prometheus.Metric) {
+	versions, err := parseMemberConfigVersions(cmapKeys)
+	if err != nil {
+		level.Warn(c.Logger).Log("msg", "Could not parse the corosync config versions", "err", err)
+		return
+	}
+
+	names := make(map[string]string)
+	for _, member := range status.Members {
+		names[member.Id] = member.Name
+	}
+	for nodeId, version := range versions {
+		ch <- c.MakeGaugeMetric("config_version", float64(version), nodeId, names[nodeId])
+	}
+}
+
+// rising error counters on a link predict it's going to be marked as faulty
+func (c *corosyncCollector) collectLinkStats(statsKeys map[string]string, ch chan<- prometheus.Metric) {
+	re := regexp.MustCompile(`^stats\.knet\.node(\d+)\.link(\d+)\.(\w+)$`)
+	for key, value := range statsKeys {
+		match := re.FindStringSubmatch(key)
+		if match == nil {
+			continue
+		}
+		for _, counter := range knetLinkCounters {
+			if match[3] != counter.key {
+				continue
+			}
+			total, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				level.Warn(c.Logger).Log("msg", "Could not parse the kronosnet statistic "+key, "err", err)
+				continue
+			}
+			ch <- c.MakeCounterMetric(counter.metric, total, match[1], match[2])
+		}
+	}
+}
+
+// compares the configuration file with the running configuration, which corosync only updates on reload;
+// the file is local, so it can't be compared when the commands run on a remote host
+func (c *corosyncCollector) collectConfigReloadNeeded(cmapCtlOutput []byte, ch chan<- prometheus.Metric) {
+	if collector.IsRemote() {
+		return
+	}
+
+	config, err := ioutil.ReadFile(c.configPath)
+	if err != nil {
+		level.Debug(c.Logger).Log("msg", "Could not read the corosync configuration file", "err", err)
+		return
+	}
+	configKeys, err := parseConfig(config)
+	if err != nil {
+		level.Warn(c.Logger).Log("msg", "Could not parse the corosync configuration file "+c.configPath, "err", err)
+		return
+	}
+
+	var reloadNeeded float64
+	changedKeys := diffConfig(configKeys, parseCmapKeys(cmapCtlOutput))
+	if len(changedKeys) > 0 {
+		level.Debug(c.Logger).Log("msg", "The corosync configuration file differs from the running configuration", "keys", strings.Join(changedKeys, ","))
+		reloadNeeded = 1
+	}
+	ch <- c.MakeGaugeMetric("config_reload_needed", reloadNeeded)
+}
+
 func (c *corosyncCollector) collectQuorumVotes(status *Status, ch chan<- prometheus.Metric) {
 	ch <- c.MakeGaugeMetric("quorum_votes", float64(status.QuorumVotes.ExpectedVotes), "expected_votes")
 	ch <- c.MakeGaugeMetric("quorum_votes", float64(status.QuorumVotes.HighestExpected), "highest_expected")
@@ -115,3 +357,57 @@ func (c *corosyncCollector) collectMemberVotes(status *Status, ch chan<- prometh
 		ch <- c.MakeGaugeMetric("member_votes", float64(member.Votes), member.Id, member.Name, local)
 	}
 }
+
+func (c *corosyncCollector) collectMembershipChanges(status *Status, ch chan<- prometheus.Metric) {
+	c.membershipMutex.Lock()
+	defer c.membershipMutex.Unlock()
+
+	// the first Ring ID we see is just the baseline: we can't know how many times it changed before the exporter started;
+	// an unknown one, i.e. when only the cmap could be read, changes nothing
+	if status.RingId != "" && c.lastRingId != "" && c.lastRingId != status.RingId {
+		c.membershipChanges++
+	}
+	if status.RingId != "" {
+		c.lastRingId = status.RingId
+	}
+
+	ch <- c.MakeCounterMetric("membership_changes_total", c.membershipChanges)
+}
+
+// all the nodes of a partition agree on the Ring ID and on the members, so the instances of the exporter reporting different ones are in different partitions
+func (c *corosyncCollector) collectPartition(status *Status, ch chan<- prometheus.Metric) {
+	if status.RingId != "" {
+		ch <- c.MakeGaugeMetric("partition_id", 1, status.RingId)
+	}
+	ch <- c.MakeGaugeMetric("member_set_hash", float64(memberSetHash(status.Members)))
+}
+
+// hashes the sorted node ids of the members, so that the hash doesn't depend on the order they are listed in;
+// it's a 32 bit hash, so that it's exactly representable as a metric value.
+// The quorum device is listed with node id 0, but it's not a node, so it's left out
+func memberSetHash(members []Member) uint32 {
+	ids := make([]string, 0, len(members))
+	for _, member := range members {
+		if member.Id == "0" {
+			continue
+		}
+		ids = append(ids, member.Id)
+	}
+	sort.Strings(ids)
+
+	h := fnv.New32a()
+	h.Write([]byte(strings.Join(ids, ",")))
+	return h.Sum32()
+}
+
+// the Corosync versions whose tools output the collector is known to parse correctly, i.e. the 2.x ones with totem rings and the 3.x ones with kronosnet
+var supportedVersions = collector.VersionRange{Min: "2.3", Max: "4"}
+
+// the version is printed by all the corosync tools alike, e.g. "Corosync Cluster Engine, version '3.1.7'"
+func (c *corosyncCollector) getToolVersion() (string, error) {
+	output, err := collector.Command(c.quorumToolPath, "-V").Output()
+	if err != nil {
+		return "", errors.Wrap(err, "corosync-quorumtool command failed")
+	}
+	return collector.FindVersion(output), nil
+}
@@ -1,48 +1,226 @@
 package corosync
 
 import (
+	"bytes"
+	"context"
+	"os/exec"
+	"strings"
 	"testing"
 
 	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
 	"github.com/stretchr/testify/assert"
 
+	"github.com/ClusterLabs/ha_cluster_exporter/collector"
 	assertcustom "github.com/ClusterLabs/ha_cluster_exporter/internal/assert"
 )
 
 func TestNewCorosyncCollector(t *testing.T) {
-	_, err := NewCollector("../../test/fake_corosync-cfgtool.sh", "../../test/fake_corosync-quorumtool.sh", false, log.NewNopLogger())
+	_, err := NewCollector("../../test/fake_corosync-cfgtool.sh", "../../test/fake_corosync-quorumtool.sh", "../../test/fake_corosync-cmapctl.sh", "../../test/fake_corosync.conf", nil, false, log.NewNopLogger())
 	assert.Nil(t, err)
 }
 
 func TestNewCorosyncCollectorChecksCfgtoolExistence(t *testing.T) {
-	_, err := NewCollector("../../test/nonexistent", "../../test/fake_corosync-quorumtool.sh", false, log.NewNopLogger())
+	_, err := NewCollector("../../test/nonexistent", "../../test/fake_corosync-quorumtool.sh", "../../test/fake_corosync-cmapctl.sh", "../../test/fake_corosync.conf", nil, false, log.NewNopLogger())
 
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "'../../test/nonexistent' does not exist")
 }
 
 func TestNewCorosyncCollectorChecksQuorumtoolExistence(t *testing.T) {
-	_, err := NewCollector("../../test/fake_corosync-cfgtool.sh", "../../test/nonexistent", false, log.NewNopLogger())
+	_, err := NewCollector("../../test/fake_corosync-cfgtool.sh", "../../test/nonexistent", "../../test/fake_corosync-cmapctl.sh", "../../test/fake_corosync.conf", nil, false, log.NewNopLogger())
 
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "'../../test/nonexistent' does not exist")
 }
 
 func TestNewCorosyncCollectorChecksCfgtoolExecutableBits(t *testing.T) {
-	_, err := NewCollector("../../test/dummy", "../../test/fake_corosync-quorumtool.sh", false, log.NewNopLogger())
+	_, err := NewCollector("../../test/dummy", "../../test/fake_corosync-quorumtool.sh", "../../test/fake_corosync-cmapctl.sh", "../../test/fake_corosync.conf", nil, false, log.NewNopLogger())
 
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "'../../test/dummy' is not executable")
 }
 
 func TestNewCorosyncCollectorChecksQuorumtoolExecutableBits(t *testing.T) {
-	_, err := NewCollector("../../test/fake_corosync-cfgtool.sh", "../../test/dummy", false, log.NewNopLogger())
+	_, err := NewCollector("../../test/fake_corosync-cfgtool.sh", "../../test/dummy", "../../test/fake_corosync-cmapctl.sh", "../../test/fake_corosync.conf", nil, false, log.NewNopLogger())
 
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "'../../test/dummy' is not executable")
 }
 
 func TestCorosyncCollector(t *testing.T) {
-	collector, _ := NewCollector("../../test/fake_corosync-cfgtool.sh", "../../test/fake_corosync-quorumtool.sh", false, log.NewNopLogger())
+	collector, _ := NewCollector("../../test/fake_corosync-cfgtool.sh", "../../test/fake_corosync-quorumtool.sh", "../../test/fake_corosync-cmapctl.sh", "../../test/fake_corosync.conf", nil, false, log.NewNopLogger())
 	assertcustom.Metrics(t, collector, "corosync.metrics")
 }
+
+func TestMembershipChanges(t *testing.T) {
+	collector, _ := NewCollector("../../test/fake_corosync-cfgtool.sh", "../../test/fake_corosync-quorumtool.sh", "../../test/fake_corosync-cmapctl.sh", "../../test/fake_corosync.conf", nil, false, log.NewNopLogger())
+
+	collectMembershipChanges := func(ringId string) float64 {
+		ch := make(chan prometheus.Metric, 1)
+		collector.collectMembershipChanges(&Status{RingId: ringId}, ch)
+		metricDto := &dto.Metric{}
+		(<-ch).Write(metricDto)
+		return metricDto.GetCounter().GetValue()
+	}
+
+	assert.Equal(t, 0.0, collectMembershipChanges("1084783375/40"))
+	assert.Equal(t, 0.0, collectMembershipChanges("1084783375/40"))
+	assert.Equal(t, 1.0, collectMembershipChanges("1084783375/44"))
+	assert.Equal(t, 1.0, collectMembershipChanges("1084783375/44"))
+	assert.Equal(t, 2.0, collectMembershipChanges("1084783376/48"))
+}
+
+func TestMemberSetHash(t *testing.T) {
+	members := []Member{{Id: "1084783375", Name: "node01"}, {Id: "1084783376", Name: "node02"}}
+
+	// the order of the members doesn't matter, only their ids do
+	assert.Equal(t, memberSetHash(members), memberSetHash([]Member{members[1], members[0]}))
+	assert.Equal(t, memberSetHash(members), memberSetHash([]Member{{Id: "1084783376", Name: "node02", Local: true}, {Id: "1084783375", Name: "node01"}}))
+
+	// the quorum device is not a node
+	assert.Equal(t, memberSetHash(members), memberSetHash(append(members, Member{Id: "0", Name: "Qdevice"})))
+
+	// a partition has a different member set
+	assert.NotEqual(t, memberSetHash(members), memberSetHash(members[:1]))
+	assert.NotEqual(t, memberSetHash(members[:1]), memberSetHash(members[1:]))
+}
+
+func TestTotemStats(t *testing.T) {
+	collector, _ := NewCollector("../../test/fake_corosync-cfgtool.sh", "../../test/fake_corosync-quorumtool.sh", "../../test/fake_corosync-cmapctl.sh", "../../test/fake_corosync.conf", nil, false, log.NewNopLogger())
+
+	ch := make(chan prometheus.Metric, 3)
+	collector.collectTotemStats(nil, map[string]string{"runtime.votequorum.quorate": "1"}, ch)
+	close(ch)
+	assert.Len(t, ch, 0)
+
+	// Corosync 2 keeps the statistics in the cmap; values that can't be parsed are skipped
+	ch = make(chan prometheus.Metric, 3)
+	collector.collectTotemStats(nil, map[string]string{
+		"runtime.totem.pg.mrp.srp.mcast_retx":             "7",
+		"runtime.totem.pg.mrp.srp.operational_token_lost": "many",
+	}, ch)
+	close(ch)
+	assert.Len(t, ch, 1)
+	metricDto := &dto.Metric{}
+	(<-ch).Write(metricDto)
+	assert.Equal(t, 7.0, metricDto.GetCounter().GetValue())
+
+	// Corosync 3 keeps them in the stats map, which takes precedence
+	ch = make(chan prometheus.Metric, 3)
+	collector.collectTotemStats(map[string]string{
+		"stats.srp.mcast_retx": "9",
+	}, map[string]string{
+		"runtime.totem.pg.mrp.srp.mcast_retx": "7",
+	}, ch)
+	close(ch)
+	assert.Len(t, ch, 1)
+	metricDto = &dto.Metric{}
+	(<-ch).Write(metricDto)
+	assert.Equal(t, 9.0, metricDto.GetCounter().GetValue())
+}
+
+func TestLinkStats(t *testing.T) {
+	collector, _ := NewCollector("../../test/fake_corosync-cfgtool.sh", "../../test/fake_corosync-quorumtool.sh", "../../test/fake_corosync-cmapctl.sh", "../../test/fake_corosync.conf", nil, false, log.NewNopLogger())
+
+	statsOutput, err := exec.Command("../../test/fake_corosync-cmapctl.sh", statsArgs...).Output()
+	assert.NoError(t, err)
+
+	ch := make(chan prometheus.Metric, 20)
+	collector.collectLinkStats(parseCmapKeys(statsOutput), ch)
+	close(ch)
+
+	// the connected flags are not counters, so they are left out
+	values := make(map[string]float64)
+	for metric := range ch {
+		metricDto := &dto.Metric{}
+		metric.Write(metricDto)
+		labels := make(map[string]string)
+		for _, label := range metricDto.GetLabel() {
+			labels[label.GetName()] = label.GetValue()
+		}
+		for _, counter := range knetLinkCounters {
+			if metric.Desc() == collector.GetDescriptor(counter.metric) {
+				values[counter.metric+"/"+labels["node_id"]+"/"+labels["link"]] = metricDto.GetCounter().GetValue()
+			}
+		}
+	}
+	assert.Len(t, values, 9)
+	assert.Equal(t, 18346.0, values["link_rx_packets_total/1084783376/0"])
+	assert.Equal(t, 18412.0, values["link_tx_packets_total/1084783376/0"])
+	assert.Equal(t, 3.0, values["link_tx_errors_total/1084783376/0"])
+	assert.Equal(t, 9120.0, values["link_rx_packets_total/1084783376/1"])
+
+	// values that can't be parsed are skipped
+	ch = make(chan prometheus.Metric, 1)
+	collector.collectLinkStats(map[string]string{"stats.knet.node2.link0.tx_total_errors": "many"}, ch)
+	close(ch)
+	assert.Len(t, ch, 0)
+}
+
+func TestCorosyncCollectRawOutput(t *testing.T) {
+	collector, _ := NewCollector("../../test/fake_corosync-cfgtool.sh", "../../test/fake_corosync-quorumtool.sh", "../../test/fake_corosync-cmapctl.sh", "../../test/fake_corosync.conf", nil, false, log.NewNopLogger())
+
+	var output bytes.Buffer
+	err := collector.CollectRawOutput(context.Background(), &output)
+
+	assert.NoError(t, err)
+	assert.Contains(t, output.String(), "### ../../test/fake_corosync-cfgtool.sh -s\n")
+	assert.Contains(t, output.String(), "### ../../test/fake_corosync-quorumtool.sh -p\n")
+	assert.Contains(t, output.String(), "### ../../test/fake_corosync-cmapctl.sh totem. quorum. nodelist. runtime.votequorum. runtime.members. runtime.totem.pg.mrp.srp.\n")
+	assert.Contains(t, output.String(), "### ../../test/fake_corosync-cmapctl.sh -m stats stats.srp. stats.knet.\n")
+}
+
+func TestCorosyncCollectorWithoutCmapctl(t *testing.T) {
+	collector, err := NewCollector("../../test/fake_corosync-cfgtool.sh", "../../test/fake_corosync-quorumtool.sh", "../../test/nonexistent", "../../test/fake_corosync.conf", nil, false, log.NewNopLogger())
+	assert.NoError(t, err)
+
+	_, err = collector.getCmap()
+	assert.Error(t, err)
+
+	var output bytes.Buffer
+	err = collector.CollectRawOutput(context.Background(), &output)
+	assert.NoError(t, err)
+	assert.NotContains(t, output.String(), "nonexistent")
+}
+
+func TestCorosyncCollectorServiceEnabled(t *testing.T) {
+	services := collector.NewServiceChecker("../../test/fake_systemctl.sh", log.NewNopLogger(), "corosync")
+	collector, err := NewCollector("../../test/fake_corosync-cfgtool.sh", "../../test/fake_corosync-quorumtool.sh", "../../test/fake_corosync-cmapctl.sh", "../../test/fake_corosync.conf", services, false, log.NewNopLogger())
+	assert.NoError(t, err)
+
+	expect := `
+	# HELP ha_cluster_corosync_service_enabled Whether the corosync systemd service is enabled to start at boot; 1 means enabled, 0 otherwise
+	# TYPE ha_cluster_corosync_service_enabled gauge
+	ha_cluster_corosync_service_enabled{service="corosync"} 1
+	`
+	err = testutil.CollectAndCompare(collector, strings.NewReader(expect), "ha_cluster_corosync_service_enabled")
+	assert.NoError(t, err)
+}
+
+func TestCorosyncConfigReloadNeeded(t *testing.T) {
+	collectConfigReloadNeeded := func(configPath string) []float64 {
+		collector, _ := NewCollector("../../test/fake_corosync-cfgtool.sh", "../../test/fake_corosync-quorumtool.sh", "../../test/fake_corosync-cmapctl.sh", configPath, nil, false, log.NewNopLogger())
+		cmapCtlOutput, err := collector.getCmap()
+		assert.NoError(t, err)
+
+		ch := make(chan prometheus.Metric, 1)
+		collector.collectConfigReloadNeeded(cmapCtlOutput, ch)
+		close(ch)
+
+		var values []float64
+		for metric := range ch {
+			metricDto := &dto.Metric{}
+			metric.Write(metricDto)
+			values = append(values, metricDto.GetGauge().GetValue())
+		}
+		return values
+	}
+
+	assert.Equal(t, []float64{0}, collectConfigReloadNeeded("../../test/fake_corosync.conf"))
+	assert.Equal(t, []float64{1}, collectConfigReloadNeeded("../../test/fake_corosync_changed.conf"))
+	// the check is skipped when the configuration file can't be read
+	assert.Empty(t, collectConfigReloadNeeded("../../test/nonexistent"))
+}
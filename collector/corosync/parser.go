@@ -2,6 +2,7 @@ package corosync
 
 import (
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -9,7 +10,7 @@ import (
 )
 
 type Parser interface {
-	Parse(cfgToolOutput []byte, quorumToolOutput []byte) (*Status, error)
+	Parse(cfgToolOutput []byte, quorumToolOutput []byte, cmapKeys map[string]string) (*Status, error)
 }
 
 type Status struct {
@@ -42,38 +43,58 @@ type Member struct {
 	Local   bool
 }
 
+type Crypto struct {
+	Cipher string
+	Hash   string
+}
+
 func NewParser() Parser {
 	return &defaultParser{}
 }
 
 type defaultParser struct{}
 
-func (p *defaultParser) Parse(cfgToolOutput []byte, quorumToolOutput []byte) (*Status, error) {
+// the cmap keys, which may be nil, are read first, since they are structured; the screen output of corosync-quorumtool changes across versions,
+// so each of its fields overrides the cmap ones only when it can be parsed, and is required only when the cmap lacks it
+func (p *defaultParser) Parse(cfgToolOutput []byte, quorumToolOutput []byte, cmapKeys map[string]string) (*Status, error) {
 	status := &Status{}
-	var err error
+	cmapQuorum := applyCmapQuorum(status, cmapKeys)
+	cmapMembers := applyCmapMembers(status, cmapKeys)
 
-	status.NodeId, err = parseNodeId(quorumToolOutput)
-	if err != nil {
+	nodeId, err := parseNodeId(quorumToolOutput)
+	if err == nil {
+		status.NodeId = nodeId
+	} else if !cmapMembers {
 		return nil, errors.Wrap(err, "could not parse node id in corosync-quorumtool output")
 	}
 
-	status.RingId, err = parseRingId(quorumToolOutput)
-	if err != nil {
+	// the Ring ID is not in the cmap, so it's left empty when only the latter is available
+	ringId, err := parseRingId(quorumToolOutput)
+	if err == nil {
+		status.RingId = ringId
+	} else if !cmapQuorum && !cmapMembers {
 		return nil, errors.Wrap(err, "could not parse ring id and seq number in corosync-quorumtool output")
 	}
 
-	status.Quorate, err = parseQuorate(quorumToolOutput)
-	if err != nil {
+	quorate, err := parseQuorate(quorumToolOutput)
+	if err == nil {
+		status.Quorate = quorate
+	} else if !cmapQuorum {
 		return nil, errors.Wrap(err, "could not parse quorate in corosync-quorumtool output")
 	}
 
-	status.QuorumVotes, err = parseQuoromVotes(quorumToolOutput)
-	if err != nil {
+	quorumVotes, err := parseQuoromVotes(quorumToolOutput)
+	if err == nil {
+		status.QuorumVotes = quorumVotes
+	} else if !cmapQuorum {
 		return nil, errors.Wrap(err, "could not parse quorum votes in corosync-quorumtool output")
 	}
 
-	status.Members, err = parseMembers(quorumToolOutput)
-	if err != nil {
+	// corosync-quorumtool also lists the quorum device, and the node names rather than addresses
+	members, err := parseMembers(quorumToolOutput)
+	if err == nil {
+		status.Members = members
+	} else if !cmapMembers {
 		return nil, errors.Wrap(err, "could not parse members in corosync-quorumtool output")
 	}
 
@@ -264,3 +285,288 @@ func extractRENamedCaptureGroups(ringsRe *regexp.Regexp, match [][]byte) map[str
 	}
 	return namedMatches
 }
+
+// tells whether corosync-cfgtool comes from corosync v3, where links replaced rings along with the kronosnet transport
+func isKnet(cfgToolOutput []byte) bool {
+	return regexp.MustCompile(`(?m)^\s*Link ID \d+`).Match(cfgToolOutput)
+}
+
+// parses the crypto settings out of the totem keys listed by corosync-cmapctl, e.g.
+/*
+	totem.crypto_cipher (str) = aes256
+	totem.crypto_hash (str) = sha256
+*/
+// unset keys get the corosync defaults: in v3 both are `none`, while in v2 they depend on `secauth`, which is on by default
+func parseCrypto(cmapCtlOutput []byte, knet bool) Crypto {
+	keys := parseCmapKeys(cmapCtlOutput)
+
+	crypto := Crypto{Cipher: "none", Hash: "none"}
+	if !knet && keys["totem.secauth"] != "off" {
+		crypto = Crypto{Cipher: "aes256", Hash: "sha1"}
+	}
+	if cipher, ok := keys["totem.crypto_cipher"]; ok {
+		crypto.Cipher = cipher
+	}
+	if hash, ok := keys["totem.crypto_hash"]; ok {
+		crypto.Hash = hash
+	}
+
+	return crypto
+}
+
+// returns the effective totem.transport setting; when it's not configured, it defaults to `knet` in v3 and to `udp`, i.e. multicast, in v2
+func parseTransport(cmapKeys map[string]string, knet bool) string {
+	if transport, ok := cmapKeys["totem.transport"]; ok {
+		return transport
+	}
+	if knet {
+		return "knet"
+	}
+	return "udp"
+}
+
+// returns the effective totem.knet_compression_model setting, which defaults to `none`, i.e. no compression
+func parseKnetCompression(cmapKeys map[string]string) string {
+	if model, ok := cmapKeys["totem.knet_compression_model"]; ok {
+		return model
+	}
+	return "none"
+}
+
+// parses the keys listed by corosync-cmapctl, e.g.
+/*
+	totem.token (u32) = 5000
+	quorum.provider (str) = corosync_votequorum
+*/
+func parseCmapKeys(cmapCtlOutput []byte) map[string]string {
+	keys := make(map[string]string)
+	re := regexp.MustCompile(`(?m)^(?P<key>[\w.]+) \(\w+\) = (?P<value>.*)$`)
+	for _, match := range re.FindAllSubmatch(cmapCtlOutput, -1) {
+		namedMatches := extractRENamedCaptureGroups(re, match)
+		keys[namedMatches["key"]] = strings.TrimSpace(namedMatches["value"])
+	}
+	return keys
+}
+
+// the keys compared between the configuration file and the running configuration: the ones of the totem and quorum sections
+// that are mirrored as they are in the cmap; the others, like the nodelist and the interfaces, are indexed differently
+var comparedConfigKeys = []string{
+	"totem.cluster_name",
+	"totem.transport",
+	"totem.ip_version",
+	"totem.secauth",
+	"totem.crypto_cipher",
+	"totem.crypto_hash",
+	"totem.token",
+	"totem.token_retransmits_before_loss_const",
+	"totem.consensus",
+	"totem.join",
+	"totem.max_messages",
+	"quorum.provider",
+	"quorum.expected_votes",
+	"quorum.two_node",
+	"quorum.wait_for_all",
+	"quorum.last_man_standing",
+	"quorum.auto_tie_breaker",
+}
+
+// parses the corosync configuration file into keys named like the cmap ones, e.g. `totem.token`, out of:
+/*
+	totem {
+		version: 2
+		token: 5000
+		interface {
+			ringnumber: 0
+		}
+	}
+*/
+func parseConfig(config []byte) (map[string]string, error) {
+	keys := make(map[string]string)
+	var sections []string
+	for _, line := range strings.Split(string(config), "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+			continue
+		case strings.HasSuffix(line, "{"):
+			sections = append(sections, strings.TrimSpace(strings.TrimSuffix(line, "{")))
+		case line == "}":
+			if len(sections) == 0 {
+				return nil, errors.New("unexpected '}'")
+			}
+			sections = sections[:len(sections)-1]
+		default:
+			separator := strings.Index(line, ":")
+			if separator < 0 {
+				return nil, errors.Errorf("could not parse line '%s'", line)
+			}
+			key := strings.Join(append(sections, strings.TrimSpace(line[:separator])), ".")
+			keys[key] = strings.TrimSpace(line[separator+1:])
+		}
+	}
+	if len(sections) > 0 {
+		return nil, errors.New("unexpected end of configuration")
+	}
+	return keys, nil
+}
+
+// returns the compared keys whose values differ between the configuration file and the running configuration,
+// including the ones that are set in only one of them
+func diffConfig(configKeys map[string]string, cmapKeys map[string]string) []string {
+	var changed []string
+	for _, key := range comparedConfigKeys {
+		configValue, inConfig := configKeys[key]
+		cmapValue, inCmap := cmapKeys[key]
+		if inConfig != inCmap || configValue != cmapValue {
+			changed = append(changed, key)
+		}
+	}
+	return changed
+}
+
+// sets the quorum state from the one votequorum publishes in the cmap, e.g.
+/*
+	runtime.votequorum.expected_votes (u32) = 2
+	runtime.votequorum.highest_expected (u32) = 2
+	runtime.votequorum.total_votes (u32) = 2
+	runtime.votequorum.quorum (u32) = 1
+	runtime.votequorum.quorate (u8) = 1
+*/
+// the status is left untouched, and false returned, unless all the keys are present and valid
+func applyCmapQuorum(status *Status, cmapKeys map[string]string) bool {
+	var votes [4]uint64
+	for i, key := range []string{"expected_votes", "highest_expected", "total_votes", "quorum"} {
+		value, err := strconv.ParseUint(cmapKeys["runtime.votequorum."+key], 10, 64)
+		if err != nil {
+			return false
+		}
+		votes[i] = value
+	}
+	quorate, err := strconv.ParseUint(cmapKeys["runtime.votequorum.quorate"], 10, 8)
+	if err != nil {
+		return false
+	}
+
+	status.QuorumVotes = QuorumVotes{
+		ExpectedVotes:   votes[0],
+		HighestExpected: votes[1],
+		TotalVotes:      votes[2],
+		Quorum:          votes[3],
+	}
+	status.Quorate = quorate != 0
+	return true
+}
+
+// sets the local node id and the members that joined the membership from the cmap, e.g.
+/*
+	runtime.votequorum.this_node_id (u32) = 1084783375
+	runtime.members.1084783375.status (str) = joined
+	nodelist.node.0.nodeid (u32) = 1084783375
+	nodelist.node.0.ring0_addr (str) = 10.0.0.1
+	nodelist.node.0.quorum_votes (u32) = 1
+*/
+// the members are named and weighted after their nodelist entry, where the votes default to 1;
+// the status is left untouched, and false returned, unless the local node id and at least one member are present
+func applyCmapMembers(status *Status, cmapKeys map[string]string) bool {
+	nodeId, ok := cmapKeys["runtime.votequorum.this_node_id"]
+	if !ok {
+		return false
+	}
+
+	nodelistRe := regexp.MustCompile(`^nodelist\.node\.(\d+)\.nodeid$`)
+	nodelist := make(map[string]string)
+	for key, value := range cmapKeys {
+		if matches := nodelistRe.FindStringSubmatch(key); matches != nil {
+			nodelist[value] = "nodelist.node." + matches[1] + "."
+		}
+	}
+
+	membersRe := regexp.MustCompile(`^runtime\.members\.(\d+)\.status$`)
+	var members []Member
+	for key, value := range cmapKeys {
+		matches := membersRe.FindStringSubmatch(key)
+		if matches == nil || value != "joined" {
+			continue
+		}
+		member := Member{Id: matches[1], Votes: 1, Local: matches[1] == nodeId}
+		if prefix, ok := nodelist[member.Id]; ok {
+			member.Name = cmapKeys[prefix+"name"]
+			if member.Name == "" {
+				member.Name = cmapKeys[prefix+"ring0_addr"]
+			}
+			if votes, err := strconv.ParseUint(cmapKeys[prefix+"quorum_votes"], 10, 64); err == nil {
+				member.Votes = votes
+			}
+		}
+		members = append(members, member)
+	}
+	if len(members) == 0 {
+		return false
+	}
+
+	// the map doesn't keep the order, while the node ids are numeric
+	sort.Slice(members, func(i, j int) bool {
+		left, _ := strconv.ParseUint(members[i].Id, 10, 64)
+		right, _ := strconv.ParseUint(members[j].Id, 10, 64)
+		return left < right
+	})
+	status.NodeId = nodeId
+	status.Members = members
+	return true
+}
+
+// counts the nodes of the nodelist, whose keys are indexed by position, e.g.
+/*
+	nodelist.node.0.nodeid (u32) = 1
+	nodelist.node.0.ring0_addr (str) = 192.168.124.10
+	nodelist.node.1.nodeid (u32) = 2
+*/
+func countConfiguredNodes(cmapKeys map[string]string) int {
+	re := regexp.MustCompile(`^nodelist\.node\.(\d+)\.`)
+	nodes := make(map[string]bool)
+	for key := range cmapKeys {
+		if matches := re.FindStringSubmatch(key); matches != nil {
+			nodes[matches[1]] = true
+		}
+	}
+	return len(nodes)
+}
+
+// counts the members that joined the membership; the ones that left are kept, with a different status, e.g.
+/*
+	runtime.members.1084783375.status (str) = joined
+	runtime.members.1084783376.status (str) = left
+*/
+func countActiveMembers(cmapKeys map[string]string) int {
+	re := regexp.MustCompile(`^runtime\.members\.\d+\.status$`)
+	members := 0
+	for key, value := range cmapKeys {
+		if re.MatchString(key) && value == "joined" {
+			members++
+		}
+	}
+	return members
+}
+
+// returns the config_version of each member that joined the membership, as exchanged when joining; the one of the local node
+// is the totem.config_version of its configuration file, which defaults to 0, e.g.
+/*
+	runtime.members.1084783375.config_version (u64) = 3
+	runtime.members.1084783375.status (str) = joined
+*/
+func parseMemberConfigVersions(cmapKeys map[string]string) (map[string]uint64, error) {
+	re := regexp.MustCompile(`^runtime\.members\.(\d+)\.config_version$`)
+	versions := make(map[string]uint64)
+	for key, value := range cmapKeys {
+		matches := re.FindStringSubmatch(key)
+		if matches == nil || cmapKeys["runtime.members."+matches[1]+".status"] != "joined" {
+			continue
+		}
+		version, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not parse the config_version of member %s", matches[1])
+		}
+		versions[matches[1]] = version
+	}
+	return versions, nil
+}
@@ -42,7 +42,7 @@ Membership information
 1084780051          1      NR dma-dog-hana01 (local)
 1084780052          1      A,V,NMW dma-dog-hana02`)
 
-	status, err := p.Parse(cfgToolOutput, quoromToolOutput)
+	status, err := p.Parse(cfgToolOutput, quoromToolOutput, nil)
 	assert.NoError(t, err)
 
 	rings := status.Rings
@@ -300,3 +300,224 @@ Membership information
 	assert.True(t, members[1].Local)
 	assert.EqualValues(t, 1, members[1].Votes)
 }
+
+func TestIsKnet(t *testing.T) {
+	assert.True(t, isKnet([]byte("Printing link status.\nLocal node ID 1\nLink ID 0\n\taddr\t= 10.0.0.1\n")))
+	assert.False(t, isKnet([]byte("Printing ring status.\nLocal node ID 1\nRING ID 0\n\tid\t= 10.0.0.1\n")))
+}
+
+func TestParseCrypto(t *testing.T) {
+	testCases := []struct {
+		name     string
+		output   string
+		knet     bool
+		expected Crypto
+	}{
+		{"explicit", "totem.crypto_cipher (str) = aes128\ntotem.crypto_hash (str) = sha512\n", true, Crypto{"aes128", "sha512"}},
+		{"v3 defaults", "totem.transport (str) = knet\n", true, Crypto{"none", "none"}},
+		{"v2 defaults", "totem.transport (str) = udpu\n", false, Crypto{"aes256", "sha1"}},
+		{"v2 secauth off", "totem.secauth (str) = off\n", false, Crypto{"none", "none"}},
+		{"v2 secauth off with explicit hash", "totem.secauth (str) = off\ntotem.crypto_hash (str) = sha256\n", false, Crypto{"none", "sha256"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, parseCrypto([]byte(tc.output), tc.knet))
+		})
+	}
+}
+
+func TestParseTransport(t *testing.T) {
+	assert.Equal(t, "udpu", parseTransport(map[string]string{"totem.transport": "udpu"}, false))
+	assert.Equal(t, "knet", parseTransport(map[string]string{}, true))
+	assert.Equal(t, "udp", parseTransport(map[string]string{}, false))
+}
+
+func TestParseKnetCompression(t *testing.T) {
+	assert.Equal(t, "zlib", parseKnetCompression(map[string]string{"totem.knet_compression_model": "zlib"}))
+	assert.Equal(t, "none", parseKnetCompression(map[string]string{}))
+}
+
+func TestParseConfig(t *testing.T) {
+	config := []byte(`
+# a comment
+totem {
+	token: 5000
+	interface {
+		ringnumber: 0
+	}
+}
+quorum {
+	provider: corosync_votequorum
+}
+`)
+
+	keys, err := parseConfig(config)
+
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"totem.token":                "5000",
+		"totem.interface.ringnumber": "0",
+		"quorum.provider":            "corosync_votequorum",
+	}, keys)
+
+	_, err = parseConfig([]byte("totem {\n\ttoken: 5000\n"))
+	assert.Error(t, err)
+	_, err = parseConfig([]byte("}\n"))
+	assert.Error(t, err)
+	_, err = parseConfig([]byte("totem {\n\ttoken 5000\n}\n"))
+	assert.Error(t, err)
+}
+
+func TestDiffConfig(t *testing.T) {
+	cmapKeys := parseCmapKeys([]byte("totem.token (u32) = 5000\ntotem.version (u32) = 2\nquorum.two_node (u8) = 1\n"))
+
+	assert.Empty(t, diffConfig(map[string]string{"totem.token": "5000", "totem.version": "2", "quorum.two_node": "1"}, cmapKeys))
+	assert.Equal(t, []string{"totem.token"}, diffConfig(map[string]string{"totem.token": "10000", "quorum.two_node": "1"}, cmapKeys))
+	assert.Equal(t, []string{"totem.consensus", "quorum.two_node"}, diffConfig(map[string]string{"totem.token": "5000", "totem.consensus": "6000"}, cmapKeys))
+}
+
+func TestApplyCmapQuorum(t *testing.T) {
+	status := &Status{QuorumVotes: QuorumVotes{ExpectedVotes: 3, HighestExpected: 3, TotalVotes: 3, Quorum: 2}, Quorate: true}
+	cmapKeys := parseCmapKeys([]byte(`runtime.votequorum.expected_votes (u32) = 3
+runtime.votequorum.highest_expected (u32) = 3
+runtime.votequorum.quorate (u8) = 0
+runtime.votequorum.quorum (u32) = 2
+runtime.votequorum.total_votes (u32) = 1
+`))
+
+	assert.True(t, applyCmapQuorum(status, cmapKeys))
+	assert.Equal(t, QuorumVotes{ExpectedVotes: 3, HighestExpected: 3, TotalVotes: 1, Quorum: 2}, status.QuorumVotes)
+	assert.False(t, status.Quorate)
+}
+
+func TestApplyCmapQuorumFallback(t *testing.T) {
+	votes := QuorumVotes{ExpectedVotes: 2, HighestExpected: 2, TotalVotes: 2, Quorum: 1}
+	status := &Status{QuorumVotes: votes, Quorate: true}
+
+	// older corosync versions don't publish all the keys
+	cmapKeys := parseCmapKeys([]byte(`runtime.votequorum.this_node_id (u32) = 1
+runtime.votequorum.two_node (u8) = 1
+runtime.votequorum.quorate (u8) = 0
+`))
+
+	assert.False(t, applyCmapQuorum(status, cmapKeys))
+	assert.Equal(t, votes, status.QuorumVotes)
+	assert.True(t, status.Quorate)
+}
+
+func TestApplyCmapMembers(t *testing.T) {
+	status := &Status{}
+	cmapKeys := parseCmapKeys([]byte(`nodelist.node.0.name (str) = hana01
+nodelist.node.0.nodeid (u32) = 2
+nodelist.node.0.ring0_addr (str) = 10.0.0.1
+nodelist.node.1.nodeid (u32) = 10
+nodelist.node.1.quorum_votes (u32) = 2
+nodelist.node.1.ring0_addr (str) = 10.0.0.2
+nodelist.node.2.nodeid (u32) = 3
+runtime.members.10.status (str) = joined
+runtime.members.2.status (str) = joined
+runtime.members.3.status (str) = left
+runtime.votequorum.this_node_id (u32) = 2
+`))
+
+	assert.True(t, applyCmapMembers(status, cmapKeys))
+	assert.Equal(t, "2", status.NodeId)
+	assert.Equal(t, []Member{
+		{Id: "2", Name: "hana01", Votes: 1, Local: true},
+		{Id: "10", Name: "10.0.0.2", Votes: 2},
+	}, status.Members)
+
+	// older corosync versions don't publish the local node id
+	status = &Status{}
+	assert.False(t, applyCmapMembers(status, parseCmapKeys([]byte(`runtime.members.2.status (str) = joined`))))
+	assert.Empty(t, status.Members)
+}
+
+func TestParseWithCmap(t *testing.T) {
+	cmapKeys := parseCmapKeys([]byte(`nodelist.node.0.nodeid (u32) = 1
+nodelist.node.0.ring0_addr (str) = 10.0.0.1
+runtime.members.1.status (str) = joined
+runtime.votequorum.expected_votes (u32) = 2
+runtime.votequorum.highest_expected (u32) = 2
+runtime.votequorum.quorate (u8) = 0
+runtime.votequorum.quorum (u32) = 2
+runtime.votequorum.this_node_id (u32) = 1
+runtime.votequorum.total_votes (u32) = 1
+`))
+
+	t.Run("cmap only", func(t *testing.T) {
+		status, err := NewParser().Parse(nil, []byte("Cannot initialize CMAP service"), cmapKeys)
+		assert.NoError(t, err)
+		assert.Equal(t, "1", status.NodeId)
+		assert.Empty(t, status.RingId)
+		assert.False(t, status.Quorate)
+		assert.Equal(t, QuorumVotes{ExpectedVotes: 2, HighestExpected: 2, TotalVotes: 1, Quorum: 2}, status.QuorumVotes)
+		assert.Equal(t, []Member{{Id: "1", Name: "10.0.0.1", Votes: 1, Local: true}}, status.Members)
+	})
+
+	t.Run("corosync-quorumtool overrides", func(t *testing.T) {
+		quorumToolOutput := []byte(`Quorum information
+------------------
+Node ID:          1
+Ring ID:          1.8
+Quorate:          Yes
+
+Membership information
+----------------------
+    Nodeid      Votes  Qdevice Name
+         1          1       NR hana01 (local)
+`)
+		status, err := NewParser().Parse(nil, quorumToolOutput, cmapKeys)
+		assert.NoError(t, err)
+		assert.Equal(t, "1.8", status.RingId)
+		assert.True(t, status.Quorate)
+		assert.Equal(t, QuorumVotes{ExpectedVotes: 2, HighestExpected: 2, TotalVotes: 1, Quorum: 2}, status.QuorumVotes)
+		assert.Equal(t, []Member{{Id: "1", Name: "hana01", Qdevice: "NR", Votes: 1, Local: true}}, status.Members)
+	})
+
+	t.Run("neither", func(t *testing.T) {
+		_, err := NewParser().Parse(nil, []byte("Cannot initialize CMAP service"), map[string]string{})
+		assert.Error(t, err)
+	})
+}
+
+func TestCountMembers(t *testing.T) {
+	cmapKeys := parseCmapKeys([]byte(`nodelist.local_node_pos (u32) = 0
+nodelist.node.0.nodeid (u32) = 1
+nodelist.node.0.ring0_addr (str) = 192.168.124.10
+nodelist.node.1.nodeid (u32) = 2
+nodelist.node.1.ring0_addr (str) = 192.168.124.11
+nodelist.node.2.nodeid (u32) = 3
+nodelist.node.2.ring0_addr (str) = 192.168.124.12
+runtime.members.1.ip (str) = r(0) ip(192.168.124.10)
+runtime.members.1.status (str) = joined
+runtime.members.2.ip (str) = r(0) ip(192.168.124.11)
+runtime.members.2.status (str) = left
+runtime.members.3.ip (str) = r(0) ip(192.168.124.12)
+runtime.members.3.status (str) = joined
+`))
+
+	assert.Equal(t, 3, countConfiguredNodes(cmapKeys))
+	assert.Equal(t, 2, countActiveMembers(cmapKeys))
+}
+
+func TestParseMemberConfigVersions(t *testing.T) {
+	cmapKeys := parseCmapKeys([]byte(`runtime.members.1.config_version (u64) = 4
+runtime.members.1.status (str) = joined
+runtime.members.2.config_version (u64) = 3
+runtime.members.2.status (str) = left
+runtime.members.3.config_version (u64) = 3
+runtime.members.3.status (str) = joined
+`))
+
+	versions, err := parseMemberConfigVersions(cmapKeys)
+	assert.NoError(t, err)
+	// the members that left keep the version they had
+	assert.Equal(t, map[string]uint64{"1": 4, "3": 3}, versions)
+
+	_, err = parseMemberConfigVersions(parseCmapKeys([]byte(`runtime.members.1.config_version (u64) = latest
+runtime.members.1.status (str) = joined
+`)))
+	assert.Error(t, err)
+}
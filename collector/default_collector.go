@@ -20,6 +20,9 @@ type DefaultCollector struct {
 	Clock       clock.Clock
 	timestamps  bool
 	Logger      log.Logger
+
+	// the metrics whose value changes on every scrape regardless of the cluster state, see SetVolatile
+	volatile map[*prometheus.Desc]bool
 }
 
 func NewDefaultCollector(subsystem string, timestamps bool, logger log.Logger) DefaultCollector {
@@ -29,6 +32,7 @@ func NewDefaultCollector(subsystem string, timestamps bool, logger log.Logger) D
 		&clock.SystemClock{},
 		timestamps,
 		logger,
+		make(map[*prometheus.Desc]bool),
 	}
 }
 
@@ -50,6 +54,18 @@ func (c *DefaultCollector) SetDescriptor(name, help string, variableLabels []str
 	c.descriptors[name] = prometheus.NewDesc(prometheus.BuildFQName(NAMESPACE, c.subsystem, name), help, variableLabels, nil)
 }
 
+// Marks already declared metrics as volatile, i.e. their values change on every scrape, like ages or I/O statistics,
+// so that they are not taken into account when telling whether the state of the collector changed.
+func (c *DefaultCollector) SetVolatile(names ...string) {
+	for _, name := range names {
+		c.volatile[c.GetDescriptor(name)] = true
+	}
+}
+
+func (c *DefaultCollector) IsVolatile(desc *prometheus.Desc) bool {
+	return c.volatile[desc]
+}
+
 func (c *DefaultCollector) Describe(ch chan<- *prometheus.Desc) {
 	for _, descriptor := range c.descriptors {
 		ch <- descriptor
@@ -77,8 +93,13 @@ func (c *DefaultCollector) makeMetric(name string, value float64, valueType prom
 	return metric
 }
 
-// check that all the given paths exist and are executable files
+// check that all the given paths exist and are executable files.
+// the check is skipped when the commands are run on a remote host, because the paths refer to its filesystem;
+// missing executables will then surface as errors when collecting.
 func CheckExecutables(paths ...string) error {
+	if IsRemote() {
+		return nil
+	}
 	for _, path := range paths {
 		fileInfo, err := os.Stat(path)
 		if err != nil || os.IsNotExist(err) {
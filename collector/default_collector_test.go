@@ -33,3 +33,14 @@ func TestMetricFactoryWithTimestamp(t *testing.T) {
 
 	assert.Equal(t, int64(clock.TEST_TIMESTAMP), *metricDto.TimestampMs)
 }
+
+func TestVolatileMetrics(t *testing.T) {
+	SUT := NewDefaultCollector("test", false, log.NewNopLogger())
+	SUT.SetDescriptor("test_metric", "", nil)
+	SUT.SetDescriptor("test_age_seconds", "", nil)
+	SUT.SetVolatile("test_age_seconds")
+
+	assert.False(t, SUT.IsVolatile(SUT.GetDescriptor("test_metric")))
+	assert.True(t, SUT.IsVolatile(SUT.GetDescriptor("test_age_seconds")))
+	assert.Panics(t, func() { SUT.SetVolatile("undeclared") })
+}
@@ -0,0 +1,202 @@
+package drbd
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// the replication protocol DRBD uses when none is configured
+const defaultProtocol = "C"
+
+// the replication protocols configured for the DRBD resources, as per the output of `drbdadm dump`
+type drbdConfig struct {
+	commonProtocol string
+	resources      map[string]resourceConfig
+}
+
+type resourceConfig struct {
+	protocol string
+	// the protocols configured in the connection sections, by host name
+	hostProtocols map[string]string
+}
+
+// returns the protocol used to replicate the given resource to the given peer; the most specific setting wins
+func (c drbdConfig) protocol(resource string, peer string) string {
+	resourceConfig := c.resources[resource]
+	if protocol, ok := resourceConfig.hostProtocols[peer]; ok {
+		return protocol
+	}
+	if resourceConfig.protocol != "" {
+		return resourceConfig.protocol
+	}
+	if c.commonProtocol != "" {
+		return c.commonProtocol
+	}
+	return defaultProtocol
+}
+
+// a section of the DRBD configuration, e.g. `net { ... }`, or a single statement like `protocol C;`, which has no children
+type configSection struct {
+	words    []string
+	children []configSection
+}
+
+// parses the output of `drbdadm dump`, which looks like:
+/*
+	common {
+		net {
+			protocol C;
+		}
+	}
+
+	resource r0 {
+		on node01 {
+			...
+		}
+		connection {
+			host node01;
+			host node02;
+			net {
+				protocol A;
+			}
+		}
+	}
+*/
+func parseDrbdConfig(dump []byte) (drbdConfig, error) {
+	sections, err := parseConfigSections(dump)
+	if err != nil {
+		return drbdConfig{}, err
+	}
+
+	config := drbdConfig{resources: make(map[string]resourceConfig)}
+	for _, section := range sections {
+		switch {
+		case section.words[0] == "common":
+			config.commonProtocol = sectionProtocol(section)
+		case section.words[0] == "resource" && len(section.words) > 1:
+			config.resources[section.words[1]] = parseResourceConfig(section)
+		}
+	}
+	return config, nil
+}
+
+func parseResourceConfig(section configSection) resourceConfig {
+	resource := resourceConfig{
+		protocol:      sectionProtocol(section),
+		hostProtocols: make(map[string]string),
+	}
+	for _, child := range section.children {
+		if child.words[0] != "connection" && child.words[0] != "connection-mesh" {
+			continue
+		}
+		protocol := sectionProtocol(child)
+		if protocol == "" {
+			continue
+		}
+		for _, statement := range child.children {
+			if statement.words[0] == "host" && len(statement.words) > 1 {
+				resource.hostProtocols[statement.words[1]] = protocol
+			}
+			if statement.words[0] == "hosts" {
+				for _, host := range statement.words[1:] {
+					resource.hostProtocols[host] = protocol
+				}
+			}
+		}
+	}
+	return resource
+}
+
+// returns the protocol set either directly in the section, as in DRBD 8, or in its net subsection; empty if none is set
+func sectionProtocol(section configSection) string {
+	protocol := ""
+	for _, child := range section.children {
+		if child.words[0] == "protocol" && len(child.words) > 1 {
+			protocol = strings.ToUpper(child.words[1])
+		}
+		if child.words[0] == "net" {
+			if netProtocol := sectionProtocol(child); netProtocol != "" {
+				protocol = netProtocol
+			}
+		}
+	}
+	return protocol
+}
+
+func parseConfigSections(config []byte) ([]configSection, error) {
+	tokens := tokenizeConfig(string(config))
+
+	// the sections being parsed, from the outermost to the innermost; the first one is a placeholder for the top level
+	stack := []configSection{{}}
+	var words []string
+	for _, token := range tokens {
+		switch token {
+		case "{":
+			if len(words) == 0 {
+				return nil, errors.New("section without a name")
+			}
+			stack = append(stack, configSection{words: words})
+			words = nil
+		case "}":
+			if len(stack) == 1 || len(words) > 0 {
+				return nil, errors.New("unexpected '}'")
+			}
+			section := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			stack[len(stack)-1].children = append(stack[len(stack)-1].children, section)
+		case ";":
+			if len(words) > 0 {
+				stack[len(stack)-1].children = append(stack[len(stack)-1].children, configSection{words: words})
+			}
+			words = nil
+		default:
+			words = append(words, token)
+		}
+	}
+	if len(stack) > 1 || len(words) > 0 {
+		return nil, errors.New("unexpected end of configuration")
+	}
+	return stack[0].children, nil
+}
+
+// splits the configuration into words, quoted strings and the `{`, `}` and `;` delimiters, dropping the comments
+func tokenizeConfig(config string) []string {
+	var tokens []string
+	var word strings.Builder
+	endWord := func() {
+		if word.Len() > 0 {
+			tokens = append(tokens, word.String())
+			word.Reset()
+		}
+	}
+
+	for i := 0; i < len(config); i++ {
+		char := config[i]
+		switch {
+		case char == '#':
+			endWord()
+			for i < len(config) && config[i] != '\n' {
+				i++
+			}
+		case char == '"':
+			endWord()
+			end := strings.IndexByte(config[i+1:], '"')
+			if end < 0 {
+				end = len(config) - i - 1
+			}
+			tokens = append(tokens, config[i+1:i+1+end])
+			i += end + 1
+		case char == '{' || char == '}' || char == ';':
+			endWord()
+			tokens = append(tokens, string(char))
+		case char == ' ' || char == '\t' || char == '\n' || char == '\r':
+			endWord()
+		default:
+			word.WriteByte(char)
+		}
+	}
+	endWord()
+
+	return tokens
+}
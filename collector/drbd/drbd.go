@@ -1,12 +1,15 @@
 package drbd
 
 import (
+	"context"
 	"encoding/json"
-	"os/exec"
+	"io"
 	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
@@ -32,32 +35,52 @@ type drbdStatus struct {
 		LoPending int    `json:"lower-pending"`
 		Quorum    bool   `json:"quorum"`
 		DiskState string `json:"disk-state"`
+		// whether the updates of the activity log are suspended, not reported by all the versions of drbdsetup
+		AlSuspended *bool `json:"al-suspended"`
 	} `json:"devices"`
 	Connections []struct {
-		PeerNodeID  int    `json:"peer-node-id"`
-		PeerRole    string `json:"peer-role"`
+		PeerNodeID int    `json:"peer-node-id"`
+		PeerName   string `json:"name"`
+		PeerRole   string `json:"peer-role"`
+		State      string `json:"connection-state"`
+		// the statistics of the connection, not reported by all the versions of drbdsetup
+		ApInFlight  *int `json:"ap-in-flight"`
+		RsInFlight  *int `json:"rs-in-flight"`
 		PeerDevices []struct {
 			Volume        int     `json:"volume"`
 			Received      int     `json:"received"`
 			Sent          int     `json:"sent"`
-			Pending       int     `json:"pending"`
-			Unacked       int     `json:"unacked"`
 			PeerDiskState string  `json:"peer-disk-state"`
+			Replication   string  `json:"replication-state"`
 			PercentInSync float64 `json:"percent-in-sync"`
+			OutOfSync     int     `json:"out-of-sync"`
+			// the requests not yet received and not yet acknowledged by the peer, not reported by all the versions of drbdsetup
+			Pending *int `json:"pending"`
+			Unacked *int `json:"unacked"`
+			// the current resync speed, only reported while resyncing by the versions of drbdsetup that have sync details
+			ResyncRate *float64 `json:"db0/dt0 [MiB/s]"`
 		} `json:"peer_devices"`
 	} `json:"connections"`
+	// whether the I/O is suspended for any reason, e.g. by the user, the fencing policy or the loss of quorum
+	Suspended bool `json:"suspended"`
 }
 
-func NewCollector(drbdSetupPath string, drbdSplitBrainPath string, timestamps bool, logger log.Logger) (*drbdCollector, error) {
+// NewCollector creates a new DRBD collector
+// the generation identifiers are only compared with uuidCheck, since it takes an extra drbdadm call per peer device, which reads the metadata
+func NewCollector(drbdSetupPath string, drbdAdmPath string, drbdSplitBrainPath string, uuidCheck bool, timestamps bool, logger log.Logger) (*drbdCollector, error) {
 	err := collector.CheckExecutables(drbdSetupPath)
 	if err != nil {
 		return nil, errors.Wrapf(err, "could not initialize '%s' collector", subsystem)
 	}
 
 	c := &drbdCollector{
-		collector.NewDefaultCollector(subsystem, timestamps, logger),
-		drbdSetupPath,
-		drbdSplitBrainPath,
+		DefaultCollector:   collector.NewDefaultCollector(subsystem, timestamps, logger),
+		drbdsetupPath:      drbdSetupPath,
+		drbdadmPath:        drbdAdmPath,
+		drbdSplitBrainPath: drbdSplitBrainPath,
+		uuidCheck:          uuidCheck,
+		lastOutOfSync:      make(map[string]outOfSyncSample),
+		toolVersion:        collector.NewToolVersion("drbd-utils", supportedVersions),
 	}
 
 	c.SetDescriptor("resources", "The DRBD resources; 1 line per name, per volume", []string{"resource", "role", "volume", "disk_state"})
@@ -68,21 +91,62 @@ func NewCollector(drbdSetupPath string, drbdSplitBrainPath string, timestamps bo
 	c.SetDescriptor("upper_pending", "Upper pending; 1 line per res, per volume", []string{"resource", "volume"})
 	c.SetDescriptor("lower_pending", "Lower pending; 1 line per res, per volume", []string{"resource", "volume"})
 	c.SetDescriptor("quorum", "Quorum status per resource and per volume", []string{"resource", "volume"})
-	c.SetDescriptor("connections", "The DRBD resource connections; 1 line per per resource, per peer_node_id", []string{"resource", "peer_node_id", "peer_role", "volume", "peer_disk_state"})
-	c.SetDescriptor("connections_sync", "The in sync percentage value for DRBD resource connections", []string{"resource", "peer_node_id", "volume"})
-	c.SetDescriptor("connections_received", "KiB received per connection", []string{"resource", "peer_node_id", "volume"})
-	c.SetDescriptor("connections_sent", "KiB sent per connection", []string{"resource", "peer_node_id", "volume"})
-	c.SetDescriptor("connections_pending", "Pending value per connection", []string{"resource", "peer_node_id", "volume"})
-	c.SetDescriptor("connections_unacked", "Unacked value per connection", []string{"resource", "peer_node_id", "volume"})
+	c.SetDescriptor("connections", "The DRBD resource connections; 1 line per per resource, per peer_node_id", []string{"resource", "peer_node_id", "peer_name", "peer_role", "volume", "peer_disk_state"})
+	c.SetDescriptor("connections_sync", "The in sync percentage value for DRBD resource connections", []string{"resource", "peer_node_id", "peer_name", "volume"})
+	c.SetDescriptor("connections_received", "KiB received per connection", []string{"resource", "peer_node_id", "peer_name", "volume"})
+	c.SetDescriptor("connections_sent", "KiB sent per connection", []string{"resource", "peer_node_id", "peer_name", "volume"})
+	c.SetDescriptor("connections_pending", "Pending value per connection", []string{"resource", "peer_node_id", "peer_name", "volume"})
+	c.SetDescriptor("connections_unacked", "Unacked value per connection", []string{"resource", "peer_node_id", "peer_name", "volume"})
+	c.SetDescriptor("ap_in_flight", "The application data sent to each peer and not yet acknowledged, as reported by drbdsetup", []string{"resource", "peer_node_id", "peer_name"})
+	c.SetDescriptor("rs_in_flight", "The resync data requested from or sent to each peer and not yet completed, as reported by drbdsetup", []string{"resource", "peer_node_id", "peer_name"})
+	c.SetDescriptor("resync_rate_bytes_per_second", "The speed of the resync of each DRBD volume with each peer, in bytes per second", []string{"resource", "peer_node_id", "peer_name", "volume"})
+	c.SetDescriptor("protocol", "The replication protocol of each DRBD resource connection; value is always 1", []string{"resource", "peer_node_id", "peer_name", "protocol"})
+	c.SetDescriptor("disk_state", "The state of the local disk of each DRBD volume; 1 means the disk is in that state, 0 otherwise", []string{"resource", "volume", "disk_state"})
+	c.SetDescriptor("dual_primary", "Whether both the local node and a peer are Primary; 1 line per resource", []string{"resource"})
+	c.SetDescriptor("uuid_mismatch", "Whether the data of each DRBD volume changed since it was last in sync with each peer, as per the generation identifiers; 1 means changed, 0 otherwise", []string{"resource", "peer_node_id", "peer_name", "volume"})
+	c.SetDescriptor("tool_version_supported", "Whether the collector is known to parse the output of the running drbd-utils version correctly; 1 means supported, 0 otherwise", []string{"version"})
+	c.SetDescriptor("resources_by_state", "The number of DRBD resources in each aggregate connection state; a resource counts in the worst state among its peers", []string{"state"})
+	c.SetDescriptor("io_suspended", "Whether the I/O of each DRBD resource is suspended, e.g. because of the fencing policy or the loss of quorum; 1 means suspended, 0 otherwise", []string{"resource"})
+	c.SetDescriptor("al_suspended", "Whether the updates of the activity log of each DRBD volume are suspended; 1 means suspended, 0 otherwise", []string{"resource", "volume"})
 	c.SetDescriptor("split_brain", "Whether a split brain has been detected; 1 line per resource, per volume.", []string{"resource", "volume"})
 
+	// the I/O statistics change on every scrape of a resource in use
+	c.SetVolatile("written", "read", "al_writes", "bm_writes", "upper_pending", "lower_pending",
+		"connections_sync", "connections_received", "connections_sent", "connections_pending", "connections_unacked",
+		"ap_in_flight", "rs_in_flight", "resync_rate_bytes_per_second")
+
 	return c, nil
 }
 
 type drbdCollector struct {
 	collector.DefaultCollector
 	drbdsetupPath      string
+	drbdadmPath        string
 	drbdSplitBrainPath string
+	uuidCheck          bool
+
+	// the out-of-sync data of each peer device seen in the previous scrape, used to compute the resync rate
+	// when drbdsetup doesn't report it
+	resyncMutex   sync.Mutex
+	lastOutOfSync map[string]outOfSyncSample
+
+	toolVersion *collector.ToolVersion
+}
+
+type outOfSyncSample struct {
+	kib int
+	at  time.Time
+}
+
+func (c *drbdCollector) CollectRawOutput(ctx context.Context, w io.Writer) error {
+	err := collector.WriteCommandOutput(ctx, w, c.drbdsetupPath, "status", "--json")
+	if err != nil {
+		return err
+	}
+	if collector.CheckExecutables(c.drbdadmPath) != nil {
+		return nil
+	}
+	return collector.WriteCommandOutput(ctx, w, c.drbdadmPath, "dump", "all")
 }
 
 func (c *drbdCollector) CollectWithError(ch chan<- prometheus.Metric) error {
@@ -90,7 +154,7 @@ func (c *drbdCollector) CollectWithError(ch chan<- prometheus.Metric) error {
 
 	c.recordDrbdSplitBrainMetric(ch)
 
-	drbdStatusRaw, err := exec.Command(c.drbdsetupPath, "status", "--json").Output()
+	drbdStatusRaw, err := collector.Command(c.drbdsetupPath, "status", "--json").Output()
 	if err != nil {
 		return errors.Wrap(err, "drbdsetup command failed")
 	}
@@ -100,7 +164,19 @@ func (c *drbdCollector) CollectWithError(ch chan<- prometheus.Metric) error {
 		return errors.Wrap(err, "could not parse drbdsetup status output")
 	}
 
+	c.toolVersion.Record(&c.DefaultCollector, ch, c.getToolVersion)
+
+	// the protocol is not part of the status, so it's only recorded if the configuration can be read too
+	config, configErr := c.getDrbdConfig()
+	if configErr != nil {
+		level.Debug(c.Logger).Log("msg", "Could not read the DRBD configuration", "err", configErr)
+	}
+
+	now := c.Clock.Now()
+	resourcesByState := make(map[string]int)
+	peerDevices := make(map[string]bool)
 	for _, resource := range drbdDev {
+		resourcesByState[aggregateState(resource)]++
 		for _, device := range resource.Devices {
 			// the `resources` metric value is always 1, otherwise it's absent
 			ch <- c.MakeGaugeMetric("resources", float64(1), resource.Name, resource.Role, strconv.Itoa(device.Volume), strings.ToLower(device.DiskState))
@@ -117,27 +193,60 @@ func (c *drbdCollector) CollectWithError(ch chan<- prometheus.Metric) error {
 				ch <- c.MakeGaugeMetric("quorum", float64(0), resource.Name, strconv.Itoa(device.Volume))
 			}
 		}
+		c.recordDiskStates(resource, ch)
+		c.recordDualPrimary(resource, ch)
+		c.recordSuspended(resource, ch)
+
 		if len(resource.Connections) == 0 {
 			level.Warn(c.Logger).Log("msg", "Could not retrieve connection info for resource "+resource.Name, "err", err)
 			continue
 		}
 		// a Resource can have multiple connection with different nodes
 		for _, conn := range resource.Connections {
+			if config != nil {
+				ch <- c.MakeGaugeMetric("protocol", 1, resource.Name, strconv.Itoa(conn.PeerNodeID), conn.PeerName, config.protocol(resource.Name, conn.PeerName))
+			}
+			if conn.ApInFlight != nil {
+				ch <- c.MakeGaugeMetric("ap_in_flight", float64(*conn.ApInFlight), resource.Name, strconv.Itoa(conn.PeerNodeID), conn.PeerName)
+			}
+			if conn.RsInFlight != nil {
+				ch <- c.MakeGaugeMetric("rs_in_flight", float64(*conn.RsInFlight), resource.Name, strconv.Itoa(conn.PeerNodeID), conn.PeerName)
+			}
 			if len(conn.PeerDevices) == 0 {
 				level.Warn(c.Logger).Log("msg", "Could not retrieve any peer device info for connection "+resource.Name, "err", err)
 				continue
 			}
 			for _, peerDev := range conn.PeerDevices {
-				ch <- c.MakeGaugeMetric("connections", float64(1), resource.Name, strconv.Itoa(conn.PeerNodeID), conn.PeerRole, strconv.Itoa(peerDev.Volume), strings.ToLower(peerDev.PeerDiskState))
-				ch <- c.MakeGaugeMetric("connections_sync", float64(peerDev.PercentInSync), resource.Name, strconv.Itoa(conn.PeerNodeID), strconv.Itoa(peerDev.Volume))
-				ch <- c.MakeGaugeMetric("connections_received", float64(peerDev.Received), resource.Name, strconv.Itoa(conn.PeerNodeID), strconv.Itoa(peerDev.Volume))
-				ch <- c.MakeGaugeMetric("connections_sent", float64(peerDev.Sent), resource.Name, strconv.Itoa(conn.PeerNodeID), strconv.Itoa(peerDev.Volume))
-				ch <- c.MakeGaugeMetric("connections_pending", float64(peerDev.Pending), resource.Name, strconv.Itoa(conn.PeerNodeID), strconv.Itoa(peerDev.Volume))
-				ch <- c.MakeGaugeMetric("connections_unacked", float64(peerDev.Unacked), resource.Name, strconv.Itoa(conn.PeerNodeID), strconv.Itoa(peerDev.Volume))
+				ch <- c.MakeGaugeMetric("connections", float64(1), resource.Name, strconv.Itoa(conn.PeerNodeID), conn.PeerName, conn.PeerRole, strconv.Itoa(peerDev.Volume), strings.ToLower(peerDev.PeerDiskState))
+				ch <- c.MakeGaugeMetric("connections_sync", float64(peerDev.PercentInSync), resource.Name, strconv.Itoa(conn.PeerNodeID), conn.PeerName, strconv.Itoa(peerDev.Volume))
+				ch <- c.MakeGaugeMetric("connections_received", float64(peerDev.Received), resource.Name, strconv.Itoa(conn.PeerNodeID), conn.PeerName, strconv.Itoa(peerDev.Volume))
+				ch <- c.MakeGaugeMetric("connections_sent", float64(peerDev.Sent), resource.Name, strconv.Itoa(conn.PeerNodeID), conn.PeerName, strconv.Itoa(peerDev.Volume))
+				if peerDev.Pending != nil {
+					ch <- c.MakeGaugeMetric("connections_pending", float64(*peerDev.Pending), resource.Name, strconv.Itoa(conn.PeerNodeID), conn.PeerName, strconv.Itoa(peerDev.Volume))
+				}
+				if peerDev.Unacked != nil {
+					ch <- c.MakeGaugeMetric("connections_unacked", float64(*peerDev.Unacked), resource.Name, strconv.Itoa(conn.PeerNodeID), conn.PeerName, strconv.Itoa(peerDev.Volume))
+				}
+
+				peerDevice := resource.Name + "/" + strconv.Itoa(conn.PeerNodeID) + "/" + strconv.Itoa(peerDev.Volume)
+				peerDevices[peerDevice] = true
+				if rate, ok := c.resyncRate(peerDevice, peerDev.OutOfSync, peerDev.ResyncRate, now); ok {
+					ch <- c.MakeGaugeMetric("resync_rate_bytes_per_second", rate, resource.Name, strconv.Itoa(conn.PeerNodeID), conn.PeerName, strconv.Itoa(peerDev.Volume))
+				}
+
+				if c.uuidCheck {
+					c.recordUuidMismatch(resource.Name, conn.PeerNodeID, conn.PeerName, peerDev.Volume, ch)
+				}
 			}
 		}
 	}
 
+	for _, state := range aggregateStates {
+		ch <- c.MakeGaugeMetric("resources_by_state", float64(resourcesByState[state]), state)
+	}
+
+	c.forgetOutOfSync(peerDevices)
+
 	return nil
 }
 
@@ -150,6 +259,228 @@ func (c *drbdCollector) Collect(ch chan<- prometheus.Metric) {
 	}
 }
 
+// all the disk states of DRBD 9, see drbdsetup(8)
+var diskStates = []string{"diskless", "attaching", "detaching", "failed", "negotiating", "inconsistent", "outdated", "dunknown", "consistent", "uptodate"}
+
+func (c *drbdCollector) recordDiskStates(resource drbdStatus, ch chan<- prometheus.Metric) {
+	for _, device := range resource.Devices {
+		diskState := strings.ToLower(device.DiskState)
+		known := false
+		for _, state := range diskStates {
+			var value float64
+			if state == diskState {
+				value = 1
+				known = true
+			}
+			ch <- c.MakeGaugeMetric("disk_state", value, resource.Name, strconv.Itoa(device.Volume), state)
+		}
+		if !known {
+			ch <- c.MakeGaugeMetric("disk_state", 1, resource.Name, strconv.Itoa(device.Volume), diskState)
+		}
+	}
+}
+
+func (c *drbdCollector) recordDualPrimary(resource drbdStatus, ch chan<- prometheus.Metric) {
+	var dualPrimary float64
+	if resource.Role == "Primary" {
+		for _, conn := range resource.Connections {
+			if conn.PeerRole == "Primary" {
+				dualPrimary = 1
+				break
+			}
+		}
+	}
+	ch <- c.MakeGaugeMetric("dual_primary", dualPrimary, resource.Name)
+}
+
+// the suspension is recorded on every scrape, so that it goes back to 0 as soon as the I/O is resumed
+func (c *drbdCollector) recordSuspended(resource drbdStatus, ch chan<- prometheus.Metric) {
+	var ioSuspended float64
+	if resource.Suspended {
+		ioSuspended = 1
+	}
+	ch <- c.MakeGaugeMetric("io_suspended", ioSuspended, resource.Name)
+
+	for _, device := range resource.Devices {
+		if device.AlSuspended == nil {
+			continue
+		}
+		var alSuspended float64
+		if *device.AlSuspended {
+			alSuspended = 1
+		}
+		ch <- c.MakeGaugeMetric("al_suspended", alSuspended, resource.Name, strconv.Itoa(device.Volume))
+	}
+}
+
+// the aggregate states of the DRBD resources, from the best to the worst
+var aggregateStates = []string{"connected", "syncing", "disconnected", "standalone"}
+
+// categorizes a resource by the worst state among its peers:
+// a connection is syncing while any of its peer devices is resyncing or about to, disconnected while it's trying to (re)connect,
+// and standalone when it's not even trying, like after a split brain; a resource without any peer is standalone too
+func aggregateState(resource drbdStatus) string {
+	if len(resource.Connections) == 0 {
+		return "standalone"
+	}
+
+	worst := 0
+	for _, conn := range resource.Connections {
+		state := "disconnected"
+		switch conn.State {
+		case "StandAlone":
+			state = "standalone"
+		case "Connected":
+			state = "connected"
+			for _, peerDev := range conn.PeerDevices {
+				if strings.Contains(peerDev.Replication, "Sync") || strings.HasPrefix(peerDev.Replication, "WFBitMap") {
+					state = "syncing"
+				}
+			}
+		}
+		for i, s := range aggregateStates {
+			if s == state && i > worst {
+				worst = i
+			}
+		}
+	}
+	return aggregateStates[worst]
+}
+
+// returns the resync rate of a peer device in bytes per second, preferring the one reported by drbdsetup;
+// otherwise, it's computed from how much the out-of-sync data decreased since the previous scrape,
+// so there is no rate until the second scrape that sees the peer device
+func (c *drbdCollector) resyncRate(peerDevice string, outOfSyncKiB int, reportedMiBs *float64, now time.Time) (float64, bool) {
+	c.resyncMutex.Lock()
+	defer c.resyncMutex.Unlock()
+
+	last, seen := c.lastOutOfSync[peerDevice]
+	c.lastOutOfSync[peerDevice] = outOfSyncSample{kib: outOfSyncKiB, at: now}
+
+	if reportedMiBs != nil {
+		return *reportedMiBs * 1024 * 1024, true
+	}
+
+	elapsed := now.Sub(last.at).Seconds()
+	if !seen || elapsed <= 0 {
+		return 0, false
+	}
+
+	// the out-of-sync data grows when the peer is disconnected and the local disk is written to, which is no resync at all
+	synced := last.kib - outOfSyncKiB
+	if synced < 0 {
+		synced = 0
+	}
+	return float64(synced) * 1024 / elapsed, true
+}
+
+// drops the out-of-sync data of the peer devices that are gone, so that removed resources and peers don't pile up
+func (c *drbdCollector) forgetOutOfSync(current map[string]bool) {
+	c.resyncMutex.Lock()
+	defer c.resyncMutex.Unlock()
+
+	for peerDevice := range c.lastOutOfSync {
+		if !current[peerDevice] {
+			delete(c.lastOutOfSync, peerDevice)
+		}
+	}
+}
+
+// the lowest bit of the generation identifiers flags whether the node was primary, so it's not part of the generation
+const uuidPrimaryFlag = 1
+
+// compares the current generation identifier of a volume with the bitmap one kept for a peer, which is the current one
+// at the time the two were last in sync: they only differ if the local data changed since then
+func (c *drbdCollector) recordUuidMismatch(resource string, peerNodeID int, peerName string, volume int, ch chan<- prometheus.Metric) {
+	if err := collector.CheckExecutables(c.drbdadmPath); err != nil {
+		level.Debug(c.Logger).Log("msg", "Could not compare the DRBD generation identifiers", "err", err)
+		return
+	}
+
+	output, err := collector.Command(c.drbdadmPath, "get-gi", resource+":"+peerName+"/"+strconv.Itoa(volume)).Output()
+	if err != nil {
+		level.Warn(c.Logger).Log("msg", "Could not read the generation identifiers of DRBD resource "+resource, "err", err)
+		return
+	}
+	current, bitmap, err := parseGenerationIdentifiers(output)
+	if err != nil {
+		level.Warn(c.Logger).Log("msg", "Could not parse the generation identifiers of DRBD resource "+resource, "err", err)
+		return
+	}
+
+	var mismatch float64
+	if bitmap != 0 && current&^uuidPrimaryFlag != bitmap&^uuidPrimaryFlag {
+		mismatch = 1
+	}
+	ch <- c.MakeGaugeMetric("uuid_mismatch", mismatch, resource, strconv.Itoa(peerNodeID), peerName, strconv.Itoa(volume))
+}
+
+// parses the current and bitmap identifiers out of the output of `drbdadm get-gi`, which looks like:
+/*
+	6D1A0B70C5F3A2A9:0000000000000000:4B3C2D1E0F1A2B3C:0000000000000000:1:1:1:1:0:0:0:0
+*/
+func parseGenerationIdentifiers(output []byte) (current uint64, bitmap uint64, err error) {
+	fields := strings.Split(strings.TrimSpace(string(output)), ":")
+	if len(fields) < 2 {
+		return 0, 0, errors.Errorf("unexpected output '%s'", strings.TrimSpace(string(output)))
+	}
+	current, err = strconv.ParseUint(fields[0], 16, 64)
+	if err != nil {
+		return 0, 0, errors.Wrap(err, "could not parse the current identifier")
+	}
+	bitmap, err = strconv.ParseUint(fields[1], 16, 64)
+	if err != nil {
+		return 0, 0, errors.Wrap(err, "could not parse the bitmap identifier")
+	}
+	return current, bitmap, nil
+}
+
+func (c *drbdCollector) getDrbdConfig() (*drbdConfig, error) {
+	if err := collector.CheckExecutables(c.drbdadmPath); err != nil {
+		return nil, err
+	}
+
+	drbdConfigRaw, err := collector.Command(c.drbdadmPath, "dump", "all").Output()
+	if err != nil {
+		return nil, errors.Wrap(err, "drbdadm command failed")
+	}
+
+	config, err := parseDrbdConfig(drbdConfigRaw)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not parse drbdadm dump output")
+	}
+	return &config, nil
+}
+
+// the drbd-utils versions whose drbdsetup output the collector is known to parse correctly
+var supportedVersions = collector.VersionRange{Min: "9.0.0", Max: "10"}
+
+// a format change in a new drbd-utils release could make the parsing go silently wrong, so unknown versions are flagged
+func (c *drbdCollector) getToolVersion() (string, error) {
+	output, err := collector.Command(c.drbdsetupPath, "--version").Output()
+	if err != nil {
+		return "", errors.Wrap(err, "drbdsetup command failed")
+	}
+	return parseDrbdsetupVersion(output), nil
+}
+
+// finds the drbd-utils version in the output of `drbdsetup --version`, which looks like:
+/*
+	DRBDADM_API_VERSION=2
+	DRBD_KERNEL_VERSION_CODE=0x090016
+	DRBD_KERNEL_VERSION=9.0.22
+	DRBDADM_VERSION_CODE=0x090d00
+	DRBDADM_VERSION=9.13.0
+*/
+func parseDrbdsetupVersion(output []byte) string {
+	for _, line := range strings.Split(string(output), "\n") {
+		if strings.HasPrefix(line, "DRBDADM_VERSION=") {
+			return strings.TrimSpace(strings.TrimPrefix(line, "DRBDADM_VERSION="))
+		}
+	}
+	return ""
+}
+
 func parseDrbdStatus(statusRaw []byte) ([]drbdStatus, error) {
 	var drbdDevs []drbdStatus
 	err := json.Unmarshal(statusRaw, &drbdDevs)
@@ -159,9 +490,32 @@ func parseDrbdStatus(statusRaw []byte) ([]drbdStatus, error) {
 	return drbdDevs, nil
 }
 
+// the prefix of the files created by the DRBD split brain hook
+const splitBrainFilePrefix = "drbd-split-brain-detected-"
+
+// lists the files created by the DRBD split brain hook; they are on the host DRBD runs on, so on a remote one they are listed via ls
+func (c *drbdCollector) splitBrainFiles() []string {
+	if !collector.IsRemote() {
+		files, _ := filepath.Glob(filepath.Join(c.drbdSplitBrainPath, splitBrainFilePrefix+"*"))
+		return files
+	}
+
+	output, err := collector.Command("ls", "-1", c.drbdSplitBrainPath).Output()
+	if err != nil {
+		level.Debug(c.Logger).Log("msg", "Could not list the DRBD split brain files on the remote host", "err", err)
+		return nil
+	}
+	var files []string
+	for _, name := range strings.Split(string(output), "\n") {
+		if strings.HasPrefix(name, splitBrainFilePrefix) {
+			files = append(files, filepath.Join(c.drbdSplitBrainPath, name))
+		}
+	}
+	return files
+}
+
 func (c *drbdCollector) recordDrbdSplitBrainMetric(ch chan<- prometheus.Metric) {
-	// look for files created by the DRBD split brain hook
-	files, _ := filepath.Glob(c.drbdSplitBrainPath + "/drbd-split-brain-detected-*")
+	files := c.splitBrainFiles()
 
 	// prepare some pattern matching
 	re := regexp.MustCompile(`drbd-split-brain-detected-(?P<resource>[\w-]+)-(?P<volume>[\w-]+)`)
@@ -1,13 +1,19 @@
 package drbd
 
 import (
+	"bytes"
+	"context"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
 	"github.com/stretchr/testify/assert"
 
+	"github.com/ClusterLabs/ha_cluster_exporter/collector"
 	assertcustom "github.com/ClusterLabs/ha_cluster_exporter/internal/assert"
 )
 
@@ -94,8 +100,6 @@ func TestDrbdParsing(t *testing.T) {
         "connection-state": "Connected",
         "congested": false,
         "peer-role": "Primary",
-        "ap-in-flight": 0,
-        "rs-in-flight": 0,
         "peer_devices": [
           {
             "volume": 0,
@@ -125,6 +129,7 @@ func TestDrbdParsing(t *testing.T) {
 	assert.Equal(t, "Secondary", drbdDevs[0].Role)
 	assert.Equal(t, "UpToDate", drbdDevs[0].Devices[0].DiskState)
 	assert.Equal(t, 1, drbdDevs[0].Connections[0].PeerNodeID)
+	assert.Equal(t, "SLE15-sp1-gm-drbd1145296-node1", drbdDevs[0].Connections[0].PeerName)
 	assert.Equal(t, "UpToDate", drbdDevs[0].Connections[0].PeerDevices[0].PeerDiskState)
 	assert.Equal(t, 0, drbdDevs[0].Devices[0].Volume)
 	assert.Equal(t, 123456, drbdDevs[0].Devices[0].Written)
@@ -137,39 +142,90 @@ func TestDrbdParsing(t *testing.T) {
 	assert.Equal(t, false, drbdDevs[1].Devices[0].Quorum)
 	assert.Equal(t, 456, drbdDevs[0].Connections[0].PeerDevices[0].Received)
 	assert.Equal(t, 654, drbdDevs[0].Connections[0].PeerDevices[0].Sent)
-	assert.Equal(t, 3, drbdDevs[0].Connections[0].PeerDevices[0].Pending)
-	assert.Equal(t, 4, drbdDevs[0].Connections[0].PeerDevices[0].Unacked)
+	assert.Equal(t, 3, *drbdDevs[0].Connections[0].PeerDevices[0].Pending)
+	assert.Equal(t, 4, *drbdDevs[0].Connections[0].PeerDevices[0].Unacked)
 	assert.Equal(t, 100.0, drbdDevs[0].Connections[0].PeerDevices[0].PercentInSync)
 	assert.Equal(t, 99.8, drbdDevs[1].Connections[0].PeerDevices[0].PercentInSync)
+	// the in-flight counters are not reported by all the versions of drbdsetup
+	assert.Equal(t, 0, *drbdDevs[0].Connections[0].ApInFlight)
+	assert.Equal(t, 0, *drbdDevs[0].Connections[0].RsInFlight)
+	assert.Nil(t, drbdDevs[1].Connections[0].ApInFlight)
+	assert.Nil(t, drbdDevs[1].Connections[0].RsInFlight)
+}
+
+func TestDrbdPeerRequestsNotReported(t *testing.T) {
+	resources, err := parseDrbdStatus([]byte(`[
+  {"name": "no-statistics", "connections": [{"peer-node-id": 1, "peer_devices": [{"volume": 0, "peer-disk-state": "UpToDate"}]}]}
+]`))
+	assert.NoError(t, err)
+
+	peerDevice := resources[0].Connections[0].PeerDevices[0]
+	assert.Nil(t, peerDevice.Pending)
+	assert.Nil(t, peerDevice.Unacked)
 }
 
 func TestNewDrbdCollector(t *testing.T) {
-	_, err := NewCollector("../../test/fake_drbdsetup.sh", "splitbrainpath", false, log.NewNopLogger())
+	_, err := NewCollector("../../test/fake_drbdsetup.sh", "../../test/fake_drbdadm.sh", "splitbrainpath", false, false, log.NewNopLogger())
 
 	assert.Nil(t, err)
 }
 
 func TestNewDrbdCollectorChecksDrbdsetupExistence(t *testing.T) {
-	_, err := NewCollector("../../test/nonexistent", "splitbrainfake", false, log.NewNopLogger())
+	_, err := NewCollector("../../test/nonexistent", "../../test/fake_drbdadm.sh", "splitbrainfake", false, false, log.NewNopLogger())
 
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "'../../test/nonexistent' does not exist")
 }
 
 func TestNewDrbdCollectorChecksDrbdsetupExecutableBits(t *testing.T) {
-	_, err := NewCollector("../../test/dummy", "splibrainfake", false, log.NewNopLogger())
+	_, err := NewCollector("../../test/dummy", "../../test/fake_drbdadm.sh", "splibrainfake", false, false, log.NewNopLogger())
 
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "'../../test/dummy' is not executable")
 }
 
 func TestDRBDCollector(t *testing.T) {
-	collector, _ := NewCollector("../../test/fake_drbdsetup.sh", "fake", false, log.NewNopLogger())
+	collector, _ := NewCollector("../../test/fake_drbdsetup.sh", "../../test/fake_drbdadm.sh", "fake", false, false, log.NewNopLogger())
 	assertcustom.Metrics(t, collector, "drbd.metrics")
 }
 
+// the shared fixture has all the peers in sync, this one has a resync in progress with one of them
+func TestDRBDResyncCollector(t *testing.T) {
+	collector, _ := NewCollector("../../test/fake_drbdsetup_resync.sh", "../../test/fake_drbdadm.sh", "fake", false, false, log.NewNopLogger())
+
+	expect := `
+	# HELP ha_cluster_drbd_al_suspended Whether the updates of the activity log of each DRBD volume are suspended; 1 means suspended, 0 otherwise
+	# TYPE ha_cluster_drbd_al_suspended gauge
+	ha_cluster_drbd_al_suspended{resource="1-single-0",volume="0"} 0
+	ha_cluster_drbd_al_suspended{resource="1-single-1",volume="0"} 0
+	# HELP ha_cluster_drbd_ap_in_flight The application data sent to each peer and not yet acknowledged, as reported by drbdsetup
+	# TYPE ha_cluster_drbd_ap_in_flight gauge
+	ha_cluster_drbd_ap_in_flight{peer_name="SLE15-sp1-gm-drbd1145296-node1",peer_node_id="1",resource="1-single-0"} 0
+	ha_cluster_drbd_ap_in_flight{peer_name="SLE15-sp1-gm-drbd1145296-node1",peer_node_id="1",resource="1-single-1"} 1024
+	# HELP ha_cluster_drbd_resources_by_state The number of DRBD resources in each aggregate connection state; a resource counts in the worst state among its peers
+	# TYPE ha_cluster_drbd_resources_by_state gauge
+	ha_cluster_drbd_resources_by_state{state="connected"} 1
+	ha_cluster_drbd_resources_by_state{state="disconnected"} 0
+	ha_cluster_drbd_resources_by_state{state="standalone"} 0
+	ha_cluster_drbd_resources_by_state{state="syncing"} 1
+	# HELP ha_cluster_drbd_resync_rate_bytes_per_second The speed of the resync of each DRBD volume with each peer, in bytes per second
+	# TYPE ha_cluster_drbd_resync_rate_bytes_per_second gauge
+	ha_cluster_drbd_resync_rate_bytes_per_second{peer_name="SLE15-sp1-gm-drbd1145296-node1",peer_node_id="1",resource="1-single-1",volume="0"} 1.31072e+07
+	# HELP ha_cluster_drbd_rs_in_flight The resync data requested from or sent to each peer and not yet completed, as reported by drbdsetup
+	# TYPE ha_cluster_drbd_rs_in_flight gauge
+	ha_cluster_drbd_rs_in_flight{peer_name="SLE15-sp1-gm-drbd1145296-node1",peer_node_id="1",resource="1-single-0"} 0
+	ha_cluster_drbd_rs_in_flight{peer_name="SLE15-sp1-gm-drbd1145296-node1",peer_node_id="1",resource="1-single-1"} 2048
+	# HELP ha_cluster_drbd_tool_version_supported Whether the collector is known to parse the output of the running drbd-utils version correctly; 1 means supported, 0 otherwise
+	# TYPE ha_cluster_drbd_tool_version_supported gauge
+	ha_cluster_drbd_tool_version_supported{version="9.13.0"} 1
+	`
+
+	err := testutil.CollectAndCompare(collector, strings.NewReader(expect), "ha_cluster_drbd_al_suspended", "ha_cluster_drbd_ap_in_flight", "ha_cluster_drbd_rs_in_flight", "ha_cluster_drbd_resync_rate_bytes_per_second", "ha_cluster_drbd_resources_by_state", "ha_cluster_drbd_tool_version_supported")
+	assert.NoError(t, err)
+}
+
 func TestDRBDSplitbrainCollector(t *testing.T) {
-	collector, _ := NewCollector("../../test/fake_drbdsetup.sh", "../../test/drbd-splitbrain", false, log.NewNopLogger())
+	collector, _ := NewCollector("../../test/fake_drbdsetup.sh", "../../test/fake_drbdadm.sh", "../../test/drbd-splitbrain", false, false, log.NewNopLogger())
 
 	expect := `
 	# HELP ha_cluster_drbd_split_brain Whether a split brain has been detected; 1 line per resource, per volume.
@@ -182,3 +238,264 @@ func TestDRBDSplitbrainCollector(t *testing.T) {
 
 	assert.NoError(t, err)
 }
+
+func TestDRBDSplitbrainCollectorRemote(t *testing.T) {
+	collector.SetRemoteHost(&collector.RemoteHost{Host: "node01", SshPath: "../../test/fake_ssh.sh"})
+	defer collector.SetRemoteHost(nil)
+
+	drbdCollector, _ := NewCollector("../../test/fake_drbdsetup.sh", "../../test/fake_drbdadm.sh", "../../test/drbd-splitbrain", false, false, log.NewNopLogger())
+	assert.ElementsMatch(t, []string{
+		"../../test/drbd-splitbrain/drbd-split-brain-detected-missingthingsWrongSkippedMetrics",
+		"../../test/drbd-splitbrain/drbd-split-brain-detected-resource01-vol01",
+		"../../test/drbd-splitbrain/drbd-split-brain-detected-resource02-vol02",
+	}, drbdCollector.splitBrainFiles())
+
+	drbdCollector, _ = NewCollector("../../test/fake_drbdsetup.sh", "../../test/fake_drbdadm.sh", "../../test/nonexistent", false, false, log.NewNopLogger())
+	assert.Empty(t, drbdCollector.splitBrainFiles())
+}
+
+func TestDrbdCollectRawOutput(t *testing.T) {
+	collector, _ := NewCollector("../../test/fake_drbdsetup.sh", "../../test/fake_drbdadm.sh", "fake", false, false, log.NewNopLogger())
+
+	var output bytes.Buffer
+	err := collector.CollectRawOutput(context.Background(), &output)
+
+	assert.NoError(t, err)
+	assert.Contains(t, output.String(), "### ../../test/fake_drbdsetup.sh status --json\n[")
+	assert.Contains(t, output.String(), "### ../../test/fake_drbdadm.sh dump all\n# /etc/drbd.conf")
+}
+
+func TestDrbdDualPrimary(t *testing.T) {
+	collector, _ := NewCollector("../../test/fake_drbdsetup.sh", "../../test/fake_drbdadm.sh", "fake", false, false, log.NewNopLogger())
+
+	resources, err := parseDrbdStatus([]byte(`[
+  {"name": "single-primary", "role": "Primary", "connections": [{"peer-role": "Secondary"}]},
+  {"name": "dual-primary", "role": "Primary", "connections": [{"peer-role": "Secondary"}, {"peer-role": "Primary"}]},
+  {"name": "secondary", "role": "Secondary", "connections": [{"peer-role": "Primary"}]}
+]`))
+	assert.NoError(t, err)
+
+	expected := []float64{0, 1, 0}
+	for i, resource := range resources {
+		ch := make(chan prometheus.Metric, 1)
+		collector.recordDualPrimary(resource, ch)
+
+		metricDto := &dto.Metric{}
+		(<-ch).Write(metricDto)
+		assert.Equal(t, expected[i], metricDto.GetGauge().GetValue(), resource.Name)
+	}
+}
+
+func TestDrbdSuspended(t *testing.T) {
+	collector, _ := NewCollector("../../test/fake_drbdsetup.sh", "../../test/fake_drbdadm.sh", "fake", false, false, log.NewNopLogger())
+
+	// the same resource, first with the I/O suspended by the loss of quorum, then after the I/O was resumed
+	resources, err := parseDrbdStatus([]byte(`[
+  {"name": "res0", "suspended": true, "devices": [{"volume": 0, "quorum": false, "al-suspended": true}]},
+  {"name": "res0", "suspended": false, "devices": [{"volume": 0, "quorum": true, "al-suspended": false}]},
+  {"name": "res1", "suspended": false, "devices": [{"volume": 0}]}
+]`))
+	assert.NoError(t, err)
+
+	expected := []map[string]float64{
+		{"io_suspended": 1, "al_suspended": 1},
+		{"io_suspended": 0, "al_suspended": 0},
+		{"io_suspended": 0},
+	}
+	for i, resource := range resources {
+		ch := make(chan prometheus.Metric, 2)
+		collector.recordSuspended(resource, ch)
+		close(ch)
+
+		values := make(map[string]float64)
+		for metric := range ch {
+			metricDto := &dto.Metric{}
+			metric.Write(metricDto)
+			for _, name := range []string{"io_suspended", "al_suspended"} {
+				if strings.Contains(metric.Desc().String(), "ha_cluster_drbd_"+name) {
+					values[name] = metricDto.GetGauge().GetValue()
+				}
+			}
+		}
+		assert.Equal(t, expected[i], values, resource.Name)
+	}
+}
+
+func TestDrbdDiskStates(t *testing.T) {
+	collector, _ := NewCollector("../../test/fake_drbdsetup.sh", "../../test/fake_drbdadm.sh", "fake", false, false, log.NewNopLogger())
+
+	resources, err := parseDrbdStatus([]byte(`[
+  {"name": "multi-volume", "role": "Primary", "devices": [{"volume": 0, "disk-state": "UpToDate"}, {"volume": 1, "disk-state": "Diskless"}]}
+]`))
+	assert.NoError(t, err)
+
+	ch := make(chan prometheus.Metric, 2*len(diskStates))
+	collector.recordDiskStates(resources[0], ch)
+	close(ch)
+
+	active := make(map[string]string)
+	for metric := range ch {
+		metricDto := &dto.Metric{}
+		metric.Write(metricDto)
+		labels := make(map[string]string)
+		for _, label := range metricDto.GetLabel() {
+			labels[label.GetName()] = label.GetValue()
+		}
+		if metricDto.GetGauge().GetValue() == 1 {
+			active[labels["volume"]] = labels["disk_state"]
+		}
+	}
+
+	assert.Equal(t, map[string]string{"0": "uptodate", "1": "diskless"}, active)
+}
+
+func TestDrbdAggregateState(t *testing.T) {
+	resources, err := parseDrbdStatus([]byte(`[
+  {"name": "connected", "connections": [{"connection-state": "Connected", "peer_devices": [{"replication-state": "Established"}]}]},
+  {"name": "syncing", "connections": [{"connection-state": "Connected", "peer_devices": [{"replication-state": "Established"}, {"replication-state": "SyncTarget"}]}]},
+  {"name": "disconnected", "connections": [{"connection-state": "Connected", "peer_devices": [{"replication-state": "SyncSource"}]}, {"connection-state": "Connecting"}]},
+  {"name": "standalone", "connections": [{"connection-state": "Connecting"}, {"connection-state": "StandAlone"}]},
+  {"name": "standalone", "connections": []}
+]`))
+	assert.NoError(t, err)
+
+	for _, resource := range resources {
+		assert.Equal(t, resource.Name, aggregateState(resource))
+	}
+}
+
+func TestDrbdCollectorWithoutDrbdadm(t *testing.T) {
+	collector, err := NewCollector("../../test/fake_drbdsetup.sh", "../../test/nonexistent", "fake", false, false, log.NewNopLogger())
+	assert.NoError(t, err)
+
+	// everything but the protocol is still collected
+	assert.Equal(t, 0, testutil.CollectAndCount(collector, "ha_cluster_drbd_protocol"))
+	assert.NotZero(t, testutil.CollectAndCount(collector, "ha_cluster_drbd_connections"))
+}
+
+func TestParseDrbdConfig(t *testing.T) {
+	config, err := parseDrbdConfig([]byte(`
+# comments are ignored { ;
+common {
+	net { protocol B; }
+}
+resource "r0" {
+	connection {
+		host node01 address 10.0.0.1:7789;
+		host node02 address 10.0.0.2:7789;
+		net {
+			protocol a;
+		}
+	}
+	connection {
+		host node01;
+		host node03;
+	}
+}
+resource r1 {
+	protocol C;
+}
+resource r2 {
+	connection-mesh {
+		hosts node01 node02 node03;
+		net {
+			protocol A;
+		}
+	}
+	net {
+		protocol C;
+	}
+}
+`))
+	assert.NoError(t, err)
+
+	assert.Equal(t, "A", config.protocol("r0", "node02"))
+	assert.Equal(t, "B", config.protocol("r0", "node03"))
+	assert.Equal(t, "C", config.protocol("r1", "node02"))
+	assert.Equal(t, "A", config.protocol("r2", "node03"))
+	assert.Equal(t, "B", config.protocol("unknown", "node02"))
+
+	config, err = parseDrbdConfig([]byte(`resource r0 { on node01 { node-id 1; } }`))
+	assert.NoError(t, err)
+	assert.Equal(t, "C", config.protocol("r0", "node02"))
+}
+
+func TestParseDrbdConfigErrors(t *testing.T) {
+	for _, dump := range []string{"resource r0 {", "}", "resource r0 { net { protocol C; }", "{ }", "protocol C"} {
+		_, err := parseDrbdConfig([]byte(dump))
+		assert.Error(t, err, dump)
+	}
+}
+
+func TestDrbdResyncRate(t *testing.T) {
+	collector, _ := NewCollector("../../test/fake_drbdsetup.sh", "../../test/fake_drbdadm.sh", "fake", false, false, log.NewNopLogger())
+	start := time.Date(2022, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	// the first sample is just the baseline
+	_, ok := collector.resyncRate("r0/1/0", 40960, nil, start)
+	assert.False(t, ok)
+
+	rate, ok := collector.resyncRate("r0/1/0", 20480, nil, start.Add(10*time.Second))
+	assert.True(t, ok)
+	assert.Equal(t, 2048.0*1024, rate)
+
+	// writes to a disconnected peer increase the out-of-sync data, which is not a negative resync
+	rate, ok = collector.resyncRate("r0/1/0", 30720, nil, start.Add(20*time.Second))
+	assert.True(t, ok)
+	assert.Equal(t, 0.0, rate)
+
+	// the rate reported by drbdsetup takes precedence
+	reported := 12.5
+	rate, ok = collector.resyncRate("r0/1/0", 10240, &reported, start.Add(30*time.Second))
+	assert.True(t, ok)
+	assert.Equal(t, 12.5*1024*1024, rate)
+
+	// each peer device has its own baseline
+	_, ok = collector.resyncRate("r0/2/0", 10240, nil, start.Add(30*time.Second))
+	assert.False(t, ok)
+
+	// the peer devices that are gone start over when they come back
+	collector.forgetOutOfSync(map[string]bool{"r0/2/0": true})
+	_, ok = collector.resyncRate("r0/1/0", 10240, nil, start.Add(40*time.Second))
+	assert.False(t, ok)
+	_, ok = collector.resyncRate("r0/2/0", 0, nil, start.Add(40*time.Second))
+	assert.True(t, ok)
+}
+
+func TestDRBDUuidMismatch(t *testing.T) {
+	collector, _ := NewCollector("../../test/fake_drbdsetup.sh", "../../test/fake_drbdadm.sh", "fake", true, false, log.NewNopLogger())
+
+	expect := `
+	# HELP ha_cluster_drbd_uuid_mismatch Whether the data of each DRBD volume changed since it was last in sync with each peer, as per the generation identifiers; 1 means changed, 0 otherwise
+	# TYPE ha_cluster_drbd_uuid_mismatch gauge
+	ha_cluster_drbd_uuid_mismatch{peer_name="SLE15-sp1-gm-drbd1145296-node1",peer_node_id="1",resource="1-single-0",volume="0"} 0
+	ha_cluster_drbd_uuid_mismatch{peer_name="SLE15-sp1-gm-drbd1145296-node1",peer_node_id="1",resource="1-single-1",volume="0"} 1
+	`
+
+	err := testutil.CollectAndCompare(collector, strings.NewReader(expect), "ha_cluster_drbd_uuid_mismatch")
+	assert.NoError(t, err)
+
+	// the check is opt-in
+	collector, _ = NewCollector("../../test/fake_drbdsetup.sh", "../../test/fake_drbdadm.sh", "fake", false, false, log.NewNopLogger())
+	err = testutil.CollectAndCompare(collector, strings.NewReader(""), "ha_cluster_drbd_uuid_mismatch")
+	assert.NoError(t, err)
+}
+
+func TestParseGenerationIdentifiers(t *testing.T) {
+	current, bitmap, err := parseGenerationIdentifiers([]byte("C8B5E1F2A3D4E5F7:6D1A0B70C5F3A2A8:4B3C2D1E0F1A2B3C:0000000000000000:1:1:1:1:0:0:0:0\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0xC8B5E1F2A3D4E5F7), current)
+	assert.Equal(t, uint64(0x6D1A0B70C5F3A2A8), bitmap)
+
+	for _, output := range []string{"", "C8B5E1F2A3D4E5F7", "nothex:0000000000000000", "C8B5E1F2A3D4E5F7:nothex"} {
+		_, _, err := parseGenerationIdentifiers([]byte(output))
+		assert.Error(t, err, output)
+	}
+}
+
+func TestParseDrbdsetupVersion(t *testing.T) {
+	output := "DRBDADM_API_VERSION=2\nDRBD_KERNEL_VERSION_CODE=0x090016\nDRBD_KERNEL_VERSION=9.0.22\nDRBDADM_VERSION_CODE=0x090d00\nDRBDADM_VERSION=9.13.0\n"
+	assert.Equal(t, "9.13.0", parseDrbdsetupVersion([]byte(output)))
+
+	assert.Equal(t, "", parseDrbdsetupVersion([]byte("DRBD_KERNEL_VERSION=9.0.22\n")))
+}
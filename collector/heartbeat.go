@@ -0,0 +1,50 @@
+package collector
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// the last time each background collection loop went through a cycle, by loop name
+var heartbeats = struct {
+	sync.Mutex
+	beats map[string]time.Time
+}{beats: make(map[string]time.Time)}
+
+// records that a background collection loop went through a cycle, whether it succeeded or not;
+// a loop whose heartbeat stops advancing has died, even though the scrapes keep succeeding
+func Heartbeat(loop string, at time.Time) {
+	heartbeats.Lock()
+	defer heartbeats.Unlock()
+	heartbeats.beats[loop] = at
+}
+
+// exposes the heartbeats of the background collection loops; loops that never went through a cycle are not reported
+type HeartbeatCollector struct {
+	heartbeatDesc *prometheus.Desc
+}
+
+func NewHeartbeatCollector() *HeartbeatCollector {
+	return &HeartbeatCollector{
+		prometheus.NewDesc(
+			prometheus.BuildFQName(NAMESPACE, "exporter", "collection_heartbeat_timestamp_seconds"),
+			"The last time each background collection loop went through a cycle.",
+			[]string{"loop"},
+			nil,
+		),
+	}
+}
+
+func (hc *HeartbeatCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- hc.heartbeatDesc
+}
+
+func (hc *HeartbeatCollector) Collect(ch chan<- prometheus.Metric) {
+	heartbeats.Lock()
+	defer heartbeats.Unlock()
+	for loop, at := range heartbeats.beats {
+		ch <- prometheus.MustNewConstMetric(hc.heartbeatDesc, prometheus.GaugeValue, float64(at.Unix()), loop)
+	}
+}
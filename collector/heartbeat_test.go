@@ -0,0 +1,26 @@
+package collector
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHeartbeatCollector(t *testing.T) {
+	SUT := NewHeartbeatCollector()
+
+	assert.Equal(t, 0, testutil.CollectAndCount(SUT))
+
+	Heartbeat("test", time.Unix(1000, 0))
+	Heartbeat("test", time.Unix(1234, 0))
+
+	expected := `
+# HELP ha_cluster_exporter_collection_heartbeat_timestamp_seconds The last time each background collection loop went through a cycle.
+# TYPE ha_cluster_exporter_collection_heartbeat_timestamp_seconds gauge
+ha_cluster_exporter_collection_heartbeat_timestamp_seconds{loop="test"} 1234
+`
+	assert.NoError(t, testutil.CollectAndCompare(SUT, strings.NewReader(expected)))
+}
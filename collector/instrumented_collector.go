@@ -1,10 +1,21 @@
 package collector
 
 import (
+	"context"
+	"hash/fnv"
+	"io"
+	"math"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
 	"github.com/ClusterLabs/ha_cluster_exporter/internal/clock"
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
 )
 
 //go:generate go run -mod=mod github.com/golang/mock/mockgen --build_flags=-mod=mod -package mock_collector -destination ../test/mock_collector/instrumented_collector.go github.com/ClusterLabs/ha_cluster_exporter/collector InstrumentableCollector
@@ -17,12 +28,28 @@ type InstrumentableCollector interface {
 	CollectWithError(ch chan<- prometheus.Metric) error
 }
 
+// describes a collector with metrics whose values change on every scrape, which are left out of the state_changed hash
+type VolatileCollector interface {
+	IsVolatile(desc *prometheus.Desc) bool
+}
+
 type InstrumentedCollector struct {
 	collector          InstrumentableCollector
 	Clock              clock.Clock
 	scrapeDurationDesc *prometheus.Desc
 	scrapeSuccessDesc  *prometheus.Desc
+	upDesc             *prometheus.Desc
+	lastErrorDesc      *prometheus.Desc
+	metricsTotalDesc   *prometheus.Desc
+	stateChangedDesc   *prometheus.Desc
+	cappedDesc         *prometheus.Desc
+	state              *collectorState
 	logger             log.Logger
+
+	// whether to timestamp the metrics with the time their data was collected, see WithCollectionTime
+	CollectionTimestamps bool
+	// the maximum number of series the collector can expose in a scrape; 0 means no limit, see Collect
+	MaxSeries int
 }
 
 func NewInstrumentedCollector(collector InstrumentableCollector, logger log.Logger) *InstrumentedCollector {
@@ -45,15 +72,88 @@ func NewInstrumentedCollector(collector InstrumentableCollector, logger log.Logg
 				"collector": collector.GetSubsystem(),
 			},
 		),
+		// the same as scrape_success, but following the <subsystem>_up convention of other exporters
+		prometheus.NewDesc(
+			prometheus.BuildFQName(NAMESPACE, collector.GetSubsystem(), "up"),
+			"Whether the last scrape of the collector succeeded; 1 means success, 0 failure.",
+			nil,
+			nil,
+		),
+		prometheus.NewDesc(
+			prometheus.BuildFQName(NAMESPACE, collector.GetSubsystem(), "last_error"),
+			"The error of the last scrape of the collector, only present if it failed; value is always 1.",
+			[]string{"message"},
+			nil,
+		),
+		prometheus.NewDesc(
+			prometheus.BuildFQName(NAMESPACE, "exporter", "metrics_total"),
+			"The number of series a collector produced in the last scrape.",
+			nil,
+			prometheus.Labels{
+				"collector": collector.GetSubsystem(),
+			},
+		),
+		prometheus.NewDesc(
+			prometheus.BuildFQName(NAMESPACE, collector.GetSubsystem(), "state_changed"),
+			"Whether the metrics of the collector changed since the previous successful scrape, ignoring counters and the values that change on every scrape; 1 means changed, 0 otherwise.",
+			nil,
+			nil,
+		),
+		prometheus.NewDesc(
+			prometheus.BuildFQName(NAMESPACE, collector.GetSubsystem(), "cardinality_capped"),
+			"Whether the collector produced more series than the maximum allowed in the last scrape, so none of them was exposed; 1 means capped, 0 otherwise.",
+			nil,
+			nil,
+		),
+		&collectorState{},
 		logger,
+		false,
+		0,
 	}
 }
 
 func (ic *InstrumentedCollector) Collect(ch chan<- prometheus.Metric) {
 	var success float64
 	begin := ic.Clock.Now()
-	err := ic.collector.CollectWithError(ch)
+
+	// we count and hash the metrics while forwarding them.
+	// With a series cap, they are held back until the collection ends, and all of them are dropped as soon as the cap is exceeded:
+	// exposing only a part of the series would be misleading, e.g. some resources would look like they were gone.
+	var metricsTotal float64
+	var stateHash uint64
+	var capped bool
+	var held []prometheus.Metric
+	counted := make(chan prometheus.Metric)
+	forwarded := make(chan struct{})
+	go func() {
+		for metric := range counted {
+			metricsTotal++
+			stateHash += ic.hashMetric(metric)
+			if ic.CollectionTimestamps {
+				metric = collectionTimestamp(metric, begin)
+			}
+			switch {
+			case ic.MaxSeries <= 0:
+				ch <- metric
+			case capped:
+				// the remaining ones are dropped too, but still counted and hashed
+			case len(held) >= ic.MaxSeries:
+				capped = true
+				held = nil
+			default:
+				held = append(held, metric)
+			}
+		}
+		for _, metric := range held {
+			ch <- metric
+		}
+		close(forwarded)
+	}()
+
+	err := ic.collector.CollectWithError(counted)
 	duration := ic.Clock.Since(begin)
+	close(counted)
+	<-forwarded
 	if err == nil {
 		success = 1
 	} else {
@@ -61,14 +161,136 @@ func (ic *InstrumentedCollector) Collect(ch chan<- prometheus.Metric) {
 	}
 	ch <- prometheus.MustNewConstMetric(ic.scrapeDurationDesc, prometheus.GaugeValue, duration.Seconds())
 	ch <- prometheus.MustNewConstMetric(ic.scrapeSuccessDesc, prometheus.GaugeValue, success)
+	ch <- prometheus.MustNewConstMetric(ic.upDesc, prometheus.GaugeValue, success)
+	if err != nil {
+		// the message comes from external commands, so the metric is dropped rather than panicking if it still can't be a label value
+		lastError, metricErr := prometheus.NewConstMetric(ic.lastErrorDesc, prometheus.GaugeValue, 1, truncateErrorMessage(err.Error()))
+		if metricErr != nil {
+			level.Warn(ic.logger).Log("msg", "Could not expose the last error of the "+ic.collector.GetSubsystem()+" collector", "err", metricErr)
+		} else {
+			ch <- lastError
+		}
+	}
+	ch <- prometheus.MustNewConstMetric(ic.metricsTotalDesc, prometheus.GaugeValue, metricsTotal)
+	var cardinalityCapped float64
+	if capped {
+		cardinalityCapped = 1
+		level.Warn(ic.logger).Log("msg", ic.collector.GetSubsystem()+" collector produced too many series, none of them is exposed", "series", metricsTotal, "max", ic.MaxSeries)
+	}
+	ch <- prometheus.MustNewConstMetric(ic.cappedDesc, prometheus.GaugeValue, cardinalityCapped)
+	// a failed scrape has partial results at best, so it's not compared with the previous one
+	if err == nil {
+		var changed float64
+		if ic.state.update(stateHash) {
+			changed = 1
+		}
+		ch <- prometheus.MustNewConstMetric(ic.stateChangedDesc, prometheus.GaugeValue, changed)
+	}
+}
+
+// the hash of the metrics of the last successful scrape of a collector
+type collectorState struct {
+	mutex  sync.Mutex
+	hashed bool
+	hash   uint64
+}
+
+// stores the hash of a successful scrape and tells whether it differs from the previous one;
+// the first scrape has nothing to be compared with, so it's never considered a change
+func (s *collectorState) update(hash uint64) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	changed := s.hashed && s.hash != hash
+	s.hashed = true
+	s.hash = hash
+	return changed
+}
+
+// hashes the descriptor, labels and value of a metric; counters and volatile metrics hash to 0 since they'd change anyway.
+// The hashes of the metrics of a scrape are summed, so that the order they are collected in doesn't matter.
+func (ic *InstrumentedCollector) hashMetric(metric prometheus.Metric) uint64 {
+	if c, ok := ic.collector.(VolatileCollector); ok && c.IsVolatile(metric.Desc()) {
+		return 0
+	}
+
+	metricDto := &dto.Metric{}
+	if err := metric.Write(metricDto); err != nil || metricDto.Counter != nil {
+		return 0
+	}
+
+	h := fnv.New64a()
+	h.Write([]byte(metric.Desc().String()))
+	for _, label := range metricDto.GetLabel() {
+		h.Write([]byte{0})
+		h.Write([]byte(label.GetName()))
+		h.Write([]byte{0})
+		h.Write([]byte(label.GetValue()))
+	}
+	var value float64
+	switch {
+	case metricDto.Gauge != nil:
+		value = metricDto.Gauge.GetValue()
+	case metricDto.Untyped != nil:
+		value = metricDto.Untyped.GetValue()
+	}
+	bits := math.Float64bits(value)
+	for i := 0; i < 8; i++ {
+		h.Write([]byte{byte(bits >> (8 * i))})
+	}
+	return h.Sum64()
+}
+
+// the maximum length of the error messages exposed as labels, to keep long command outputs out of the series
+const maxErrorMessageLength = 200
+
+// the invalid UTF-8 sequences, e.g. from the output of commands in another encoding, are replaced first, since they can't be in label values
+func truncateErrorMessage(message string) string {
+	message = strings.ToValidUTF8(message, "\uFFFD")
+	if utf8.RuneCountInString(message) <= maxErrorMessageLength {
+		return message
+	}
+	return string([]rune(message)[:maxErrorMessageLength-3]) + "..."
+}
+
+// a metric whose data was collected before the scrape, e.g. in the background
+type collectedMetric struct {
+	prometheus.Metric
+	collectedAt time.Time
+}
+
+// marks a metric as collected at the given time rather than during the scrape;
+// it only affects the timestamps of instrumented collectors with CollectionTimestamps enabled
+func WithCollectionTime(metric prometheus.Metric, collectedAt time.Time) prometheus.Metric {
+	return collectedMetric{metric, collectedAt}
+}
+
+// timestamps a metric with the time its data was collected, i.e. the start of the scrape, unless it was collected earlier
+func collectionTimestamp(metric prometheus.Metric, scrapeBegin time.Time) prometheus.Metric {
+	if collected, ok := metric.(collectedMetric); ok {
+		return prometheus.NewMetricWithTimestamp(collected.collectedAt, collected.Metric)
+	}
+	return prometheus.NewMetricWithTimestamp(scrapeBegin, metric)
 }
 
 func (ic *InstrumentedCollector) Describe(ch chan<- *prometheus.Desc) {
 	ic.collector.Describe(ch)
 	ch <- ic.scrapeDurationDesc
 	ch <- ic.scrapeSuccessDesc
+	ch <- ic.upDesc
+	ch <- ic.lastErrorDesc
+	ch <- ic.metricsTotalDesc
+	ch <- ic.stateChangedDesc
+	ch <- ic.cappedDesc
 }
 
 func (ic *InstrumentedCollector) GetSubsystem() string {
 	return ic.collector.GetSubsystem()
 }
+
+func (ic *InstrumentedCollector) CollectRawOutput(ctx context.Context, w io.Writer) error {
+	c, ok := ic.collector.(RawOutputCollector)
+	if !ok {
+		return errors.Errorf("'%s' collector doesn't run any external command", ic.collector.GetSubsystem())
+	}
+	return c.CollectRawOutput(ctx, w)
+}
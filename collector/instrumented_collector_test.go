@@ -4,10 +4,14 @@ import (
 	"errors"
 	"strings"
 	"testing"
+	"time"
+	"unicode/utf8"
 
 	"github.com/go-kit/log"
 	"github.com/golang/mock/gomock"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
 	"github.com/stretchr/testify/assert"
 
 	"github.com/ClusterLabs/ha_cluster_exporter/internal/clock"
@@ -26,7 +30,19 @@ func TestInstrumentedCollector(t *testing.T) {
 	SUT := NewInstrumentedCollector(mockCollector, log.NewNopLogger())
 	SUT.Clock = &clock.StoppedClock{}
 
-	metrics := `# HELP ha_cluster_scrape_duration_seconds Duration of a collector scrape.
+	metrics := `# HELP ha_cluster_exporter_metrics_total The number of series a collector produced in the last scrape.
+# TYPE ha_cluster_exporter_metrics_total gauge
+ha_cluster_exporter_metrics_total{collector="mock_collector"} 0
+# HELP ha_cluster_mock_collector_cardinality_capped Whether the collector produced more series than the maximum allowed in the last scrape, so none of them was exposed; 1 means capped, 0 otherwise.
+# TYPE ha_cluster_mock_collector_cardinality_capped gauge
+ha_cluster_mock_collector_cardinality_capped 0
+# HELP ha_cluster_mock_collector_state_changed Whether the metrics of the collector changed since the previous successful scrape, ignoring counters and the values that change on every scrape; 1 means changed, 0 otherwise.
+# TYPE ha_cluster_mock_collector_state_changed gauge
+ha_cluster_mock_collector_state_changed 0
+# HELP ha_cluster_mock_collector_up Whether the last scrape of the collector succeeded; 1 means success, 0 failure.
+# TYPE ha_cluster_mock_collector_up gauge
+ha_cluster_mock_collector_up 1
+# HELP ha_cluster_scrape_duration_seconds Duration of a collector scrape.
 # TYPE ha_cluster_scrape_duration_seconds gauge
 ha_cluster_scrape_duration_seconds{collector="mock_collector"} 1.234
 # HELP ha_cluster_scrape_success Whether a collector succeeded.
@@ -50,13 +66,235 @@ func TestInstrumentedCollectorScrapeFailure(t *testing.T) {
 
 	SUT := NewInstrumentedCollector(mockCollector, log.NewNopLogger())
 
-	metrics := `# HELP ha_cluster_scrape_success Whether a collector succeeded.
+	metrics := `# HELP ha_cluster_mock_collector_last_error The error of the last scrape of the collector, only present if it failed; value is always 1.
+# TYPE ha_cluster_mock_collector_last_error gauge
+ha_cluster_mock_collector_last_error{message="test error"} 1
+# HELP ha_cluster_mock_collector_up Whether the last scrape of the collector succeeded; 1 means success, 0 failure.
+# TYPE ha_cluster_mock_collector_up gauge
+ha_cluster_mock_collector_up 0
+# HELP ha_cluster_scrape_success Whether a collector succeeded.
 # TYPE ha_cluster_scrape_success gauge
 ha_cluster_scrape_success{collector="mock_collector"} 0
 `
 
-	err := testutil.CollectAndCompare(SUT, strings.NewReader(metrics), "ha_cluster_scrape_success")
+	err := testutil.CollectAndCompare(SUT, strings.NewReader(metrics), "ha_cluster_scrape_success", "ha_cluster_mock_collector_up", "ha_cluster_mock_collector_last_error")
 	assert.NoError(t, err)
 
 	assert.NotNil(t, collectWithError)
 }
+
+func TestInstrumentedCollectorMetricsTotal(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	desc := prometheus.NewDesc("mock_metric", "A mock metric.", []string{"id"}, nil)
+
+	mockCollector := mock_collector.NewMockInstrumentableCollector(ctrl)
+	mockCollector.EXPECT().GetSubsystem().Return("mock_collector").AnyTimes()
+	mockCollector.EXPECT().Describe(gomock.Any()).Do(func(ch chan<- *prometheus.Desc) {
+		ch <- desc
+	})
+	mockCollector.EXPECT().CollectWithError(gomock.Any()).DoAndReturn(func(ch chan<- prometheus.Metric) error {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, 1, "a")
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, 1, "b")
+		return nil
+	})
+
+	SUT := NewInstrumentedCollector(mockCollector, log.NewNopLogger())
+
+	metrics := `# HELP ha_cluster_exporter_metrics_total The number of series a collector produced in the last scrape.
+# TYPE ha_cluster_exporter_metrics_total gauge
+ha_cluster_exporter_metrics_total{collector="mock_collector"} 2
+# HELP mock_metric A mock metric.
+# TYPE mock_metric gauge
+mock_metric{id="a"} 1
+mock_metric{id="b"} 1
+`
+
+	err := testutil.CollectAndCompare(SUT, strings.NewReader(metrics), "ha_cluster_exporter_metrics_total", "mock_metric")
+	assert.NoError(t, err)
+}
+
+func TestInstrumentedCollectorMaxSeries(t *testing.T) {
+	desc := prometheus.NewDesc("mock_metric", "A mock metric.", []string{"id"}, nil)
+
+	testCases := []struct {
+		name      string
+		maxSeries int
+		metrics   string
+	}{
+		{
+			name:      "below the cap",
+			maxSeries: 2,
+			metrics: `# HELP ha_cluster_mock_collector_cardinality_capped Whether the collector produced more series than the maximum allowed in the last scrape, so none of them was exposed; 1 means capped, 0 otherwise.
+# TYPE ha_cluster_mock_collector_cardinality_capped gauge
+ha_cluster_mock_collector_cardinality_capped 0
+# HELP ha_cluster_exporter_metrics_total The number of series a collector produced in the last scrape.
+# TYPE ha_cluster_exporter_metrics_total gauge
+ha_cluster_exporter_metrics_total{collector="mock_collector"} 2
+# HELP mock_metric A mock metric.
+# TYPE mock_metric gauge
+mock_metric{id="a"} 1
+mock_metric{id="b"} 1
+`,
+		},
+		{
+			name:      "above the cap",
+			maxSeries: 1,
+			metrics: `# HELP ha_cluster_mock_collector_cardinality_capped Whether the collector produced more series than the maximum allowed in the last scrape, so none of them was exposed; 1 means capped, 0 otherwise.
+# TYPE ha_cluster_mock_collector_cardinality_capped gauge
+ha_cluster_mock_collector_cardinality_capped 1
+# HELP ha_cluster_exporter_metrics_total The number of series a collector produced in the last scrape.
+# TYPE ha_cluster_exporter_metrics_total gauge
+ha_cluster_exporter_metrics_total{collector="mock_collector"} 2
+`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockCollector := mock_collector.NewMockInstrumentableCollector(ctrl)
+			mockCollector.EXPECT().GetSubsystem().Return("mock_collector").AnyTimes()
+			mockCollector.EXPECT().Describe(gomock.Any()).Do(func(ch chan<- *prometheus.Desc) {
+				ch <- desc
+			})
+			mockCollector.EXPECT().CollectWithError(gomock.Any()).DoAndReturn(func(ch chan<- prometheus.Metric) error {
+				ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, 1, "a")
+				ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, 1, "b")
+				return nil
+			})
+
+			SUT := NewInstrumentedCollector(mockCollector, log.NewNopLogger())
+			SUT.MaxSeries = tc.maxSeries
+
+			err := testutil.CollectAndCompare(SUT, strings.NewReader(tc.metrics), "ha_cluster_mock_collector_cardinality_capped", "ha_cluster_exporter_metrics_total", "mock_metric")
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestInstrumentedCollectorCollectionTimestamps(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	desc := prometheus.NewDesc("mock_metric", "A mock metric.", []string{"id"}, nil)
+	collectedAt := time.Unix(1000, 0)
+
+	mockCollector := mock_collector.NewMockInstrumentableCollector(ctrl)
+	mockCollector.EXPECT().GetSubsystem().Return("mock_collector").AnyTimes()
+	mockCollector.EXPECT().CollectWithError(gomock.Any()).DoAndReturn(func(ch chan<- prometheus.Metric) error {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, 1, "scraped")
+		ch <- WithCollectionTime(prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, 1, "background"), collectedAt)
+		return nil
+	}).Times(2)
+
+	SUT := NewInstrumentedCollector(mockCollector, log.NewNopLogger())
+	SUT.Clock = &clock.StoppedClock{}
+
+	collectTimestamps := func() map[string]*int64 {
+		ch := make(chan prometheus.Metric, 10)
+		SUT.Collect(ch)
+		close(ch)
+
+		timestamps := make(map[string]*int64)
+		for metric := range ch {
+			metricDto := &dto.Metric{}
+			metric.Write(metricDto)
+			for _, label := range metricDto.GetLabel() {
+				if label.GetName() == "id" {
+					timestamps[label.GetValue()] = metricDto.TimestampMs
+				}
+			}
+		}
+		return timestamps
+	}
+
+	timestamps := collectTimestamps()
+	assert.Nil(t, timestamps["scraped"])
+	assert.Nil(t, timestamps["background"])
+
+	SUT.CollectionTimestamps = true
+	timestamps = collectTimestamps()
+	assert.Equal(t, int64(clock.TEST_TIMESTAMP), *timestamps["scraped"])
+	assert.Equal(t, int64(1000000), *timestamps["background"])
+}
+
+// a collector whose metrics are volatile if they are described by a given descriptor
+type volatileMockCollector struct {
+	*mock_collector.MockInstrumentableCollector
+	volatileDesc *prometheus.Desc
+}
+
+func (c volatileMockCollector) IsVolatile(desc *prometheus.Desc) bool {
+	return desc == c.volatileDesc
+}
+
+func TestInstrumentedCollectorStateChanged(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	stateDesc := prometheus.NewDesc("mock_state", "A mock state.", []string{"id"}, nil)
+	ageDesc := prometheus.NewDesc("mock_age_seconds", "A mock age.", nil, nil)
+	counterDesc := prometheus.NewDesc("mock_total", "A mock counter.", nil, nil)
+
+	scrapes := []struct {
+		states  map[string]float64
+		err     error
+		changed string
+	}{
+		{map[string]float64{"a": 1, "b": 0}, nil, "0"},
+		{map[string]float64{"b": 0, "a": 1}, nil, "0"},
+		{map[string]float64{"a": 1, "b": 1}, nil, "1"},
+		{map[string]float64{"a": 1}, errors.New("test error"), ""},
+		{map[string]float64{"a": 1, "b": 1}, nil, "0"},
+		{map[string]float64{"a": 1}, nil, "1"},
+	}
+
+	var scrape int
+	mockCollector := mock_collector.NewMockInstrumentableCollector(ctrl)
+	mockCollector.EXPECT().GetSubsystem().Return("mock_collector").AnyTimes()
+	mockCollector.EXPECT().Describe(gomock.Any()).Do(func(ch chan<- *prometheus.Desc) {
+		ch <- stateDesc
+		ch <- ageDesc
+		ch <- counterDesc
+	}).AnyTimes()
+	mockCollector.EXPECT().CollectWithError(gomock.Any()).DoAndReturn(func(ch chan<- prometheus.Metric) error {
+		for id, value := range scrapes[scrape].states {
+			ch <- prometheus.MustNewConstMetric(stateDesc, prometheus.GaugeValue, value, id)
+		}
+		// neither the volatile metrics nor the counters are taken into account
+		ch <- prometheus.MustNewConstMetric(ageDesc, prometheus.GaugeValue, float64(scrape))
+		ch <- prometheus.MustNewConstMetric(counterDesc, prometheus.CounterValue, float64(scrape))
+		return scrapes[scrape].err
+	}).Times(len(scrapes))
+
+	SUT := NewInstrumentedCollector(volatileMockCollector{mockCollector, ageDesc}, log.NewNopLogger())
+
+	for scrape = range scrapes {
+		var metrics string
+		if scrapes[scrape].changed != "" {
+			metrics = `# HELP ha_cluster_mock_collector_state_changed Whether the metrics of the collector changed since the previous successful scrape, ignoring counters and the values that change on every scrape; 1 means changed, 0 otherwise.
+# TYPE ha_cluster_mock_collector_state_changed gauge
+ha_cluster_mock_collector_state_changed ` + scrapes[scrape].changed + "\n"
+		}
+
+		err := testutil.CollectAndCompare(SUT, strings.NewReader(metrics), "ha_cluster_mock_collector_state_changed")
+		assert.NoError(t, err, "scrape %d", scrape)
+	}
+}
+
+func TestTruncateErrorMessage(t *testing.T) {
+	assert.Equal(t, "crm_mon: connection refused", truncateErrorMessage("crm_mon: connection refused"))
+
+	truncated := truncateErrorMessage(strings.Repeat("é", 300))
+	assert.Equal(t, maxErrorMessageLength, utf8.RuneCountInString(truncated))
+	assert.True(t, strings.HasSuffix(truncated, "..."))
+
+	assert.Equal(t, "drbdsetup: r\uFFFDsum\uFFFD", truncateErrorMessage("drbdsetup: r\xe9sum\xe9"))
+	truncated = truncateErrorMessage(strings.Repeat("\xff ", 300))
+	assert.True(t, utf8.ValidString(truncated))
+	assert.Equal(t, maxErrorMessageLength, utf8.RuneCountInString(truncated))
+}
@@ -0,0 +1,65 @@
+package lvmlockd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+
+	assertcustom "github.com/ClusterLabs/ha_cluster_exporter/internal/assert"
+)
+
+func TestNewLvmlockdCollector(t *testing.T) {
+	_, err := NewCollector("../../test/fake_lvmlockctl.sh", false, log.NewNopLogger())
+
+	assert.Nil(t, err)
+}
+
+func TestNewLvmlockdCollectorChecksLvmlockctlExistence(t *testing.T) {
+	_, err := NewCollector("../../test/nonexistent", false, log.NewNopLogger())
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "'../../test/nonexistent' does not exist")
+}
+
+func TestLvmlockdCollector(t *testing.T) {
+	collector, err := NewCollector("../../test/fake_lvmlockctl.sh", false, log.NewNopLogger())
+
+	assert.Nil(t, err)
+	assertcustom.Metrics(t, collector, "lvmlockd.metrics")
+}
+
+func TestLvmlockdCollectorInactive(t *testing.T) {
+	collector, err := NewCollector("../../test/fake_lvmlockctl_inactive.sh", false, log.NewNopLogger())
+	assert.Nil(t, err)
+
+	expect := `
+	# HELP ha_cluster_lvmlockd_active Whether lvmlockd is running, i.e. lvmlockctl can connect to it; 1 means active, 0 otherwise
+	# TYPE ha_cluster_lvmlockd_active gauge
+	ha_cluster_lvmlockd_active 0
+	`
+
+	err = testutil.CollectAndCompare(collector, strings.NewReader(expect))
+	assert.NoError(t, err)
+}
+
+func TestParseLvmlockctlInfo(t *testing.T) {
+	output := []byte(`
+VG vg1 lock_type=sanlock 9HvtiD-qmbl-ANVH-9ZyR-AbQn-8UpF-k0Rtn7
+LS sanlock lvm_vg1
+LK VG ex ver 5
+
+VG vg2 lock_type=dlm wJ0H1g-F8Qz-8Lb1-MH4G-J8Y6-WbLQ-d8bBZ0
+LS dlm lvm_vg2
+`)
+
+	lockspaces := parseLvmlockctlInfo(output)
+
+	assert.Equal(t, []lockspace{
+		{VG: "vg1", LockType: "sanlock", Mode: "ex"},
+		{VG: "vg2", LockType: "dlm"},
+	}, lockspaces)
+	assert.Empty(t, parseLvmlockctlInfo([]byte{}))
+}
@@ -9,19 +9,24 @@ https://clusterlabs.org/pacemaker/doc/en-US/Pacemaker/2.0/html-single/Pacemaker_
 */
 
 type Root struct {
+	// the CRM feature set of the DC, which the CIB is written with
+	CrmFeatureSet string `xml:"crm_feature_set,attr"`
+	// the version of the CIB, which each node holds a copy of; admin_epoch and epoch are only increased by configuration changes
+	AdminEpoch string `xml:"admin_epoch,attr"`
+	Epoch      string `xml:"epoch,attr"`
+	NumUpdates string `xml:"num_updates,attr"`
+	// the last time the CIB was written, e.g. "Mon Nov 18 17:48:21 2019"
+	LastWritten   string `xml:"cib-last-written,attr"`
 	Configuration struct {
 		CrmConfig struct {
 			ClusterProperties []Attribute `xml:"cluster_property_set>nvpair"`
 		} `xml:"crm_config"`
-		Nodes []struct {
-			Id                 string      `xml:"id,attr"`
-			Uname              string      `xml:"uname,attr"`
-			InstanceAttributes []Attribute `xml:"instance_attributes>nvpair"`
-		} `xml:"nodes>node"`
+		Nodes     []Node `xml:"nodes>node"`
 		Resources struct {
 			Primitives []Primitive `xml:"primitive"`
 			Masters    []Clone     `xml:"master"`
 			Clones     []Clone     `xml:"clone"`
+			Groups     []Group     `xml:"group"`
 		} `xml:"resources"`
 		Constraints struct {
 			RscLocations []struct {
@@ -30,9 +35,31 @@ type Root struct {
 				Resource string `xml:"rsc,attr"`
 				Role     string `xml:"role,attr"`
 				Score    string `xml:"score,attr"`
+				Rules    []Rule `xml:"rule"`
 			} `xml:"rsc_location"`
+			// "then" is started after "first"; kind is Mandatory by default, unless the deprecated score is 0
+			RscOrders []struct {
+				Id    string `xml:"id,attr"`
+				First string `xml:"first,attr"`
+				Then  string `xml:"then,attr"`
+				Kind  string `xml:"kind,attr"`
+				Score string `xml:"score,attr"`
+			} `xml:"rsc_order"`
+			// "rsc" is placed where "with-rsc" runs; only an INFINITY score makes it mandatory
+			RscColocations []struct {
+				Id           string `xml:"id,attr"`
+				Resource     string `xml:"rsc,attr"`
+				WithResource string `xml:"with-rsc,attr"`
+				Score        string `xml:"score,attr"`
+			} `xml:"rsc_colocation"`
 		} `xml:"constraints"`
+		FencingLevels []FencingLevel `xml:"fencing-topology>fencing-level"`
+		// the defaults of the resource meta-attributes; the rules of the sets, if any, are not evaluated
+		RscDefaults []Attribute `xml:"rsc_defaults>meta_attributes>nvpair"`
 	} `xml:"configuration"`
+	Status struct {
+		NodeStates []NodeState `xml:"node_state"`
+	} `xml:"status"`
 }
 
 type Attribute struct {
@@ -41,6 +68,72 @@ type Attribute struct {
 	Value string `xml:"value,attr"`
 }
 
+type Node struct {
+	Id    string `xml:"id,attr"`
+	Uname string `xml:"uname,attr"`
+	// the attributes are kept grouped by set, since a set can be conditional on a rule
+	InstanceAttributeSets []AttributeSet `xml:"instance_attributes"`
+}
+
+// returns the attributes of all the sets of the node, regardless of their rules
+func (n Node) InstanceAttributes() []Attribute {
+	var attributes []Attribute
+	for _, set := range n.InstanceAttributeSets {
+		attributes = append(attributes, set.Attributes...)
+	}
+	return attributes
+}
+
+type AttributeSet struct {
+	Id         string      `xml:"id,attr"`
+	Rules      []Rule      `xml:"rule"`
+	Attributes []Attribute `xml:"nvpair"`
+}
+
+// the state of a node as seen by the controller; newer Pacemaker versions set in_ccm and crmd to the time the state was
+// last entered, rather than to "true" and "online" respectively
+type NodeState struct {
+	Id       string `xml:"id,attr"`
+	Uname    string `xml:"uname,attr"`
+	InCcm    string `xml:"in_ccm,attr"`
+	Crmd     string `xml:"crmd,attr"`
+	Join     string `xml:"join,attr"`
+	Expected string `xml:"expected,attr"`
+	// remote and guest nodes have no controller of their own, so their crmd and join attributes are never set
+	RemoteNode string `xml:"remote_node,attr"`
+	// the operation history of the resources on the node
+	LrmResources []LrmResource `xml:"lrm>lrm_resources>lrm_resource"`
+}
+
+type LrmResource struct {
+	Id         string     `xml:"id,attr"`
+	Operations []LrmRscOp `xml:"lrm_rsc_op"`
+}
+
+type LrmRscOp struct {
+	Id           string `xml:"id,attr"`
+	Operation    string `xml:"operation,attr"`
+	Interval     string `xml:"interval,attr"`
+	OnNode       string `xml:"on_node,attr"`
+	LastRun      string `xml:"last-run,attr"`
+	LastRcChange string `xml:"last-rc-change,attr"`
+	// only set on migrate_to and migrate_from operations
+	MigrateSource string `xml:"migrate_source,attr"`
+	MigrateTarget string `xml:"migrate_target,attr"`
+}
+
+// a level of the fencing topology, whose devices are tried together before moving on to the next level;
+// the nodes it applies to are either named, matched by a regular expression, or matched by the value of a node attribute
+type FencingLevel struct {
+	Id              string `xml:"id,attr"`
+	Index           int    `xml:"index,attr"`
+	Devices         string `xml:"devices,attr"`
+	Target          string `xml:"target,attr"`
+	TargetPattern   string `xml:"target-pattern,attr"`
+	TargetAttribute string `xml:"target-attribute,attr"`
+	TargetValue     string `xml:"target-value,attr"`
+}
+
 type Primitive struct {
 	Id                 string      `xml:"id,attr"`
 	Class              string      `xml:"class,attr"`
@@ -48,18 +141,63 @@ type Primitive struct {
 	Provider           string      `xml:"provider,attr"`
 	InstanceAttributes []Attribute `xml:"instance_attributes>nvpair"`
 	MetaAttributes     []Attribute `xml:"meta_attributes>nvpair"`
-	Operations         []struct {
-		Id   string `xml:"id,attr"`
-		Name string `xml:"name,attr"`
-		Role string `xml:"role,attr"`
-		// todo: interval and timeout are time based vars. We should in future parse them correctly insteaf of string
-		Interval string `xml:"interval,attr"`
-		Timeout  string `xml:"timeout,attr"`
-	} `xml:"operations>op"`
+	Operations         []Operation `xml:"operations>op"`
+}
+
+type Operation struct {
+	Id   string `xml:"id,attr"`
+	Name string `xml:"name,attr"`
+	Role string `xml:"role,attr"`
+	// todo: interval and timeout are time based vars. We should in future parse them correctly insteaf of string
+	Interval       string `xml:"interval,attr"`
+	IntervalOrigin string `xml:"interval-origin,attr"`
+	Timeout        string `xml:"timeout,attr"`
 }
 
+// a clone wraps either a primitive or a group; Group is nil in the former case
 type Clone struct {
 	Id             string      `xml:"id,attr"`
 	MetaAttributes []Attribute `xml:"meta_attributes>nvpair"`
 	Primitive      Primitive   `xml:"primitive"`
+	Group          *Group      `xml:"group"`
+}
+
+type Group struct {
+	Id             string      `xml:"id,attr"`
+	MetaAttributes []Attribute `xml:"meta_attributes>nvpair"`
+	Primitives     []Primitive `xml:"primitive"`
+}
+
+// a rule of a constraint, whose expressions are combined with its boolean-op; "and" is the default.
+// only the date expressions are parsed, since the other ones depend on the node they are evaluated on
+type Rule struct {
+	Id              string           `xml:"id,attr"`
+	Score           string           `xml:"score,attr"`
+	BooleanOp       string           `xml:"boolean-op,attr"`
+	DateExpressions []DateExpression `xml:"date_expression"`
+	Rules           []Rule           `xml:"rule"`
+}
+
+type DateExpression struct {
+	Id        string    `xml:"id,attr"`
+	Operation string    `xml:"operation,attr"`
+	Start     string    `xml:"start,attr"`
+	End       string    `xml:"end,attr"`
+	Duration  *DateSpec `xml:"duration"`
+	DateSpec  *DateSpec `xml:"date_spec"`
+}
+
+// the fields of a date_spec are ranges like "1-5" or single values; the ones of a duration are plain amounts
+type DateSpec struct {
+	Years     string `xml:"years,attr"`
+	Months    string `xml:"months,attr"`
+	Weeks     string `xml:"weeks,attr"`
+	Days      string `xml:"days,attr"`
+	Hours     string `xml:"hours,attr"`
+	Minutes   string `xml:"minutes,attr"`
+	Seconds   string `xml:"seconds,attr"`
+	Monthdays string `xml:"monthdays,attr"`
+	Weekdays  string `xml:"weekdays,attr"`
+	Yeardays  string `xml:"yeardays,attr"`
+	Weekyears string `xml:"weekyears,attr"`
 }
@@ -2,9 +2,10 @@ package cib
 
 import (
 	"encoding/xml"
-	"os/exec"
 
 	"github.com/pkg/errors"
+
+	"github.com/ClusterLabs/ha_cluster_exporter/collector"
 )
 
 type Parser interface {
@@ -17,7 +18,7 @@ type cibAdminParser struct {
 
 func (p *cibAdminParser) Parse() (Root, error) {
 	var CIB Root
-	cibXML, err := exec.Command(p.cibAdminPath, "--query", "--local").Output()
+	cibXML, err := collector.Command(p.cibAdminPath, "--query", "--local").Output()
 	if err != nil {
 		return CIB, errors.Wrap(err, "error while executing cibadmin")
 	}
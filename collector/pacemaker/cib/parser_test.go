@@ -1,6 +1,7 @@
 package cib
 
 import (
+	"encoding/xml"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -15,11 +16,27 @@ func TestParse(t *testing.T) {
 	p := NewCibAdminParser("../../../test/fake_cibadmin.sh")
 	data, err := p.Parse()
 	assert.NoError(t, err)
+	assert.Equal(t, "0", data.AdminEpoch)
+	assert.Equal(t, "6881", data.Epoch)
+	assert.Equal(t, "Mon Nov 18 17:48:21 2019", data.LastWritten)
+	assert.Equal(t, "0", data.NumUpdates)
 	assert.Equal(t, 2, len(data.Configuration.Nodes))
 	assert.Equal(t, "cib-bootstrap-options-cluster-name", data.Configuration.CrmConfig.ClusterProperties[3].Id)
 	assert.Equal(t, "hana_cluster", data.Configuration.CrmConfig.ClusterProperties[3].Value)
 	assert.Equal(t, "node01", data.Configuration.Nodes[0].Uname)
 	assert.Equal(t, "node02", data.Configuration.Nodes[1].Uname)
+	assert.Equal(t, "nodes-1084783375", data.Configuration.Nodes[0].InstanceAttributeSets[0].Id)
+	assert.Equal(t, 6, len(data.Configuration.Nodes[0].InstanceAttributes()))
+	assert.Equal(t, 2, len(data.Status.NodeStates))
+	nodeState := data.Status.NodeStates[0]
+	assert.Equal(t, "1084783375", nodeState.Id)
+	assert.Equal(t, "node01", nodeState.Uname)
+	assert.Equal(t, "true", nodeState.InCcm)
+	assert.Equal(t, "online", nodeState.Crmd)
+	assert.Equal(t, "member", nodeState.Join)
+	assert.Equal(t, "member", nodeState.Expected)
+	assert.Equal(t, "rsc_SAPHana_PRD_HDB00", nodeState.LrmResources[0].Id)
+	assert.Equal(t, LrmRscOp{Id: "rsc_SAPHana_PRD_HDB00_monitor_60000", Operation: "monitor", Interval: "60000", OnNode: "node01", LastRcChange: "1573663906"}, nodeState.LrmResources[0].Operations[2])
 	assert.Equal(t, 4, len(data.Configuration.Resources.Primitives))
 	assert.Equal(t, 1, len(data.Configuration.Resources.Masters))
 	assert.Equal(t, 1, len(data.Configuration.Resources.Clones))
@@ -62,3 +79,53 @@ func TestParse(t *testing.T) {
 	assert.Equal(t, "Dummy", data.Configuration.Resources.Primitives[2].Type)
 
 }
+
+func TestParseFencingTopology(t *testing.T) {
+	var CIB Root
+	err := xml.Unmarshal([]byte(`
+<cib>
+  <configuration>
+    <fencing-topology>
+      <fencing-level id="fencing-node01-1" target="node01" index="1" devices="stonith-sbd"/>
+      <fencing-level id="fencing-nodes-2" target-pattern="node0[12]" index="2" devices="stonith-sbd"/>
+    </fencing-topology>
+  </configuration>
+</cib>`), &CIB)
+	assert.NoError(t, err)
+	assert.Equal(t, []FencingLevel{
+		{Id: "fencing-node01-1", Index: 1, Devices: "stonith-sbd", Target: "node01"},
+		{Id: "fencing-nodes-2", Index: 2, Devices: "stonith-sbd", TargetPattern: "node0[12]"},
+	}, CIB.Configuration.FencingLevels)
+}
+
+func TestParseRules(t *testing.T) {
+	var CIB Root
+	err := xml.Unmarshal([]byte(`
+<cib>
+  <configuration>
+    <constraints>
+      <rsc_location id="location-rsc_ip-windows" rsc="rsc_ip">
+        <rule id="location-rsc_ip-windows-migrated" score="-INFINITY">
+          <date_expression id="location-rsc_ip-windows-migrated-expr" operation="gt" start="2020-01-01"/>
+        </rule>
+        <rule id="location-rsc_ip-windows-legacy" score="-INFINITY" boolean-op="and">
+          <expression id="location-rsc_ip-windows-legacy-expr" attribute="#uname" operation="eq" value="node02"/>
+          <date_expression id="location-rsc_ip-windows-legacy-date" operation="date_spec">
+            <date_spec id="location-rsc_ip-windows-legacy-spec" years="2019"/>
+          </date_expression>
+        </rule>
+      </rsc_location>
+    </constraints>
+  </configuration>
+</cib>`), &CIB)
+	assert.NoError(t, err)
+
+	rules := CIB.Configuration.Constraints.RscLocations[0].Rules
+	assert.Len(t, rules, 2)
+	assert.Equal(t, "location-rsc_ip-windows-migrated", rules[0].Id)
+	assert.Equal(t, "gt", rules[0].DateExpressions[0].Operation)
+	assert.Equal(t, "2020-01-01", rules[0].DateExpressions[0].Start)
+	assert.Equal(t, "and", rules[1].BooleanOp)
+	assert.Equal(t, "date_spec", rules[1].DateExpressions[0].Operation)
+	assert.Equal(t, "2019", rules[1].DateExpressions[0].DateSpec.Years)
+}
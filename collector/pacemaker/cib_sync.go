@@ -0,0 +1,142 @@
+package pacemaker
+
+import (
+	"context"
+	"encoding/xml"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+
+	"github.com/ClusterLabs/ha_cluster_exporter/collector"
+	"github.com/ClusterLabs/ha_cluster_exporter/collector/pacemaker/cib"
+)
+
+// the name of the CIB synchronization check loop in the heartbeat metric
+const cibSyncHeartbeatLoop = "cib_sync"
+
+// queries the CIB manager of each online cluster node for the version of its copy periodically in the background, and keeps the result of the last check,
+// because it costs an extra cibadmin call per node, which is too expensive to be done on each scrape
+type cibSyncChecker struct {
+	cibAdminPath string
+	logger       log.Logger
+	runner       *collector.BackgroundRunner
+
+	mutex sync.Mutex
+	// the nodes to query, as seen by the last scrape
+	nodes     []string
+	checked   bool
+	checkedAt time.Time
+	outOfSync int
+	unknown   int
+}
+
+// creates a cibSyncChecker and starts checking once per interval; the checks only query the nodes once a scrape has found them
+func newCibSyncChecker(cibAdminPath string, interval time.Duration, logger log.Logger) *cibSyncChecker {
+	s := &cibSyncChecker{
+		cibAdminPath: cibAdminPath,
+		logger:       logger,
+	}
+
+	s.runner = collector.RunInBackground(cibSyncHeartbeatLoop, interval, s.check)
+
+	return s
+}
+
+// stops checking the CIB copies; the result of the last check is kept
+func (s *cibSyncChecker) stop() {
+	s.runner.Stop()
+}
+
+// sets the nodes the next checks query
+func (s *cibSyncChecker) setNodes(nodes []string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.nodes = nodes
+}
+
+func (s *cibSyncChecker) check(ctx context.Context) {
+	s.mutex.Lock()
+	nodes := s.nodes
+	s.mutex.Unlock()
+	if len(nodes) == 0 {
+		return
+	}
+
+	checkedAt := time.Now()
+	versions := make(map[string]cibVersion)
+	unknown := 0
+	for _, node := range nodes {
+		version, err := s.queryCibVersion(ctx, node)
+		if err != nil {
+			level.Debug(s.logger).Log("msg", "Could not query the CIB version of node "+node, "err", err)
+			unknown++
+			continue
+		}
+		versions[node] = version
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.checked = true
+	s.checkedAt = checkedAt
+	s.outOfSync = len(cibOutOfSync(versions))
+	s.unknown = unknown
+}
+
+// returns the counts of the last check and when it started; ok is false until the first check completes
+func (s *cibSyncChecker) result() (outOfSync int, unknown int, checkedAt time.Time, ok bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.outOfSync, s.unknown, s.checkedAt, s.checked
+}
+
+// only the attributes of the root element are queried, instead of the whole CIB
+func (s *cibSyncChecker) queryCibVersion(ctx context.Context, node string) (cibVersion, error) {
+	var version cibVersion
+
+	output, err := collector.CommandContext(ctx, s.cibAdminPath, "--query", "--node", node, "--xpath", "/cib", "--no-children").Output()
+	if err != nil {
+		return version, errors.Wrap(err, "error while executing cibadmin")
+	}
+
+	var CIB cib.Root
+	err = xml.Unmarshal(output, &CIB)
+	if err != nil {
+		return version, errors.Wrap(err, "could not parse cibadmin output from XML")
+	}
+
+	version.adminEpoch, err = strconv.Atoi(CIB.AdminEpoch)
+	if err != nil {
+		return version, errors.Wrap(err, "could not parse admin_epoch")
+	}
+	version.epoch, err = strconv.Atoi(CIB.Epoch)
+	if err != nil {
+		return version, errors.Wrap(err, "could not parse epoch")
+	}
+
+	return version, nil
+}
+
+// returns the nodes whose CIB version is older than the newest one
+func cibOutOfSync(versions map[string]cibVersion) []string {
+	var newest cibVersion
+	for _, version := range versions {
+		if newest.olderThan(version) {
+			newest = version
+		}
+	}
+
+	var outOfSync []string
+	for node, version := range versions {
+		if version.olderThan(newest) {
+			outOfSync = append(outOfSync, node)
+		}
+	}
+	sort.Strings(outOfSync)
+	return outOfSync
+}
@@ -5,9 +5,19 @@ package crmmon
 type Root struct {
 	Version string `xml:"version,attr"`
 	Summary struct {
+		// the Designated Controller, which coordinates the cluster; there's none while the cluster is electing one
+		CurrentDc struct {
+			Present bool   `xml:"present,attr"`
+			Version string `xml:"version,attr"`
+			Name    string `xml:"name,attr"`
+			Id      string `xml:"id,attr"`
+		} `xml:"current_dc"`
 		Nodes struct {
 			Number int `xml:"number,attr"`
 		} `xml:"nodes_configured"`
+		LastUpdate struct {
+			Time string `xml:"time,attr"`
+		} `xml:"last_update"`
 		LastChange struct {
 			Time string `xml:"time,attr"`
 		} `xml:"last_change"`
@@ -17,7 +27,9 @@ type Root struct {
 			Blocked  int `xml:"blocked,attr"`
 		} `xml:"resources_configured"`
 		ClusterOptions struct {
-			StonithEnabled bool `xml:"stonith-enabled,attr"`
+			StonithEnabled  bool `xml:"stonith-enabled,attr"`
+			HaveWatchdog    bool `xml:"have-watchdog,attr"`
+			MaintenanceMode bool `xml:"maintenance-mode,attr"`
 		} `xml:"cluster_options"`
 	} `xml:"summary"`
 	Nodes          []Node `xml:"nodes>node"`
@@ -37,12 +49,22 @@ type Root struct {
 				Name               string `xml:"id,attr"`
 				MigrationThreshold int    `xml:"migration-threshold,attr"`
 				FailCount          int    `xml:"fail-count,attr"`
+				Operations         []struct {
+					Call         int    `xml:"call,attr"`
+					Task         string `xml:"task,attr"`
+					Interval     string `xml:"interval,attr"`
+					LastRcChange string `xml:"last-rc-change,attr"`
+					LastRun      string `xml:"last-run,attr"`
+					Rc           int    `xml:"rc,attr"`
+				} `xml:"operation_history"`
 			} `xml:"resource_history"`
 		} `xml:"node"`
 	} `xml:"node_history"`
-	Resources []Resource `xml:"resources>resource"`
-	Clones    []Clone    `xml:"resources>clone"`
-	Groups    []Group    `xml:"resources>group"`
+	// only failed and pending actions are listed, unless the parser asks for the full fence history
+	FenceHistory []FenceEvent `xml:"fence_history>fence_event"`
+	Resources    []Resource   `xml:"resources>resource"`
+	Clones       []Clone      `xml:"resources>clone"`
+	Groups       []Group      `xml:"resources>group"`
 }
 
 type Node struct {
@@ -59,6 +81,8 @@ type Node struct {
 	DC               bool   `xml:"is_dc,attr"`
 	ResourcesRunning int    `xml:"resources_running,attr"`
 	Type             string `xml:"type,attr"`
+	// only set on guest nodes, which are remote nodes too, to the resource of the container or VM the node runs in
+	IdAsResource string `xml:"id_as_resource,attr"`
 }
 
 type Resource struct {
@@ -72,6 +96,7 @@ type Resource struct {
 	Failed         bool   `xml:"failed,attr"`
 	FailureIgnored bool   `xml:"failure_ignored,attr"`
 	NodesRunningOn int    `xml:"nodes_running_on,attr"`
+	Pending        string `xml:"pending,attr"`
 	Node           *struct {
 		Name   string `xml:"name,attr"`
 		Id     string `xml:"id,attr"`
@@ -87,9 +112,21 @@ type Clone struct {
 	FailureIgnored bool       `xml:"failure_ignored,attr"`
 	Unique         bool       `xml:"unique,attr"`
 	Resources      []Resource `xml:"resource"`
+	// only set on clones of groups, with an instance of the group for each instance of the clone, e.g. `grp:0`
+	Groups []Group `xml:"group"`
 }
 
 type Group struct {
 	Id        string     `xml:"id,attr"`
 	Resources []Resource `xml:"resource"`
 }
+
+type FenceEvent struct {
+	Target   string `xml:"target,attr"`
+	Action   string `xml:"action,attr"`
+	Status   string `xml:"status,attr"`
+	Delegate string `xml:"delegate,attr"`
+	Origin   string `xml:"origin,attr"`
+	// only set once the action is over
+	Completed string `xml:"completed,attr"`
+}
@@ -2,21 +2,48 @@ package crmmon
 
 import (
 	"encoding/xml"
-	"os/exec"
+	"sync"
 
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
 	"github.com/pkg/errors"
+
+	"github.com/ClusterLabs/ha_cluster_exporter/collector"
 )
 
+// the crm_mon arguments
+var Args = []string{"-X", "--inactive"}
+
+// the fence history level 2 adds the successful actions to the failed and pending ones; crm_mon only accepts it since Pacemaker 2.0
+const fullFenceHistoryArg = "--fence-history=2"
+
 type Parser interface {
 	Parse() (Root, error)
+	Args() []string
 }
 
 type crmMonParser struct {
 	crmMonPath string
+	logger     log.Logger
+
+	// whether to ask for the full fence history; it's turned off as soon as crm_mon turns out not to support it
+	fenceHistoryMutex sync.Mutex
+	fullFenceHistory  bool
 }
 
 func (c *crmMonParser) Parse() (crmMon Root, err error) {
-	crmMonXML, err := exec.Command(c.crmMonPath, "-X", "--inactive").Output()
+	args := c.Args()
+	crmMonXML, err := collector.Command(c.crmMonPath, args...).Output()
+	if err != nil && len(args) > len(Args) && !collector.IsRemoteFailure(err) {
+		// older crm_mon versions reject the option as a whole, so we try again without it
+		var retryErr error
+		crmMonXML, retryErr = collector.Command(c.crmMonPath, Args...).Output()
+		if retryErr == nil {
+			level.Warn(c.logger).Log("msg", "crm_mon doesn't support the full fence history, which requires Pacemaker 2.0 or later; it won't be asked for anymore", "err", err)
+			c.disableFullFenceHistory()
+			err = nil
+		}
+	}
 	if err != nil {
 		return crmMon, errors.Wrap(err, "error while executing crm_mon")
 	}
@@ -29,6 +56,23 @@ func (c *crmMonParser) Parse() (crmMon Root, err error) {
 	return crmMon, nil
 }
 
-func NewCrmMonParser(crmMonPath string) *crmMonParser {
-	return &crmMonParser{crmMonPath}
+// returns the arguments crm_mon is run with
+func (c *crmMonParser) Args() []string {
+	c.fenceHistoryMutex.Lock()
+	defer c.fenceHistoryMutex.Unlock()
+	if !c.fullFenceHistory {
+		return Args
+	}
+	return append(append([]string{}, Args...), fullFenceHistoryArg)
+}
+
+func (c *crmMonParser) disableFullFenceHistory() {
+	c.fenceHistoryMutex.Lock()
+	defer c.fenceHistoryMutex.Unlock()
+	c.fullFenceHistory = false
+}
+
+// if fullFenceHistory is true, the fence history also lists the successful actions, as long as crm_mon supports it
+func NewCrmMonParser(crmMonPath string, fullFenceHistory bool, logger log.Logger) *crmMonParser {
+	return &crmMonParser{crmMonPath: crmMonPath, logger: logger, fullFenceHistory: fullFenceHistory}
 }
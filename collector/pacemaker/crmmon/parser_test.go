@@ -1,26 +1,35 @@
 package crmmon
 
 import (
+	"bytes"
+	"strings"
 	"testing"
 
+	"github.com/go-kit/log"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestConstructor(t *testing.T) {
-	p := NewCrmMonParser("foo")
+	p := NewCrmMonParser("foo", false, log.NewNopLogger())
 	assert.Equal(t, "foo", p.crmMonPath)
 }
 
 func TestParse(t *testing.T) {
-	p := NewCrmMonParser("../../../test/fake_crm_mon.sh")
+	p := NewCrmMonParser("../../../test/fake_crm_mon.sh", false, log.NewNopLogger())
 	data, err := p.Parse()
 	assert.NoError(t, err)
 	assert.Equal(t, "2.0.0", data.Version)
 	assert.Equal(t, 8, data.Summary.Resources.Number)
 	assert.Equal(t, 1, data.Summary.Resources.Disabled)
 	assert.Equal(t, 0, data.Summary.Resources.Blocked)
+	assert.Equal(t, "Fri Oct 18 11:48:54 2019", data.Summary.LastUpdate.Time)
+	assert.True(t, data.Summary.CurrentDc.Present)
+	assert.Equal(t, "node01", data.Summary.CurrentDc.Name)
+	assert.Equal(t, "1.1.18+20180430.b12c320f5-3.15.1-b12c320f5", data.Summary.CurrentDc.Version)
 	assert.Equal(t, "Fri Oct 18 11:48:22 2019", data.Summary.LastChange.Time)
 	assert.Equal(t, 2, data.Summary.Nodes.Number)
+	assert.Equal(t, true, data.Summary.ClusterOptions.StonithEnabled)
+	assert.Equal(t, true, data.Summary.ClusterOptions.HaveWatchdog)
 	assert.Equal(t, "node01", data.Nodes[0].Name)
 	assert.Equal(t, "1084783375", data.Nodes[0].Id)
 	assert.Equal(t, true, data.Nodes[0].Online)
@@ -47,14 +56,46 @@ func TestParse(t *testing.T) {
 	assert.Equal(t, 5000, data.NodeHistory.Nodes[0].ResourceHistory[0].MigrationThreshold)
 	assert.Equal(t, 2, data.NodeHistory.Nodes[0].ResourceHistory[1].FailCount)
 	assert.Equal(t, "rsc_SAPHana_PRD_HDB00", data.NodeHistory.Nodes[0].ResourceHistory[0].Name)
+	assert.Len(t, data.NodeHistory.Nodes[0].ResourceHistory[0].Operations, 3)
+	assert.Equal(t, 32, data.NodeHistory.Nodes[0].ResourceHistory[0].Operations[2].Call)
+	assert.Equal(t, "monitor", data.NodeHistory.Nodes[0].ResourceHistory[0].Operations[2].Task)
+	assert.Equal(t, "Thu Oct 10 12:58:03 2019", data.NodeHistory.Nodes[0].ResourceHistory[0].Operations[2].LastRcChange)
+	assert.Equal(t, "60000ms", data.NodeHistory.Nodes[0].ResourceHistory[0].Operations[2].Interval)
+	assert.Equal(t, "", data.NodeHistory.Nodes[0].ResourceHistory[0].Operations[2].LastRun)
+	assert.Equal(t, "Thu Oct 10 12:57:32 2019", data.NodeHistory.Nodes[0].ResourceHistory[1].Operations[1].LastRun)
+	assert.Equal(t, 8, data.NodeHistory.Nodes[0].ResourceHistory[0].Operations[2].Rc)
 	assert.Equal(t, 4, len(data.Resources))
 	assert.Equal(t, "test-stop", data.Resources[0].Id)
 	assert.Equal(t, false, data.Resources[0].Active)
 	assert.Equal(t, "Stopped", data.Resources[0].Role)
 }
 
+func TestParseFullFenceHistory(t *testing.T) {
+	assert.Equal(t, []string{"-X", "--inactive"}, NewCrmMonParser("../../../test/fake_crm_mon.sh", false, log.NewNopLogger()).Args())
+
+	p := NewCrmMonParser("../../../test/fake_crm_mon.sh", true, log.NewNopLogger())
+	assert.Equal(t, []string{"-X", "--inactive", "--fence-history=2"}, p.Args())
+	_, err := p.Parse()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"-X", "--inactive", "--fence-history=2"}, p.Args())
+}
+
+func TestParseFullFenceHistoryUnsupported(t *testing.T) {
+	var logs bytes.Buffer
+	p := NewCrmMonParser("../../../test/fake_crm_mon_pacemaker1.sh", true, log.NewLogfmtLogger(&logs))
+
+	// the option is dropped, and the warning logged, only once
+	for i := 0; i < 2; i++ {
+		data, err := p.Parse()
+		assert.NoError(t, err)
+		assert.Equal(t, "2.0.0", data.Version)
+	}
+	assert.Equal(t, []string{"-X", "--inactive"}, p.Args())
+	assert.Equal(t, 1, strings.Count(logs.String(), "doesn't support the full fence history"))
+}
+
 func TestParseClones(t *testing.T) {
-	p := NewCrmMonParser("../../../test/fake_crm_mon.sh")
+	p := NewCrmMonParser("../../../test/fake_crm_mon.sh", false, log.NewNopLogger())
 	data, err := p.Parse()
 	assert.NoError(t, err)
 	assert.Equal(t, 3, len(data.Clones))
@@ -67,10 +108,12 @@ func TestParseClones(t *testing.T) {
 	assert.Equal(t, "Master", data.Clones[0].Resources[0].Role)
 	assert.Equal(t, "rsc_SAPHana_PRD_HDB00", data.Clones[0].Resources[1].Id)
 	assert.Equal(t, "Slave", data.Clones[0].Resources[1].Role)
+	assert.Equal(t, "", data.Clones[0].Resources[0].Pending)
+	assert.Equal(t, "Monitoring", data.Clones[0].Resources[1].Pending)
 }
 
 func TestParseGroups(t *testing.T) {
-	p := NewCrmMonParser("../../../test/fake_crm_mon.sh")
+	p := NewCrmMonParser("../../../test/fake_crm_mon.sh", false, log.NewNopLogger())
 	data, err := p.Parse()
 	assert.NoError(t, err)
 	assert.Equal(t, 2, len(data.Groups))
@@ -89,7 +132,7 @@ func TestParseGroups(t *testing.T) {
 }
 
 func TestParseNodeAttributes(t *testing.T) {
-	p := NewCrmMonParser("../../../test/fake_crm_mon.sh")
+	p := NewCrmMonParser("../../../test/fake_crm_mon.sh", false, log.NewNopLogger())
 	data, err := p.Parse()
 	assert.NoError(t, err)
 	assert.Len(t, data.NodeAttributes.Nodes, 2)
@@ -146,3 +189,13 @@ func TestParseNodeAttributes(t *testing.T) {
 	assert.Equal(t, "30", data.NodeAttributes.Nodes[1].Attributes[9].Value)
 	assert.Equal(t, "100", data.NodeAttributes.Nodes[1].Attributes[10].Value)
 }
+
+func TestParseFenceHistory(t *testing.T) {
+	p := NewCrmMonParser("../../../test/fake_crm_mon.sh", false, log.NewNopLogger())
+	data, err := p.Parse()
+	assert.NoError(t, err)
+	assert.Len(t, data.FenceHistory, 2)
+	assert.Equal(t, FenceEvent{Target: "node02", Action: "reboot", Status: "pending", Delegate: "node01", Origin: "node01"}, data.FenceHistory[0])
+	assert.Equal(t, "failed", data.FenceHistory[1].Status)
+	assert.Equal(t, "2019-10-18 11:02:42Z", data.FenceHistory[1].Completed)
+}
@@ -0,0 +1,139 @@
+package pacemaker
+
+import (
+	"math"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/ClusterLabs/ha_cluster_exporter/collector/pacemaker/cib"
+	"github.com/ClusterLabs/ha_cluster_exporter/collector/pacemaker/crmmon"
+)
+
+// a resource that can't start because a resource it depends on via a mandatory constraint is not running
+type blockedDependency struct {
+	resource   string
+	dependency string
+	constraint string
+}
+
+// a resource blocked by several dependencies has one line for each of them, but it's only counted once
+func (c *pacemakerCollector) recordDependencyBlocked(crmMon crmmon.Root, CIB cib.Root, ch chan<- prometheus.Metric) {
+	blocked := make(map[string]bool)
+	for _, b := range dependencyBlocked(crmMon, CIB) {
+		ch <- c.MakeGaugeMetric("resource_dependency_blocked", 1, b.resource, b.dependency, b.constraint)
+		blocked[b.resource] = true
+	}
+	ch <- c.MakeGaugeMetric("resources_dependency_blocked", float64(len(blocked)))
+}
+
+// returns the resources that should run but don't, and have a mandatory ordering or colocation dependency that isn't running either;
+// the disabled and failed resources are left out, since they are stopped for another reason.
+// Only whether the resources are running is taken into account, not their role, so a dependency on a promoted instance is satisfied by any running one.
+func dependencyBlocked(crmMon crmmon.Root, CIB cib.Root) []blockedDependency {
+	running, failed := resourcesRunning(crmMon)
+	disabled := resourcesDisabled(CIB)
+
+	var blocked []blockedDependency
+	record := func(resource string, dependency string, constraint string) {
+		isDisabled, configured := disabled[resource]
+		if !configured || isDisabled || running[resource] || failed[resource] || running[dependency] {
+			return
+		}
+		blocked = append(blocked, blockedDependency{resource, dependency, constraint})
+	}
+
+	constraints := CIB.Configuration.Constraints
+	for _, order := range constraints.RscOrders {
+		if order.Kind == "Mandatory" || (order.Kind == "" && order.Score != "0") {
+			record(order.Then, order.First, order.Id)
+		}
+	}
+	for _, colocation := range constraints.RscColocations {
+		if score, err := parseScore(colocation.Score); err == nil && math.IsInf(score, 1) {
+			record(colocation.Resource, colocation.WithResource, colocation.Id)
+		}
+	}
+	return blocked
+}
+
+// returns which primitives, groups and clones are running and which ones failed, as per crm_mon;
+// a group is running only if all its members are, while a clone is as soon as any of its instances is
+func resourcesRunning(crmMon crmmon.Root) (running map[string]bool, failed map[string]bool) {
+	running = make(map[string]bool)
+	failed = make(map[string]bool)
+	// the instances of unique clones and of cloned groups count for their configured id too
+	mark := func(id string, isRunning bool, isFailed bool) {
+		for _, id := range []string{id, baseResourceId(id)} {
+			running[id] = running[id] || isRunning
+			failed[id] = failed[id] || isFailed
+		}
+	}
+	record := func(resource crmmon.Resource) (isRunning bool, isFailed bool) {
+		isRunning = resource.Active && resource.Role != "Stopped"
+		isFailed = isResourceFailed(resource)
+		mark(resource.Id, isRunning, isFailed)
+		return isRunning, isFailed
+	}
+	recordGroup := func(group crmmon.Group) (allRunning bool, anyFailed bool) {
+		allRunning = len(group.Resources) > 0
+		for _, resource := range group.Resources {
+			isRunning, isFailed := record(resource)
+			allRunning = allRunning && isRunning
+			anyFailed = anyFailed || isFailed
+		}
+		mark(group.Id, allRunning, anyFailed)
+		return allRunning, anyFailed
+	}
+
+	for _, resource := range crmMon.Resources {
+		record(resource)
+	}
+	for _, group := range crmMon.Groups {
+		recordGroup(group)
+	}
+	for _, clone := range crmMon.Clones {
+		var anyRunning, anyFailed bool
+		for _, resource := range clone.Resources {
+			isRunning, isFailed := record(resource)
+			anyRunning = anyRunning || isRunning
+			anyFailed = anyFailed || isFailed
+		}
+		for _, group := range clone.Groups {
+			isRunning, isFailed := recordGroup(group)
+			anyRunning = anyRunning || isRunning
+			anyFailed = anyFailed || isFailed
+		}
+		mark(clone.Id, anyRunning, anyFailed)
+	}
+	return running, failed
+}
+
+// returns whether each configured primitive, group and clone is disabled, i.e. its target-role is Stopped,
+// either on its own or on its parent group or clone
+func resourcesDisabled(CIB cib.Root) map[string]bool {
+	resources := make(map[string]bool)
+	// a parent being stopped stops all of its members, whatever their own target-role, so every level is checked
+	isDisabled := func(metaAttributes []cib.Attribute) bool {
+		for _, attribute := range metaAttributes {
+			if attribute.Name == "target-role" && strings.EqualFold(attribute.Value, "Stopped") {
+				return true
+			}
+		}
+		return false
+	}
+
+	forEachPrimitive(CIB, func(primitive cib.Primitive, parentMetaAttributes []cib.Attribute, _ bool) {
+		resources[primitive.Id] = isDisabled(primitive.MetaAttributes) || isDisabled(parentMetaAttributes)
+	})
+	for _, clone := range append(CIB.Configuration.Resources.Clones, CIB.Configuration.Resources.Masters...) {
+		resources[clone.Id] = isDisabled(clone.MetaAttributes)
+		if clone.Group != nil {
+			resources[clone.Group.Id] = resources[clone.Id] || isDisabled(clone.Group.MetaAttributes)
+		}
+	}
+	for _, group := range CIB.Configuration.Resources.Groups {
+		resources[group.Id] = isDisabled(group.MetaAttributes)
+	}
+	return resources
+}
@@ -1,10 +1,16 @@
 package pacemaker
 
 import (
+	"context"
+	"io"
 	"math"
+	"os"
+	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
+	"unicode"
 
 	"github.com/ClusterLabs/ha_cluster_exporter/collector"
 	"github.com/ClusterLabs/ha_cluster_exporter/collector/pacemaker/cib"
@@ -14,29 +20,156 @@ import (
 	"github.com/go-kit/log/level"
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
 )
 
 const subsystem = "pacemaker"
 
-func NewCollector(crmMonPath string, cibAdminPath string, timestamps bool, logger log.Logger) (*pacemakerCollector, error) {
+// Options are the optional features of the pacemaker collector; the zero value disables all of them
+type Options struct {
+	// the configuration is checked with crm_verify only if CrmVerifyInterval is greater than 0; if crm_verify can't be found, the check is skipped
+	CrmVerifyPath     string
+	CrmVerifyInterval time.Duration
+	// the same goes for the resource placement scores, which are computed with crm_simulate every CrmSimulateInterval
+	CrmSimulatePath     string
+	CrmSimulateInterval time.Duration
+	// the copies of the CIB held by the nodes are compared every CibSyncInterval, if greater than 0
+	CibSyncInterval time.Duration
+	// whether the pacemaker services are enabled at boot is checked only if Services is not nil
+	Services *collector.ServiceChecker
+	// if LocalNode is not empty, the metrics with a node label are only recorded for that node, see includesNode
+	LocalNode string
+	// if ResourceTypeLabel is true, the metrics with a resource label also have a type one, see SetDescriptor
+	ResourceTypeLabel bool
+	// if FullFenceHistory is true, crm_mon is asked for the successful fencing actions too, which older versions don't support
+	FullFenceHistory bool
+}
+
+// NewCollector creates a new pacemaker collector, with the optional features enabled by options
+func NewCollector(crmMonPath string, cibAdminPath string, options Options, timestamps bool, logger log.Logger) (*pacemakerCollector, error) {
 	err := collector.CheckExecutables(crmMonPath, cibAdminPath)
 	if err != nil {
 		return nil, errors.Wrapf(err, "could not initialize '%s' collector", subsystem)
 	}
 
 	c := &pacemakerCollector{
-		collector.NewDefaultCollector(subsystem, timestamps, logger),
-		crmmon.NewCrmMonParser(crmMonPath),
-		cib.NewCibAdminParser(cibAdminPath),
+		DefaultCollector: collector.NewDefaultCollector(subsystem, timestamps, logger),
+		crmMonParser:     crmmon.NewCrmMonParser(crmMonPath, options.FullFenceHistory, logger),
+		cibParser:        cib.NewCibAdminParser(cibAdminPath),
+		crmMonPath:       crmMonPath,
+		cibAdminPath:     cibAdminPath,
+		localNode:        options.LocalNode,
+		services:         options.Services,
+		lastNodeStates:   make(map[string]string),
+		nodeTransitions:  make(map[string]map[string]float64),
+		resourceStates:   newResourceStates(),
+		toolVersion:      collector.NewToolVersion("Pacemaker", supportedVersions),
+	}
+	if options.ResourceTypeLabel {
+		c.resourceTypes = newResourceTypes()
+	}
+
+	if options.CrmVerifyInterval > 0 {
+		if err := collector.CheckExecutables(options.CrmVerifyPath); err != nil {
+			level.Warn(logger).Log("msg", "The cluster configuration won't be verified", "err", err)
+		} else {
+			c.configVerifier = newConfigVerifier(options.CrmVerifyPath, options.CrmVerifyInterval, logger)
+		}
+	}
+
+	if options.CrmSimulateInterval > 0 {
+		if err := collector.CheckExecutables(options.CrmSimulatePath); err != nil {
+			level.Warn(logger).Log("msg", "The resource placement scores won't be computed", "err", err)
+		} else {
+			c.placementScorer = newPlacementScorer(options.CrmSimulatePath, options.CrmSimulateInterval, logger)
+		}
+	}
+
+	if options.CibSyncInterval > 0 {
+		c.cibSyncChecker = newCibSyncChecker(cibAdminPath, options.CibSyncInterval, logger)
+	}
+
+	c.cibFileVariable = cibFileVariable()
+	if c.cibFileVariable != "" {
+		level.Warn(logger).Log("msg", "The Pacemaker tools will read a CIB file instead of the live cluster, so the metrics won't reflect the cluster status", "variable", c.cibFileVariable, "value", os.Getenv(c.cibFileVariable))
 	}
+
 	c.SetDescriptor("nodes", "The status of each node in the cluster; 1 means the node is in that status, 0 otherwise", []string{"node", "type", "status"})
+	c.SetDescriptor("node_standby", "Whether a node is in standby, and why; 1 means the node is in standby, 0 otherwise", []string{"node", "reason"})
+	c.SetDescriptor("node_standby_expiry_timestamp_seconds", "The time the timed standby of each node expires, as per the rules of its standby attribute", []string{"node"})
+	c.SetDescriptor("node_in_ccm", "Whether each node is part of the cluster membership, as per the CIB status; 1 means member, 0 otherwise", []string{"node"})
+	c.SetDescriptor("node_crmd_joined", "Whether the controller of each node is online and joined the cluster, as per the CIB status; 1 means joined, 0 otherwise", []string{"node"})
+	c.SetDescriptor("node_state", "The current state of each node in the cluster; value is always 1", []string{"node", "type", "state"})
+	c.SetDescriptor("node_transitions_total", "The number of times each node joined or left the cluster since the exporter started, by the state it transitioned to", []string{"node", "to_state"})
 	c.SetDescriptor("node_attributes", "Metadata attributes of each node; value is always 1", []string{"node", "name", "value"})
 	c.SetDescriptor("resources", "The status of each resource in the cluster; 1 means the resource is in that status, 0 otherwise", []string{"node", "resource", "role", "managed", "status", "agent", "group", "clone"})
+	c.SetDescriptor("resource_state", "The current state of each resource; value is always 1", []string{"resource", "state"})
+	c.SetDescriptor("resource_state_since_timestamp_seconds", "The time each resource entered its current state, as seen by the exporter", []string{"resource"})
+	c.SetDescriptor("resource_orphaned", "Whether a resource is orphaned, i.e. still active but no longer in the configuration; 1 means orphaned, 0 otherwise", []string{"node", "resource"})
+	c.SetDescriptor("resource_failed", "Whether a resource failed on the node it's on and is waiting for the cluster to recover it; 1 means failed, 0 otherwise", []string{"node", "resource"})
+	c.SetDescriptor("resource_failure_timeout_seconds", "The failure-timeout of each resource in seconds, after which its failures are expired; 0 means the failures never expire", []string{"resource"})
+	c.SetDescriptor("resource_stickiness", "The effective resource-stickiness of each primitive resource, i.e. how much it prefers to stay where it's running, including the values inherited from its parent and the resource defaults", []string{"resource"})
+	c.SetDescriptor("resource_monitor_interval_seconds", "The interval of the recurring monitor operation of each resource in seconds; 0 means the resource is not monitored", []string{"resource"})
+	c.SetDescriptor("resource_blocked", "Whether a resource is blocked, i.e. the cluster can't manage it anymore; 1 means blocked, 0 otherwise", []string{"node", "resource", "clone"})
+	c.SetDescriptor("resource_pending", "Whether a resource has a pending operation; 1 means an operation is in progress, 0 otherwise", []string{"node", "resource", "operation"})
+	c.SetDescriptor("operations_in_flight", "The number of resource operations currently being executed across the cluster", nil)
+	c.SetDescriptor("resources_dependency_blocked", "The number of resources that can't start because a resource they depend on via a mandatory ordering or colocation constraint is not running", nil)
+	c.SetDescriptor("resource_dependency_blocked", "The resources that can't start because of a mandatory ordering or colocation constraint, and the dependency that isn't running; value is always 1", []string{"resource", "dependency", "constraint"})
+	c.SetDescriptor("group_members", "The members of each resource group; the value is the position of the member in the group, starting from 1", []string{"group", "resource"})
+	c.SetDescriptor("group_running", "The number of members of each resource group that are currently running", []string{"group"})
+	c.SetDescriptor("live_connection", "Whether crm_mon got the status from the live cluster during the last scrape; 0 means it couldn't connect to it, or it read a CIB file instead", nil)
+	c.SetDescriptor("service_enabled", "Whether each Pacemaker systemd service is enabled to start at boot; 1 means enabled, 0 otherwise", []string{"service"})
+	c.SetDescriptor("dc_version", "The Pacemaker version of the Designated Controller and the CRM feature set of the cluster; value is always 1", []string{"node", "version", "feature_set"})
+	c.SetDescriptor("tool_version_supported", "Whether the collector is known to parse the output of the running Pacemaker version correctly; 1 means supported, 0 otherwise", []string{"version"})
 	c.SetDescriptor("stonith_enabled", "Whether or not stonith is enabled", nil)
+	c.SetDescriptor("stonith_devices_configured", "The number of fencing devices configured in the cluster", nil)
+	c.SetDescriptor("stonith_devices_active", "The number of configured fencing devices that are currently active", nil)
+	c.SetDescriptor("stonith_timeout_seconds", "The stonith-timeout of the cluster in seconds, i.e. how long to wait for a fencing action to complete", nil)
+	c.SetDescriptor("stonith_watchdog_timeout_seconds", "The stonith-watchdog-timeout of the cluster in seconds, i.e. how long to wait before assuming a node was fenced by its watchdog; 0 means disabled, negative values mean derived from the SBD watchdog timeout", nil)
+	c.SetDescriptor("fencing_levels", "The number of fencing topology levels that apply to each node; 0 means the node relies on the fencing devices that can fence it, without a topology", []string{"node"})
+	c.SetDescriptor("stonith_device_timeout_seconds", "The timeouts of the fencing actions configured on each fencing device in seconds, which override the stonith-timeout", []string{"device", "action"})
+	c.SetDescriptor("fence_in_progress", "The nodes a fencing action is currently pending against; value is always 1", []string{"target"})
+	c.SetDescriptor("last_fence_age_seconds", "How long ago the most recent successful fencing action completed, in seconds", nil)
+	c.SetDescriptor("have_watchdog", "Whether or not Pacemaker detected a watchdog device for fencing", nil)
+	c.SetDescriptor("cluster_recheck_interval_seconds", "The cluster-recheck-interval in seconds, i.e. how often the scheduler re-evaluates time-based rules and failure expiration; 0 means disabled", nil)
+	c.SetDescriptor("batch_limit", "The maximum number of actions the cluster runs in parallel; 0 means the limit is computed dynamically from the load of the nodes", nil)
+	c.SetDescriptor("migration_limit", "The maximum number of live migrations the cluster runs in parallel on each node; -1 means unlimited", nil)
+	c.SetDescriptor("symmetric_cluster", "Whether resources can run on any node by default; 0 means they can only run where explicitly allowed by location constraints", nil)
+	c.SetDescriptor("start_failure_is_fatal", "Whether a single start failure bans a resource from a node, instead of counting towards its migration-threshold", nil)
+	c.SetDescriptor("no_quorum_policy", "The policy the cluster applies when it loses quorum; 1 means the policy is in effect, 0 otherwise", []string{"policy"})
+	c.SetDescriptor("clone_unique", "Whether a clone is globally unique, i.e. its instances are not interchangeable; 1 means unique, 0 means anonymous", []string{"clone"})
+	c.SetDescriptor("clone_promotable", "Whether the instances of a clone can be promoted; 1 means promotable, 0 otherwise", []string{"clone"})
+	c.SetDescriptor("clone_max", "The maximum number of instances of a clone that can run in the whole cluster", []string{"clone"})
+	c.SetDescriptor("clone_node_max", "The maximum number of instances of a clone that can run on a single node", []string{"clone"})
+	c.SetDescriptor("clone_promoted_max", "The maximum number of instances of a promotable clone that can be promoted at the same time", []string{"clone"})
+	c.SetDescriptor("clone_running", "The number of instances of a clone that are currently running", []string{"clone"})
+	c.SetDescriptor("clone_promoted", "The number of instances of a promotable clone that are currently promoted", []string{"clone"})
+	c.SetDescriptor("resource_promoted_on", "The nodes the promoted instances of each promotable clone are running on; value is always 1", []string{"resource", "node"})
 	c.SetDescriptor("fail_count", "The Fail count number per node and resource id", []string{"node", "resource"})
+	c.SetDescriptor("resources_needing_cleanup", "The number of resources that failed on any node and have no failure-timeout, so their failures will never expire without a cleanup", nil)
+	c.SetDescriptor("resource_last_op_rc", "The return code of the last operation run on each resource, per node", []string{"node", "resource", "operation", "rc_text"})
+	c.SetDescriptor("resource_running_node", "The node each primitive resource is currently running on; value is always 1", []string{"resource", "node"})
+	c.SetDescriptor("resource_allocated_node", "The node each running primitive resource is allocated to, which differs from the running one while a live migration is in progress; value is always 1", []string{"resource", "node"})
+	c.SetDescriptor("resource_last_run_timestamp_seconds", "The timestamp of the most recent recorded run of each operation of each resource, on any node", []string{"resource", "operation", "interval"})
+	c.SetDescriptor("resource_op_drift_seconds", "How far the last run of each anchored recurring operation was from its schedule, in seconds; negative values mean it ran early", []string{"node", "resource", "operation"})
+	c.SetDescriptor("resource_placement_score", "The allocation score of each primitive resource on each node, as computed by crm_simulate during the last run", []string{"resource", "node"})
 	c.SetDescriptor("migration_threshold", "The migration_threshold number per node and resource id", []string{"node", "resource"})
+	c.SetDescriptor("config_errors", "The number of errors crm_verify found in the cluster configuration during the last check", nil)
+	c.SetDescriptor("config_warnings", "The number of warnings crm_verify found in the cluster configuration during the last check", nil)
+	c.SetDescriptor("nodes_cib_out_of_sync", "The number of online nodes whose copy of the CIB holds an older configuration version than the newest one in the cluster, as of the last check", nil)
+	c.SetDescriptor("nodes_cib_unknown", "The number of online nodes whose copy of the CIB could not be queried during the last check", nil)
 	c.SetDescriptor("config_last_change", "The timestamp of the last change of the cluster configuration", nil)
+	c.SetDescriptor("cib_updates_total", "The number of updates of the CIB, either to the configuration or to the status, since the exporter started", nil)
+	c.SetDescriptor("last_update_timestamp_seconds", "The timestamp of the last time the CIB was written, i.e. the cluster data was updated", nil)
+	c.SetDescriptor("last_lrm_refresh_timestamp_seconds", "The timestamp of the last time the resource operation history was refreshed, e.g. by a resource cleanup", nil)
+	c.SetDescriptor("status_freshness_timestamp_seconds", "The timestamp of the most recent change in the result of any resource operation", nil)
+	c.SetDescriptor("maintenance", "Whether the cluster, each node and each resource are in maintenance, including because of the cluster-wide maintenance mode; 1 means in maintenance, 0 otherwise", []string{"scope", "target"})
 	c.SetDescriptor("location_constraints", "Resource location constraints. The value indicates the score.", []string{"constraint", "node", "resource", "role"})
+	c.SetDescriptor("active_rules", "The number of time-based rules of location constraints that are currently in effect", nil)
+	c.SetDescriptor("active_rule", "The time-based rules of location constraints that are currently in effect; value is always 1", []string{"constraint", "resource", "rule"})
+
+	// these change with time or with the recurring monitors, even if nothing happens in the cluster
+	c.SetVolatile("last_fence_age_seconds", "resource_last_run_timestamp_seconds", "resource_op_drift_seconds", "status_freshness_timestamp_seconds")
 
 	return c, nil
 }
@@ -45,12 +178,66 @@ type pacemakerCollector struct {
 	collector.DefaultCollector
 	crmMonParser crmmon.Parser
 	cibParser    cib.Parser
+	crmMonPath   string
+	cibAdminPath string
+
+	// the only node the per-node metrics are recorded for; empty for all of them
+	localNode string
+	// warns once that the local node is not part of the cluster, in which case no per-node metric is recorded
+	localNodeWarning sync.Once
+
+	// nil when the metrics have no resource type label
+	resourceTypes *resourceTypes
+
+	// nil when the configuration is not verified
+	configVerifier *configVerifier
+
+	// nil when the placement scores are not computed
+	placementScorer *placementScorer
+
+	// nil when the copies of the CIB are not compared
+	cibSyncChecker *cibSyncChecker
+
+	// nil when the services are not checked
+	services *collector.ServiceChecker
+
+	// the environment variable that makes the Pacemaker tools read a CIB file instead of the live cluster; empty when none is set
+	cibFileVariable string
+
+	// the membership state of each node seen in the previous scrape, used to detect transitions across scrapes
+	nodeTransitionsMutex sync.Mutex
+	lastNodeStates       map[string]string
+	nodeTransitions      map[string]map[string]float64
+
+	resourceStates *resourceStates
+
+	// a format change in a new Pacemaker release could make the parsing go silently wrong, so unknown versions are flagged
+	toolVersion *collector.ToolVersion
+
+	// the error each invalid attribute was last warned about with, see warnInvalidAttribute
+	invalidAttributesMutex sync.Mutex
+	invalidAttributes      map[invalidAttribute]string
+
+	// the CIB version seen in the previous scrape, used to count the CIB updates across scrapes
+	cibUpdatesMutex sync.Mutex
+	lastCibVersion  *cibFullVersion
+	cibUpdates      float64
 }
 
 func (c *pacemakerCollector) CollectWithError(ch chan<- prometheus.Metric) error {
 	level.Debug(c.Logger).Log("msg", "Collecting pacemaker metrics...")
 
+	if c.localNode != "" {
+		var flush func()
+		ch, flush = c.localNodeSeries(ch)
+		defer flush()
+	}
+
 	crmMon, err := c.crmMonParser.Parse()
+	// this is recorded even when crm_mon fails, which is precisely when it drops
+	c.recordLiveConnection(err, ch)
+	// this too, since a node whose services are not enabled is typically found out when the cluster stack is down
+	c.services.Record(&c.DefaultCollector, ch)
 	if err != nil {
 		return errors.Wrap(err, "crm_mon parser error")
 	}
@@ -60,19 +247,67 @@ func (c *pacemakerCollector) CollectWithError(ch chan<- prometheus.Metric) error
 		return errors.Wrap(err, "cibadmin parser error")
 	}
 
+	if c.resourceTypes != nil {
+		c.resourceTypes.update(CIB)
+	}
+	c.checkLocalNode(crmMon)
+
+	c.toolVersion.Record(&c.DefaultCollector, ch, func() (string, error) { return crmMon.Version, nil })
+	c.recordDcVersion(crmMon, CIB, ch)
+	c.recordCibUpdates(CIB, ch)
 	c.recordStonithStatus(crmMon, ch)
+	c.recordWatchdogStatus(crmMon, ch)
+	c.recordNoQuorumPolicy(CIB, ch)
+	c.recordSymmetricCluster(CIB, ch)
+	c.recordStartFailureIsFatal(CIB, ch)
+	c.recordClusterRecheckInterval(CIB, ch)
+	c.recordSchedulerLimits(CIB, ch)
 	c.recordNodes(crmMon, ch)
+	c.recordNodeTransitions(crmMon, ch)
 	c.recordNodeAttributes(crmMon, ch)
+	c.recordNodeMembership(CIB, ch)
+	c.recordNodeStandby(crmMon, CIB, ch)
+	c.recordNodeStandbyExpiry(CIB, ch)
+	c.recordMaintenance(crmMon, CIB, ch)
 	c.recordResources(crmMon, ch)
+	c.recordResourceStates(crmMon, ch)
+	c.recordBlockedResources(crmMon, ch)
+	c.recordOperationsInFlight(crmMon, ch)
+	c.recordDependencyBlocked(crmMon, CIB, ch)
+	c.recordGroups(crmMon, ch)
+	c.recordClones(crmMon, CIB, ch)
 	c.recordFailCounts(crmMon, ch)
+	failureTimeouts := c.resourcesFailureTimeout(CIB)
+	c.recordResourcesNeedingCleanup(crmMon, failureTimeouts, ch)
 	c.recordMigrationThresholds(crmMon, ch)
+	c.recordLastOperations(crmMon, ch)
+	c.recordStatusFreshness(crmMon, ch)
+	c.recordLastLrmRefresh(CIB, ch)
+	c.recordCibOutOfSync(crmMon, ch)
 	c.recordConstraints(CIB, ch)
+	c.recordActiveRules(CIB, ch)
+	c.recordStonithDevices(crmMon, CIB, ch)
+	c.recordFencingLevels(CIB, ch)
+	c.recordStonithTimeouts(CIB, ch)
+	c.recordStonithWatchdogTimeout(CIB, ch)
+	c.recordFenceInProgress(crmMon, ch)
+	c.recordLastFenceAge(crmMon, ch)
+	c.recordFailureTimeouts(failureTimeouts, ch)
+	c.recordStickiness(CIB, ch)
+	c.recordMonitorIntervals(CIB, ch)
+	c.recordOperationDrifts(crmMon, CIB, ch)
+	c.recordLastRuns(CIB, ch)
+	c.recordResourceNodes(crmMon, CIB, ch)
+	c.recordConfigVerification(ch)
+	c.recordPlacementScores(ch)
 
 	err = c.recordCibLastChange(crmMon, ch)
 	if err != nil {
 		return errors.Wrap(err, "could not record CIB last change")
 	}
 
+	c.recordLastUpdate(CIB, ch)
+
 	return nil
 }
 
@@ -85,6 +320,67 @@ func (c *pacemakerCollector) Collect(ch chan<- prometheus.Metric) {
 	}
 }
 
+// Stop stops the background checks of the collector, if any; their last results are still recorded
+func (c *pacemakerCollector) Stop() {
+	if c.configVerifier != nil {
+		c.configVerifier.stop()
+	}
+	if c.placementScorer != nil {
+		c.placementScorer.stop()
+	}
+	if c.cibSyncChecker != nil {
+		c.cibSyncChecker.stop()
+	}
+}
+
+func (c *pacemakerCollector) CollectRawOutput(ctx context.Context, w io.Writer) error {
+	err := collector.WriteCommandOutput(ctx, w, c.crmMonPath, c.crmMonParser.Args()...)
+	if err != nil {
+		return err
+	}
+	return collector.WriteCommandOutput(ctx, w, c.cibAdminPath, "--query", "--local")
+}
+
+// the environment variables that make the Pacemaker tools read a CIB file instead of connecting to the live cluster
+var cibFileVariables = []string{"CIB_file", "CIB_shadow"}
+
+// returns the first of the CIB file variables set for the exporter; the environment doesn't change while it runs, so this is only checked once.
+// the remote commands don't inherit the environment of the exporter, so they always connect to the live cluster
+func cibFileVariable() string {
+	if collector.IsRemote() {
+		return ""
+	}
+	for _, variable := range cibFileVariables {
+		if os.Getenv(variable) != "" {
+			return variable
+		}
+	}
+	return ""
+}
+
+// crm_mon can't connect to the cluster while Pacemaker is (re)starting, which explains the transient gaps in the other metrics
+func (c *pacemakerCollector) recordLiveConnection(crmMonErr error, ch chan<- prometheus.Metric) {
+	var live float64
+	if crmMonErr == nil && c.cibFileVariable == "" {
+		live = 1
+	}
+
+	ch <- c.MakeGaugeMetric("live_connection", live)
+}
+
+// the Pacemaker versions whose crm_mon and cibadmin output the collector is known to parse correctly
+var supportedVersions = collector.VersionRange{Min: "1.1.18", Max: "3"}
+
+// the nodes are upgraded one at a time, so the cluster runs mixed versions while an upgrade is in progress;
+// the DC sets the feature set of the whole cluster, and the nodes older than it can't join anymore
+func (c *pacemakerCollector) recordDcVersion(crmMon crmmon.Root, CIB cib.Root, ch chan<- prometheus.Metric) {
+	dc := crmMon.Summary.CurrentDc
+	if !dc.Present {
+		return
+	}
+	ch <- c.MakeGaugeMetric("dc_version", 1, dc.Name, dc.Version, CIB.CrmFeatureSet)
+}
+
 func (c *pacemakerCollector) recordStonithStatus(crmMon crmmon.Root, ch chan<- prometheus.Metric) {
 	var stonithEnabled float64
 	if crmMon.Summary.ClusterOptions.StonithEnabled {
@@ -94,6 +390,110 @@ func (c *pacemakerCollector) recordStonithStatus(crmMon crmmon.Root, ch chan<- p
 	ch <- c.MakeGaugeMetric("stonith_enabled", stonithEnabled)
 }
 
+func (c *pacemakerCollector) recordWatchdogStatus(crmMon crmmon.Root, ch chan<- prometheus.Metric) {
+	var haveWatchdog float64
+	if crmMon.Summary.ClusterOptions.HaveWatchdog {
+		haveWatchdog = 1
+	}
+
+	ch <- c.MakeGaugeMetric("have_watchdog", haveWatchdog)
+}
+
+// the no-quorum-policy values known to Pacemaker; "stop" is the default
+var noQuorumPolicies = []string{"stop", "freeze", "ignore", "demote", "suicide"}
+
+func (c *pacemakerCollector) recordNoQuorumPolicy(CIB cib.Root, ch chan<- prometheus.Metric) {
+	policy := noQuorumPolicy(CIB)
+
+	known := false
+	for _, p := range noQuorumPolicies {
+		var value float64
+		if p == policy {
+			value = 1
+			known = true
+		}
+		ch <- c.MakeGaugeMetric("no_quorum_policy", value, p)
+	}
+
+	// still report values this exporter doesn't know about, e.g. ones introduced by newer Pacemaker versions
+	if !known {
+		ch <- c.MakeGaugeMetric("no_quorum_policy", 1, policy)
+	}
+}
+
+// the effective no-quorum-policy, falling back to the Pacemaker default when it's not configured
+func noQuorumPolicy(CIB cib.Root) string {
+	policy, ok := getAttribute(CIB.Configuration.CrmConfig.ClusterProperties, "no-quorum-policy")
+	if !ok || policy == "" {
+		return "stop"
+	}
+	return strings.ToLower(policy)
+}
+
+func (c *pacemakerCollector) recordSymmetricCluster(CIB cib.Root, ch chan<- prometheus.Metric) {
+	var symmetricCluster float64
+	if isSymmetricCluster(CIB) {
+		symmetricCluster = 1
+	}
+
+	ch <- c.MakeGaugeMetric("symmetric_cluster", symmetricCluster)
+}
+
+// the effective symmetric-cluster property, which is true unless configured otherwise
+func isSymmetricCluster(CIB cib.Root) bool {
+	value, ok := getAttribute(CIB.Configuration.CrmConfig.ClusterProperties, "symmetric-cluster")
+	return !ok || isCibTrue(value)
+}
+
+func (c *pacemakerCollector) recordStartFailureIsFatal(CIB cib.Root, ch chan<- prometheus.Metric) {
+	var startFailureIsFatal float64
+	if isStartFailureFatal(CIB) {
+		startFailureIsFatal = 1
+	}
+
+	ch <- c.MakeGaugeMetric("start_failure_is_fatal", startFailureIsFatal)
+}
+
+// the effective start-failure-is-fatal property, which is true unless configured otherwise
+func isStartFailureFatal(CIB cib.Root) bool {
+	value, ok := getAttribute(CIB.Configuration.CrmConfig.ClusterProperties, "start-failure-is-fatal")
+	return !ok || isCibTrue(value)
+}
+
+// the default of the cluster-recheck-interval cluster property
+const defaultClusterRecheckInterval = "15min"
+
+func (c *pacemakerCollector) recordClusterRecheckInterval(CIB cib.Root, ch chan<- prometheus.Metric) {
+	interval := getAttributeOrDefault(CIB.Configuration.CrmConfig.ClusterProperties, "cluster-recheck-interval", defaultClusterRecheckInterval)
+	seconds, err := parseTimeoutSeconds(interval)
+	if err != nil {
+		level.Warn(c.Logger).Log("msg", "Could not parse cluster-recheck-interval", "err", err)
+		return
+	}
+
+	ch <- c.MakeGaugeMetric("cluster_recheck_interval_seconds", seconds)
+}
+
+// the defaults of the batch-limit and migration-limit cluster properties
+var defaultSchedulerLimits = map[string]string{
+	"batch-limit":     "0",
+	"migration-limit": "-1",
+}
+
+// these throttle how many actions run at the same time, so wrong values make failovers slow or overload the nodes
+func (c *pacemakerCollector) recordSchedulerLimits(CIB cib.Root, ch chan<- prometheus.Metric) {
+	for property, fallback := range defaultSchedulerLimits {
+		value := getAttributeOrDefault(CIB.Configuration.CrmConfig.ClusterProperties, property, fallback)
+		limit, err := strconv.Atoi(value)
+		if err != nil {
+			level.Warn(c.Logger).Log("msg", "Could not parse "+property, "err", err)
+			continue
+		}
+
+		ch <- c.MakeGaugeMetric(strings.ReplaceAll(property, "-", "_"), float64(limit))
+	}
+}
+
 func (c *pacemakerCollector) recordNodes(crmMon crmmon.Root, ch chan<- prometheus.Metric) {
 	for _, node := range crmMon.Nodes {
 
@@ -117,32 +517,291 @@ func (c *pacemakerCollector) recordNodes(crmMon crmmon.Root, ch chan<- prometheu
 			if flag {
 				statusValue = 1
 			}
-			ch <- c.MakeGaugeMetric("nodes", statusValue, node.Name, node.Type, nodeStatus)
+			ch <- c.MakeGaugeMetric("nodes", statusValue, node.Name, nodeType(node), nodeStatus)
+		}
+
+		ch <- c.MakeGaugeMetric("node_state", 1, node.Name, nodeType(node), nodeState(node))
+	}
+}
+
+// crm_mon reports the guest nodes as remote ones, since they run pacemaker_remote too, but they can be told apart
+// by the resource of the container or VM they run in, which the cluster manages like any other resource
+func nodeType(node crmmon.Node) string {
+	if node.Type == "remote" && node.IdAsResource != "" {
+		return "guest"
+	}
+	return node.Type
+}
+
+// records the membership and the controller join state of each node separately, since a node can be in the former but
+// not in the latter, i.e. it is reachable on the network but stuck while joining the cluster
+func (c *pacemakerCollector) recordNodeMembership(CIB cib.Root, ch chan<- prometheus.Metric) {
+	for _, nodeState := range CIB.Status.NodeStates {
+		var inCcm float64
+		if isNodeStateSet(nodeState.InCcm, "true") {
+			inCcm = 1
+		}
+		ch <- c.MakeGaugeMetric("node_in_ccm", inCcm, nodeState.Uname)
+
+		// without a controller, there's nothing to join
+		if isCibTrue(nodeState.RemoteNode) {
+			continue
+		}
+		var crmdJoined float64
+		if isNodeStateSet(nodeState.Crmd, "online") && nodeState.Join == "member" {
+			crmdJoined = 1
 		}
+		ch <- c.MakeGaugeMetric("node_crmd_joined", crmdJoined, nodeState.Uname)
 	}
 }
 
-func (c *pacemakerCollector) recordResources(crmMon crmmon.Root, ch chan<- prometheus.Metric) {
-	for _, resource := range crmMon.Resources {
-		c.recordResource(resource, "", "", ch)
+// tells whether a node_state attribute is set to the given value, or to the positive timestamp newer Pacemaker versions use instead
+func isNodeStateSet(value string, setValue string) bool {
+	if value == setValue {
+		return true
 	}
-	for _, clone := range crmMon.Clones {
-		recorded := make(map[crmmon.Resource]bool) // we need to track cloned resources to avoid duplicates
-		for _, resource := range clone.Resources {
-			// Avoid recording stopped cloned resources multiple times
-			if recorded[resource] == true {
-				continue
+	timestamp, err := strconv.ParseInt(value, 10, 64)
+	return err == nil && timestamp > 0
+}
+
+// the membership states of a node, as far as node_transitions_total is concerned
+var nodeMembershipStates = []string{"online", "offline"}
+
+func (c *pacemakerCollector) recordNodeTransitions(crmMon crmmon.Root, ch chan<- prometheus.Metric) {
+	c.nodeTransitionsMutex.Lock()
+	defer c.nodeTransitionsMutex.Unlock()
+
+	// only the nodes still in the cluster are carried over, so that removed ones don't pile up
+	lastNodeStates := make(map[string]string, len(crmMon.Nodes))
+	nodeTransitions := make(map[string]map[string]float64, len(crmMon.Nodes))
+	for _, node := range crmMon.Nodes {
+		state := "offline"
+		if node.Online {
+			state = "online"
+		}
+
+		transitions, ok := c.nodeTransitions[node.Name]
+		if !ok {
+			transitions = make(map[string]float64)
+		}
+		nodeTransitions[node.Name] = transitions
+		// the first state we see is just the baseline: we can't know how many times it changed before the exporter started
+		if lastState, ok := c.lastNodeStates[node.Name]; ok && lastState != state {
+			transitions[state]++
+		}
+		lastNodeStates[node.Name] = state
+
+		for _, toState := range nodeMembershipStates {
+			ch <- c.MakeCounterMetric("node_transitions_total", transitions[toState], node.Name, toState)
+		}
+	}
+	c.lastNodeStates = lastNodeStates
+	c.nodeTransitions = nodeTransitions
+}
+
+// reduces the status flags of a node to a single state, the most severe ones taking precedence
+func nodeState(node crmmon.Node) string {
+	switch {
+	case node.Unclean:
+		return "unclean"
+	// a node that is joining the cluster is reported as pending and not online yet
+	case node.Pending:
+		return "pending"
+	case node.StandbyOnFail:
+		return "standby_onfail"
+	case !node.Online:
+		return "offline"
+	case node.Standby:
+		return "standby"
+	case node.Maintenance:
+		return "maintenance"
+	default:
+		return "online"
+	}
+}
+
+func (c *pacemakerCollector) recordNodeStandby(crmMon crmmon.Root, CIB cib.Root, ch chan<- prometheus.Metric) {
+	for _, node := range crmMon.Nodes {
+		var standby float64
+		if node.Standby || node.StandbyOnFail {
+			standby = 1
+		}
+		ch <- c.MakeGaugeMetric("node_standby", standby, node.Name, standbyReason(node, CIB))
+	}
+}
+
+// a node can be put in standby until a given time, by setting the standby attribute in a set with a date rule;
+// permanent standbys have no expiry, so they're left out, and so are the recurring ones, e.g. based on a date_spec
+func (c *pacemakerCollector) recordNodeStandbyExpiry(CIB cib.Root, ch chan<- prometheus.Metric) {
+	for _, node := range CIB.Configuration.Nodes {
+		expiry, ok := standbyExpiry(node)
+		if !ok {
+			continue
+		}
+		ch <- c.MakeGaugeMetric("node_standby_expiry_timestamp_seconds", float64(expiry.Unix()), node.Uname)
+	}
+}
+
+// returns the latest end of the rules of the attribute sets putting a node in standby
+func standbyExpiry(node cib.Node) (expiry time.Time, ok bool) {
+	for _, set := range node.InstanceAttributeSets {
+		if value, found := getAttribute(set.Attributes, "standby"); !found || !isCibTrue(value) || len(set.Rules) == 0 {
+			continue
+		}
+		for _, rule := range set.Rules {
+			end, bounded := ruleEnd(rule)
+			if bounded && (!ok || end.After(expiry)) {
+				expiry = end
+				ok = true
 			}
+		}
+	}
+	return expiry, ok
+}
+
+// tells apart nodes put in standby by an operator from the ones put in standby by a failed operation with on-fail=standby;
+// Pacemaker sets the standby flag in both cases, so the former is only recognized via the permanent standby node attribute
+func standbyReason(node crmmon.Node, CIB cib.Root) string {
+	switch {
+	case node.StandbyOnFail:
+		return "onfail"
+	case !node.Standby:
+		return "none"
+	}
+
+	for _, cibNode := range CIB.Configuration.Nodes {
+		if cibNode.Uname != node.Name {
+			continue
+		}
+		if value, ok := getAttribute(cibNode.InstanceAttributes(), "standby"); ok && isCibTrue(value) {
+			return "manual"
+		}
+	}
+
+	return "unknown"
+}
+
+// tells whether a CIB value is a true boolean, according to Pacemaker's own rules
+func isCibTrue(value string) bool {
+	switch strings.ToLower(value) {
+	case "true", "on", "yes", "y", "1":
+		return true
+	default:
+		return false
+	}
+}
 
-			c.recordResource(resource, "", clone.Id, ch)
+// records the maintenance of all the scopes in a single family, so that a single query can tell whether anything is in maintenance;
+// the cluster-wide maintenance mode applies to every node and resource, even if their own flags are not set
+func (c *pacemakerCollector) recordMaintenance(crmMon crmmon.Root, CIB cib.Root, ch chan<- prometheus.Metric) {
+	clusterMaintenance := crmMon.Summary.ClusterOptions.MaintenanceMode
+
+	var clusterValue float64
+	if clusterMaintenance {
+		clusterValue = 1
+	}
+	ch <- c.MakeGaugeMetric("maintenance", clusterValue, "cluster", "")
+
+	for _, node := range crmMon.Nodes {
+		var nodeValue float64
+		if clusterMaintenance || node.Maintenance {
+			nodeValue = 1
+		}
+		ch <- c.MakeGaugeMetric("maintenance", nodeValue, "node", node.Name)
+	}
+
+	for primitive, maintenance := range resourcesMaintenance(CIB) {
+		var resourceValue float64
+		if clusterMaintenance || maintenance {
+			resourceValue = 1
+		}
+		ch <- c.MakeGaugeMetric("maintenance", resourceValue, "resource", primitive)
+	}
+}
 
-			recorded[resource] = true
+// returns whether each primitive has the maintenance meta-attribute set, either on its own or on its parent group or clone
+func resourcesMaintenance(CIB cib.Root) map[string]bool {
+	resources := make(map[string]bool)
+	forEachPrimitive(CIB, func(primitive cib.Primitive, parentMetaAttributes []cib.Attribute, _ bool) {
+		// the attribute of the primitive takes precedence, so a member can be taken out of the maintenance of its parent
+		value, ok := getAttribute(primitive.MetaAttributes, "maintenance")
+		if !ok {
+			value, ok = getAttribute(parentMetaAttributes, "maintenance")
 		}
+		resources[primitive.Id] = ok && isCibTrue(value)
+	})
+	return resources
+}
+
+func (c *pacemakerCollector) recordResources(crmMon crmmon.Root, ch chan<- prometheus.Metric) {
+	type instance struct {
+		resource crmmon.Resource
+		group    string
+		clone    string
 	}
+	recorded := make(map[instance]bool) // we need to track cloned resources to avoid duplicates
+	forEachResource(crmMon, func(resource crmmon.Resource, group string, clone string) {
+		// Avoid recording stopped cloned resources multiple times
+		key := instance{resource, group, clone}
+		if recorded[key] {
+			return
+		}
+
+		c.recordResource(resource, group, clone, ch)
+
+		recorded[key] = true
+	})
+}
+
+// groups start their members in order and stop them in reverse order,
+// so a group with less members running than configured is either in transition or stuck half-way
+func (c *pacemakerCollector) recordGroups(crmMon crmmon.Root, ch chan<- prometheus.Metric) {
 	for _, group := range crmMon.Groups {
-		for _, resource := range group.Resources {
-			c.recordResource(resource, group.Id, "", ch)
+		for i, resource := range group.Resources {
+			ch <- c.MakeGaugeMetric("group_members", float64(i+1), group.Id, resource.Id)
+		}
+		ch <- c.MakeGaugeMetric("group_running", float64(groupRunningMembers(group)), group.Id)
+	}
+}
+
+func groupRunningMembers(group crmmon.Group) int {
+	running := 0
+	for _, resource := range group.Resources {
+		if resource.Active {
+			running++
+		}
+	}
+	return running
+}
+
+func (c *pacemakerCollector) recordBlockedResources(crmMon crmmon.Root, ch chan<- prometheus.Metric) {
+	type instance struct {
+		node     string
+		resource string
+		clone    string
+	}
+
+	// clone instances are recorded one by one, so that blocked instances can be told apart from healthy ones;
+	// the stopped ones though have no node, so they are merged and considered blocked if any of them is
+	blocked := make(map[instance]bool)
+	record := func(resource crmmon.Resource, clone string) {
+		key := instance{resource: resource.Id, clone: clone}
+		if resource.Node != nil {
+			key.node = resource.Node.Name
+		}
+		blocked[key] = blocked[key] || resource.Blocked
+	}
+
+	forEachResource(crmMon, func(resource crmmon.Resource, _ string, clone string) {
+		record(resource, clone)
+	})
+
+	for key, isBlocked := range blocked {
+		var value float64
+		if isBlocked {
+			value = 1
 		}
+		ch <- c.MakeGaugeMetric("resource_blocked", value, key.node, key.resource, key.clone)
 	}
 }
 
@@ -183,6 +842,143 @@ func (c *pacemakerCollector) recordResource(resource crmmon.Resource, group stri
 
 		ch <- c.MakeGaugeMetric("resources", statusValue, labels...)
 	}
+
+	var orphaned float64
+	if resource.Orphaned {
+		orphaned = 1
+	}
+	ch <- c.MakeGaugeMetric("resource_orphaned", orphaned, nodeName, resource.Id)
+
+	var failed float64
+	if isResourceFailed(resource) {
+		failed = 1
+	}
+	ch <- c.MakeGaugeMetric("resource_failed", failed, nodeName, resource.Id)
+
+	var pending float64
+	if resource.Pending != "" {
+		pending = 1
+	}
+	ch <- c.MakeGaugeMetric("resource_pending", pending, nodeName, resource.Id, strings.ToLower(resource.Pending))
+}
+
+// the pending operations are only reported if the record-pending cluster option is on, which is the default since Pacemaker 2.1;
+// each resource has at most one, so the operations of the resources are counted instance by instance
+func (c *pacemakerCollector) recordOperationsInFlight(crmMon crmmon.Root, ch chan<- prometheus.Metric) {
+	var inFlight float64
+	forEachResource(crmMon, func(resource crmmon.Resource, _ string, _ string) {
+		if resource.Pending != "" {
+			inFlight++
+		}
+	})
+
+	ch <- c.MakeGaugeMetric("operations_in_flight", inFlight)
+}
+
+// the failed flag stays on until the resource recovers or moves away,
+// unless its failures are ignored, e.g. with on-fail=ignore, in which case the cluster won't take any recovery action
+func isResourceFailed(resource crmmon.Resource) bool {
+	return resource.Failed && !resource.FailureIgnored
+}
+
+func (c *pacemakerCollector) recordClones(crmMon crmmon.Root, CIB cib.Root, ch chan<- prometheus.Metric) {
+	nodes := len(CIB.Configuration.Nodes)
+	for _, clone := range CIB.Configuration.Resources.Clones {
+		c.recordClone(clone, isCibTrue(getAttributeOrDefault(clone.MetaAttributes, "promotable", "false")), nodes, ch)
+	}
+	// master is the deprecated form of a promotable clone
+	for _, master := range CIB.Configuration.Resources.Masters {
+		c.recordClone(master, true, nodes, ch)
+	}
+
+	for _, clone := range crmMon.Clones {
+		ch <- c.MakeGaugeMetric("clone_running", float64(cloneRunningInstances(clone)), clone.Id)
+
+		if !clone.MultiState {
+			continue
+		}
+		promoted := clonePromotedInstances(clone)
+		ch <- c.MakeGaugeMetric("clone_promoted", float64(len(promoted)), clone.Id)
+		for _, resource := range promoted {
+			ch <- c.MakeGaugeMetric("resource_promoted_on", 1, resource.Id, resource.Node.Name)
+		}
+	}
+}
+
+func (c *pacemakerCollector) recordClone(clone cib.Clone, promotable bool, nodes int, ch chan<- prometheus.Metric) {
+	var unique float64
+	if isCibTrue(getAttributeOrDefault(clone.MetaAttributes, "globally-unique", "false")) {
+		unique = 1
+	}
+	ch <- c.MakeGaugeMetric("clone_unique", unique, clone.Id)
+
+	var promotableValue float64
+	if promotable {
+		promotableValue = 1
+	}
+	ch <- c.MakeGaugeMetric("clone_promotable", promotableValue, clone.Id)
+
+	limits, err := cloneInstanceLimits(clone, nodes)
+	if err != nil {
+		c.warnInvalidAttribute(clone.Id, "instance limits", "Could not parse the instance limits of clone "+clone.Id, err)
+		return
+	}
+	ch <- c.MakeGaugeMetric("clone_max", float64(limits.max), clone.Id)
+	ch <- c.MakeGaugeMetric("clone_node_max", float64(limits.nodeMax), clone.Id)
+	if promotable {
+		ch <- c.MakeGaugeMetric("clone_promoted_max", float64(limits.promotedMax), clone.Id)
+	}
+}
+
+type cloneLimits struct {
+	max         int
+	nodeMax     int
+	promotedMax int
+}
+
+// reads the instance limits from the meta-attributes of a clone, falling back to the Pacemaker defaults:
+// as many instances as there are nodes, one per node, and one promoted instance
+func cloneInstanceLimits(clone cib.Clone, nodes int) (limits cloneLimits, err error) {
+	limits.max, err = strconv.Atoi(getAttributeOrDefault(clone.MetaAttributes, "clone-max", strconv.Itoa(nodes)))
+	if err != nil {
+		return limits, errors.Wrap(err, "invalid clone-max")
+	}
+	limits.nodeMax, err = strconv.Atoi(getAttributeOrDefault(clone.MetaAttributes, "clone-node-max", "1"))
+	if err != nil {
+		return limits, errors.Wrap(err, "invalid clone-node-max")
+	}
+	// master-max is the deprecated name of promoted-max
+	promotedMax := getAttributeOrDefault(clone.MetaAttributes, "master-max", "1")
+	promotedMax = getAttributeOrDefault(clone.MetaAttributes, "promoted-max", promotedMax)
+	limits.promotedMax, err = strconv.Atoi(promotedMax)
+	if err != nil {
+		return limits, errors.Wrap(err, "invalid promoted-max")
+	}
+	return limits, nil
+}
+
+func cloneRunningInstances(clone crmmon.Clone) int {
+	running := 0
+	for _, resource := range clone.Resources {
+		if resource.Active {
+			running++
+		}
+	}
+	return running
+}
+
+// the active instances of a clone in the promoted role, which older Pacemaker versions call "Master"
+func clonePromotedInstances(clone crmmon.Clone) []crmmon.Resource {
+	var promoted []crmmon.Resource
+	for _, resource := range clone.Resources {
+		if !resource.Active || resource.Node == nil {
+			continue
+		}
+		if strings.EqualFold(resource.Role, "Promoted") || strings.EqualFold(resource.Role, "Master") {
+			promoted = append(promoted, resource)
+		}
+	}
+	return promoted
 }
 
 func (c *pacemakerCollector) recordFailCounts(crmMon crmmon.Root, ch chan<- prometheus.Metric) {
@@ -201,6 +997,24 @@ func (c *pacemakerCollector) recordFailCounts(crmMon crmmon.Root, ch chan<- prom
 	}
 }
 
+// counts the resources whose failures can't expire on their own, which is why they keep affecting the placement until cleaned up
+func (c *pacemakerCollector) recordResourcesNeedingCleanup(crmMon crmmon.Root, failureTimeouts map[string]float64, ch chan<- prometheus.Metric) {
+	needingCleanup := make(map[string]bool)
+	for _, node := range crmMon.NodeHistory.Nodes {
+		for _, resHistory := range node.ResourceHistory {
+			if resHistory.FailCount == 0 {
+				continue
+			}
+			resource := baseResourceId(resHistory.Name)
+			if failureTimeouts[resource] == 0 {
+				needingCleanup[resource] = true
+			}
+		}
+	}
+
+	ch <- c.MakeGaugeMetric("resources_needing_cleanup", float64(len(needingCleanup)))
+}
+
 func (c *pacemakerCollector) recordCibLastChange(crmMon crmmon.Root, ch chan<- prometheus.Metric) error {
 	t, err := time.Parse(time.ANSIC, crmMon.Summary.LastChange.Time)
 	if err != nil {
@@ -212,18 +1026,515 @@ func (c *pacemakerCollector) recordCibLastChange(crmMon crmmon.Root, ch chan<- p
 	return nil
 }
 
-func (c *pacemakerCollector) recordMigrationThresholds(crmMon crmmon.Root, ch chan<- prometheus.Metric) {
-	for _, node := range crmMon.NodeHistory.Nodes {
-		for _, resHistory := range node.ResourceHistory {
-			ch <- c.MakeGaugeMetric("migration_threshold", float64(resHistory.MigrationThreshold), node.Name, resHistory.Name)
-		}
+// the last update reported by crm_mon is just the time crm_mon ran, so the time the CIB was last written is taken instead
+func (c *pacemakerCollector) recordLastUpdate(CIB cib.Root, ch chan<- prometheus.Metric) {
+	t, err := time.Parse(time.ANSIC, CIB.LastWritten)
+	if err != nil {
+		level.Warn(c.Logger).Log("msg", "Could not parse the cib-last-written time", "err", err)
+		return
 	}
+
+	ch <- c.MakeGaugeMetric("last_update_timestamp_seconds", float64(t.Unix()))
 }
 
-func (c *pacemakerCollector) recordConstraints(CIB cib.Root, ch chan<- prometheus.Metric) {
-	for _, constraint := range CIB.Configuration.Constraints.RscLocations {
-		var constraintScore float64
-		switch constraint.Score {
+// records the time of the most recent result change of any operation in the status section;
+// operations keep changing in a healthy cluster, e.g. due to monitors failing and recovering, so a very old value may mean it is wedged
+func (c *pacemakerCollector) recordStatusFreshness(crmMon crmmon.Root, ch chan<- prometheus.Metric) {
+	t, ok := statusFreshness(crmMon)
+	if !ok {
+		return
+	}
+
+	ch <- c.MakeGaugeMetric("status_freshness_timestamp_seconds", float64(t.Unix()))
+}
+
+// returns the most recent last-rc-change of all the operations; ok is false if none of them has a valid one
+func statusFreshness(crmMon crmmon.Root) (latest time.Time, ok bool) {
+	for _, node := range crmMon.NodeHistory.Nodes {
+		for _, resHistory := range node.ResourceHistory {
+			for _, operation := range resHistory.Operations {
+				t, err := parseOperationTime(operation.LastRcChange)
+				if err != nil {
+					continue
+				}
+				if !ok || t.After(latest) {
+					latest = t
+					ok = true
+				}
+			}
+		}
+	}
+	return latest, ok
+}
+
+// parses the operation timestamps of crm_mon, which are like `Thu Oct 10 12:57:33 2019` in older versions
+// and like `2019-10-10 12:57:33 +02:00` in newer ones; the former are in the local time of the node
+func parseOperationTime(value string) (time.Time, error) {
+	t, err := time.ParseInLocation(time.ANSIC, value, time.Local)
+	if err != nil {
+		t, err = time.Parse("2006-01-02 15:04:05 -07:00", value)
+	}
+	if err != nil {
+		return time.Time{}, errors.Errorf("invalid operation time '%s'", value)
+	}
+	return t, nil
+}
+
+// last-lrm-refresh is only set after the history has been refreshed at least once, so the metric is omitted until then
+func (c *pacemakerCollector) recordLastLrmRefresh(CIB cib.Root, ch chan<- prometheus.Metric) {
+	value, ok := getAttribute(CIB.Configuration.CrmConfig.ClusterProperties, "last-lrm-refresh")
+	if !ok {
+		return
+	}
+
+	timestamp, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		level.Warn(c.Logger).Log("msg", "Could not parse the last-lrm-refresh cluster property", "value", value, "err", err)
+		return
+	}
+
+	ch <- c.MakeGaugeMetric("last_lrm_refresh_timestamp_seconds", float64(timestamp))
+}
+
+// the configuration version of a CIB copy, compared by admin_epoch first, then by epoch;
+// num_updates is left out, since it changes with every status update and would make the copies look out of sync all the time
+type cibVersion struct {
+	adminEpoch int
+	epoch      int
+}
+
+func (v cibVersion) olderThan(other cibVersion) bool {
+	if v.adminEpoch != other.adminEpoch {
+		return v.adminEpoch < other.adminEpoch
+	}
+	return v.epoch < other.epoch
+}
+
+// the version of the whole CIB, num_updates included, which is reset to 0 on every configuration change
+type cibFullVersion struct {
+	cibVersion
+	numUpdates int
+}
+
+func parseCibFullVersion(CIB cib.Root) (cibFullVersion, error) {
+	var version cibFullVersion
+	var err error
+
+	version.adminEpoch, err = strconv.Atoi(CIB.AdminEpoch)
+	if err != nil {
+		return version, errors.Wrap(err, "could not parse admin_epoch")
+	}
+	version.epoch, err = strconv.Atoi(CIB.Epoch)
+	if err != nil {
+		return version, errors.Wrap(err, "could not parse epoch")
+	}
+	version.numUpdates, err = strconv.Atoi(CIB.NumUpdates)
+	if err != nil {
+		return version, errors.Wrap(err, "could not parse num_updates")
+	}
+
+	return version, nil
+}
+
+// num_updates alone can't be exposed as a counter, since it goes back to 0 on every configuration change,
+// so the updates are summed across scrapes instead; the first version seen is just the baseline
+func (c *pacemakerCollector) recordCibUpdates(CIB cib.Root, ch chan<- prometheus.Metric) {
+	version, err := parseCibFullVersion(CIB)
+	if err != nil {
+		level.Warn(c.Logger).Log("msg", "Could not parse the CIB version", "err", err)
+		return
+	}
+
+	c.cibUpdatesMutex.Lock()
+	defer c.cibUpdatesMutex.Unlock()
+
+	if c.lastCibVersion != nil {
+		c.cibUpdates += cibUpdatesBetween(*c.lastCibVersion, version)
+	}
+	c.lastCibVersion = &version
+
+	ch <- c.MakeCounterMetric("cib_updates_total", c.cibUpdates)
+}
+
+// returns how many times the CIB was updated between two versions: each configuration change increments the epoch and resets num_updates,
+// while each status update increments num_updates. When the admin_epoch changes, or the version goes backwards, e.g. because the CIB was replaced,
+// only the updates since are known, i.e. num_updates
+func cibUpdatesBetween(previous cibFullVersion, current cibFullVersion) float64 {
+	switch {
+	case current.cibVersion == previous.cibVersion && current.numUpdates >= previous.numUpdates:
+		return float64(current.numUpdates - previous.numUpdates)
+	case current.adminEpoch == previous.adminEpoch && current.epoch > previous.epoch:
+		return float64(current.epoch - previous.epoch + current.numUpdates)
+	default:
+		return float64(current.numUpdates)
+	}
+}
+
+// the copies of the CIB can diverge during a partition or when the updates fail to propagate, which is an early sign of a split;
+// the online cluster nodes are handed over to the background check, which records how many of them it found out of sync, or couldn't query
+func (c *pacemakerCollector) recordCibOutOfSync(crmMon crmmon.Root, ch chan<- prometheus.Metric) {
+	if c.cibSyncChecker == nil {
+		return
+	}
+
+	var nodes []string
+	for _, node := range crmMon.Nodes {
+		// remote and guest nodes don't hold a copy of the CIB
+		if node.Online && node.Type == "member" {
+			nodes = append(nodes, node.Name)
+		}
+	}
+	c.cibSyncChecker.setNodes(nodes)
+
+	outOfSync, unknown, checkedAt, ok := c.cibSyncChecker.result()
+	if !ok {
+		return
+	}
+
+	// the check runs in the background, so the data can be older than the scrape
+	ch <- collector.WithCollectionTime(c.MakeGaugeMetric("nodes_cib_out_of_sync", float64(outOfSync)), checkedAt)
+	ch <- collector.WithCollectionTime(c.MakeGaugeMetric("nodes_cib_unknown", float64(unknown)), checkedAt)
+}
+
+func (c *pacemakerCollector) recordMigrationThresholds(crmMon crmmon.Root, ch chan<- prometheus.Metric) {
+	for _, node := range crmMon.NodeHistory.Nodes {
+		for _, resHistory := range node.ResourceHistory {
+			ch <- c.MakeGaugeMetric("migration_threshold", float64(resHistory.MigrationThreshold), node.Name, resHistory.Name)
+		}
+	}
+}
+
+// records the return code of the most recent operation of each resource, i.e. the one with the highest call ID
+func (c *pacemakerCollector) recordLastOperations(crmMon crmmon.Root, ch chan<- prometheus.Metric) {
+	for _, node := range crmMon.NodeHistory.Nodes {
+		for _, resHistory := range node.ResourceHistory {
+			if len(resHistory.Operations) == 0 {
+				continue
+			}
+			last := resHistory.Operations[0]
+			for _, operation := range resHistory.Operations[1:] {
+				if operation.Call > last.Call {
+					last = operation
+				}
+			}
+			ch <- c.MakeGaugeMetric("resource_last_op_rc", float64(last.Rc), node.Name, resHistory.Name, last.Task, ocfReturnCodeText(last.Rc))
+		}
+	}
+}
+
+func (c *pacemakerCollector) recordLastRuns(CIB cib.Root, ch chan<- prometheus.Metric) {
+	for resource, operations := range lastRuns(CIB) {
+		for operation, lastRun := range operations {
+			ch <- c.MakeGaugeMetric("resource_last_run_timestamp_seconds", float64(lastRun), resource, operation.name, operation.interval)
+		}
+	}
+}
+
+// an operation of a resource, told apart by its interval, so that e.g. the probes, whose interval is 0, and the recurring monitors are kept separate
+type lastRunOperation struct {
+	name string
+	// in seconds
+	interval string
+}
+
+// returns the most recent run of each operation of each resource, across all the nodes, from the operation history in the CIB;
+// the results of recurring operations are only recorded when they change, so last-rc-change is taken into account too
+func lastRuns(CIB cib.Root) map[string]map[lastRunOperation]int64 {
+	runs := make(map[string]map[lastRunOperation]int64)
+	for _, nodeState := range CIB.Status.NodeStates {
+		for _, lrmResource := range nodeState.LrmResources {
+			for _, op := range lrmResource.Operations {
+				var lastRun int64
+				for _, value := range []string{op.LastRun, op.LastRcChange} {
+					if t, err := strconv.ParseInt(value, 10, 64); err == nil && t > lastRun {
+						lastRun = t
+					}
+				}
+				if lastRun == 0 {
+					continue
+				}
+
+				// the history records the intervals in milliseconds, and leaves them out of the one-off operations
+				var interval float64
+				if op.Interval != "" {
+					var err error
+					interval, err = parseTimeoutSeconds(op.Interval + "ms")
+					if err != nil {
+						continue
+					}
+				}
+				operation := lastRunOperation{name: op.Operation, interval: strconv.FormatFloat(interval, 'f', -1, 64)}
+
+				if runs[lrmResource.Id] == nil {
+					runs[lrmResource.Id] = make(map[lastRunOperation]int64)
+				}
+				if lastRun > runs[lrmResource.Id][operation] {
+					runs[lrmResource.Id][operation] = lastRun
+				}
+			}
+		}
+	}
+	return runs
+}
+
+// the instances of clones, including the members of cloned groups, have a line for each node they run on;
+// anonymous clones can run more than one instance on the same node though, so those are only recorded once
+func (c *pacemakerCollector) recordResourceNodes(crmMon crmmon.Root, CIB cib.Root, ch chan<- prometheus.Metric) {
+	type placement struct {
+		resource string
+		node     string
+	}
+	recorded := make(map[placement]bool)
+
+	targets := migrationTargets(CIB)
+	forEachResource(crmMon, func(resource crmmon.Resource, _ string, _ string) {
+		if !resource.Active || resource.Node == nil {
+			return
+		}
+		key := placement{resource.Id, resource.Node.Name}
+		if recorded[key] {
+			return
+		}
+		recorded[key] = true
+
+		allocated := resource.Node.Name
+		if target, migrating := targets[resource.Id]; migrating {
+			allocated = target
+		}
+		ch <- c.MakeGaugeMetric("resource_running_node", 1, resource.Id, resource.Node.Name)
+		ch <- c.MakeGaugeMetric("resource_allocated_node", 1, resource.Id, allocated)
+	})
+}
+
+// returns the node each resource is being live migrated to, by resource id, from the operation history in the CIB:
+// the last operation of a resource on the source node stays migrate_to until the migration is over, i.e. until it's stopped there
+func migrationTargets(CIB cib.Root) map[string]string {
+	targets := make(map[string]string)
+	for _, nodeState := range CIB.Status.NodeStates {
+		for _, lrmResource := range nodeState.LrmResources {
+			for _, op := range lrmResource.Operations {
+				if op.Id == lrmResource.Id+"_last_0" && op.Operation == "migrate_to" && op.MigrateTarget != "" {
+					targets[lrmResource.Id] = op.MigrateTarget
+				}
+			}
+		}
+	}
+	return targets
+}
+
+// warns if the node the per-node metrics are restricted to is unknown to the cluster, e.g. because crm_node and crm_mon disagree on its name
+func (c *pacemakerCollector) checkLocalNode(crmMon crmmon.Root) {
+	if c.localNode == "" {
+		return
+	}
+	for _, node := range crmMon.Nodes {
+		if node.Name == c.localNode {
+			return
+		}
+	}
+	c.localNodeWarning.Do(func() {
+		level.Warn(c.Logger).Log("msg", "The local node is not part of the cluster, so no per-node metric will be recorded", "node", c.localNode)
+	})
+}
+
+// LocalNodeName returns the name the cluster knows the local node by, which may differ from its hostname, via crm_node
+func LocalNodeName(crmNodePath string) (string, error) {
+	output, err := collector.Command(crmNodePath, "-n").Output()
+	if err != nil {
+		return "", errors.Wrap(err, "crm_node failed")
+	}
+	name := strings.TrimSpace(string(output))
+	if name == "" {
+		return "", errors.New("crm_node returned no node name")
+	}
+	return name, nil
+}
+
+// tells whether the per-node metrics of a node are recorded, i.e. whether the collector is not restricted to the local node, or it is that node;
+// this way, each exporter of a cluster only contributes the data of its own node, instead of duplicating the data of every node.
+// An empty node, e.g. the one of a stopped resource, is not any node in particular, so it's recorded like the cluster-wide metrics.
+func (c *pacemakerCollector) includesNode(node string) bool {
+	return c.localNode == "" || node == "" || node == c.localNode
+}
+
+// forwards to ch the metrics of the nodes that are recorded, i.e. all the ones without a node label, and the others as per includesNode;
+// filtering the collected metrics by their label covers all the per-node metrics at once.
+// flush must be called once the collection is done, to wait for the metrics still in flight.
+func (c *pacemakerCollector) localNodeSeries(ch chan<- prometheus.Metric) (filtered chan<- prometheus.Metric, flush func()) {
+	in := make(chan prometheus.Metric)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for metric := range in {
+			if c.includesNode(nodeLabel(metric)) {
+				ch <- metric
+			}
+		}
+	}()
+	return in, func() {
+		close(in)
+		<-done
+	}
+}
+
+// returns the value of the node label of a metric, or an empty string if it has none
+func nodeLabel(metric prometheus.Metric) string {
+	metricDto := &dto.Metric{}
+	if err := metric.Write(metricDto); err != nil {
+		return ""
+	}
+	for _, label := range metricDto.GetLabel() {
+		if label.GetName() == "node" {
+			return label.GetValue()
+		}
+	}
+	return ""
+}
+
+// the OCF resource agent exit codes, see https://clusterlabs.org/pacemaker/doc/2.1/Pacemaker_Administration/html/agents.html#ocf-return-codes
+var ocfReturnCodes = map[int]string{
+	0:   "ok",
+	1:   "generic_error",
+	2:   "invalid_argument",
+	3:   "unimplemented_feature",
+	4:   "insufficient_privileges",
+	5:   "not_installed",
+	6:   "not_configured",
+	7:   "not_running",
+	8:   "running_promoted",
+	9:   "failed_promoted",
+	190: "degraded",
+	191: "degraded_promoted",
+}
+
+// maps an OCF exit code to a human readable text; unknown codes are returned as they are
+func ocfReturnCodeText(rc int) string {
+	if text, ok := ocfReturnCodes[rc]; ok {
+		return text
+	}
+	return strconv.Itoa(rc)
+}
+
+// records the drift of the operations anchored to an interval-origin, i.e. which are scheduled at fixed times rather than
+// at fixed intervals from the previous run; crm_mon doesn't report the last run of all the operations, so only the ones it does are recorded
+func (c *pacemakerCollector) recordOperationDrifts(crmMon crmmon.Root, CIB cib.Root, ch chan<- prometheus.Metric) {
+	anchored := anchoredOperations(CIB)
+	if len(anchored) == 0 {
+		return
+	}
+
+	for _, node := range crmMon.NodeHistory.Nodes {
+		for _, resHistory := range node.ResourceHistory {
+			operations := anchored[baseResourceId(resHistory.Name)]
+			for _, operation := range resHistory.Operations {
+				if operation.LastRun == "" {
+					continue
+				}
+				for _, anchoredOperation := range operations {
+					if anchoredOperation.Name != operation.Task || !sameInterval(anchoredOperation.Interval, operation.Interval) {
+						continue
+					}
+					drift, err := operationDrift(anchoredOperation, operation.LastRun)
+					if err != nil {
+						level.Debug(c.Logger).Log("msg", "Could not compute the drift of an operation of resource "+resHistory.Name, "err", err)
+						continue
+					}
+					ch <- c.MakeGaugeMetric("resource_op_drift_seconds", drift, node.Name, resHistory.Name, operation.Task)
+				}
+			}
+		}
+	}
+}
+
+// returns the operations with an interval-origin, by resource
+func anchoredOperations(CIB cib.Root) map[string][]cib.Operation {
+	anchored := make(map[string][]cib.Operation)
+	forEachPrimitive(CIB, func(primitive cib.Primitive, _ []cib.Attribute, _ bool) {
+		for _, operation := range primitive.Operations {
+			if operation.IntervalOrigin != "" {
+				anchored[primitive.Id] = append(anchored[primitive.Id], operation)
+			}
+		}
+	})
+	return anchored
+}
+
+// tells whether two recurring operation intervals are the same, e.g. `10` in the CIB and `10000ms` in crm_mon
+func sameInterval(a string, b string) bool {
+	aSeconds, err := parseTimeoutSeconds(a)
+	if err != nil {
+		return false
+	}
+	bSeconds, err := parseTimeoutSeconds(b)
+	if err != nil {
+		return false
+	}
+	return aSeconds > 0 && aSeconds == bSeconds
+}
+
+// returns how many seconds the last run of an anchored operation was after its closest scheduled time
+func operationDrift(anchored cib.Operation, lastRun string) (float64, error) {
+	interval, err := parseTimeoutSeconds(anchored.Interval)
+	if err != nil {
+		return 0, err
+	}
+	origin, err := parseDate(anchored.IntervalOrigin)
+	if err != nil {
+		return 0, err
+	}
+	run, err := parseOperationTime(lastRun)
+	if err != nil {
+		return 0, err
+	}
+
+	period := time.Duration(interval * float64(time.Second))
+	drift := run.Sub(origin) % period
+	if drift < 0 {
+		drift += period
+	}
+	// a run shortly before its scheduled time is early, rather than late by almost a whole interval
+	if drift > period/2 {
+		drift -= period
+	}
+
+	return drift.Seconds(), nil
+}
+
+func (c *pacemakerCollector) recordConfigVerification(ch chan<- prometheus.Metric) {
+	if c.configVerifier == nil {
+		return
+	}
+
+	configErrors, configWarnings, verifiedAt, ok := c.configVerifier.result()
+	if !ok {
+		return
+	}
+
+	// the check runs in the background, so the data can be older than the scrape
+	ch <- collector.WithCollectionTime(c.MakeGaugeMetric("config_errors", float64(configErrors)), verifiedAt)
+	ch <- collector.WithCollectionTime(c.MakeGaugeMetric("config_warnings", float64(configWarnings)), verifiedAt)
+}
+
+func (c *pacemakerCollector) recordPlacementScores(ch chan<- prometheus.Metric) {
+	if c.placementScorer == nil {
+		return
+	}
+
+	scores, scoredAt, ok := c.placementScorer.result()
+	if !ok {
+		return
+	}
+
+	// the scores are computed in the background, so the data can be older than the scrape
+	for placement, score := range scores {
+		ch <- collector.WithCollectionTime(c.MakeGaugeMetric("resource_placement_score", score, placement.resource, placement.node), scoredAt)
+	}
+}
+
+func (c *pacemakerCollector) recordConstraints(CIB cib.Root, ch chan<- prometheus.Metric) {
+	for _, constraint := range CIB.Configuration.Constraints.RscLocations {
+		var constraintScore float64
+		switch constraint.Score {
 		case "INFINITY":
 			constraintScore = math.Inf(1)
 		case "-INFINITY":
@@ -237,6 +1548,29 @@ func (c *pacemakerCollector) recordConstraints(CIB cib.Root, ch chan<- prometheu
 	}
 }
 
+// records the location constraint rules whose time conditions are currently met, e.g. maintenance windows;
+// only the date expressions are evaluated, since the other ones depend on the node
+func (c *pacemakerCollector) recordActiveRules(CIB cib.Root, ch chan<- prometheus.Metric) {
+	now := c.Clock.Now()
+	activeRules := 0
+	for _, constraint := range CIB.Configuration.Constraints.RscLocations {
+		for _, rule := range constraint.Rules {
+			active, timeBased, err := ruleActive(rule, now)
+			if err != nil {
+				level.Warn(c.Logger).Log("msg", "Could not evaluate rule "+rule.Id+" of constraint "+constraint.Id, "err", err)
+				continue
+			}
+			if !timeBased || !active {
+				continue
+			}
+			activeRules++
+			ch <- c.MakeGaugeMetric("active_rule", 1, constraint.Id, constraint.Resource, rule.Id)
+		}
+	}
+
+	ch <- c.MakeGaugeMetric("active_rules", float64(activeRules))
+}
+
 func (c *pacemakerCollector) recordNodeAttributes(crmMon crmmon.Root, ch chan<- prometheus.Metric) {
 	for _, node := range crmMon.NodeAttributes.Nodes {
 		for _, attr := range node.Attributes {
@@ -244,3 +1578,412 @@ func (c *pacemakerCollector) recordNodeAttributes(crmMon crmmon.Root, ch chan<-
 		}
 	}
 }
+
+func (c *pacemakerCollector) recordFailureTimeouts(failureTimeouts map[string]float64, ch chan<- prometheus.Metric) {
+	for resource, failureTimeout := range failureTimeouts {
+		ch <- c.MakeGaugeMetric("resource_failure_timeout_seconds", failureTimeout, resource)
+	}
+}
+
+// returns the failure-timeout of a primitive in seconds, falling back to the one of its parents, if any, and then to the resource defaults;
+// 0 means it's not set
+func primitiveFailureTimeout(primitive cib.Primitive, parentMetaAttributes []cib.Attribute, defaults []cib.Attribute) (float64, error) {
+	for _, metaAttributes := range [][]cib.Attribute{primitive.MetaAttributes, parentMetaAttributes, defaults} {
+		value, ok := getAttribute(metaAttributes, "failure-timeout")
+		if !ok {
+			continue
+		}
+		return parseTimeoutSeconds(value)
+	}
+	return 0, nil
+}
+
+// returns the failure-timeout of each primitive in seconds; the invalid values are logged and left out,
+// and since Pacemaker ignores them too, the missing resources count as having no failure-timeout
+func (c *pacemakerCollector) resourcesFailureTimeout(CIB cib.Root) map[string]float64 {
+	resources := make(map[string]float64)
+	forEachPrimitive(CIB, func(primitive cib.Primitive, parentMetaAttributes []cib.Attribute, _ bool) {
+		failureTimeout, err := primitiveFailureTimeout(primitive, parentMetaAttributes, CIB.Configuration.RscDefaults)
+		if err != nil {
+			c.warnInvalidAttribute(primitive.Id, "failure-timeout", "Could not parse failure-timeout of resource "+primitive.Id, err)
+			return
+		}
+		resources[primitive.Id] = failureTimeout
+	})
+	return resources
+}
+
+func (c *pacemakerCollector) recordStickiness(CIB cib.Root, ch chan<- prometheus.Metric) {
+	defaults := CIB.Configuration.RscDefaults
+	// the default-resource-stickiness cluster property is the deprecated form of the resource default, which takes precedence
+	if value, ok := getAttribute(CIB.Configuration.CrmConfig.ClusterProperties, "default-resource-stickiness"); ok {
+		defaults = append(defaults[:len(defaults):len(defaults)], cib.Attribute{Name: "resource-stickiness", Value: value})
+	}
+
+	forEachPrimitive(CIB, func(primitive cib.Primitive, parentMetaAttributes []cib.Attribute, isCloneInstance bool) {
+		stickiness, err := primitiveStickiness(primitive, parentMetaAttributes, defaults, isCloneInstance)
+		if err != nil {
+			c.warnInvalidAttribute(primitive.Id, "resource-stickiness", "Could not parse resource-stickiness of resource "+primitive.Id, err)
+			return
+		}
+		ch <- c.MakeGaugeMetric("resource_stickiness", stickiness, primitive.Id)
+	})
+}
+
+// returns the resource-stickiness of a primitive, falling back to the one of its parent, if any, then to the resource defaults;
+// when it's set nowhere, Pacemaker defaults to 1 for the clone instances and to 0 for all the other resources
+func primitiveStickiness(primitive cib.Primitive, parentMetaAttributes []cib.Attribute, defaults []cib.Attribute, isCloneInstance bool) (float64, error) {
+	for _, metaAttributes := range [][]cib.Attribute{primitive.MetaAttributes, parentMetaAttributes, defaults} {
+		value, ok := getAttribute(metaAttributes, "resource-stickiness")
+		if !ok {
+			continue
+		}
+		return parseScore(value)
+	}
+	if isCloneInstance {
+		return 1, nil
+	}
+	return 0, nil
+}
+
+func (c *pacemakerCollector) recordMonitorIntervals(CIB cib.Root, ch chan<- prometheus.Metric) {
+	forEachPrimitive(CIB, func(primitive cib.Primitive, _ []cib.Attribute, _ bool) {
+		c.recordMonitorInterval(primitive, ch)
+	})
+}
+
+func (c *pacemakerCollector) recordMonitorInterval(primitive cib.Primitive, ch chan<- prometheus.Metric) {
+	interval, err := monitorInterval(primitive)
+	if err != nil {
+		c.warnInvalidAttribute(primitive.Id, "monitor interval", "Could not parse monitor interval of resource "+primitive.Id, err)
+		return
+	}
+
+	ch <- c.MakeGaugeMetric("resource_monitor_interval_seconds", interval, primitive.Id)
+}
+
+// returns the interval of the recurring monitor operation of a primitive in seconds, or 0 if there is none;
+// promotable resources usually have one monitor per role, in which case the shortest interval is returned
+func monitorInterval(primitive cib.Primitive) (float64, error) {
+	var shortest float64
+	for _, operation := range primitive.Operations {
+		if operation.Name != "monitor" {
+			continue
+		}
+		interval, err := parseTimeoutSeconds(operation.Interval)
+		if err != nil {
+			return 0, err
+		}
+		// monitors with a 0 interval are one-off probes, not recurring checks
+		if interval > 0 && (shortest == 0 || interval < shortest) {
+			shortest = interval
+		}
+	}
+	return shortest, nil
+}
+
+// calls visit for each primitive configured in the CIB, including the members of the groups and of the cloned groups,
+// along with the meta-attributes it inherits from its parents, the nearest first, and whether it's instantiated by a clone
+func forEachPrimitive(CIB cib.Root, visit func(primitive cib.Primitive, parentMetaAttributes []cib.Attribute, isCloneInstance bool)) {
+	resources := CIB.Configuration.Resources
+	visitClone := func(clone cib.Clone) {
+		if clone.Group == nil {
+			visit(clone.Primitive, clone.MetaAttributes, true)
+			return
+		}
+		parentMetaAttributes := append(append([]cib.Attribute{}, clone.Group.MetaAttributes...), clone.MetaAttributes...)
+		for _, primitive := range clone.Group.Primitives {
+			visit(primitive, parentMetaAttributes, true)
+		}
+	}
+
+	for _, primitive := range resources.Primitives {
+		visit(primitive, nil, false)
+	}
+	for _, clone := range resources.Clones {
+		visitClone(clone)
+	}
+	for _, master := range resources.Masters {
+		visitClone(master)
+	}
+	for _, group := range resources.Groups {
+		for _, primitive := range group.Primitives {
+			visit(primitive, group.MetaAttributes, false)
+		}
+	}
+}
+
+// calls visit for each resource reported by crm_mon, including the members of the groups, the instances of the clones
+// and the members of the instances of the cloned groups, along with the configured ids of the group and the clone it belongs to, if any
+func forEachResource(crmMon crmmon.Root, visit func(resource crmmon.Resource, group string, clone string)) {
+	for _, resource := range crmMon.Resources {
+		visit(resource, "", "")
+	}
+	for _, group := range crmMon.Groups {
+		for _, resource := range group.Resources {
+			visit(resource, group.Id, "")
+		}
+	}
+	for _, clone := range crmMon.Clones {
+		for _, resource := range clone.Resources {
+			visit(resource, "", clone.Id)
+		}
+		for _, group := range clone.Groups {
+			for _, resource := range group.Resources {
+				visit(resource, baseResourceId(group.Id), clone.Id)
+			}
+		}
+	}
+}
+
+// returns the configured id of a resource as reported by crm_mon: the instances of unique clones and of cloned groups
+// have a numeric suffix, e.g. `rsc:1`, which the configuration doesn't
+func baseResourceId(id string) string {
+	return strings.SplitN(id, ":", 2)[0]
+}
+
+func getAttribute(attributes []cib.Attribute, name string) (string, bool) {
+	for _, attribute := range attributes {
+		if attribute.Name == name {
+			return attribute.Value, true
+		}
+	}
+	return "", false
+}
+
+func getAttributeOrDefault(attributes []cib.Attribute, name string, fallback string) string {
+	value, ok := getAttribute(attributes, name)
+	if !ok {
+		return fallback
+	}
+	return value
+}
+
+// parses a Pacemaker time specification like `60`, `60s` or `10min` into seconds
+func parseTimeoutSeconds(timeout string) (float64, error) {
+	units := map[string]float64{
+		"":     1,
+		"s":    1,
+		"sec":  1,
+		"ms":   1e-3,
+		"msec": 1e-3,
+		"us":   1e-6,
+		"usec": 1e-6,
+		"m":    60,
+		"min":  60,
+		"h":    3600,
+		"hr":   3600,
+	}
+
+	timeout = strings.TrimSpace(timeout)
+	number := strings.TrimRightFunc(timeout, unicode.IsLetter)
+	multiplier, ok := units[strings.ToLower(timeout[len(number):])]
+	if !ok {
+		return 0, errors.Errorf("unknown unit in time specification '%s'", timeout)
+	}
+
+	value, err := strconv.ParseFloat(strings.TrimSpace(number), 64)
+	if err != nil {
+		return 0, errors.Wrapf(err, "invalid time specification '%s'", timeout)
+	}
+
+	return value * multiplier, nil
+}
+
+func (c *pacemakerCollector) recordStonithDevices(crmMon crmmon.Root, CIB cib.Root, ch chan<- prometheus.Metric) {
+	configured := make(map[string]bool)
+	for _, primitive := range stonithPrimitives(CIB) {
+		configured[primitive.Id] = true
+	}
+
+	// a device is active when at least one of its instances is, so we count resource ids only once
+	active := make(map[string]bool)
+	forEachResource(crmMon, func(resource crmmon.Resource, _ string, _ string) {
+		id := baseResourceId(resource.Id)
+		if configured[id] && resource.Active {
+			active[id] = true
+		}
+	})
+
+	ch <- c.MakeGaugeMetric("stonith_devices_configured", float64(len(configured)))
+	ch <- c.MakeGaugeMetric("stonith_devices_active", float64(len(active)))
+}
+
+// returns the fencing devices configured in the CIB, including the cloned and grouped ones
+func stonithPrimitives(CIB cib.Root) []cib.Primitive {
+	var primitives []cib.Primitive
+	forEachPrimitive(CIB, func(primitive cib.Primitive, _ []cib.Attribute, _ bool) {
+		if primitive.Class == "stonith" {
+			primitives = append(primitives, primitive)
+		}
+	})
+	return primitives
+}
+
+// records the nodes being fenced right now; the lines disappear as soon as the actions complete, whatever their outcome
+func (c *pacemakerCollector) recordFenceInProgress(crmMon crmmon.Root, ch chan<- prometheus.Metric) {
+	recorded := make(map[string]bool)
+	for _, event := range crmMon.FenceHistory {
+		if event.Status != "pending" || recorded[event.Target] {
+			continue
+		}
+		recorded[event.Target] = true
+		ch <- c.MakeGaugeMetric("fence_in_progress", 1, event.Target)
+	}
+}
+
+func (c *pacemakerCollector) recordLastFenceAge(crmMon crmmon.Root, ch chan<- prometheus.Metric) {
+	completed, ok := lastFence(crmMon)
+	if !ok {
+		return
+	}
+
+	ch <- c.MakeGaugeMetric("last_fence_age_seconds", c.Clock.Since(completed).Seconds())
+}
+
+// the formats of the completion time of fencing actions, e.g. "2019-10-18 11:02:42Z"; newer Pacemaker versions use an offset like "+02:00"
+var fenceCompletedLayouts = []string{
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02 15:04:05 Z07:00",
+	"2006-01-02 15:04:05Z0700",
+	"2006-01-02 15:04:05 -0700",
+}
+
+// returns when the most recent successful fencing action completed; ok is false if there is none in the fence history
+func lastFence(crmMon crmmon.Root) (latest time.Time, ok bool) {
+	for _, event := range crmMon.FenceHistory {
+		if event.Status != "success" {
+			continue
+		}
+		for _, layout := range fenceCompletedLayouts {
+			completed, err := time.Parse(layout, event.Completed)
+			if err != nil {
+				continue
+			}
+			if completed.After(latest) {
+				latest = completed
+				ok = true
+			}
+			break
+		}
+	}
+	return latest, ok
+}
+
+// the default of the stonith-watchdog-timeout cluster property, i.e. watchdog-based fencing is disabled
+const defaultStonithWatchdogTimeout = "0"
+
+// the stonith-watchdog-timeout must be consistent with the SBD_WATCHDOG_TIMEOUT for watchdog-based fencing to be safe,
+// so the raw value is exposed to be compared with it; negative values tell Pacemaker to derive it from SBD
+func (c *pacemakerCollector) recordStonithWatchdogTimeout(CIB cib.Root, ch chan<- prometheus.Metric) {
+	timeout := getAttributeOrDefault(CIB.Configuration.CrmConfig.ClusterProperties, "stonith-watchdog-timeout", defaultStonithWatchdogTimeout)
+	seconds, err := parseTimeoutSeconds(timeout)
+	if err != nil {
+		level.Warn(c.Logger).Log("msg", "Could not parse stonith-watchdog-timeout", "err", err)
+		return
+	}
+
+	ch <- c.MakeGaugeMetric("stonith_watchdog_timeout_seconds", seconds)
+}
+
+func (c *pacemakerCollector) recordFencingLevels(CIB cib.Root, ch chan<- prometheus.Metric) {
+	// the target patterns are compiled once per scrape rather than once per node
+	var matchers []func(node cib.Node) bool
+	for _, fencingLevel := range CIB.Configuration.FencingLevels {
+		matcher, err := fencingLevelMatcher(fencingLevel)
+		if err != nil {
+			c.warnInvalidAttribute(fencingLevel.Id, "target", "Could not match fencing level "+fencingLevel.Id, err)
+			continue
+		}
+		matchers = append(matchers, matcher)
+	}
+
+	for _, node := range CIB.Configuration.Nodes {
+		levels := 0
+		for _, matches := range matchers {
+			if matches(node) {
+				levels++
+			}
+		}
+		ch <- c.MakeGaugeMetric("fencing_levels", float64(levels), node.Uname)
+	}
+}
+
+// an attribute of an element of the configuration, e.g. the failure-timeout of a resource or the target of a fencing level
+type invalidAttribute struct {
+	id        string
+	attribute string
+}
+
+// an invalid attribute stays in the CIB until it's fixed, so it's only warned about the first time it's seen,
+// and again when the error changes, e.g. because it was set to another invalid value
+func (c *pacemakerCollector) warnInvalidAttribute(id string, attribute string, msg string, err error) {
+	c.invalidAttributesMutex.Lock()
+	defer c.invalidAttributesMutex.Unlock()
+
+	if c.invalidAttributes == nil {
+		c.invalidAttributes = make(map[invalidAttribute]string)
+	}
+	key := invalidAttribute{id, attribute}
+	if warned, ok := c.invalidAttributes[key]; ok && warned == err.Error() {
+		return
+	}
+	c.invalidAttributes[key] = err.Error()
+	level.Warn(c.Logger).Log("msg", msg, "err", err)
+}
+
+// returns whether a fencing level applies to a node; like Pacemaker does, the target pattern is not anchored
+func fencingLevelMatcher(fencingLevel cib.FencingLevel) (func(node cib.Node) bool, error) {
+	switch {
+	case fencingLevel.Target != "":
+		return func(node cib.Node) bool {
+			return fencingLevel.Target == node.Uname
+		}, nil
+	case fencingLevel.TargetPattern != "":
+		pattern, err := regexp.Compile(fencingLevel.TargetPattern)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not parse target-pattern")
+		}
+		return func(node cib.Node) bool {
+			return pattern.MatchString(node.Uname)
+		}, nil
+	case fencingLevel.TargetAttribute != "":
+		return func(node cib.Node) bool {
+			value, ok := getAttribute(node.InstanceAttributes(), fencingLevel.TargetAttribute)
+			return ok && value == fencingLevel.TargetValue
+		}, nil
+	default:
+		return nil, errors.New("no target")
+	}
+}
+
+// the default of the stonith-timeout cluster property
+const defaultStonithTimeout = "60s"
+
+// matches the per-action timeouts of the fencing devices, e.g. pcmk_reboot_timeout
+var stonithActionTimeoutPattern = regexp.MustCompile(`^pcmk_(\w+)_timeout$`)
+
+func (c *pacemakerCollector) recordStonithTimeouts(CIB cib.Root, ch chan<- prometheus.Metric) {
+	stonithTimeout := getAttributeOrDefault(CIB.Configuration.CrmConfig.ClusterProperties, "stonith-timeout", defaultStonithTimeout)
+	seconds, err := parseTimeoutSeconds(stonithTimeout)
+	if err != nil {
+		level.Warn(c.Logger).Log("msg", "Could not parse stonith-timeout", "err", err)
+	} else {
+		ch <- c.MakeGaugeMetric("stonith_timeout_seconds", seconds)
+	}
+
+	for _, primitive := range stonithPrimitives(CIB) {
+		for _, attribute := range primitive.InstanceAttributes {
+			matches := stonithActionTimeoutPattern.FindStringSubmatch(attribute.Name)
+			if matches == nil {
+				continue
+			}
+			seconds, err := parseTimeoutSeconds(attribute.Value)
+			if err != nil {
+				c.warnInvalidAttribute(primitive.Id, attribute.Name, "Could not parse "+attribute.Name+" of fencing device "+primitive.Id, err)
+				continue
+			}
+			ch <- c.MakeGaugeMetric("stonith_device_timeout_seconds", seconds, primitive.Id, matches[1])
+		}
+	}
+}
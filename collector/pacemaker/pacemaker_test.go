@@ -1,37 +1,1845 @@
 package pacemaker
 
 import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"math"
+	"os"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/go-kit/log"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
 	"github.com/stretchr/testify/assert"
 
+	"github.com/ClusterLabs/ha_cluster_exporter/collector"
+	"github.com/ClusterLabs/ha_cluster_exporter/collector/pacemaker/cib"
+	"github.com/ClusterLabs/ha_cluster_exporter/collector/pacemaker/crmmon"
 	assertcustom "github.com/ClusterLabs/ha_cluster_exporter/internal/assert"
+	"github.com/ClusterLabs/ha_cluster_exporter/internal/clock"
 )
 
+// the operation times of older crm_mon versions have no time zone and are taken in the local time,
+// so the fixtures are read in UTC wherever the tests run
+func TestMain(m *testing.M) {
+	time.Local = time.UTC
+	os.Exit(m.Run())
+}
+
 func TestNewPacemakerCollector(t *testing.T) {
-	_, err := NewCollector("../../test/fake_crm_mon.sh", "../../test/fake_cibadmin.sh", false, log.NewNopLogger())
+	_, err := NewCollector("../../test/fake_crm_mon.sh", "../../test/fake_cibadmin.sh", Options{}, false, log.NewNopLogger())
 
 	assert.Nil(t, err)
 }
 
 func TestNewPacemakerCollectorChecksCrmMonExistence(t *testing.T) {
-	_, err := NewCollector("../../test/nonexistent", "", false, log.NewNopLogger())
+	_, err := NewCollector("../../test/nonexistent", "", Options{}, false, log.NewNopLogger())
 
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "'../../test/nonexistent' does not exist")
 }
 
 func TestNewPacemakerCollectorChecksCrmMonExecutableBits(t *testing.T) {
-	_, err := NewCollector("../../test/dummy", "", false, log.NewNopLogger())
+	_, err := NewCollector("../../test/dummy", "", Options{}, false, log.NewNopLogger())
 
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "'../../test/dummy' is not executable")
 }
 
 func TestPacemakerCollector(t *testing.T) {
-	collector, err := NewCollector("../../test/fake_crm_mon.sh", "../../test/fake_cibadmin.sh", false, log.NewNopLogger())
+	collector, err := NewCollector("../../test/fake_crm_mon.sh", "../../test/fake_cibadmin.sh", Options{}, false, log.NewNopLogger())
 
 	assert.Nil(t, err)
+	seedResourceStates(t, collector)
 	assertcustom.Metrics(t, collector, "pacemaker.metrics")
 }
+
+// makes the resource states seen in a previous scrape, so that the times they were entered don't depend on when the test runs
+func seedResourceStates(t *testing.T, c *pacemakerCollector) {
+	crmMon, err := c.crmMonParser.Parse()
+	assert.NoError(t, err)
+	c.resourceStates.update(currentResourceStates(crmMon), time.Unix(1, 0))
+}
+
+func TestPacemakerCollectorLocalNodeOnly(t *testing.T) {
+	pacemakerCollector, err := NewCollector("../../test/fake_crm_mon.sh", "../../test/fake_cibadmin.sh", Options{LocalNode: "node01"}, false, log.NewNopLogger())
+	assert.Nil(t, err)
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(pacemakerCollector)
+	families, err := registry.Gather()
+	assert.NoError(t, err)
+
+	nodes := make(map[string]map[string]bool)
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if label.GetName() != "node" {
+					continue
+				}
+				if nodes[family.GetName()] == nil {
+					nodes[family.GetName()] = make(map[string]bool)
+				}
+				nodes[family.GetName()][label.GetValue()] = true
+			}
+		}
+	}
+
+	// all the metrics with a node label are restricted to the local node, or to no node at all, like the stopped resources
+	assert.Contains(t, nodes["ha_cluster_pacemaker_resources"], "", "stopped resources")
+	for name, values := range nodes {
+		delete(values, "")
+		assert.Equal(t, map[string]bool{"node01": true}, values, name)
+	}
+	for _, name := range []string{"nodes", "node_state", "node_attributes", "resources", "resource_failed", "fail_count", "migration_threshold", "resource_last_op_rc", "resource_running_node"} {
+		assert.Contains(t, nodes, "ha_cluster_pacemaker_"+name)
+	}
+}
+
+func TestLocalNodeName(t *testing.T) {
+	nodeName, err := LocalNodeName("../../test/fake_crm_node.sh")
+	assert.NoError(t, err)
+	assert.Equal(t, "node01", nodeName)
+
+	_, err = LocalNodeName("../../test/nonexistent")
+	assert.Error(t, err)
+}
+
+func TestPacemakerCollectorResourceTypeLabel(t *testing.T) {
+	pacemakerCollector, err := NewCollector("../../test/fake_crm_mon.sh", "../../test/fake_cibadmin.sh", Options{ResourceTypeLabel: true}, false, log.NewNopLogger())
+	assert.Nil(t, err)
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(pacemakerCollector)
+	families, err := registry.Gather()
+	assert.NoError(t, err)
+
+	// every metric with a resource label also has a type one
+	types := make(map[string]map[string]string)
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			labels := make(map[string]string)
+			for _, label := range metric.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+			resource, ok := labels["resource"]
+			if !ok {
+				continue
+			}
+			resourceType, ok := labels["type"]
+			assert.True(t, ok, family.GetName())
+			if types[family.GetName()] == nil {
+				types[family.GetName()] = make(map[string]string)
+			}
+			types[family.GetName()][resource] = resourceType
+		}
+	}
+
+	assert.Equal(t, "IPaddr2", types["ha_cluster_pacemaker_resources"]["rsc_ip_PRD_HDB00"])
+	assert.Equal(t, "SAPHana", types["ha_cluster_pacemaker_resource_promoted_on"]["rsc_SAPHana_PRD_HDB00"])
+	assert.Equal(t, "SAPHanaTopology", types["ha_cluster_pacemaker_fail_count"]["rsc_SAPHanaTopology_PRD_HDB00"])
+}
+
+func TestPacemakerCollectorServiceEnabled(t *testing.T) {
+	services := collector.NewServiceChecker("../../test/fake_systemctl.sh", log.NewNopLogger(), "pacemaker", "pacemaker_remote")
+	pacemakerCollector, err := NewCollector("../../test/fake_crm_mon.sh", "../../test/fake_cibadmin.sh", Options{Services: services}, false, log.NewNopLogger())
+	assert.Nil(t, err)
+
+	// pacemaker_remote is not installed, so it has no line
+	expect := `
+	# HELP ha_cluster_pacemaker_service_enabled Whether each Pacemaker systemd service is enabled to start at boot; 1 means enabled, 0 otherwise
+	# TYPE ha_cluster_pacemaker_service_enabled gauge
+	ha_cluster_pacemaker_service_enabled{service="pacemaker"} 1
+	`
+	err = testutil.CollectAndCompare(pacemakerCollector, strings.NewReader(expect), "ha_cluster_pacemaker_service_enabled")
+	assert.NoError(t, err)
+}
+
+func TestResourceTypesOfCloneInstances(t *testing.T) {
+	types := newResourceTypes()
+	types.types = map[string]string{"rsc_ip": "IPaddr2"}
+
+	assert.Equal(t, "IPaddr2", types.get("rsc_ip"))
+	assert.Equal(t, "IPaddr2", types.get("rsc_ip:1"))
+	assert.Equal(t, "", types.get("orphaned"))
+}
+
+func TestPacemakerCollectorRemote(t *testing.T) {
+	collector.SetRemoteHost(&collector.RemoteHost{Host: "node01", SshPath: "../../test/fake_ssh.sh"})
+	defer collector.SetRemoteHost(nil)
+
+	pacemakerCollector, err := NewCollector("../../test/fake_crm_mon.sh", "../../test/fake_cibadmin.sh", Options{}, false, log.NewNopLogger())
+
+	assert.Nil(t, err)
+	seedResourceStates(t, pacemakerCollector)
+	assertcustom.Metrics(t, pacemakerCollector, "pacemaker.metrics")
+}
+
+func TestPacemakerCollectorRemoteFailure(t *testing.T) {
+	collector.SetRemoteHost(&collector.RemoteHost{Host: "unreachable", SshPath: "../../test/fake_ssh.sh"})
+	defer collector.SetRemoteHost(nil)
+
+	pacemakerCollector, err := NewCollector("../../test/fake_crm_mon.sh", "../../test/fake_cibadmin.sh", Options{}, false, log.NewNopLogger())
+	assert.Nil(t, err)
+
+	err = pacemakerCollector.CollectWithError(make(chan prometheus.Metric, 1000))
+	assert.Error(t, err)
+}
+
+func TestLiveConnection(t *testing.T) {
+	pacemakerCollector, _ := NewCollector("../../test/fake_crm_mon.sh", "../../test/fake_cibadmin.sh", Options{}, false, log.NewNopLogger())
+
+	recordLiveConnection := func(crmMonErr error) float64 {
+		ch := make(chan prometheus.Metric, 1)
+		pacemakerCollector.recordLiveConnection(crmMonErr, ch)
+
+		metricDto := &dto.Metric{}
+		(<-ch).Write(metricDto)
+		return metricDto.GetGauge().GetValue()
+	}
+
+	assert.Equal(t, float64(1), recordLiveConnection(nil))
+	assert.Equal(t, float64(0), recordLiveConnection(errors.New("exit status 102")))
+
+	// the variables are checked when the collector is created
+	os.Setenv("CIB_file", "/tmp/cib.xml")
+	defer os.Unsetenv("CIB_file")
+	assert.Equal(t, float64(1), recordLiveConnection(nil))
+
+	pacemakerCollector, _ = NewCollector("../../test/fake_crm_mon.sh", "../../test/fake_cibadmin.sh", Options{}, false, log.NewNopLogger())
+	assert.Equal(t, "CIB_file", pacemakerCollector.cibFileVariable)
+	assert.Equal(t, float64(0), recordLiveConnection(nil))
+}
+
+func TestNodeState(t *testing.T) {
+	testCases := []struct {
+		node     crmmon.Node
+		expected string
+	}{
+		{crmmon.Node{Online: true}, "online"},
+		{crmmon.Node{Online: false}, "offline"},
+		{crmmon.Node{Online: false, Unclean: true}, "unclean"},
+		{crmmon.Node{Online: false, Pending: true}, "pending"},
+		{crmmon.Node{Online: true, Standby: true}, "standby"},
+		{crmmon.Node{Online: true, Standby: true, StandbyOnFail: true}, "standby_onfail"},
+		{crmmon.Node{Online: true, Maintenance: true}, "maintenance"},
+	}
+
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expected, nodeState(tc.node))
+	}
+}
+
+func TestPacemakerCollectRawOutput(t *testing.T) {
+	collector, _ := NewCollector("../../test/fake_crm_mon.sh", "../../test/fake_cibadmin.sh", Options{}, false, log.NewNopLogger())
+
+	var output bytes.Buffer
+	err := collector.CollectRawOutput(context.Background(), &output)
+
+	assert.NoError(t, err)
+	assert.Contains(t, output.String(), "### ../../test/fake_crm_mon.sh -X --inactive\n<?xml")
+	assert.Contains(t, output.String(), "### ../../test/fake_cibadmin.sh --query --local\n<cib")
+}
+
+func TestParseTimeoutSeconds(t *testing.T) {
+	testCases := []struct {
+		timeout  string
+		expected float64
+	}{
+		{"60", 60},
+		{"60s", 60},
+		{"10min", 600},
+		{"2h", 7200},
+		{"500ms", 0.5},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.timeout, func(t *testing.T) {
+			seconds, err := parseTimeoutSeconds(tc.timeout)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, seconds)
+		})
+	}
+
+	for _, timeout := range []string{"", "10days", "min"} {
+		_, err := parseTimeoutSeconds(timeout)
+		assert.Error(t, err, timeout)
+	}
+}
+
+func TestStandbyReason(t *testing.T) {
+	var CIB cib.Root
+	CIB.Configuration.Nodes = []cib.Node{
+		{Uname: "node01", InstanceAttributeSets: []cib.AttributeSet{{Attributes: []cib.Attribute{{Name: "standby", Value: "on"}}}}},
+	}
+
+	testCases := []struct {
+		name     string
+		node     crmmon.Node
+		expected string
+	}{
+		{"not in standby", crmmon.Node{Name: "node01"}, "none"},
+		{"onfail", crmmon.Node{Name: "node01", Standby: true, StandbyOnFail: true}, "onfail"},
+		{"manual", crmmon.Node{Name: "node01", Standby: true}, "manual"},
+		{"unknown", crmmon.Node{Name: "node02", Standby: true}, "unknown"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, standbyReason(tc.node, CIB))
+		})
+	}
+}
+
+func TestNoQuorumPolicy(t *testing.T) {
+	var CIB cib.Root
+	assert.Equal(t, "stop", noQuorumPolicy(CIB))
+
+	CIB.Configuration.CrmConfig.ClusterProperties = []cib.Attribute{{Name: "no-quorum-policy", Value: "Ignore"}}
+	assert.Equal(t, "ignore", noQuorumPolicy(CIB))
+}
+
+func TestGroupRunningMembers(t *testing.T) {
+	group := crmmon.Group{
+		Id: "grp_HA1_ASCS00",
+		Resources: []crmmon.Resource{
+			{Id: "rsc_ip_HA1_ASCS00", Active: true},
+			{Id: "rsc_fs_HA1_ASCS00", Active: true},
+			{Id: "rsc_sap_HA1_ASCS00", Failed: true},
+		},
+	}
+
+	assert.Equal(t, 2, groupRunningMembers(group))
+}
+
+func TestMonitorInterval(t *testing.T) {
+	testCases := []struct {
+		name       string
+		operations []cib.Operation
+		expected   float64
+	}{
+		{"no operations", nil, 0},
+		{"no monitor", []cib.Operation{{Name: "start", Interval: "0"}}, 0},
+		{"probe only", []cib.Operation{{Name: "monitor", Interval: "0"}}, 0},
+		{"monitor", []cib.Operation{{Name: "start", Interval: "0"}, {Name: "monitor", Interval: "2min"}}, 120},
+		{"monitor per role", []cib.Operation{{Name: "monitor", Interval: "61", Role: "Slave"}, {Name: "monitor", Interval: "60", Role: "Master"}}, 60},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			interval, err := monitorInterval(cib.Primitive{Id: "test", Operations: tc.operations})
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, interval)
+		})
+	}
+
+	_, err := monitorInterval(cib.Primitive{Id: "test", Operations: []cib.Operation{{Name: "monitor", Interval: "10 fortnights"}}})
+	assert.Error(t, err)
+}
+
+func TestStickiness(t *testing.T) {
+	stickiness := func(value string) []cib.Attribute {
+		return []cib.Attribute{{Name: "resource-stickiness", Value: value}}
+	}
+	testCases := []struct {
+		name            string
+		meta            []cib.Attribute
+		parentMeta      []cib.Attribute
+		defaults        []cib.Attribute
+		isCloneInstance bool
+		expected        float64
+	}{
+		{"unset", nil, nil, nil, false, 0},
+		{"unset clone instance", nil, nil, nil, true, 1},
+		{"defaults", nil, nil, stickiness("100"), true, 100},
+		{"parent", nil, stickiness("INFINITY"), stickiness("100"), false, math.Inf(1)},
+		{"own", stickiness("-5"), stickiness("INFINITY"), stickiness("100"), false, -5},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			value, err := primitiveStickiness(cib.Primitive{Id: "test", MetaAttributes: tc.meta}, tc.parentMeta, tc.defaults, tc.isCloneInstance)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, value)
+		})
+	}
+
+	_, err := primitiveStickiness(cib.Primitive{Id: "test", MetaAttributes: stickiness("sticky")}, nil, nil, false)
+	assert.Error(t, err)
+}
+
+func TestStickinessDefaults(t *testing.T) {
+	pacemakerCollector, _ := NewCollector("../../test/fake_crm_mon.sh", "../../test/fake_cibadmin.sh", Options{}, false, log.NewNopLogger())
+
+	CIB := cib.Root{}
+	CIB.Configuration.CrmConfig.ClusterProperties = []cib.Attribute{{Name: "default-resource-stickiness", Value: "50"}}
+	CIB.Configuration.Resources.Primitives = []cib.Primitive{{Id: "rsc_plain"}}
+	CIB.Configuration.Resources.Clones = []cib.Clone{{Id: "cln_test", Group: &cib.Group{Id: "grp_test", Primitives: []cib.Primitive{{Id: "rsc_member"}}}}}
+
+	stickiness := func() []float64 {
+		ch := make(chan prometheus.Metric, 2)
+		pacemakerCollector.recordStickiness(CIB, ch)
+		close(ch)
+		var values []float64
+		for metric := range ch {
+			metricDto := &dto.Metric{}
+			metric.Write(metricDto)
+			values = append(values, metricDto.GetGauge().GetValue())
+		}
+		return values
+	}
+
+	// the legacy cluster property applies to the members of cloned groups too
+	assert.Equal(t, []float64{50, 50}, stickiness())
+
+	// the resource defaults take precedence over the legacy cluster property
+	CIB.Configuration.RscDefaults = []cib.Attribute{{Name: "resource-stickiness", Value: "100"}}
+	assert.Equal(t, []float64{100, 100}, stickiness())
+
+	// without any default, the members of cloned groups are clone instances
+	CIB.Configuration.RscDefaults = nil
+	CIB.Configuration.CrmConfig.ClusterProperties = nil
+	assert.Equal(t, []float64{0, 1}, stickiness())
+}
+
+func TestOcfReturnCodeText(t *testing.T) {
+	assert.Equal(t, "ok", ocfReturnCodeText(0))
+	assert.Equal(t, "not_installed", ocfReturnCodeText(5))
+	assert.Equal(t, "not_running", ocfReturnCodeText(7))
+	assert.Equal(t, "degraded_promoted", ocfReturnCodeText(191))
+	assert.Equal(t, "42", ocfReturnCodeText(42))
+}
+
+func TestConfigVerification(t *testing.T) {
+	collector, err := NewCollector("../../test/fake_crm_mon.sh", "../../test/fake_cibadmin.sh", Options{CrmVerifyPath: "../../test/fake_crm_verify.sh", CrmVerifyInterval: time.Hour}, false, log.NewNopLogger())
+	assert.NoError(t, err)
+	assert.NotNil(t, collector.configVerifier)
+	defer collector.Stop()
+
+	// the first check runs in the background as soon as the collector is created
+	assert.Eventually(t, func() bool {
+		_, _, _, ok := collector.configVerifier.result()
+		return ok
+	}, 5*time.Second, 10*time.Millisecond)
+
+	configErrors, configWarnings, verifiedAt, _ := collector.configVerifier.result()
+	assert.Equal(t, 2, configErrors)
+	assert.Equal(t, 1, configWarnings)
+	assert.False(t, verifiedAt.IsZero())
+}
+
+func TestConfigVerificationDisabled(t *testing.T) {
+	collector, err := NewCollector("../../test/fake_crm_mon.sh", "../../test/fake_cibadmin.sh", Options{CrmVerifyPath: "../../test/fake_crm_verify.sh"}, false, log.NewNopLogger())
+	assert.NoError(t, err)
+	assert.Nil(t, collector.configVerifier)
+
+	collector, err = NewCollector("../../test/fake_crm_mon.sh", "../../test/fake_cibadmin.sh", Options{CrmVerifyPath: "../../test/nonexistent", CrmVerifyInterval: time.Hour}, false, log.NewNopLogger())
+	assert.NoError(t, err)
+	assert.Nil(t, collector.configVerifier)
+}
+
+func TestParseCrmVerify(t *testing.T) {
+	configErrors, configWarnings := parseCrmVerify([]byte("warning: unpack_config:\tBlind faith: not fencing unseen nodes\n"))
+	assert.Equal(t, 0, configErrors)
+	assert.Equal(t, 1, configWarnings)
+
+	configErrors, configWarnings = parseCrmVerify(nil)
+	assert.Equal(t, 0, configErrors)
+	assert.Equal(t, 0, configWarnings)
+}
+
+func TestPlacementScores(t *testing.T) {
+	collector, err := NewCollector("../../test/fake_crm_mon.sh", "../../test/fake_cibadmin.sh", Options{CrmSimulatePath: "../../test/fake_crm_simulate.sh", CrmSimulateInterval: time.Hour}, false, log.NewNopLogger())
+	assert.NoError(t, err)
+	assert.NotNil(t, collector.placementScorer)
+	defer collector.Stop()
+
+	// the first run happens in the background as soon as the collector is created
+	assert.Eventually(t, func() bool {
+		_, _, ok := collector.placementScorer.result()
+		return ok
+	}, 5*time.Second, 10*time.Millisecond)
+
+	scores, scoredAt, _ := collector.placementScorer.result()
+	assert.Len(t, scores, 4)
+	assert.Equal(t, 2000.0, scores[placement{"rsc_ip_PRD_HDB00", "node01"}])
+	assert.False(t, scoredAt.IsZero())
+}
+
+func TestPlacementScoresDisabled(t *testing.T) {
+	collector, err := NewCollector("../../test/fake_crm_mon.sh", "../../test/fake_cibadmin.sh", Options{CrmSimulatePath: "../../test/fake_crm_simulate.sh"}, false, log.NewNopLogger())
+	assert.NoError(t, err)
+	assert.Nil(t, collector.placementScorer)
+
+	collector, err = NewCollector("../../test/fake_crm_mon.sh", "../../test/fake_cibadmin.sh", Options{CrmSimulatePath: "../../test/nonexistent", CrmSimulateInterval: time.Hour}, false, log.NewNopLogger())
+	assert.NoError(t, err)
+	assert.Nil(t, collector.placementScorer)
+}
+
+func TestParseCrmSimulateScores(t *testing.T) {
+	output := `native_color: rsc_ip allocation score on node01: INFINITY
+pcmk__primitive_assign: rsc_ip allocation score on node02: -INFINITY
+pcmk__primitive_assign: rsc_fs allocation score on node01: -300
+group_color: grp allocation score on node01: 0
+rsc_ms:0 promotion score on node01: 150
+pcmk__primitive_assign: rsc_bad allocation score on node01: unknown
+`
+	assert.Equal(t, map[placement]float64{
+		{"rsc_ip", "node01"}: math.Inf(1),
+		{"rsc_ip", "node02"}: math.Inf(-1),
+		{"rsc_fs", "node01"}: -300,
+	}, parseCrmSimulateScores([]byte(output)))
+
+	assert.Empty(t, parseCrmSimulateScores(nil))
+}
+
+func TestLastRuns(t *testing.T) {
+	var CIB cib.Root
+	assert.Empty(t, lastRuns(CIB))
+
+	CIB.Status.NodeStates = []cib.NodeState{
+		{Uname: "node01", LrmResources: []cib.LrmResource{{Id: "rsc_ip", Operations: []cib.LrmRscOp{
+			{Operation: "start", LastRun: "1000", LastRcChange: "1000"},
+			{Operation: "monitor", Interval: "0", LastRun: "900", LastRcChange: "900"},
+			{Operation: "monitor", Interval: "10000", LastRcChange: "1010"},
+		}}}},
+		{Uname: "node02", LrmResources: []cib.LrmResource{{Id: "rsc_ip", Operations: []cib.LrmRscOp{
+			{Operation: "monitor", Interval: "0", LastRun: "1200", LastRcChange: "800"},
+			{Operation: "stop", LastRun: "invalid"},
+		}}}},
+	}
+
+	assert.Equal(t, map[string]map[lastRunOperation]int64{
+		"rsc_ip": {
+			{name: "start", interval: "0"}:    1000,
+			{name: "monitor", interval: "0"}:  1200,
+			{name: "monitor", interval: "10"}: 1010,
+		},
+	}, lastRuns(CIB))
+}
+
+func TestMigrationTargets(t *testing.T) {
+	var CIB cib.Root
+	assert.Empty(t, migrationTargets(CIB))
+
+	CIB.Status.NodeStates = []cib.NodeState{
+		{Uname: "node01", LrmResources: []cib.LrmResource{
+			{Id: "rsc_vm_migrating", Operations: []cib.LrmRscOp{
+				{Id: "rsc_vm_migrating_last_0", Operation: "migrate_to", MigrateSource: "node01", MigrateTarget: "node02"},
+			}},
+			{Id: "rsc_vm_migrated", Operations: []cib.LrmRscOp{
+				{Id: "rsc_vm_migrated_last_0", Operation: "stop"},
+				{Id: "rsc_vm_migrated_last_failure_0", Operation: "migrate_to", MigrateSource: "node01", MigrateTarget: "node02"},
+			}},
+		}},
+		{Uname: "node02", LrmResources: []cib.LrmResource{
+			{Id: "rsc_vm_migrated", Operations: []cib.LrmRscOp{
+				{Id: "rsc_vm_migrated_last_0", Operation: "migrate_from", MigrateSource: "node01", MigrateTarget: "node02"},
+			}},
+		}},
+	}
+
+	assert.Equal(t, map[string]string{"rsc_vm_migrating": "node02"}, migrationTargets(CIB))
+}
+
+func TestCibOutOfSync(t *testing.T) {
+	assert.Empty(t, cibOutOfSync(map[string]cibVersion{
+		"node01": {adminEpoch: 0, epoch: 10},
+		"node02": {adminEpoch: 0, epoch: 10},
+	}))
+	assert.Equal(t, []string{"node02", "node03"}, cibOutOfSync(map[string]cibVersion{
+		"node01": {adminEpoch: 0, epoch: 10},
+		"node02": {adminEpoch: 0, epoch: 9},
+		"node03": {adminEpoch: 0, epoch: 8},
+	}))
+	// admin_epoch takes precedence over epoch
+	assert.Equal(t, []string{"node01"}, cibOutOfSync(map[string]cibVersion{
+		"node01": {adminEpoch: 0, epoch: 10},
+		"node02": {adminEpoch: 1, epoch: 1},
+	}))
+}
+
+func TestCibSyncCheck(t *testing.T) {
+	checker := newCibSyncChecker("../../test/fake_cibadmin_node.sh", time.Hour, log.NewNopLogger())
+	defer checker.stop()
+
+	// the first check runs before any node is known, so it has no result
+	_, _, _, ok := checker.result()
+	assert.False(t, ok)
+
+	checker.setNodes([]string{"node01", "node02", "node03"})
+	checker.check(context.Background())
+
+	outOfSync, unknown, checkedAt, ok := checker.result()
+	assert.True(t, ok)
+	assert.Equal(t, 1, outOfSync)
+	assert.Equal(t, 1, unknown)
+	assert.False(t, checkedAt.IsZero())
+}
+
+func TestQueryCibVersion(t *testing.T) {
+	checker := &cibSyncChecker{cibAdminPath: "../../test/fake_cibadmin_node.sh", logger: log.NewNopLogger()}
+
+	version, err := checker.queryCibVersion(context.Background(), "node01")
+	assert.NoError(t, err)
+	assert.Equal(t, cibVersion{adminEpoch: 0, epoch: 6881}, version)
+
+	version, err = checker.queryCibVersion(context.Background(), "node02")
+	assert.NoError(t, err)
+	assert.Equal(t, cibVersion{adminEpoch: 0, epoch: 6880}, version)
+
+	_, err = checker.queryCibVersion(context.Background(), "node03")
+	assert.Error(t, err)
+}
+
+func TestCibSyncCheckDisabled(t *testing.T) {
+	pacemakerCollector, err := NewCollector("../../test/fake_crm_mon.sh", "../../test/fake_cibadmin.sh", Options{}, false, log.NewNopLogger())
+	assert.NoError(t, err)
+	assert.Nil(t, pacemakerCollector.cibSyncChecker)
+
+	pacemakerCollector, err = NewCollector("../../test/fake_crm_mon.sh", "../../test/fake_cibadmin.sh", Options{CibSyncInterval: time.Hour}, false, log.NewNopLogger())
+	assert.NoError(t, err)
+	assert.NotNil(t, pacemakerCollector.cibSyncChecker)
+	pacemakerCollector.Stop()
+}
+
+func TestIsSymmetricCluster(t *testing.T) {
+	var CIB cib.Root
+	assert.True(t, isSymmetricCluster(CIB))
+
+	CIB.Configuration.CrmConfig.ClusterProperties = []cib.Attribute{{Name: "symmetric-cluster", Value: "false"}}
+	assert.False(t, isSymmetricCluster(CIB))
+
+	CIB.Configuration.CrmConfig.ClusterProperties = []cib.Attribute{{Name: "symmetric-cluster", Value: "yes"}}
+	assert.True(t, isSymmetricCluster(CIB))
+}
+
+func TestIsStartFailureFatal(t *testing.T) {
+	var CIB cib.Root
+	assert.True(t, isStartFailureFatal(CIB))
+
+	CIB.Configuration.CrmConfig.ClusterProperties = []cib.Attribute{{Name: "start-failure-is-fatal", Value: "false"}}
+	assert.False(t, isStartFailureFatal(CIB))
+}
+
+func TestStatusFreshness(t *testing.T) {
+	crmMon, err := crmmon.NewCrmMonParser("../../test/fake_crm_mon.sh", false, log.NewNopLogger()).Parse()
+	assert.NoError(t, err)
+
+	latest, ok := statusFreshness(crmMon)
+	assert.True(t, ok)
+	assert.Equal(t, time.Date(2020, 2, 24, 9, 46, 58, 0, time.Local), latest)
+
+	_, ok = statusFreshness(crmmon.Root{})
+	assert.False(t, ok)
+}
+
+func TestParseOperationTime(t *testing.T) {
+	parsed, err := parseOperationTime("Thu Oct 10 12:57:33 2019")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2019, 10, 10, 12, 57, 33, 0, time.Local).Unix(), parsed.Unix())
+
+	parsed, err = parseOperationTime("2019-10-10 12:57:33 +02:00")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1570705053), parsed.Unix())
+
+	_, err = parseOperationTime("")
+	assert.Error(t, err)
+}
+
+func TestCloneInstanceLimits(t *testing.T) {
+	limits, err := cloneInstanceLimits(cib.Clone{Id: "test"}, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, cloneLimits{max: 3, nodeMax: 1, promotedMax: 1}, limits)
+
+	limits, err = cloneInstanceLimits(cib.Clone{Id: "test", MetaAttributes: []cib.Attribute{
+		{Name: "clone-max", Value: "4"},
+		{Name: "clone-node-max", Value: "2"},
+		{Name: "master-max", Value: "2"},
+	}}, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, cloneLimits{max: 4, nodeMax: 2, promotedMax: 2}, limits)
+
+	// promoted-max takes precedence over the deprecated master-max
+	limits, err = cloneInstanceLimits(cib.Clone{Id: "test", MetaAttributes: []cib.Attribute{
+		{Name: "master-max", Value: "2"},
+		{Name: "promoted-max", Value: "3"},
+	}}, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, limits.promotedMax)
+
+	_, err = cloneInstanceLimits(cib.Clone{Id: "test", MetaAttributes: []cib.Attribute{{Name: "clone-max", Value: "many"}}}, 3)
+	assert.Error(t, err)
+}
+
+func TestClonePromotedInstances(t *testing.T) {
+	var clone crmmon.Clone
+	err := xml.Unmarshal([]byte(`
+<clone id="msl_test" multi_state="true">
+	<resource id="rsc_test" role="Promoted" active="true"><node name="node02" id="2"/></resource>
+	<resource id="rsc_test" role="Unpromoted" active="true"><node name="node01" id="1"/></resource>
+	<resource id="rsc_test" role="Master" active="false"/>
+</clone>`), &clone)
+	assert.NoError(t, err)
+
+	promoted := clonePromotedInstances(clone)
+	assert.Len(t, promoted, 1)
+	assert.Equal(t, "node02", promoted[0].Node.Name)
+
+	clone.Resources[0].Role = "Unpromoted"
+	assert.Empty(t, clonePromotedInstances(clone))
+}
+
+func TestNodeMembership(t *testing.T) {
+	pacemakerCollector, err := NewCollector("../../test/fake_crm_mon.sh", "../../test/fake_cibadmin.sh", Options{}, false, log.NewNopLogger())
+	assert.NoError(t, err)
+
+	CIB := cib.Root{}
+	CIB.Status.NodeStates = []cib.NodeState{
+		{Uname: "node01", InCcm: "true", Crmd: "online", Join: "member"},
+		// in the membership, but the controller didn't join yet
+		{Uname: "node02", InCcm: "1634567890", Crmd: "1634567891", Join: "pending"},
+		{Uname: "node03", InCcm: "false", Crmd: "offline", Join: "down"},
+		// remote nodes have no controller
+		{Uname: "remote01", InCcm: "true", RemoteNode: "true"},
+	}
+
+	ch := make(chan prometheus.Metric, 7)
+	pacemakerCollector.recordNodeMembership(CIB, ch)
+	close(ch)
+
+	values := make(map[string]float64)
+	for metric := range ch {
+		metricDto := &dto.Metric{}
+		metric.Write(metricDto)
+		name := "crmd_joined"
+		if strings.Contains(metric.Desc().String(), "node_in_ccm") {
+			name = "in_ccm"
+		}
+		values[name+"/"+metricDto.GetLabel()[0].GetValue()] = metricDto.GetGauge().GetValue()
+	}
+
+	assert.Equal(t, map[string]float64{
+		"in_ccm/node01": 1, "crmd_joined/node01": 1,
+		"in_ccm/node02": 1, "crmd_joined/node02": 0,
+		"in_ccm/node03": 0, "crmd_joined/node03": 0,
+		"in_ccm/remote01": 1,
+	}, values)
+}
+
+func TestCibUpdatesBetween(t *testing.T) {
+	version := func(adminEpoch int, epoch int, numUpdates int) cibFullVersion {
+		return cibFullVersion{cibVersion{adminEpoch, epoch}, numUpdates}
+	}
+
+	assert.Equal(t, float64(0), cibUpdatesBetween(version(0, 10, 5), version(0, 10, 5)))
+	// status updates
+	assert.Equal(t, float64(3), cibUpdatesBetween(version(0, 10, 5), version(0, 10, 8)))
+	// two configuration changes, then a status update
+	assert.Equal(t, float64(3), cibUpdatesBetween(version(0, 10, 5), version(0, 12, 1)))
+	// the CIB was replaced with an older one, or its admin_epoch was bumped
+	assert.Equal(t, float64(2), cibUpdatesBetween(version(0, 10, 5), version(0, 9, 2)))
+	assert.Equal(t, float64(2), cibUpdatesBetween(version(0, 10, 5), version(1, 1, 2)))
+}
+
+func TestCibUpdatesTotal(t *testing.T) {
+	pacemakerCollector, _ := NewCollector("../../test/fake_crm_mon.sh", "../../test/fake_cibadmin.sh", Options{}, false, log.NewNopLogger())
+
+	recordCibUpdates := func(epoch string, numUpdates string) float64 {
+		ch := make(chan prometheus.Metric, 1)
+		pacemakerCollector.recordCibUpdates(cib.Root{AdminEpoch: "0", Epoch: epoch, NumUpdates: numUpdates}, ch)
+		metricDto := &dto.Metric{}
+		(<-ch).Write(metricDto)
+		return metricDto.GetCounter().GetValue()
+	}
+
+	// the first version is just the baseline
+	assert.Equal(t, float64(0), recordCibUpdates("10", "5"))
+	assert.Equal(t, float64(2), recordCibUpdates("10", "7"))
+	assert.Equal(t, float64(4), recordCibUpdates("11", "1"))
+
+	// an unparsable version is skipped, without affecting the count
+	ch := make(chan prometheus.Metric, 1)
+	pacemakerCollector.recordCibUpdates(cib.Root{AdminEpoch: "0", Epoch: "11"}, ch)
+	close(ch)
+	assert.Empty(t, ch)
+	assert.Equal(t, float64(5), recordCibUpdates("11", "2"))
+}
+
+func TestDcVersionWithoutDc(t *testing.T) {
+	pacemakerCollector, _ := NewCollector("../../test/fake_crm_mon.sh", "../../test/fake_cibadmin.sh", Options{}, false, log.NewNopLogger())
+
+	// e.g. while the DC is being elected
+	crmMon := crmmon.Root{}
+	crmMon.Summary.CurrentDc.Present = false
+	ch := make(chan prometheus.Metric, 1)
+	pacemakerCollector.recordDcVersion(crmMon, cib.Root{CrmFeatureSet: "3.1.0"}, ch)
+	close(ch)
+
+	assert.Empty(t, ch)
+}
+
+func TestNodeType(t *testing.T) {
+	assert.Equal(t, "member", nodeType(crmmon.Node{Name: "node01", Type: "member"}))
+	assert.Equal(t, "remote", nodeType(crmmon.Node{Name: "remote01", Type: "remote"}))
+	assert.Equal(t, "guest", nodeType(crmmon.Node{Name: "guest01", Type: "remote", IdAsResource: "vm_guest01"}))
+}
+
+func TestNodeTransitions(t *testing.T) {
+	pacemakerCollector, _ := NewCollector("../../test/fake_crm_mon.sh", "../../test/fake_cibadmin.sh", Options{}, false, log.NewNopLogger())
+
+	recordNodeTransitions := func(online bool, otherNodes ...crmmon.Node) map[string]float64 {
+		ch := make(chan prometheus.Metric, len(nodeMembershipStates)*(1+len(otherNodes)))
+		nodes := append([]crmmon.Node{{Name: "node01", Online: online}}, otherNodes...)
+		pacemakerCollector.recordNodeTransitions(crmmon.Root{Nodes: nodes}, ch)
+		close(ch)
+
+		transitions := make(map[string]float64)
+		for metric := range ch {
+			metricDto := &dto.Metric{}
+			metric.Write(metricDto)
+			labels := map[string]string{}
+			for _, label := range metricDto.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+			if labels["node"] == "node01" {
+				transitions[labels["to_state"]] = metricDto.GetCounter().GetValue()
+			}
+		}
+		return transitions
+	}
+
+	assert.Equal(t, map[string]float64{"online": 0, "offline": 0}, recordNodeTransitions(true))
+	assert.Equal(t, map[string]float64{"online": 0, "offline": 0}, recordNodeTransitions(true))
+	assert.Equal(t, map[string]float64{"online": 0, "offline": 1}, recordNodeTransitions(false))
+	assert.Equal(t, map[string]float64{"online": 1, "offline": 1}, recordNodeTransitions(true))
+	assert.Equal(t, map[string]float64{"online": 1, "offline": 2}, recordNodeTransitions(false))
+
+	// the nodes removed from the cluster are forgotten
+	recordNodeTransitions(false, crmmon.Node{Name: "node02", Online: true})
+	assert.Len(t, pacemakerCollector.lastNodeStates, 2)
+	recordNodeTransitions(false)
+	assert.Equal(t, map[string]string{"node01": "offline"}, pacemakerCollector.lastNodeStates)
+	assert.Len(t, pacemakerCollector.nodeTransitions, 1)
+}
+
+func TestResourceStates(t *testing.T) {
+	states := newResourceStates()
+	start := time.Unix(1000, 0)
+
+	current := states.update(map[string]string{"rsc_ip": "started", "rsc_db": "stopped"}, start)
+	assert.Equal(t, map[string]resourceState{"rsc_ip": {"started", start}, "rsc_db": {"stopped", start}}, current)
+
+	// only the resources whose state changed are reset
+	current = states.update(map[string]string{"rsc_ip": "started", "rsc_db": "started"}, start.Add(time.Minute))
+	assert.Equal(t, map[string]resourceState{"rsc_ip": {"started", start}, "rsc_db": {"started", start.Add(time.Minute)}}, current)
+
+	// the resources that are gone start over when they come back
+	states.update(map[string]string{"rsc_db": "started"}, start.Add(2*time.Minute))
+	current = states.update(map[string]string{"rsc_ip": "started", "rsc_db": "started"}, start.Add(3*time.Minute))
+	assert.Equal(t, map[string]resourceState{"rsc_ip": {"started", start.Add(3 * time.Minute)}, "rsc_db": {"started", start.Add(time.Minute)}}, current)
+}
+
+func TestCurrentResourceStates(t *testing.T) {
+	crmMon := crmmon.Root{
+		Resources: []crmmon.Resource{
+			{Id: "rsc_ip", Role: "Started", Active: true},
+			{Id: "rsc_fs", Role: "Started", Active: true, Failed: true},
+			{Id: "rsc_db", Role: "Stopped"},
+		},
+		Clones: []crmmon.Clone{
+			{Id: "msl_db", Resources: []crmmon.Resource{
+				{Id: "rsc_hana", Role: "Promoted", Active: true},
+				{Id: "rsc_hana", Role: "Unpromoted", Active: true},
+				{Id: "rsc_hana", Role: "Unpromoted", Active: true},
+			}},
+			{Id: "cln_base", Groups: []crmmon.Group{
+				{Id: "grp_base:0", Resources: []crmmon.Resource{{Id: "rsc_dlm", Role: "Started", Active: true}}},
+				{Id: "grp_base:1", Resources: []crmmon.Resource{{Id: "rsc_dlm", Role: "Stopped"}}},
+			}},
+		},
+	}
+
+	assert.Equal(t, map[string]string{
+		"rsc_ip":   "started",
+		"rsc_fs":   "failed",
+		"rsc_db":   "stopped",
+		"rsc_hana": "promoted,unpromoted",
+		"rsc_dlm":  "started,stopped",
+	}, currentResourceStates(crmMon))
+}
+
+func TestOperationDrift(t *testing.T) {
+	testCases := []struct {
+		name     string
+		origin   string
+		interval string
+		lastRun  string
+		expected float64
+	}{
+		{"on time", "2019-10-10 02:00:00", "1h", "Thu Oct 10 14:00:00 2019", 0},
+		{"late", "2019-10-10T02:00:00", "1h", "Thu Oct 10 14:00:30 2019", 30},
+		{"early", "2019-10-10", "24h", "Wed Oct  9 23:59:50 2019", -10},
+		{"origin in the future", "2019-10-11T02:00:00Z", "60", "Thu Oct 10 14:00:05 2019", 5},
+		{"newer crm_mon", "2019-10-10 02:00:00", "10min", "2019-10-10 14:02:00 +00:00", 120},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			drift, err := operationDrift(cib.Operation{Name: "monitor", Interval: tc.interval, IntervalOrigin: tc.origin}, tc.lastRun)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, drift)
+		})
+	}
+
+	_, err := operationDrift(cib.Operation{Name: "monitor", Interval: "1h", IntervalOrigin: "yesterday"}, "Thu Oct 10 14:00:00 2019")
+	assert.Error(t, err)
+}
+
+func TestSameInterval(t *testing.T) {
+	assert.True(t, sameInterval("10", "10000ms"))
+	assert.True(t, sameInterval("1min", "60s"))
+	assert.False(t, sameInterval("10", "20000ms"))
+	assert.False(t, sameInterval("0", "0ms"))
+	assert.False(t, sameInterval("", "10000ms"))
+}
+
+func TestResourcesMaintenance(t *testing.T) {
+	maintenance := func(value string) []cib.Attribute {
+		return []cib.Attribute{{Name: "maintenance", Value: value}}
+	}
+
+	CIB := cib.Root{}
+	CIB.Configuration.Resources.Primitives = []cib.Primitive{{Id: "rsc_plain"}, {Id: "rsc_own", MetaAttributes: maintenance("true")}}
+	CIB.Configuration.Resources.Clones = []cib.Clone{{MetaAttributes: maintenance("on"), Primitive: cib.Primitive{Id: "rsc_cloned"}}}
+	CIB.Configuration.Resources.Groups = []cib.Group{{
+		MetaAttributes: maintenance("true"),
+		Primitives:     []cib.Primitive{{Id: "rsc_member"}, {Id: "rsc_excluded", MetaAttributes: maintenance("false")}},
+	}}
+
+	assert.Equal(t, map[string]bool{
+		"rsc_plain":    false,
+		"rsc_own":      true,
+		"rsc_cloned":   true,
+		"rsc_member":   true,
+		"rsc_excluded": false,
+	}, resourcesMaintenance(CIB))
+}
+
+func TestForEachPrimitive(t *testing.T) {
+	CIB := cib.Root{}
+	err := xml.Unmarshal([]byte(`<cib>
+	<configuration>
+		<resources>
+			<primitive id="rsc_plain"/>
+			<group id="grp_test">
+				<meta_attributes><nvpair name="target-role" value="Started"/></meta_attributes>
+				<primitive id="rsc_member"/>
+			</group>
+			<clone id="cln_test">
+				<meta_attributes><nvpair name="target-role" value="Stopped"/></meta_attributes>
+				<primitive id="rsc_cloned"/>
+			</clone>
+			<clone id="cln_grp_test">
+				<meta_attributes><nvpair name="target-role" value="Stopped"/></meta_attributes>
+				<group id="grp_cloned">
+					<meta_attributes><nvpair name="target-role" value="Started"/></meta_attributes>
+					<primitive id="rsc_cloned_member"/>
+				</group>
+			</clone>
+			<master id="msl_test">
+				<primitive id="rsc_promotable"/>
+			</master>
+		</resources>
+	</configuration>
+</cib>`), &CIB)
+	assert.NoError(t, err)
+
+	type visited struct {
+		parentTargetRole string
+		isCloneInstance  bool
+	}
+	primitives := make(map[string]visited)
+	forEachPrimitive(CIB, func(primitive cib.Primitive, parentMetaAttributes []cib.Attribute, isCloneInstance bool) {
+		targetRole, _ := getAttribute(parentMetaAttributes, "target-role")
+		primitives[primitive.Id] = visited{targetRole, isCloneInstance}
+	})
+
+	assert.Equal(t, map[string]visited{
+		"rsc_plain":         {"", false},
+		"rsc_member":        {"Started", false},
+		"rsc_cloned":        {"Stopped", true},
+		"rsc_cloned_member": {"Started", true},
+		"rsc_promotable":    {"", true},
+	}, primitives)
+
+	// a stopped clone stops the members of its group, whatever the target-role of the group
+	disabled := resourcesDisabled(CIB)
+	assert.True(t, disabled["rsc_cloned_member"])
+	assert.True(t, disabled["grp_cloned"])
+	assert.False(t, disabled["rsc_member"])
+}
+
+func TestIsResourceFailed(t *testing.T) {
+	assert.False(t, isResourceFailed(crmmon.Resource{Role: "Started"}))
+	assert.True(t, isResourceFailed(crmmon.Resource{Role: "Started", Failed: true}))
+	assert.True(t, isResourceFailed(crmmon.Resource{Role: "Stopped", Failed: true}))
+	assert.False(t, isResourceFailed(crmmon.Resource{Role: "Started", Failed: true, FailureIgnored: true}))
+}
+
+func TestClusterMaintenanceImpliesAllScopes(t *testing.T) {
+	pacemakerCollector, _ := NewCollector("../../test/fake_crm_mon.sh", "../../test/fake_cibadmin.sh", Options{}, false, log.NewNopLogger())
+
+	crmMon := crmmon.Root{Nodes: []crmmon.Node{{Name: "node01"}, {Name: "node02"}}}
+	crmMon.Summary.ClusterOptions.MaintenanceMode = true
+	CIB := cib.Root{}
+	CIB.Configuration.Resources.Primitives = []cib.Primitive{{Id: "rsc_ip"}}
+
+	ch := make(chan prometheus.Metric, 4)
+	pacemakerCollector.recordMaintenance(crmMon, CIB, ch)
+	close(ch)
+
+	targets := make(map[string]float64)
+	for metric := range ch {
+		metricDto := &dto.Metric{}
+		metric.Write(metricDto)
+		labels := make(map[string]string)
+		for _, label := range metricDto.GetLabel() {
+			labels[label.GetName()] = label.GetValue()
+		}
+		targets[labels["scope"]+"/"+labels["target"]] = metricDto.GetGauge().GetValue()
+	}
+
+	assert.Equal(t, map[string]float64{
+		"cluster/":        1,
+		"node/node01":     1,
+		"node/node02":     1,
+		"resource/rsc_ip": 1,
+	}, targets)
+}
+
+func TestStonithTimeouts(t *testing.T) {
+	pacemakerCollector, _ := NewCollector("../../test/fake_crm_mon.sh", "../../test/fake_cibadmin.sh", Options{}, false, log.NewNopLogger())
+
+	CIB := cib.Root{}
+	CIB.Configuration.Resources.Primitives = []cib.Primitive{
+		{Id: "rsc_ip", Class: "ocf", InstanceAttributes: []cib.Attribute{{Name: "pcmk_monitor_timeout", Value: "10"}}},
+		{Id: "fence_a", Class: "stonith", InstanceAttributes: []cib.Attribute{{Name: "pcmk_off_timeout", Value: "90s"}, {Name: "pcmk_delay_max", Value: "30s"}}},
+	}
+	CIB.Configuration.Resources.Clones = []cib.Clone{{Primitive: cib.Primitive{
+		Id: "fence_b", Class: "stonith", InstanceAttributes: []cib.Attribute{{Name: "pcmk_reboot_timeout", Value: "soon"}},
+	}}}
+
+	ch := make(chan prometheus.Metric, 4)
+	pacemakerCollector.recordStonithTimeouts(CIB, ch)
+	close(ch)
+
+	timeouts := make(map[string]float64)
+	for metric := range ch {
+		metricDto := &dto.Metric{}
+		metric.Write(metricDto)
+		labels := make(map[string]string)
+		for _, label := range metricDto.GetLabel() {
+			labels[label.GetName()] = label.GetValue()
+		}
+		timeouts[labels["device"]+"/"+labels["action"]] = metricDto.GetGauge().GetValue()
+	}
+
+	// the stonith-timeout falls back to the default, and only the valid timeouts of fencing devices are recorded
+	assert.Equal(t, map[string]float64{"/": 60, "fence_a/off": 90}, timeouts)
+}
+
+func TestStonithDevices(t *testing.T) {
+	pacemakerCollector, _ := NewCollector("../../test/fake_crm_mon.sh", "../../test/fake_cibadmin.sh", Options{}, false, log.NewNopLogger())
+
+	CIB := cib.Root{}
+	CIB.Configuration.Resources.Groups = []cib.Group{{Id: "grp_fencing", Primitives: []cib.Primitive{{Id: "fence_a", Class: "stonith"}}}}
+	CIB.Configuration.Resources.Clones = []cib.Clone{{Id: "cln_fencing", Primitive: cib.Primitive{Id: "fence_b", Class: "stonith"}}}
+
+	crmMon := crmmon.Root{}
+	crmMon.Groups = []crmmon.Group{{Id: "grp_fencing", Resources: []crmmon.Resource{{Id: "fence_a", Active: false}}}}
+	crmMon.Clones = []crmmon.Clone{{Id: "cln_fencing", Unique: true, Resources: []crmmon.Resource{{Id: "fence_b:0", Active: true}, {Id: "fence_b:1", Active: true}}}}
+
+	ch := make(chan prometheus.Metric, 2)
+	pacemakerCollector.recordStonithDevices(crmMon, CIB, ch)
+	close(ch)
+
+	// the grouped device is configured but stopped, while the instances of the unique clone count as one active device
+	var values []float64
+	for metric := range ch {
+		metricDto := &dto.Metric{}
+		metric.Write(metricDto)
+		values = append(values, metricDto.GetGauge().GetValue())
+	}
+	assert.Equal(t, []float64{2, 1}, values)
+}
+
+func TestStonithWatchdogTimeout(t *testing.T) {
+	pacemakerCollector, _ := NewCollector("../../test/fake_crm_mon.sh", "../../test/fake_cibadmin.sh", Options{}, false, log.NewNopLogger())
+
+	recordTimeout := func(value string) []float64 {
+		CIB := cib.Root{}
+		if value != "" {
+			CIB.Configuration.CrmConfig.ClusterProperties = []cib.Attribute{{Name: "stonith-watchdog-timeout", Value: value}}
+		}
+
+		ch := make(chan prometheus.Metric, 1)
+		pacemakerCollector.recordStonithWatchdogTimeout(CIB, ch)
+		close(ch)
+
+		var timeouts []float64
+		for metric := range ch {
+			metricDto := &dto.Metric{}
+			metric.Write(metricDto)
+			timeouts = append(timeouts, metricDto.GetGauge().GetValue())
+		}
+		return timeouts
+	}
+
+	assert.Equal(t, []float64{0}, recordTimeout(""))
+	assert.Equal(t, []float64{10}, recordTimeout("10s"))
+	assert.Equal(t, []float64{-1}, recordTimeout("-1"))
+	assert.Empty(t, recordTimeout("soon"))
+}
+
+func TestResourcesNeedingCleanup(t *testing.T) {
+	pacemakerCollector, _ := NewCollector("../../test/fake_crm_mon.sh", "../../test/fake_cibadmin.sh", Options{}, false, log.NewNopLogger())
+
+	failureTimeout := func(value string) []cib.Attribute {
+		return []cib.Attribute{{Name: "failure-timeout", Value: value}}
+	}
+
+	CIB := cib.Root{}
+	CIB.Configuration.Resources.Primitives = []cib.Primitive{
+		{Id: "rsc_expiring", MetaAttributes: failureTimeout("10min")},
+		{Id: "rsc_sticky"},
+		{Id: "rsc_disabled", MetaAttributes: failureTimeout("0")},
+		{Id: "rsc_invalid", MetaAttributes: failureTimeout("soon")},
+		{Id: "rsc_clean"},
+	}
+	CIB.Configuration.Resources.Clones = []cib.Clone{{Primitive: cib.Primitive{Id: "rsc_unique"}}}
+	CIB.Configuration.Resources.Groups = []cib.Group{{
+		MetaAttributes: failureTimeout("60"),
+		Primitives:     []cib.Primitive{{Id: "rsc_member"}},
+	}}
+
+	crmMon := crmmon.Root{}
+	err := xml.Unmarshal([]byte(`<crm_mon>
+		<node_history>
+			<node name="node01">
+				<resource_history id="rsc_expiring" fail-count="3"/>
+				<resource_history id="rsc_sticky" fail-count="1"/>
+				<resource_history id="rsc_clean" fail-count="0"/>
+				<resource_history id="rsc_unique:0" fail-count="1"/>
+				<resource_history id="rsc_member" fail-count="2"/>
+			</node>
+			<node name="node02">
+				<resource_history id="rsc_sticky" fail-count="1000000"/>
+				<resource_history id="rsc_disabled" fail-count="1"/>
+				<resource_history id="rsc_invalid" fail-count="1"/>
+				<resource_history id="rsc_unique:1" fail-count="1"/>
+			</node>
+		</node_history>
+	</crm_mon>`), &crmMon)
+	assert.NoError(t, err)
+
+	ch := make(chan prometheus.Metric, 1)
+	pacemakerCollector.recordResourcesNeedingCleanup(crmMon, pacemakerCollector.resourcesFailureTimeout(CIB), ch)
+	close(ch)
+
+	metricDto := &dto.Metric{}
+	(<-ch).Write(metricDto)
+
+	// each resource is counted once, no matter on how many nodes or instances it failed, and invalid failure-timeouts count as unset
+	assert.Equal(t, float64(4), metricDto.GetGauge().GetValue())
+
+	// the resources without a failure-timeout of their own take the default one
+	CIB.Configuration.RscDefaults = failureTimeout("1h")
+	ch = make(chan prometheus.Metric, 1)
+	pacemakerCollector.recordResourcesNeedingCleanup(crmMon, pacemakerCollector.resourcesFailureTimeout(CIB), ch)
+	close(ch)
+
+	metricDto = &dto.Metric{}
+	(<-ch).Write(metricDto)
+	assert.Equal(t, float64(2), metricDto.GetGauge().GetValue(), "the disabled and the invalid ones are left")
+}
+
+func TestResourcesFailureTimeout(t *testing.T) {
+	pacemakerCollector, _ := NewCollector("../../test/fake_crm_mon.sh", "../../test/fake_cibadmin.sh", Options{}, false, log.NewNopLogger())
+
+	CIB := cib.Root{}
+	CIB.Configuration.RscDefaults = []cib.Attribute{{Name: "failure-timeout", Value: "10min"}}
+	CIB.Configuration.Resources.Primitives = []cib.Primitive{
+		{Id: "rsc_default"},
+		{Id: "rsc_own", MetaAttributes: []cib.Attribute{{Name: "failure-timeout", Value: "60"}}},
+		{Id: "rsc_disabled", MetaAttributes: []cib.Attribute{{Name: "failure-timeout", Value: "0"}}},
+	}
+
+	assert.Equal(t, map[string]float64{"rsc_default": 600, "rsc_own": 60, "rsc_disabled": 0}, pacemakerCollector.resourcesFailureTimeout(CIB))
+
+	CIB.Configuration.RscDefaults = nil
+	assert.Equal(t, map[string]float64{"rsc_default": 0, "rsc_own": 60, "rsc_disabled": 0}, pacemakerCollector.resourcesFailureTimeout(CIB))
+}
+
+func TestDependencyBlocked(t *testing.T) {
+	CIB := cib.Root{}
+	err := xml.Unmarshal([]byte(`<cib>
+		<configuration>
+			<resources>
+				<primitive id="rsc_fs"/>
+				<primitive id="rsc_db"/>
+				<primitive id="rsc_app"/>
+				<primitive id="rsc_ip"/>
+				<primitive id="rsc_report">
+					<meta_attributes><nvpair name="target-role" value="Stopped"/></meta_attributes>
+				</primitive>
+				<primitive id="rsc_web"/>
+				<group id="grp_backup">
+					<primitive id="rsc_backup_fs"/>
+					<primitive id="rsc_backup"/>
+				</group>
+			</resources>
+			<constraints>
+				<rsc_order id="ord_fs_db" first="rsc_fs" then="rsc_db"/>
+				<rsc_order id="ord_db_app" kind="Mandatory" first="rsc_db" then="rsc_app"/>
+				<rsc_order id="ord_db_report" first="rsc_db" then="rsc_report"/>
+				<rsc_order id="ord_db_web" kind="Optional" first="rsc_db" then="rsc_web"/>
+				<rsc_order id="ord_legacy" score="0" first="rsc_db" then="rsc_web"/>
+				<rsc_colocation id="col_ip_db" score="INFINITY" rsc="rsc_ip" with-rsc="rsc_db"/>
+				<rsc_colocation id="col_web_db" score="2000" rsc="rsc_web" with-rsc="rsc_db"/>
+				<rsc_colocation id="col_backup_fs" score="INFINITY" rsc="grp_backup" with-rsc="rsc_fs"/>
+			</constraints>
+		</configuration>
+	</cib>`), &CIB)
+	assert.NoError(t, err)
+
+	crmMon := crmmon.Root{}
+	err = xml.Unmarshal([]byte(`<crm_mon>
+		<resources>
+			<resource id="rsc_fs" role="Stopped" active="false" failed="true"/>
+			<resource id="rsc_db" role="Stopped" active="false"/>
+			<resource id="rsc_app" role="Stopped" active="false"/>
+			<resource id="rsc_ip" role="Stopped" active="false"/>
+			<resource id="rsc_report" role="Stopped" active="false"/>
+			<resource id="rsc_web" role="Stopped" active="false"/>
+			<group id="grp_backup">
+				<resource id="rsc_backup_fs" role="Started" active="true"/>
+				<resource id="rsc_backup" role="Stopped" active="false"/>
+			</group>
+		</resources>
+	</crm_mon>`), &crmMon)
+	assert.NoError(t, err)
+
+	// the failed, disabled and optionally dependent resources are not blocked by their dependencies
+	assert.ElementsMatch(t, []blockedDependency{
+		{"rsc_db", "rsc_fs", "ord_fs_db"},
+		{"rsc_app", "rsc_db", "ord_db_app"},
+		{"rsc_ip", "rsc_db", "col_ip_db"},
+		{"grp_backup", "rsc_fs", "col_backup_fs"},
+	}, dependencyBlocked(crmMon, CIB))
+
+	pacemakerCollector, _ := NewCollector("../../test/fake_crm_mon.sh", "../../test/fake_cibadmin.sh", Options{}, false, log.NewNopLogger())
+	ch := make(chan prometheus.Metric, 5)
+	pacemakerCollector.recordDependencyBlocked(crmMon, CIB, ch)
+	close(ch)
+	assert.Len(t, ch, 5)
+}
+
+func TestDependencyBlockedByCloneOfGroup(t *testing.T) {
+	CIB := cib.Root{}
+	err := xml.Unmarshal([]byte(`<cib>
+		<configuration>
+			<resources>
+				<primitive id="rsc_app"/>
+				<primitive id="rsc_ip"/>
+				<clone id="cln_storage">
+					<group id="grp_storage">
+						<primitive id="rsc_dlm"/>
+						<primitive id="rsc_fs"/>
+					</group>
+				</clone>
+			</resources>
+			<constraints>
+				<rsc_order id="ord_storage_app" first="cln_storage" then="rsc_app"/>
+				<rsc_colocation id="col_ip_storage" score="+INFINITY" rsc="rsc_ip" with-rsc="cln_storage"/>
+			</constraints>
+		</configuration>
+	</cib>`), &CIB)
+	assert.NoError(t, err)
+
+	crmMon := crmmon.Root{}
+	err = xml.Unmarshal([]byte(`<crm_mon>
+		<resources>
+			<resource id="rsc_app" role="Stopped" active="false"/>
+			<resource id="rsc_ip" role="Stopped" active="false"/>
+			<clone id="cln_storage">
+				<group id="grp_storage:0">
+					<resource id="rsc_dlm" role="Started" active="true"/>
+					<resource id="rsc_fs" role="Stopped" active="false"/>
+				</group>
+				<group id="grp_storage:1">
+					<resource id="rsc_dlm" role="Stopped" active="false"/>
+					<resource id="rsc_fs" role="Stopped" active="false"/>
+				</group>
+			</clone>
+		</resources>
+	</crm_mon>`), &crmMon)
+	assert.NoError(t, err)
+
+	// no instance of the cloned group is complete, so the clone is not running
+	assert.ElementsMatch(t, []blockedDependency{
+		{"rsc_app", "cln_storage", "ord_storage_app"},
+		{"rsc_ip", "cln_storage", "col_ip_storage"},
+	}, dependencyBlocked(crmMon, CIB))
+
+	crmMon.Clones[0].Groups[1].Resources[0].Active = true
+	crmMon.Clones[0].Groups[1].Resources[0].Role = "Started"
+	crmMon.Clones[0].Groups[1].Resources[1].Active = true
+	crmMon.Clones[0].Groups[1].Resources[1].Role = "Started"
+	running, _ := resourcesRunning(crmMon)
+	assert.True(t, running["cln_storage"])
+	assert.True(t, running["grp_storage"])
+	assert.False(t, running["grp_storage:0"])
+	assert.Empty(t, dependencyBlocked(crmMon, CIB))
+}
+
+func TestLastFence(t *testing.T) {
+	var crmMon crmmon.Root
+	err := xml.Unmarshal([]byte(`
+	<crm_mon>
+		<fence_history>
+			<fence_event action="reboot" target="node02" status="pending"/>
+			<fence_event action="off" target="node03" status="failed" completed="2019-10-18 11:02:42Z"/>
+			<fence_event action="reboot" target="node01" status="success" completed="2019-10-18 10:30:00Z"/>
+			<fence_event action="reboot" target="node02" status="success" completed="2019-10-18 12:15:00 +02:00"/>
+		</fence_history>
+	</crm_mon>`), &crmMon)
+	assert.NoError(t, err)
+
+	// failed actions don't count, even if more recent
+	completed, ok := lastFence(crmMon)
+	assert.True(t, ok)
+	assert.Equal(t, time.Date(2019, 10, 18, 10, 30, 0, 0, time.UTC).Unix(), completed.Unix())
+
+	crmMon.FenceHistory = crmMon.FenceHistory[:2]
+	_, ok = lastFence(crmMon)
+	assert.False(t, ok)
+}
+
+func TestLastFenceAge(t *testing.T) {
+	pacemakerCollector, err := NewCollector("../../test/fake_crm_mon.sh", "../../test/fake_cibadmin.sh", Options{}, false, log.NewNopLogger())
+	assert.NoError(t, err)
+	pacemakerCollector.Clock = &clock.StoppedClock{}
+
+	crmMon := crmmon.Root{FenceHistory: []crmmon.FenceEvent{{Target: "node02", Status: "success", Completed: "2019-10-18 10:30:00Z"}}}
+	ch := make(chan prometheus.Metric, 1)
+	pacemakerCollector.recordLastFenceAge(crmMon, ch)
+	close(ch)
+
+	metricDto := &dto.Metric{}
+	(<-ch).Write(metricDto)
+	assert.Equal(t, 1.234, metricDto.GetGauge().GetValue())
+}
+
+func TestRuleActive(t *testing.T) {
+	// a Wednesday
+	now := time.Date(2021, 3, 17, 10, 30, 0, 0, time.Local)
+
+	dateSpec := func(spec cib.DateSpec) cib.DateExpression {
+		return cib.DateExpression{Operation: "date_spec", DateSpec: &spec}
+	}
+	officeHours := dateSpec(cib.DateSpec{Weekdays: "1-5", Hours: "9-16"})
+	weekend := dateSpec(cib.DateSpec{Weekdays: "6-7"})
+
+	testCases := []struct {
+		rule      cib.Rule
+		active    bool
+		timeBased bool
+	}{
+		{cib.Rule{DateExpressions: []cib.DateExpression{officeHours}}, true, true},
+		{cib.Rule{DateExpressions: []cib.DateExpression{weekend}}, false, true},
+		{cib.Rule{DateExpressions: []cib.DateExpression{officeHours, weekend}}, false, true},
+		{cib.Rule{BooleanOp: "or", DateExpressions: []cib.DateExpression{officeHours, weekend}}, true, true},
+		// nested rules without date expressions are left out
+		{cib.Rule{DateExpressions: []cib.DateExpression{officeHours}, Rules: []cib.Rule{{Id: "attributes"}}}, true, true},
+		{cib.Rule{Rules: []cib.Rule{{DateExpressions: []cib.DateExpression{weekend}}}}, false, true},
+		{cib.Rule{}, false, false},
+	}
+
+	for _, testCase := range testCases {
+		active, timeBased, err := ruleActive(testCase.rule, now)
+		assert.NoError(t, err)
+		assert.Equal(t, testCase.active, active)
+		assert.Equal(t, testCase.timeBased, timeBased)
+	}
+
+	_, _, err := ruleActive(cib.Rule{DateExpressions: []cib.DateExpression{dateSpec(cib.DateSpec{Hours: "nine"})}}, now)
+	assert.Error(t, err)
+}
+
+func TestStandbyExpiry(t *testing.T) {
+	var CIB cib.Root
+	err := xml.Unmarshal([]byte(`
+<cib>
+  <configuration>
+    <nodes>
+      <node id="1" uname="node01">
+        <instance_attributes id="nodes-1">
+          <nvpair id="nodes-1-site" name="site" value="PRIMARY"/>
+        </instance_attributes>
+        <instance_attributes id="nodes-1-timed-standby">
+          <rule id="nodes-1-timed-standby-rule" score="INFINITY">
+            <date_expression id="nodes-1-timed-standby-rule-expr" operation="lt" end="2026-10-20T12:00:00Z"/>
+          </rule>
+          <nvpair id="nodes-1-timed-standby-standby" name="standby" value="on"/>
+        </instance_attributes>
+      </node>
+      <node id="2" uname="node02">
+        <instance_attributes id="nodes-2">
+          <nvpair id="nodes-2-standby" name="standby" value="on"/>
+        </instance_attributes>
+      </node>
+      <node id="3" uname="node03">
+        <instance_attributes id="nodes-3-weekend-standby">
+          <rule id="nodes-3-weekend-standby-rule" score="INFINITY">
+            <date_expression id="nodes-3-weekend-standby-rule-expr" operation="date_spec">
+              <date_spec id="nodes-3-weekend-standby-rule-spec" weekdays="6-7"/>
+            </date_expression>
+          </rule>
+          <nvpair id="nodes-3-weekend-standby-standby" name="standby" value="on"/>
+        </instance_attributes>
+      </node>
+    </nodes>
+  </configuration>
+</cib>`), &CIB)
+	assert.NoError(t, err)
+
+	expiry, ok := standbyExpiry(CIB.Configuration.Nodes[0])
+	assert.True(t, ok)
+	assert.Equal(t, time.Date(2026, 10, 20, 12, 0, 0, 0, time.UTC).Unix(), expiry.Unix())
+
+	_, ok = standbyExpiry(CIB.Configuration.Nodes[1])
+	assert.False(t, ok, "permanent standby")
+
+	_, ok = standbyExpiry(CIB.Configuration.Nodes[2])
+	assert.False(t, ok, "recurring standby")
+}
+
+func TestRuleEnd(t *testing.T) {
+	lt := func(end string) cib.DateExpression {
+		return cib.DateExpression{Operation: "lt", End: end}
+	}
+
+	_, bounded := ruleEnd(cib.Rule{DateExpressions: []cib.DateExpression{{Operation: "gt", Start: "2026-10-01"}}})
+	assert.False(t, bounded)
+
+	end, bounded := ruleEnd(cib.Rule{DateExpressions: []cib.DateExpression{lt("2026-10-20"), {Operation: "gt", Start: "2026-10-01"}}})
+	assert.True(t, bounded)
+	assert.Equal(t, "2026-10-20", end.Format("2006-01-02"))
+
+	end, bounded = ruleEnd(cib.Rule{DateExpressions: []cib.DateExpression{lt("2026-10-20"), lt("2026-10-18")}})
+	assert.True(t, bounded)
+	assert.Equal(t, "2026-10-18", end.Format("2006-01-02"))
+
+	end, bounded = ruleEnd(cib.Rule{BooleanOp: "or", DateExpressions: []cib.DateExpression{lt("2026-10-20"), lt("2026-10-18")}})
+	assert.True(t, bounded)
+	assert.Equal(t, "2026-10-20", end.Format("2006-01-02"))
+
+	_, bounded = ruleEnd(cib.Rule{BooleanOp: "or", DateExpressions: []cib.DateExpression{lt("2026-10-20"), {Operation: "gt", Start: "2026-10-01"}}})
+	assert.False(t, bounded)
+
+	end, bounded = ruleEnd(cib.Rule{DateExpressions: []cib.DateExpression{{Operation: "in_range", Start: "2026-10-15", Duration: &cib.DateSpec{Days: "3"}}}})
+	assert.True(t, bounded)
+	assert.Equal(t, "2026-10-18", end.Format("2006-01-02"))
+}
+
+func TestDateExpressionActive(t *testing.T) {
+	now := time.Date(2021, 3, 17, 10, 30, 0, 0, time.Local)
+
+	testCases := []struct {
+		expression cib.DateExpression
+		active     bool
+	}{
+		{cib.DateExpression{Operation: "gt", Start: "2021-03-17 10:00:00"}, true},
+		{cib.DateExpression{Operation: "gt", Start: "2021-03-18"}, false},
+		{cib.DateExpression{Operation: "lt", End: "2021-03-17T11:00"}, true},
+		{cib.DateExpression{Operation: "lt", End: "2020-01-01"}, false},
+		{cib.DateExpression{Operation: "in_range", Start: "2021-03-01", End: "2021-03-31"}, true},
+		{cib.DateExpression{Operation: "in_range", Start: "2021-03-01", Duration: &cib.DateSpec{Weeks: "1"}}, false},
+		{cib.DateExpression{Operation: "in_range", Start: "2021-03-17 10:00:00", Duration: &cib.DateSpec{Hours: "1"}}, true},
+		{cib.DateExpression{Operation: "in_range", End: "2021-03-17T10:29:00"}, false},
+		{cib.DateExpression{Operation: "in_range", Start: "2021-03-17T10:00:00Z", End: "2021-03-17T12:00:00Z"}, now.UTC().Hour() >= 10 && now.UTC().Hour() < 12},
+		{cib.DateExpression{Operation: "date_spec", DateSpec: &cib.DateSpec{Years: "2021", Months: "3", Monthdays: "15-20", Weekdays: "3", Yeardays: "76", Minutes: "0-30"}}, true},
+		{cib.DateExpression{Operation: "date_spec", DateSpec: &cib.DateSpec{Weekyears: "2021", Weeks: "10"}}, false},
+	}
+
+	for _, testCase := range testCases {
+		active, err := dateExpressionActive(testCase.expression, now)
+		assert.NoError(t, err)
+		assert.Equal(t, testCase.active, active, testCase.expression)
+	}
+
+	for _, expression := range []cib.DateExpression{
+		{Operation: "gt"},
+		{Operation: "in_range"},
+		{Operation: "in_range", Start: "2021-03-01", Duration: &cib.DateSpec{Days: "a few"}},
+		{Operation: "date_spec"},
+		{Operation: "sometime", Start: "2021-03-01"},
+	} {
+		_, err := dateExpressionActive(expression, now)
+		assert.Error(t, err, expression)
+	}
+}
+
+func TestClusterRecheckInterval(t *testing.T) {
+	pacemakerCollector, _ := NewCollector("../../test/fake_crm_mon.sh", "../../test/fake_cibadmin.sh", Options{}, false, log.NewNopLogger())
+
+	recheckInterval := func(CIB cib.Root) []float64 {
+		ch := make(chan prometheus.Metric, 1)
+		pacemakerCollector.recordClusterRecheckInterval(CIB, ch)
+		close(ch)
+
+		var values []float64
+		for metric := range ch {
+			metricDto := &dto.Metric{}
+			metric.Write(metricDto)
+			values = append(values, metricDto.GetGauge().GetValue())
+		}
+		return values
+	}
+
+	var CIB cib.Root
+	assert.Equal(t, []float64{900}, recheckInterval(CIB))
+
+	CIB.Configuration.CrmConfig.ClusterProperties = []cib.Attribute{{Name: "cluster-recheck-interval", Value: "2min"}}
+	assert.Equal(t, []float64{120}, recheckInterval(CIB))
+
+	CIB.Configuration.CrmConfig.ClusterProperties = []cib.Attribute{{Name: "cluster-recheck-interval", Value: "often"}}
+	assert.Empty(t, recheckInterval(CIB))
+}
+
+func TestSchedulerLimits(t *testing.T) {
+	pacemakerCollector, _ := NewCollector("../../test/fake_crm_mon.sh", "../../test/fake_cibadmin.sh", Options{}, false, log.NewNopLogger())
+
+	schedulerLimits := func(CIB cib.Root) map[string]float64 {
+		ch := make(chan prometheus.Metric, 2)
+		pacemakerCollector.recordSchedulerLimits(CIB, ch)
+		close(ch)
+
+		limits := make(map[string]float64)
+		for metric := range ch {
+			metricDto := &dto.Metric{}
+			metric.Write(metricDto)
+			for _, name := range []string{"batch_limit", "migration_limit"} {
+				if strings.Contains(metric.Desc().String(), "ha_cluster_pacemaker_"+name) {
+					limits[name] = metricDto.GetGauge().GetValue()
+				}
+			}
+		}
+		return limits
+	}
+
+	var CIB cib.Root
+	assert.Equal(t, map[string]float64{"batch_limit": 0, "migration_limit": -1}, schedulerLimits(CIB))
+
+	CIB.Configuration.CrmConfig.ClusterProperties = []cib.Attribute{{Name: "batch-limit", Value: "30"}, {Name: "migration-limit", Value: "2"}}
+	assert.Equal(t, map[string]float64{"batch_limit": 30, "migration_limit": 2}, schedulerLimits(CIB))
+
+	CIB.Configuration.CrmConfig.ClusterProperties = []cib.Attribute{{Name: "batch-limit", Value: "many"}}
+	assert.Equal(t, map[string]float64{"migration_limit": -1}, schedulerLimits(CIB))
+}
+
+func TestFencingLevelMatcher(t *testing.T) {
+	node := cib.Node{Uname: "node01", InstanceAttributeSets: []cib.AttributeSet{{Attributes: []cib.Attribute{{Name: "rack", Value: "1"}}}}}
+
+	testCases := []struct {
+		level    cib.FencingLevel
+		expected bool
+	}{
+		{cib.FencingLevel{Target: "node01"}, true},
+		{cib.FencingLevel{Target: "node02"}, false},
+		{cib.FencingLevel{TargetPattern: "node0[12]"}, true},
+		{cib.FencingLevel{TargetPattern: "^node1"}, false},
+		{cib.FencingLevel{TargetAttribute: "rack", TargetValue: "1"}, true},
+		{cib.FencingLevel{TargetAttribute: "rack", TargetValue: "2"}, false},
+		{cib.FencingLevel{TargetAttribute: "site", TargetValue: "1"}, false},
+	}
+	for _, tc := range testCases {
+		matches, err := fencingLevelMatcher(tc.level)
+		assert.NoError(t, err)
+		assert.Equal(t, tc.expected, matches(node), tc.level)
+	}
+
+	_, err := fencingLevelMatcher(cib.FencingLevel{TargetPattern: "node("})
+	assert.Error(t, err)
+	_, err = fencingLevelMatcher(cib.FencingLevel{})
+	assert.Error(t, err)
+}
+
+func TestVirtualIPs(t *testing.T) {
+	CIB := cib.Root{}
+	CIB.Configuration.Resources.Primitives = []cib.Primitive{
+		{Id: "rsc_ip", Type: "IPaddr2", InstanceAttributes: []cib.Attribute{{Name: "cidr_netmask", Value: "24"}, {Name: "ip", Value: "10.0.0.1"}}},
+		{Id: "rsc_fs", Type: "Filesystem", InstanceAttributes: []cib.Attribute{{Name: "ip", Value: "10.0.0.2"}}},
+	}
+	CIB.Configuration.Resources.Groups = []cib.Group{{Id: "grp", Primitives: []cib.Primitive{
+		{Id: "rsc_ip_grp", Type: "IPaddr2", InstanceAttributes: []cib.Attribute{{Name: "ip", Value: "fd00::1"}}},
+	}}}
+	CIB.Configuration.Resources.Masters = []cib.Clone{{Id: "msl", Primitive: cib.Primitive{
+		Id: "rsc_ip_msl", Type: "IPaddr2", InstanceAttributes: []cib.Attribute{{Name: "ip", Value: "10.0.0.3"}},
+	}}}
+
+	assert.Equal(t, map[string]string{"rsc_ip": "10.0.0.1", "rsc_ip_grp": "fd00::1", "rsc_ip_msl": "10.0.0.3"}, VirtualIPs(CIB))
+}
+
+func TestStartedResources(t *testing.T) {
+	crmMon, err := crmmon.NewCrmMonParser("../../test/fake_crm_mon.sh", false, log.NewNopLogger()).Parse()
+	assert.NoError(t, err)
+
+	started := StartedResources(crmMon, "node01")
+	assert.True(t, started["rsc_ip_PRD_HDB00"])
+	assert.False(t, StartedResources(crmMon, "node03")["rsc_ip_PRD_HDB00"])
+}
+
+// collects the gauges recorded by a collector method, by their label values joined with a slash
+func gaugeValues(record func(ch chan<- prometheus.Metric)) map[string]float64 {
+	ch := make(chan prometheus.Metric, 100)
+	record(ch)
+	close(ch)
+
+	values := make(map[string]float64)
+	for metric := range ch {
+		metricDto := &dto.Metric{}
+		metric.Write(metricDto)
+		var labelValues []string
+		for _, label := range metricDto.GetLabel() {
+			labelValues = append(labelValues, label.GetValue())
+		}
+		values[strings.Join(labelValues, "/")] = metricDto.GetGauge().GetValue()
+	}
+	return values
+}
+
+func TestLastLrmRefresh(t *testing.T) {
+	pacemakerCollector, _ := NewCollector("../../test/fake_crm_mon.sh", "../../test/fake_cibadmin.sh", Options{}, false, log.NewNopLogger())
+
+	lastLrmRefresh := func(value string) cib.Root {
+		CIB := cib.Root{}
+		CIB.Configuration.CrmConfig.ClusterProperties = []cib.Attribute{{Name: "last-lrm-refresh", Value: value}}
+		return CIB
+	}
+
+	values := gaugeValues(func(ch chan<- prometheus.Metric) {
+		pacemakerCollector.recordLastLrmRefresh(lastLrmRefresh("1571325725"), ch)
+	})
+	assert.Equal(t, map[string]float64{"": 1571325725}, values)
+
+	values = gaugeValues(func(ch chan<- prometheus.Metric) {
+		pacemakerCollector.recordLastLrmRefresh(lastLrmRefresh("yesterday"), ch)
+	})
+	assert.Empty(t, values)
+
+	values = gaugeValues(func(ch chan<- prometheus.Metric) { pacemakerCollector.recordLastLrmRefresh(cib.Root{}, ch) })
+	assert.Empty(t, values, "never refreshed")
+}
+
+func TestOperationDrifts(t *testing.T) {
+	pacemakerCollector, _ := NewCollector("../../test/fake_crm_mon.sh", "../../test/fake_cibadmin.sh", Options{}, false, log.NewNopLogger())
+
+	crmMon, err := crmmon.NewCrmMonParser("../../test/fake_crm_mon.sh", false, log.NewNopLogger()).Parse()
+	assert.NoError(t, err)
+
+	CIB := cib.Root{}
+	CIB.Configuration.Resources.Primitives = []cib.Primitive{{Id: "rsc_ip_PRD_HDB00", Operations: []cib.Operation{
+		{Name: "start", Interval: "0"},
+		{Name: "monitor", Interval: "10", IntervalOrigin: "2019-10-10 12:00:00"},
+	}}}
+
+	// the monitor last ran at 12:57:32, 2 seconds after the closest scheduled run
+	values := gaugeValues(func(ch chan<- prometheus.Metric) { pacemakerCollector.recordOperationDrifts(crmMon, CIB, ch) })
+	assert.Equal(t, map[string]float64{"node01/monitor/rsc_ip_PRD_HDB00": 2}, values)
+}
+
+func TestActiveRules(t *testing.T) {
+	pacemakerCollector, _ := NewCollector("../../test/fake_crm_mon.sh", "../../test/fake_cibadmin.sh", Options{}, false, log.NewNopLogger())
+
+	var CIB cib.Root
+	err := xml.Unmarshal([]byte(`
+<cib>
+  <configuration>
+    <constraints>
+      <rsc_location id="location-rsc_ip-windows" rsc="rsc_ip">
+        <rule id="location-rsc_ip-windows-migrated" score="-INFINITY">
+          <date_expression id="location-rsc_ip-windows-migrated-expr" operation="gt" start="2020-01-01"/>
+        </rule>
+        <rule id="location-rsc_ip-windows-legacy" score="-INFINITY" boolean-op="and">
+          <expression id="location-rsc_ip-windows-legacy-expr" attribute="#uname" operation="eq" value="node02"/>
+          <date_expression id="location-rsc_ip-windows-legacy-date" operation="date_spec">
+            <date_spec id="location-rsc_ip-windows-legacy-spec" years="2019"/>
+          </date_expression>
+        </rule>
+        <rule id="location-rsc_ip-windows-node" score="-INFINITY">
+          <expression id="location-rsc_ip-windows-node-expr" attribute="#uname" operation="eq" value="node01"/>
+        </rule>
+      </rsc_location>
+    </constraints>
+  </configuration>
+</cib>`), &CIB)
+	assert.NoError(t, err)
+
+	// the rules without date expressions are neither recorded nor counted
+	values := gaugeValues(func(ch chan<- prometheus.Metric) { pacemakerCollector.recordActiveRules(CIB, ch) })
+	assert.Equal(t, map[string]float64{
+		"location-rsc_ip-windows/rsc_ip/location-rsc_ip-windows-migrated": 1,
+		"": 1,
+	}, values)
+}
+
+func TestFencingLevels(t *testing.T) {
+	var logs bytes.Buffer
+	pacemakerCollector, _ := NewCollector("../../test/fake_crm_mon.sh", "../../test/fake_cibadmin.sh", Options{}, false, log.NewLogfmtLogger(&logs))
+
+	CIB := cib.Root{}
+	CIB.Configuration.Nodes = []cib.Node{{Uname: "node01"}, {Uname: "node02"}, {Uname: "node03"}}
+	CIB.Configuration.FencingLevels = []cib.FencingLevel{
+		{Id: "fencing-node01-1", Index: 1, Devices: "stonith-sbd", Target: "node01"},
+		{Id: "fencing-nodes-2", Index: 2, Devices: "stonith-sbd", TargetPattern: "node0[12]"},
+		{Id: "fencing-invalid", Index: 3, Devices: "stonith-sbd", TargetPattern: "node("},
+	}
+
+	// invalid levels are not counted, and only warned about once
+	for i := 0; i < 2; i++ {
+		values := gaugeValues(func(ch chan<- prometheus.Metric) { pacemakerCollector.recordFencingLevels(CIB, ch) })
+		assert.Equal(t, map[string]float64{"node01": 2, "node02": 1, "node03": 0}, values)
+	}
+	assert.Equal(t, 1, strings.Count(logs.String(), "fencing-invalid"))
+}
+
+func TestWarnInvalidAttribute(t *testing.T) {
+	var logs bytes.Buffer
+	pacemakerCollector, _ := NewCollector("../../test/fake_crm_mon.sh", "../../test/fake_cibadmin.sh", Options{}, false, log.NewLogfmtLogger(&logs))
+
+	CIB := cib.Root{}
+	CIB.Configuration.Resources.Primitives = []cib.Primitive{
+		{Id: "rsc_invalid", MetaAttributes: []cib.Attribute{{Name: "resource-stickiness", Value: "sticky"}, {Name: "failure-timeout", Value: "soon"}}},
+	}
+
+	// the same invalid attributes are only warned about once across scrapes
+	for i := 0; i < 2; i++ {
+		gaugeValues(func(ch chan<- prometheus.Metric) { pacemakerCollector.recordStickiness(CIB, ch) })
+		pacemakerCollector.resourcesFailureTimeout(CIB)
+	}
+	assert.Equal(t, 1, strings.Count(logs.String(), "resource-stickiness of resource rsc_invalid"))
+	assert.Equal(t, 1, strings.Count(logs.String(), "failure-timeout of resource rsc_invalid"))
+
+	// but again once they are set to another invalid value
+	CIB.Configuration.Resources.Primitives[0].MetaAttributes[0].Value = "very sticky"
+	gaugeValues(func(ch chan<- prometheus.Metric) { pacemakerCollector.recordStickiness(CIB, ch) })
+	assert.Equal(t, 2, strings.Count(logs.String(), "resource-stickiness of resource rsc_invalid"))
+}
+
+func TestBaseResourceId(t *testing.T) {
+	assert.Equal(t, "rsc_ip", baseResourceId("rsc_ip"))
+	assert.Equal(t, "rsc_unique", baseResourceId("rsc_unique:1"))
+	assert.Equal(t, "grp_base", baseResourceId("grp_base:0"))
+}
+
+func TestForEachResource(t *testing.T) {
+	crmMon := crmmon.Root{}
+	err := xml.Unmarshal([]byte(`<crm_mon>
+		<resources>
+			<resource id="rsc_ip" pending="Starting"/>
+			<group id="grp_app">
+				<resource id="rsc_fs"/>
+			</group>
+			<clone id="cln_ping">
+				<resource id="rsc_ping" blocked="true"/>
+			</clone>
+			<clone id="cln_base">
+				<group id="grp_base:0">
+					<resource id="rsc_dlm" blocked="true"/>
+				</group>
+				<group id="grp_base:1">
+					<resource id="rsc_dlm" pending="Monitoring"/>
+				</group>
+			</clone>
+		</resources>
+	</crm_mon>`), &crmMon)
+	assert.NoError(t, err)
+
+	var visited []string
+	forEachResource(crmMon, func(resource crmmon.Resource, group string, clone string) {
+		visited = append(visited, resource.Id+"/"+group+"/"+clone)
+	})
+	assert.Equal(t, []string{"rsc_ip//", "rsc_fs/grp_app/", "rsc_ping//cln_ping", "rsc_dlm/grp_base/cln_base", "rsc_dlm/grp_base/cln_base"}, visited)
+
+	pacemakerCollector, _ := NewCollector("../../test/fake_crm_mon.sh", "../../test/fake_cibadmin.sh", Options{}, false, log.NewNopLogger())
+
+	// the members of the cloned groups are counted like the other resources
+	values := gaugeValues(func(ch chan<- prometheus.Metric) { pacemakerCollector.recordOperationsInFlight(crmMon, ch) })
+	assert.Equal(t, map[string]float64{"": 2}, values)
+
+	values = gaugeValues(func(ch chan<- prometheus.Metric) { pacemakerCollector.recordBlockedResources(crmMon, ch) })
+	assert.Equal(t, float64(1), values["cln_base//rsc_dlm"])
+	assert.Equal(t, float64(1), values["cln_ping//rsc_ping"])
+	assert.Equal(t, float64(0), values["//rsc_ip"])
+}
+
+func TestResourceNodes(t *testing.T) {
+	pacemakerCollector, _ := NewCollector("../../test/fake_crm_mon.sh", "../../test/fake_cibadmin.sh", Options{}, false, log.NewNopLogger())
+
+	crmMon := crmmon.Root{}
+	err := xml.Unmarshal([]byte(`<crm_mon>
+		<resources>
+			<resource id="rsc_ip" active="true"><node name="node01"/></resource>
+			<resource id="rsc_stopped" active="false"/>
+			<clone id="cln_base">
+				<group id="grp_base:0">
+					<resource id="rsc_dlm" active="true"><node name="node01"/></resource>
+				</group>
+				<group id="grp_base:1">
+					<resource id="rsc_dlm" active="true"><node name="node02"/></resource>
+				</group>
+			</clone>
+			<clone id="cln_dummy">
+				<resource id="rsc_dummy" active="true"><node name="node01"/></resource>
+				<resource id="rsc_dummy" active="true"><node name="node01"/></resource>
+			</clone>
+		</resources>
+	</crm_mon>`), &crmMon)
+	assert.NoError(t, err)
+
+	// each pair of lines is recorded once, even for the anonymous instances running on the same node
+	values := gaugeValues(func(ch chan<- prometheus.Metric) { pacemakerCollector.recordResourceNodes(crmMon, cib.Root{}, ch) })
+	assert.Equal(t, map[string]float64{
+		"node01/rsc_ip":    1,
+		"node01/rsc_dlm":   1,
+		"node02/rsc_dlm":   1,
+		"node01/rsc_dummy": 1,
+	}, values)
+
+	ch := make(chan prometheus.Metric, 100)
+	pacemakerCollector.recordResourceNodes(crmMon, cib.Root{}, ch)
+	close(ch)
+	assert.Len(t, ch, 8, "a running and an allocated node line for each")
+}
+
+func TestLastUpdate(t *testing.T) {
+	pacemakerCollector, _ := NewCollector("../../test/fake_crm_mon.sh", "../../test/fake_cibadmin.sh", Options{}, false, log.NewNopLogger())
+
+	values := gaugeValues(func(ch chan<- prometheus.Metric) {
+		pacemakerCollector.recordLastUpdate(cib.Root{LastWritten: "Mon Nov 18 17:48:21 2019"}, ch)
+	})
+	assert.Equal(t, map[string]float64{"": 1574099301}, values)
+
+	// an invalid time only leaves this metric out
+	values = gaugeValues(func(ch chan<- prometheus.Metric) {
+		pacemakerCollector.recordLastUpdate(cib.Root{LastWritten: "yesterday"}, ch)
+	})
+	assert.Empty(t, values)
+}
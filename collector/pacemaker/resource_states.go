@@ -0,0 +1,99 @@
+package pacemaker
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/ClusterLabs/ha_cluster_exporter/collector/pacemaker/crmmon"
+)
+
+// the state of each resource and since when it's in it, retained across scrapes;
+// the exporter can't know when the state was entered before it started, so the first state seen starts at the first scrape
+type resourceStates struct {
+	mutex  sync.Mutex
+	states map[string]resourceState
+}
+
+type resourceState struct {
+	state string
+	since time.Time
+}
+
+func newResourceStates() *resourceStates {
+	return &resourceStates{states: make(map[string]resourceState)}
+}
+
+// records the current states, resetting the time of the ones that changed; the resources no longer in the status are forgotten,
+// so they start over if they come back
+func (s *resourceStates) update(current map[string]string, now time.Time) map[string]resourceState {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	states := make(map[string]resourceState, len(current))
+	for resource, state := range current {
+		previous, ok := s.states[resource]
+		if ok && previous.state == state {
+			states[resource] = previous
+		} else {
+			states[resource] = resourceState{state, now}
+		}
+	}
+	s.states = states
+
+	result := make(map[string]resourceState, len(states))
+	for resource, state := range states {
+		result[resource] = state
+	}
+	return result
+}
+
+func (c *pacemakerCollector) recordResourceStates(crmMon crmmon.Root, ch chan<- prometheus.Metric) {
+	for resource, state := range c.resourceStates.update(currentResourceStates(crmMon), c.Clock.Now()) {
+		// the state is in a metric of its own, so that the series of the time don't change along with it
+		ch <- c.MakeGaugeMetric("resource_state", 1, resource, state.state)
+		ch <- c.MakeGaugeMetric("resource_state_since_timestamp_seconds", float64(state.since.Unix()), resource)
+	}
+}
+
+// returns the state of each primitive resource; the instances of an anonymous clone share the same id,
+// so the state of such a resource is the list of the distinct states of its instances, e.g. "promoted,unpromoted"
+func currentResourceStates(crmMon crmmon.Root) map[string]string {
+	instanceStates := make(map[string]map[string]bool)
+	record := func(resource crmmon.Resource) {
+		if instanceStates[resource.Id] == nil {
+			instanceStates[resource.Id] = make(map[string]bool)
+		}
+		instanceStates[resource.Id][resourceInstanceState(resource)] = true
+	}
+
+	forEachResource(crmMon, func(resource crmmon.Resource, _ string, _ string) {
+		record(resource)
+	})
+
+	states := make(map[string]string, len(instanceStates))
+	for resource, set := range instanceStates {
+		var list []string
+		for state := range set {
+			list = append(list, state)
+		}
+		sort.Strings(list)
+		states[resource] = strings.Join(list, ",")
+	}
+	return states
+}
+
+// reduces a resource instance to its role, unless it failed or it's stopped
+func resourceInstanceState(resource crmmon.Resource) string {
+	switch {
+	case isResourceFailed(resource):
+		return "failed"
+	case !resource.Active || resource.Role == "Stopped":
+		return "stopped"
+	default:
+		return strings.ToLower(resource.Role)
+	}
+}
@@ -0,0 +1,93 @@
+package pacemaker
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/ClusterLabs/ha_cluster_exporter/collector/pacemaker/cib"
+)
+
+// the type of each resource in the configuration, i.e. the agent of its primitive, like IPaddr2,
+// so that the metrics of the resources can be grouped by type without relabeling
+type resourceTypes struct {
+	mutex sync.Mutex
+	types map[string]string
+
+	// the position of the resource label of each metric having one
+	resourceLabels map[string]int
+}
+
+func newResourceTypes() *resourceTypes {
+	return &resourceTypes{
+		types:          make(map[string]string),
+		resourceLabels: make(map[string]int),
+	}
+}
+
+// reads the resource types out of the configuration; clones take the type of their primitive,
+// while groups, cloned or not, have none, since their members can be of different types
+func (t *resourceTypes) update(CIB cib.Root) {
+	types := make(map[string]string)
+	forEachPrimitive(CIB, func(primitive cib.Primitive, _ []cib.Attribute, _ bool) {
+		types[primitive.Id] = primitive.Type
+	})
+	for _, clone := range append(CIB.Configuration.Resources.Clones, CIB.Configuration.Resources.Masters...) {
+		if clone.Group == nil {
+			types[clone.Id] = clone.Primitive.Type
+		}
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.types = types
+}
+
+// returns the type of a resource, which is empty for the resources missing from the configuration, like the orphaned ones;
+// the instances of unique clones, like rsc:0, have the type of their primitive
+func (t *resourceTypes) get(resource string) string {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if resourceType, ok := t.types[resource]; ok {
+		return resourceType
+	}
+	if i := strings.LastIndex(resource, ":"); i >= 0 {
+		return t.types[resource[:i]]
+	}
+	return ""
+}
+
+// declares the metrics like the default collector does, except that a type label is appended to the ones with a resource label
+func (c *pacemakerCollector) SetDescriptor(name, help string, variableLabels []string) {
+	if c.resourceTypes != nil {
+		for i, label := range variableLabels {
+			if label == "resource" {
+				c.resourceTypes.resourceLabels[name] = i
+				variableLabels = append(variableLabels[:len(variableLabels):len(variableLabels)], "type")
+				break
+			}
+		}
+	}
+	c.DefaultCollector.SetDescriptor(name, help, variableLabels)
+}
+
+func (c *pacemakerCollector) MakeGaugeMetric(name string, value float64, labelValues ...string) prometheus.Metric {
+	return c.DefaultCollector.MakeGaugeMetric(name, value, c.withResourceType(name, labelValues)...)
+}
+
+func (c *pacemakerCollector) MakeCounterMetric(name string, value float64, labelValues ...string) prometheus.Metric {
+	return c.DefaultCollector.MakeCounterMetric(name, value, c.withResourceType(name, labelValues)...)
+}
+
+// appends the value of the type label to the label values of the metrics having a resource label
+func (c *pacemakerCollector) withResourceType(name string, labelValues []string) []string {
+	if c.resourceTypes == nil {
+		return labelValues
+	}
+	i, ok := c.resourceTypes.resourceLabels[name]
+	if !ok || i >= len(labelValues) {
+		return labelValues
+	}
+	return append(labelValues[:len(labelValues):len(labelValues)], c.resourceTypes.get(labelValues[i]))
+}
@@ -0,0 +1,262 @@
+package pacemaker
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/ClusterLabs/ha_cluster_exporter/collector/pacemaker/cib"
+)
+
+// the ISO 8601 date formats accepted in the start and end of date expressions and in interval-origin;
+// like Pacemaker does, dates without an offset are in the local time
+var dateLayouts = []string{
+	"2006-01-02T15:04:05Z07:00",
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// evaluates the date expressions of a rule, and of its nested rules, at the given time;
+// the other expressions are left out, so timeBased is false if the rule has no date expression at all
+func ruleActive(rule cib.Rule, now time.Time) (active bool, timeBased bool, err error) {
+	var results []bool
+	for _, expression := range rule.DateExpressions {
+		result, err := dateExpressionActive(expression, now)
+		if err != nil {
+			return false, true, errors.Wrapf(err, "could not evaluate date expression '%s'", expression.Id)
+		}
+		results = append(results, result)
+	}
+	for _, nested := range rule.Rules {
+		result, nestedTimeBased, err := ruleActive(nested, now)
+		if err != nil {
+			return false, true, err
+		}
+		if nestedTimeBased {
+			results = append(results, result)
+		}
+	}
+
+	if len(results) == 0 {
+		return false, false, nil
+	}
+
+	or := strings.EqualFold(rule.BooleanOp, "or")
+	active = !or
+	for _, result := range results {
+		if or {
+			active = active || result
+		} else {
+			active = active && result
+		}
+	}
+	return active, true, nil
+}
+
+func dateExpressionActive(expression cib.DateExpression, now time.Time) (bool, error) {
+	switch expression.Operation {
+	case "gt":
+		start, err := parseDate(expression.Start)
+		if err != nil {
+			return false, err
+		}
+		return now.After(start), nil
+	case "lt":
+		end, err := parseDate(expression.End)
+		if err != nil {
+			return false, err
+		}
+		return now.Before(end), nil
+	case "in_range", "":
+		return dateRangeActive(expression, now)
+	case "date_spec":
+		if expression.DateSpec == nil {
+			return false, errors.New("missing date_spec")
+		}
+		return dateSpecMatches(*expression.DateSpec, now)
+	default:
+		return false, errors.Errorf("unknown operation '%s'", expression.Operation)
+	}
+}
+
+// the range is open if either the start or the end is missing; the end can also be given as a duration after the start
+func dateRangeActive(expression cib.DateExpression, now time.Time) (bool, error) {
+	if expression.Start == "" && expression.End == "" {
+		return false, errors.New("in_range needs a start or an end")
+	}
+
+	if expression.Start != "" {
+		start, err := parseDate(expression.Start)
+		if err != nil {
+			return false, err
+		}
+		if now.Before(start) {
+			return false, nil
+		}
+		if expression.End == "" && expression.Duration != nil {
+			end, err := addDuration(start, *expression.Duration)
+			if err != nil {
+				return false, err
+			}
+			return !now.After(end), nil
+		}
+	}
+
+	if expression.End != "" {
+		end, err := parseDate(expression.End)
+		if err != nil {
+			return false, err
+		}
+		return !now.After(end), nil
+	}
+
+	return true, nil
+}
+
+// returns when the date expressions of a rule, and of its nested rules, stop being satisfied for good;
+// bounded is false if they never do, e.g. because of an open range or a date_spec, or if a date can't be parsed
+func ruleEnd(rule cib.Rule) (end time.Time, bounded bool) {
+	var ends []time.Time
+	unbounded := false
+	for _, expression := range rule.DateExpressions {
+		if expressionEnd, ok := dateExpressionEnd(expression); ok {
+			ends = append(ends, expressionEnd)
+		} else {
+			unbounded = true
+		}
+	}
+	for _, nested := range rule.Rules {
+		if nestedEnd, ok := ruleEnd(nested); ok {
+			ends = append(ends, nestedEnd)
+		} else {
+			unbounded = true
+		}
+	}
+
+	if len(ends) == 0 {
+		return time.Time{}, false
+	}
+
+	// any of the expressions ending is enough to end an "and" rule, while all of them have to end for an "or" one
+	or := strings.EqualFold(rule.BooleanOp, "or")
+	if or && unbounded {
+		return time.Time{}, false
+	}
+	end = ends[0]
+	for _, e := range ends[1:] {
+		if (or && e.After(end)) || (!or && e.Before(end)) {
+			end = e
+		}
+	}
+	return end, true
+}
+
+func dateExpressionEnd(expression cib.DateExpression) (time.Time, bool) {
+	switch expression.Operation {
+	case "lt", "in_range", "":
+		if expression.End != "" {
+			end, err := parseDate(expression.End)
+			return end, err == nil
+		}
+		if expression.Operation != "lt" && expression.Start != "" && expression.Duration != nil {
+			start, err := parseDate(expression.Start)
+			if err != nil {
+				return time.Time{}, false
+			}
+			end, err := addDuration(start, *expression.Duration)
+			return end, err == nil
+		}
+	}
+	return time.Time{}, false
+}
+
+func parseDate(value string) (time.Time, error) {
+	for _, layout := range dateLayouts {
+		date, err := time.ParseInLocation(layout, value, time.Local)
+		if err == nil {
+			return date, nil
+		}
+	}
+	return time.Time{}, errors.Errorf("could not parse date '%s'", value)
+}
+
+func addDuration(start time.Time, duration cib.DateSpec) (time.Time, error) {
+	var err error
+	amount := func(value string) int {
+		if value == "" || err != nil {
+			return 0
+		}
+		var n int
+		n, err = strconv.Atoi(value)
+		return n
+	}
+
+	end := start.AddDate(amount(duration.Years), amount(duration.Months), amount(duration.Weeks)*7+amount(duration.Days))
+	end = end.Add(time.Duration(amount(duration.Hours))*time.Hour + time.Duration(amount(duration.Minutes))*time.Minute + time.Duration(amount(duration.Seconds))*time.Second)
+	if err != nil {
+		return time.Time{}, errors.Wrap(err, "could not parse duration")
+	}
+	return end, nil
+}
+
+// whether all the fields set in a date_spec match the given time; weekdays go from 1 (Monday) to 7 (Sunday)
+func dateSpecMatches(spec cib.DateSpec, now time.Time) (bool, error) {
+	weekday := int(now.Weekday())
+	if weekday == 0 {
+		weekday = 7
+	}
+	weekYear, week := now.ISOWeek()
+
+	fields := []struct {
+		spec  string
+		value int
+	}{
+		{spec.Years, now.Year()},
+		{spec.Months, int(now.Month())},
+		{spec.Monthdays, now.Day()},
+		{spec.Weekdays, weekday},
+		{spec.Yeardays, now.YearDay()},
+		{spec.Weekyears, weekYear},
+		{spec.Weeks, week},
+		{spec.Hours, now.Hour()},
+		{spec.Minutes, now.Minute()},
+		{spec.Seconds, now.Second()},
+	}
+
+	for _, field := range fields {
+		if field.spec == "" {
+			continue
+		}
+		matches, err := inSpecRange(field.spec, field.value)
+		if err != nil {
+			return false, err
+		}
+		if !matches {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// whether a value is in a date_spec range, which is either a single value like "9" or an inclusive range like "9-16"
+func inSpecRange(spec string, value int) (bool, error) {
+	bounds := strings.SplitN(spec, "-", 2)
+	low, err := strconv.Atoi(strings.TrimSpace(bounds[0]))
+	if err != nil {
+		return false, errors.Errorf("could not parse range '%s'", spec)
+	}
+	high := low
+	if len(bounds) == 2 {
+		high, err = strconv.Atoi(strings.TrimSpace(bounds[1]))
+		if err != nil {
+			return false, errors.Errorf("could not parse range '%s'", spec)
+		}
+	}
+	return value >= low && value <= high, nil
+}
@@ -0,0 +1,122 @@
+package pacemaker
+
+import (
+	"context"
+	"math"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+
+	"github.com/ClusterLabs/ha_cluster_exporter/collector"
+)
+
+// the name of the placement scores loop in the heartbeat metric
+const scoresHeartbeatLoop = "crm_simulate"
+
+type placement struct {
+	resource string
+	node     string
+}
+
+// runs crm_simulate periodically in the background and keeps the placement scores of the last run,
+// because simulating the whole cluster is too expensive to be done on each scrape
+type placementScorer struct {
+	crmSimulatePath string
+	logger          log.Logger
+	runner          *collector.BackgroundRunner
+
+	mutex    sync.Mutex
+	scored   bool
+	scoredAt time.Time
+	scores   map[placement]float64
+}
+
+// creates a placementScorer and starts computing the scores right away, then once per interval
+func newPlacementScorer(crmSimulatePath string, interval time.Duration, logger log.Logger) *placementScorer {
+	s := &placementScorer{
+		crmSimulatePath: crmSimulatePath,
+		logger:          logger,
+	}
+
+	s.runner = collector.RunInBackground(scoresHeartbeatLoop, interval, func(ctx context.Context) {
+		err := s.score(ctx)
+		if err != nil {
+			level.Warn(s.logger).Log("msg", "Could not compute the resource placement scores", "err", err)
+		}
+	})
+
+	return s
+}
+
+// stops computing the scores; the scores of the last run are kept
+func (s *placementScorer) stop() {
+	s.runner.Stop()
+}
+
+func (s *placementScorer) score(ctx context.Context) error {
+	scoredAt := time.Now()
+
+	output, err := collector.CommandContext(ctx, s.crmSimulatePath, "--live-check", "--show-scores").Output()
+	if err != nil {
+		return errors.Wrap(err, "could not run crm_simulate")
+	}
+
+	scores := parseCrmSimulateScores(output)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.scored = true
+	s.scoredAt = scoredAt
+	s.scores = scores
+
+	return nil
+}
+
+// returns the scores of the last run and when it started; ok is false until the first run completes
+func (s *placementScorer) result() (scores map[placement]float64, scoredAt time.Time, ok bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.scores, s.scoredAt, s.scored
+}
+
+// parses the allocation scores of the primitive resources out of the output of `crm_simulate --show-scores`;
+// the lines of groups and clones are intermediate steps of the placement, so only the final ones are kept:
+/*
+	pcmk__clone_allocate: cln_SAPHanaTopology allocation score on node01: 0
+	pcmk__native_allocate: rsc_ip_PRD_HDB00 allocation score on node01: 150
+	pcmk__native_allocate: rsc_ip_PRD_HDB00 allocation score on node02: -INFINITY
+	rsc_SAPHana_PRD_HDB00:0 promotion score on node01: 150
+*/
+// the function name in front of the scores changed across Pacemaker versions, so all of them are recognized
+func parseCrmSimulateScores(output []byte) map[placement]float64 {
+	re := regexp.MustCompile(`(?m)^\s*(?:native_color|pcmk__native_allocate|pcmk__primitive_assign):\s+(\S+) allocation score on (\S+):\s+(\S+)\s*$`)
+	scores := make(map[placement]float64)
+	for _, match := range re.FindAllSubmatch(output, -1) {
+		score, err := parseScore(string(match[3]))
+		if err != nil {
+			continue
+		}
+		scores[placement{resource: string(match[1]), node: string(match[2])}] = score
+	}
+	return scores
+}
+
+// parses a Pacemaker score, where the infinities are spelled out
+func parseScore(value string) (float64, error) {
+	switch value {
+	case "INFINITY", "+INFINITY":
+		return math.Inf(1), nil
+	case "-INFINITY":
+		return math.Inf(-1), nil
+	}
+	score, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, errors.Wrapf(err, "could not parse score '%s'", value)
+	}
+	return float64(score), nil
+}
@@ -0,0 +1,100 @@
+package pacemaker
+
+import (
+	"context"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+
+	"github.com/ClusterLabs/ha_cluster_exporter/collector"
+)
+
+// the name of the configuration check loop in the heartbeat metric
+const verifyHeartbeatLoop = "crm_verify"
+
+// runs crm_verify periodically in the background and keeps the result of the last run,
+// because checking the whole live configuration is too expensive to be done on each scrape
+type configVerifier struct {
+	crmVerifyPath string
+	logger        log.Logger
+	runner        *collector.BackgroundRunner
+
+	mutex      sync.Mutex
+	verified   bool
+	verifiedAt time.Time
+	errors     int
+	warnings   int
+}
+
+// creates a configVerifier and starts checking the configuration right away, then once per interval
+func newConfigVerifier(crmVerifyPath string, interval time.Duration, logger log.Logger) *configVerifier {
+	v := &configVerifier{
+		crmVerifyPath: crmVerifyPath,
+		logger:        logger,
+	}
+
+	v.runner = collector.RunInBackground(verifyHeartbeatLoop, interval, func(ctx context.Context) {
+		err := v.verify(ctx)
+		if err != nil {
+			level.Warn(v.logger).Log("msg", "Could not verify the cluster configuration", "err", err)
+		}
+	})
+
+	return v
+}
+
+// stops checking the configuration; the result of the last check is kept
+func (v *configVerifier) stop() {
+	v.runner.Stop()
+}
+
+func (v *configVerifier) verify(ctx context.Context) error {
+	verifiedAt := time.Now()
+
+	// crm_verify exits with a non-zero code when the configuration is not valid, so only failing to run it at all is an error
+	output, err := collector.CommandContext(ctx, v.crmVerifyPath, "--live-check", "-V").CombinedOutput()
+	if _, isExitError := err.(*exec.ExitError); err != nil && (!isExitError || collector.IsRemoteFailure(err)) {
+		return errors.Wrap(err, "could not run crm_verify")
+	}
+
+	configErrors, configWarnings := parseCrmVerify(output)
+
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	v.verified = true
+	v.verifiedAt = verifiedAt
+	v.errors = configErrors
+	v.warnings = configWarnings
+
+	return nil
+}
+
+// returns the counts of the last check and when it started; ok is false until the first check completes
+func (v *configVerifier) result() (configErrors int, configWarnings int, verifiedAt time.Time, ok bool) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	return v.errors, v.warnings, v.verifiedAt, v.verified
+}
+
+// counts the errors and warnings in the output of crm_verify, which has one line per issue like:
+/*
+	   error: unpack_resources:	Resource start-up disabled since no STONITH resources have been defined
+	warning: unpack_rsc_op_failure:	Processing failed op monitor for rsc_ip on node01: not running (7)
+*/
+func parseCrmVerify(output []byte) (configErrors int, configWarnings int) {
+	re := regexp.MustCompile(`(?mi)^\s*(error|warning):`)
+	for _, match := range re.FindAllSubmatch(output, -1) {
+		if strings.EqualFold(string(match[1]), "warning") {
+			configWarnings++
+		} else {
+			configErrors++
+		}
+	}
+	return configErrors, configWarnings
+}
@@ -0,0 +1,34 @@
+package pacemaker
+
+import (
+	"github.com/ClusterLabs/ha_cluster_exporter/collector/pacemaker/cib"
+	"github.com/ClusterLabs/ha_cluster_exporter/collector/pacemaker/crmmon"
+)
+
+// the resource agent managing the virtual IPs
+const ipAgent = "IPaddr2"
+
+// VirtualIPs returns the ip of each IPaddr2 resource, by resource id, wherever it's configured; the vip collector checks them
+func VirtualIPs(CIB cib.Root) map[string]string {
+	ips := make(map[string]string)
+	forEachPrimitive(CIB, func(primitive cib.Primitive, _ []cib.Attribute, _ bool) {
+		if primitive.Type != ipAgent {
+			return
+		}
+		if ip, ok := getAttribute(primitive.InstanceAttributes, "ip"); ok {
+			ips[primitive.Id] = ip
+		}
+	})
+	return ips
+}
+
+// StartedResources returns the ids of the resources that are started on a node, including the members of groups and the instances of clones
+func StartedResources(crmMon crmmon.Root, node string) map[string]bool {
+	started := make(map[string]bool)
+	forEachResource(crmMon, func(resource crmmon.Resource, _ string, _ string) {
+		if resource.Role == "Started" && resource.Active && resource.Node != nil && resource.Node.Name == node {
+			started[resource.Id] = true
+		}
+	})
+	return started
+}
@@ -0,0 +1,48 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// describes a collector that can dump the raw output of the external commands it runs,
+// so that parsing issues can be debugged against exactly what the cluster tools produced
+type RawOutputCollector interface {
+	SubsystemCollector
+	CollectRawOutput(ctx context.Context, w io.Writer) error
+}
+
+// runs the given command and writes its raw standard output to w, preceded by a header line with the command itself.
+// non-zero exit codes are not considered errors, because some tools use them to report faults while still printing their output;
+// the exit code is written in the header instead.
+func WriteCommandOutput(ctx context.Context, w io.Writer, path string, args ...string) error {
+	command := strings.Join(append([]string{path}, args...), " ")
+
+	output, err := CommandContext(ctx, path, args...).Output()
+	if ctx.Err() != nil {
+		return errors.Wrapf(ctx.Err(), "'%s' did not complete", command)
+	}
+
+	exitError, isExitError := err.(*exec.ExitError)
+	if err != nil && (!isExitError || IsRemoteFailure(err)) {
+		return errors.Wrapf(err, "could not run '%s'", command)
+	}
+
+	if isExitError {
+		fmt.Fprintf(w, "### %s (exit code %d)\n", command, exitError.ExitCode())
+	} else {
+		fmt.Fprintf(w, "### %s\n", command)
+	}
+	_, err = w.Write(output)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(w)
+
+	return nil
+}
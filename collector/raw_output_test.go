@@ -0,0 +1,41 @@
+package collector
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteCommandOutput(t *testing.T) {
+	var output bytes.Buffer
+	err := WriteCommandOutput(context.Background(), &output, "../test/fake_systemctl.sh", "show", "sbd.service")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "### ../test/fake_systemctl.sh show sbd.service\nTimeoutStartUSec=1min 30s\nTimeoutStopUSec=1min\n\n", output.String())
+}
+
+func TestWriteCommandOutputWithExitCode(t *testing.T) {
+	var output bytes.Buffer
+	err := WriteCommandOutput(context.Background(), &output, "../test/fake_sbd.sh", "-d", "/dev/vdd", "dump")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "### ../test/fake_sbd.sh -d /dev/vdd dump (exit code 1)\n\n", output.String())
+}
+
+func TestWriteCommandOutputErrors(t *testing.T) {
+	var output bytes.Buffer
+	err := WriteCommandOutput(context.Background(), &output, "../test/nonexistent")
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "could not run '../test/nonexistent'")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = WriteCommandOutput(ctx, &output, "../test/fake_systemctl.sh")
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "'../test/fake_systemctl.sh' did not complete")
+	assert.Empty(t, output.String())
+}
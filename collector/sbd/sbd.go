@@ -1,10 +1,13 @@
 package sbd
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"math"
 	"os"
-	"os/exec"
+	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
@@ -22,8 +25,17 @@ const subsystem = "sbd"
 const SBD_STATUS_UNHEALTHY = "unhealthy"
 const SBD_STATUS_HEALTHY = "healthy"
 
+// the actions SBD can take when its watchers don't report in time, as set in SBD_TIMEOUT_ACTION
+var sbdActions = []string{"reboot", "off", "crashdump"}
+
+// the action SBD takes when SBD_TIMEOUT_ACTION is unset, or only sets the flushing behaviour
+const defaultSbdAction = "reboot"
+
 // NewCollector create a new sbd collector
-func NewCollector(sbdPath string, sbdConfigPath string, timestamps bool, logger log.Logger) (*sbdCollector, error) {
+// the systemctl executable is optional: if it can't be found, the sbd service timeouts are simply not exposed
+// the watchdog class in sysfs is only used to tell when SBD last pinged its watchdog, where the driver supports it
+// whether the sbd service is enabled at boot is checked only if services is not nil
+func NewCollector(sbdPath string, sbdConfigPath string, systemctlPath string, watchdogSysfsPath string, services *collector.ServiceChecker, timestamps bool, logger log.Logger) (*sbdCollector, error) {
 	err := checkArguments(sbdPath, sbdConfigPath)
 	if err != nil {
 		return nil, errors.Wrapf(err, "could not initialize '%s' collector", subsystem)
@@ -33,10 +45,21 @@ func NewCollector(sbdPath string, sbdConfigPath string, timestamps bool, logger
 		collector.NewDefaultCollector(subsystem, timestamps, logger),
 		sbdPath,
 		sbdConfigPath,
+		systemctlPath,
+		watchdogSysfsPath,
+		services,
 	}
 
 	c.SetDescriptor("devices", "SBD devices; one line per device", []string{"device", "status"})
 	c.SetDescriptor("timeouts", "SBD timeouts for each device and type", []string{"device", "type"})
+	c.SetDescriptor("watchdog_timeout", "The SBD_WATCHDOG_TIMEOUT in seconds, as set in the SBD configuration", nil)
+	c.SetDescriptor("watchdog_last_ping_age_seconds", "How long ago SBD last pinged its watchdog device, in seconds", nil)
+	c.SetDescriptor("service_enabled", "Whether the sbd systemd service is enabled to start at boot; 1 means enabled, 0 otherwise", []string{"service"})
+	c.SetDescriptor("service_timeouts", "The systemd timeouts of the sbd service in seconds; one line per type", []string{"type"})
+	c.SetDescriptor("action", "The action SBD takes when it self-fences on a timeout, as set in the SBD configuration; one line per known action, the effective one having value 1", []string{"action"})
+	c.SetDescriptor("device_pending_message", "Whether a node slot on an SBD device holds a message not yet delivered; one line per device and node", []string{"device", "node"})
+
+	c.SetVolatile("watchdog_last_ping_age_seconds")
 
 	return c, nil
 }
@@ -53,13 +76,20 @@ func checkArguments(sbdPath string, sbdConfigPath string) error {
 
 type sbdCollector struct {
 	collector.DefaultCollector
-	sbdPath       string
-	sbdConfigPath string
+	sbdPath           string
+	sbdConfigPath     string
+	systemctlPath     string
+	watchdogSysfsPath string
+	// nil when the service is not checked
+	services *collector.ServiceChecker
 }
 
 func (c *sbdCollector) CollectWithError(ch chan<- prometheus.Metric) error {
 	level.Debug(c.Logger).Log("msg", "Collecting pacemaker metrics...")
 
+	// sbd is started along with corosync, as a dependency of it, but only if its own unit is enabled
+	c.services.Record(&c.DefaultCollector, ch)
+
 	sbdConfiguration, err := readSdbFile(c.sbdConfigPath)
 	if err != nil {
 		return err
@@ -81,6 +111,48 @@ func (c *sbdCollector) CollectWithError(ch chan<- prometheus.Metric) error {
 		ch <- c.MakeGaugeMetric("timeouts", sbdMsgWait, sbdDev, "msgwait")
 	}
 
+	for sbdDev, nodeMessages := range c.getSbdPendingMessages(sbdDevices) {
+		for node, pending := range nodeMessages {
+			var value float64
+			if pending {
+				value = 1
+			}
+			ch <- c.MakeGaugeMetric("device_pending_message", value, sbdDev, node)
+		}
+	}
+
+	if watchdogTimeout, ok := getSbdWatchdogTimeout(sbdConfiguration); ok {
+		ch <- c.MakeGaugeMetric("watchdog_timeout", watchdogTimeout)
+	}
+
+	action, err := getSbdTimeoutAction(sbdConfiguration)
+	if err != nil {
+		level.Warn(c.Logger).Log("msg", "Could not parse the sbd timeout action", "err", err)
+	} else {
+		for _, a := range sbdActions {
+			var value float64
+			if a == action {
+				value = 1
+			}
+			ch <- c.MakeGaugeMetric("action", value, a)
+		}
+	}
+
+	lastPingAge, err := c.getWatchdogLastPingAge(getSbdWatchdogDevice(sbdConfiguration))
+	if err != nil {
+		level.Debug(c.Logger).Log("msg", "Could not detect when the sbd watchdog was last pinged", "err", err)
+	} else {
+		ch <- c.MakeGaugeMetric("watchdog_last_ping_age_seconds", lastPingAge)
+	}
+
+	serviceTimeouts, err := c.getServiceTimeouts()
+	if err != nil {
+		level.Debug(c.Logger).Log("msg", "Could not detect the sbd service timeouts", "err", err)
+	}
+	for timeoutType, timeout := range serviceTimeouts {
+		ch <- c.MakeGaugeMetric("service_timeouts", timeout, timeoutType)
+	}
+
 	return nil
 }
 
@@ -93,6 +165,26 @@ func (c *sbdCollector) Collect(ch chan<- prometheus.Metric) {
 	}
 }
 
+func (c *sbdCollector) CollectRawOutput(ctx context.Context, w io.Writer) error {
+	sbdConfiguration, err := readSdbFile(c.sbdConfigPath)
+	if err != nil {
+		return err
+	}
+
+	for _, sbdDev := range getSbdDevices(sbdConfiguration) {
+		err = collector.WriteCommandOutput(ctx, w, c.sbdPath, "-d", sbdDev, "dump")
+		if err != nil {
+			return err
+		}
+		err = collector.WriteCommandOutput(ctx, w, c.sbdPath, "-d", sbdDev, "list")
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func readSdbFile(sbdConfigPath string) ([]byte, error) {
 	sbdConfFile, err := os.Open(sbdConfigPath)
 	if err != nil {
@@ -130,12 +222,190 @@ func getSbdDevices(sbdConfigRaw []byte) []string {
 	return sbdDevices
 }
 
+// retrieve the SBD_WATCHDOG_TIMEOUT value from the config file contents, if set
+func getSbdWatchdogTimeout(sbdConfigRaw []byte) (float64, bool) {
+	regex := regexp.MustCompile(`(?m)^\s*SBD_WATCHDOG_TIMEOUT="?(\d+)"?\s*$`)
+	matches := regex.FindStringSubmatch(string(sbdConfigRaw))
+	if matches == nil {
+		return 0, false
+	}
+
+	timeout, err := strconv.ParseFloat(matches[1], 64)
+	if err != nil {
+		return 0, false
+	}
+
+	return timeout, true
+}
+
+// retrieve the effective action from the SBD_TIMEOUT_ACTION value in the config file contents, e.g. `flush,reboot`;
+// the value combines an optional flush|noflush with an optional reboot|off|crashdump, and the action defaults to reboot when not given
+func getSbdTimeoutAction(sbdConfigRaw []byte) (string, error) {
+	regex := regexp.MustCompile(`(?m)^\s*SBD_TIMEOUT_ACTION="?([\w,]*)"?\s*$`)
+	matches := regex.FindStringSubmatch(string(sbdConfigRaw))
+	if matches == nil {
+		return defaultSbdAction, nil
+	}
+
+	action := defaultSbdAction
+	for _, token := range strings.Split(matches[1], ",") {
+		switch token {
+		case "", "flush", "noflush":
+		case "reboot", "off", "crashdump":
+			action = token
+		default:
+			return "", errors.Errorf("unknown SBD_TIMEOUT_ACTION '%s'", token)
+		}
+	}
+
+	return action, nil
+}
+
+// retrieve the SBD_WATCHDOG_DEV value from the config file contents, which defaults to /dev/watchdog
+func getSbdWatchdogDevice(sbdConfigRaw []byte) string {
+	regex := regexp.MustCompile(`(?m)^\s*SBD_WATCHDOG_DEV="?([\w-/]+)"?\s*$`)
+	matches := regex.FindStringSubmatch(string(sbdConfigRaw))
+	if matches == nil {
+		return "/dev/watchdog"
+	}
+	return matches[1]
+}
+
+// the watchdog timer is reset to its timeout on each ping, so the time since the last one is the timeout minus the time left;
+// not all the drivers expose the time left in sysfs, and it's only meaningful while the watchdog is active
+func (c *sbdCollector) getWatchdogLastPingAge(device string) (float64, error) {
+	// sysfs is local, so it can't tell anything about the watchdog when the commands run on a remote host
+	if collector.IsRemote() {
+		return 0, errors.New("the watchdog can't be checked on a remote host")
+	}
+	if device == "/dev/null" {
+		return 0, errors.New("sbd is configured without a watchdog")
+	}
+
+	// the legacy /dev/watchdog device is an alias of the first watchdog registered, i.e. /dev/watchdog0
+	name := filepath.Base(device)
+	if name == "watchdog" {
+		name = "watchdog0"
+	}
+	sysfsDevice := filepath.Join(c.watchdogSysfsPath, name)
+
+	state, err := readSysfsAttribute(sysfsDevice, "state")
+	if err == nil && state != "active" {
+		return 0, errors.Errorf("watchdog '%s' is %s", device, state)
+	}
+
+	timeout, err := readSysfsAttribute(sysfsDevice, "timeout")
+	if err != nil {
+		return 0, err
+	}
+	timeleft, err := readSysfsAttribute(sysfsDevice, "timeleft")
+	if err != nil {
+		return 0, err
+	}
+
+	timeoutSeconds, err := strconv.ParseFloat(timeout, 64)
+	if err != nil {
+		return 0, errors.Wrapf(err, "could not parse the timeout of watchdog '%s'", device)
+	}
+	timeleftSeconds, err := strconv.ParseFloat(timeleft, 64)
+	if err != nil {
+		return 0, errors.Wrapf(err, "could not parse the time left of watchdog '%s'", device)
+	}
+
+	return math.Max(timeoutSeconds-timeleftSeconds, 0), nil
+}
+
+func readSysfsAttribute(device string, attribute string) (string, error) {
+	content, err := ioutil.ReadFile(filepath.Join(device, attribute))
+	if err != nil {
+		return "", errors.Wrapf(err, "could not read watchdog attribute '%s'", attribute)
+	}
+	return strings.TrimSpace(string(content)), nil
+}
+
+// retrieve the start and stop timeouts of the sbd systemd service, in seconds, via systemctl
+func (c *sbdCollector) getServiceTimeouts() (map[string]float64, error) {
+	if err := collector.CheckExecutables(c.systemctlPath); err != nil {
+		return nil, err
+	}
+
+	output, err := collector.Command(c.systemctlPath, "show", "sbd.service", "--property=TimeoutStartUSec", "--property=TimeoutStopUSec").Output()
+	if err != nil {
+		return nil, errors.Wrap(err, "systemctl command failed")
+	}
+
+	properties := map[string]string{
+		"TimeoutStartUSec": "start",
+		"TimeoutStopUSec":  "stop",
+	}
+
+	timeouts := make(map[string]float64)
+	for _, line := range strings.Split(string(output), "\n") {
+		property := strings.SplitN(strings.TrimSpace(line), "=", 2)
+		if len(property) != 2 {
+			continue
+		}
+		timeoutType, ok := properties[property[0]]
+		if !ok {
+			continue
+		}
+		timeout, err := parseSystemdTimespan(property[1])
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not parse %s", property[0])
+		}
+		timeouts[timeoutType] = timeout
+	}
+
+	return timeouts, nil
+}
+
+// parses a systemd time span like `1min 30s` into seconds; `infinity` is parsed as +Inf
+// see systemd.time(7)
+func parseSystemdTimespan(timespan string) (float64, error) {
+	timespan = strings.TrimSpace(timespan)
+	if timespan == "" {
+		return 0, errors.New("empty time span")
+	}
+	if timespan == "infinity" {
+		return math.Inf(1), nil
+	}
+
+	units := map[string]float64{
+		"us": 1e-6, "usec": 1e-6,
+		"ms": 1e-3, "msec": 1e-3,
+		"": 1, "s": 1, "sec": 1, "second": 1, "seconds": 1,
+		"m": 60, "min": 60, "minute": 60, "minutes": 60,
+		"h": 3600, "hr": 3600, "hour": 3600, "hours": 3600,
+		"d": 86400, "day": 86400, "days": 86400,
+		"w": 604800, "week": 604800, "weeks": 604800,
+		"M": 2629800, "month": 2629800, "months": 2629800,
+		"y": 31557600, "year": 31557600, "years": 31557600,
+	}
+
+	regex := regexp.MustCompile(`^(\d+(?:\.\d+)?)\s*([a-zA-Z]*)$`)
+	var seconds float64
+	for _, part := range strings.Fields(timespan) {
+		matches := regex.FindStringSubmatch(part)
+		if matches == nil {
+			return 0, errors.Errorf("invalid time span '%s'", timespan)
+		}
+		multiplier, ok := units[matches[2]]
+		if !ok {
+			return 0, errors.Errorf("unknown unit '%s' in time span '%s'", matches[2], timespan)
+		}
+		value, _ := strconv.ParseFloat(matches[1], 64)
+		seconds += value * multiplier
+	}
+
+	return seconds, nil
+}
+
 // this function takes a list of sbd devices and returns
 // a map of SBD device names with 1 if healthy, 0 if not
 func (c *sbdCollector) getSbdDeviceStatuses(sbdDevices []string) map[string]string {
 	sbdStatuses := make(map[string]string)
 	for _, sbdDev := range sbdDevices {
-		_, err := exec.Command(c.sbdPath, "-d", sbdDev, "dump").Output()
+		_, err := collector.Command(c.sbdPath, "-d", sbdDev, "dump").Output()
 
 		// in case of error the device is not healthy
 		if err != nil {
@@ -153,7 +423,7 @@ func (c *sbdCollector) getSbdTimeouts(sbdDevices []string) (map[string]float64,
 	sbdWatchdogs := make(map[string]float64)
 	sbdMsgWaits := make(map[string]float64)
 	for _, sbdDev := range sbdDevices {
-		sbdDump, _ := exec.Command(c.sbdPath, "-d", sbdDev, "dump").Output()
+		sbdDump, _ := collector.Command(c.sbdPath, "-d", sbdDev, "dump").Output()
 
 		regexW := regexp.MustCompile(`Timeout \(msgwait\)  *: \d+`)
 		regex := regexp.MustCompile(`Timeout \(watchdog\)  *: \d+`)
@@ -183,3 +453,32 @@ func (c *sbdCollector) getSbdTimeouts(sbdDevices []string) (map[string]float64,
 	}
 	return sbdWatchdogs, sbdMsgWaits
 }
+
+// for each sbd device, check which node slots hold a message other than `clear` via `sbd list`;
+// devices that can't be listed are skipped
+func (c *sbdCollector) getSbdPendingMessages(sbdDevices []string) map[string]map[string]bool {
+	pendingMessages := make(map[string]map[string]bool)
+	for _, sbdDev := range sbdDevices {
+		sbdList, err := collector.Command(c.sbdPath, "-d", sbdDev, "list").Output()
+		if err != nil {
+			level.Debug(c.Logger).Log("msg", "Could not list the SBD device slots", "device", sbdDev, "err", err)
+			continue
+		}
+		pendingMessages[sbdDev] = parseSbdList(sbdList)
+	}
+	return pendingMessages
+}
+
+// parses the output of `sbd list`, i.e. one line per slot like `0	node01	clear	`,
+// where the optional last field is the node which sent the message
+func parseSbdList(sbdList []byte) map[string]bool {
+	pending := make(map[string]bool)
+	for _, line := range strings.Split(string(sbdList), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 3 {
+			continue
+		}
+		pending[fields[1]] = fields[2] != "clear"
+	}
+	return pending
+}
@@ -1,11 +1,17 @@
 package sbd
 
 import (
+	"bytes"
+	"context"
+	"math"
+	"strings"
 	"testing"
 
 	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 
+	"github.com/ClusterLabs/ha_cluster_exporter/collector"
 	assertcustom "github.com/ClusterLabs/ha_cluster_exporter/internal/assert"
 )
 
@@ -188,40 +194,173 @@ SBD_DEVICE=/dev/vdc;/dev/vdd`
 }
 
 func TestNewSbdCollector(t *testing.T) {
-	_, err := NewCollector("../../test/fake_sbd.sh", "../../test/fake_sbdconfig", false, log.NewNopLogger())
+	_, err := NewCollector("../../test/fake_sbd.sh", "../../test/fake_sbdconfig", "../../test/fake_systemctl.sh", "../../test/fake_watchdog", nil, false, log.NewNopLogger())
 
 	assert.Nil(t, err)
 }
 
 func TestNewSbdCollectorChecksSbdConfigExistence(t *testing.T) {
-	_, err := NewCollector("../../test/fake_sbd.sh", "../../test/nonexistent", false, log.NewNopLogger())
+	_, err := NewCollector("../../test/fake_sbd.sh", "../../test/nonexistent", "../../test/fake_systemctl.sh", "../../test/fake_watchdog", nil, false, log.NewNopLogger())
 
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "'../../test/nonexistent' does not exist")
 }
 
 func TestNewSbdCollectorChecksSbdExistence(t *testing.T) {
-	_, err := NewCollector("../../test/nonexistent", "../../test/fake_sbdconfig", false, log.NewNopLogger())
+	_, err := NewCollector("../../test/nonexistent", "../../test/fake_sbdconfig", "../../test/fake_systemctl.sh", "../../test/fake_watchdog", nil, false, log.NewNopLogger())
 
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "'../../test/nonexistent' does not exist")
 }
 
 func TestNewSbdCollectorChecksSbdExecutableBits(t *testing.T) {
-	_, err := NewCollector("../../test/dummy", "../../test/fake_sbdconfig", false, log.NewNopLogger())
+	_, err := NewCollector("../../test/dummy", "../../test/fake_sbdconfig", "../../test/fake_systemctl.sh", "../../test/fake_watchdog", nil, false, log.NewNopLogger())
 
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "'../../test/dummy' is not executable")
 }
 
 func TestSBDCollector(t *testing.T) {
-	collector, _ := NewCollector("../../test/fake_sbd_dump.sh", "../../test/fake_sbdconfig", false, log.NewNopLogger())
+	collector, _ := NewCollector("../../test/fake_sbd_dump.sh", "../../test/fake_sbdconfig", "../../test/fake_systemctl.sh", "../../test/fake_watchdog", nil, false, log.NewNopLogger())
 	assertcustom.Metrics(t, collector, "sbd.metrics")
 }
 
 func TestWatchdog(t *testing.T) {
-	collector, err := NewCollector("../../test/fake_sbd_dump.sh", "../../test/fake_sbdconfig", false, log.NewNopLogger())
+	collector, err := NewCollector("../../test/fake_sbd_dump.sh", "../../test/fake_sbdconfig", "../../test/fake_systemctl.sh", "../../test/fake_watchdog", nil, false, log.NewNopLogger())
 
 	assert.Nil(t, err)
 	assertcustom.Metrics(t, collector, "sbd.metrics")
 }
+
+func TestSbdCollectorWithoutSystemctl(t *testing.T) {
+	collector, err := NewCollector("../../test/fake_sbd_dump.sh", "../../test/fake_sbdconfig", "../../test/nonexistent", "../../test/fake_watchdog", nil, false, log.NewNopLogger())
+	assert.Nil(t, err)
+
+	serviceTimeouts, err := collector.getServiceTimeouts()
+	assert.Error(t, err)
+	assert.Empty(t, serviceTimeouts)
+}
+
+func TestSbdCollectorServiceEnabled(t *testing.T) {
+	services := collector.NewServiceChecker("../../test/fake_systemctl.sh", log.NewNopLogger(), "sbd")
+	collector, err := NewCollector("../../test/fake_sbd_dump.sh", "../../test/fake_sbdconfig", "../../test/fake_systemctl.sh", "../../test/fake_watchdog", services, false, log.NewNopLogger())
+	assert.Nil(t, err)
+
+	expect := `
+	# HELP ha_cluster_sbd_service_enabled Whether the sbd systemd service is enabled to start at boot; 1 means enabled, 0 otherwise
+	# TYPE ha_cluster_sbd_service_enabled gauge
+	ha_cluster_sbd_service_enabled{service="sbd"} 0
+	`
+	err = testutil.CollectAndCompare(collector, strings.NewReader(expect), "ha_cluster_sbd_service_enabled")
+	assert.NoError(t, err)
+}
+
+func TestGetSbdWatchdogTimeout(t *testing.T) {
+	timeout, ok := getSbdWatchdogTimeout([]byte("SBD_DEVICE=/dev/vdc\n# SBD_WATCHDOG_TIMEOUT=10\nSBD_WATCHDOG_TIMEOUT=\"15\"\n"))
+	assert.True(t, ok)
+	assert.Equal(t, 15.0, timeout)
+
+	_, ok = getSbdWatchdogTimeout([]byte("SBD_DEVICE=/dev/vdc\n#SBD_WATCHDOG_TIMEOUT=10\n"))
+	assert.False(t, ok)
+}
+
+func TestGetSbdTimeoutAction(t *testing.T) {
+	action, err := getSbdTimeoutAction([]byte("#SBD_TIMEOUT_ACTION=flush,reboot\nSBD_TIMEOUT_ACTION=\"noflush,off\"\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, "off", action)
+
+	action, err = getSbdTimeoutAction([]byte("SBD_TIMEOUT_ACTION=crashdump\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, "crashdump", action)
+
+	action, err = getSbdTimeoutAction([]byte("SBD_TIMEOUT_ACTION=noflush\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, "reboot", action)
+
+	action, err = getSbdTimeoutAction([]byte("SBD_DEVICE=/dev/vdc\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, "reboot", action)
+
+	_, err = getSbdTimeoutAction([]byte("SBD_TIMEOUT_ACTION=flush,poweroff\n"))
+	assert.Error(t, err)
+}
+
+func TestGetSbdWatchdogDevice(t *testing.T) {
+	assert.Equal(t, "/dev/watchdog1", getSbdWatchdogDevice([]byte("#SBD_WATCHDOG_DEV=/dev/watchdog\nSBD_WATCHDOG_DEV=\"/dev/watchdog1\"\n")))
+	assert.Equal(t, "/dev/watchdog", getSbdWatchdogDevice([]byte("SBD_DEVICE=/dev/vdc\n")))
+}
+
+func TestWatchdogLastPingAge(t *testing.T) {
+	collector, err := NewCollector("../../test/fake_sbd_dump.sh", "../../test/fake_sbdconfig", "../../test/fake_systemctl.sh", "../../test/fake_watchdog", nil, false, log.NewNopLogger())
+	assert.Nil(t, err)
+
+	age, err := collector.getWatchdogLastPingAge("/dev/watchdog")
+	assert.NoError(t, err)
+	assert.Equal(t, 2.0, age)
+
+	age, err = collector.getWatchdogLastPingAge("/dev/watchdog0")
+	assert.NoError(t, err)
+	assert.Equal(t, 2.0, age)
+
+	_, err = collector.getWatchdogLastPingAge("/dev/watchdog1")
+	assert.Error(t, err)
+
+	_, err = collector.getWatchdogLastPingAge("/dev/null")
+	assert.Error(t, err)
+}
+
+func TestWatchdogLastPingAgeRemote(t *testing.T) {
+	sbdCollector, err := NewCollector("../../test/fake_sbd_dump.sh", "../../test/fake_sbdconfig", "../../test/fake_systemctl.sh", "../../test/fake_watchdog", nil, false, log.NewNopLogger())
+	assert.Nil(t, err)
+
+	collector.SetRemoteHost(&collector.RemoteHost{Host: "node01", SshPath: "../../test/fake_ssh.sh"})
+	defer collector.SetRemoteHost(nil)
+
+	_, err = sbdCollector.getWatchdogLastPingAge("/dev/watchdog")
+	assert.Error(t, err)
+}
+
+func TestParseSystemdTimespan(t *testing.T) {
+	testCases := []struct {
+		timespan string
+		expected float64
+	}{
+		{"0", 0},
+		{"90", 90},
+		{"45s", 45},
+		{"1min 30s", 90},
+		{"1h 2min 3s 500ms", 3723.5},
+		{"infinity", math.Inf(1)},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.timespan, func(t *testing.T) {
+			seconds, err := parseSystemdTimespan(tc.timespan)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, seconds)
+		})
+	}
+
+	for _, timespan := range []string{"", "1 fortnight", "abc"} {
+		_, err := parseSystemdTimespan(timespan)
+		assert.Error(t, err, timespan)
+	}
+}
+
+func TestParseSbdList(t *testing.T) {
+	pending := parseSbdList([]byte("0\tnode01\tclear\t\n1\tnode02\treset\tnode01\n2\tnode03\ttest\tnode01\n\n"))
+
+	assert.Equal(t, map[string]bool{"node01": false, "node02": true, "node03": true}, pending)
+}
+
+func TestSbdCollectRawOutput(t *testing.T) {
+	collector, _ := NewCollector("../../test/fake_sbd_dump.sh", "../../test/fake_sbdconfig", "../../test/fake_systemctl.sh", "../../test/fake_watchdog", nil, false, log.NewNopLogger())
+
+	var output bytes.Buffer
+	err := collector.CollectRawOutput(context.Background(), &output)
+
+	assert.NoError(t, err)
+	assert.Contains(t, output.String(), "### ../../test/fake_sbd_dump.sh -d /dev/vdc dump\n")
+	assert.Contains(t, output.String(), "### ../../test/fake_sbd_dump.sh -d /dev/vdd dump\n")
+	assert.Contains(t, output.String(), "### ../../test/fake_sbd_dump.sh -d /dev/vdd list\n")
+}
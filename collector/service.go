@@ -0,0 +1,113 @@
+package collector
+
+import (
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// tells whether a systemd service is enabled to start at boot, as reported by `systemctl is-enabled`;
+// installed is false if the unit file can't be found.
+// Only the `enabled` state counts, since e.g. `enabled-runtime` doesn't survive a reboot, and `masked` prevents the start altogether.
+func ServiceEnabled(systemctlPath string, service string) (enabled bool, installed bool, err error) {
+	// is-enabled exits with a non-zero code for any state but the enabled ones, while still printing the state
+	output, err := Command(systemctlPath, "is-enabled", service+".service").Output()
+	var exitError *exec.ExitError
+	if err != nil && (!errors.As(err, &exitError) || IsRemoteFailure(err)) {
+		return false, false, errors.Wrapf(err, "could not check whether the '%s' service is enabled", service)
+	}
+
+	state := strings.TrimSpace(string(output))
+	// older systemd versions only print an error on stderr for a missing unit, newer ones print not-found
+	if state == "" || state == "not-found" {
+		return false, false, nil
+	}
+	return state == "enabled", true, nil
+}
+
+// whether the services are enabled rarely changes, so they are checked at most once per interval rather than on every scrape
+const serviceCheckInterval = time.Minute
+
+// checks whether the services of a collector are enabled to start at boot, and records them as its service_enabled metric
+type ServiceChecker struct {
+	systemctlPath string
+	services      []string
+	logger        log.Logger
+
+	mutex     sync.Mutex
+	checkedAt time.Time
+	// whether each installed service is enabled, as of the last check; nil until the first one
+	enabled map[string]bool
+}
+
+// returns nil if systemctl can't be run, in which case the services are not checked
+func NewServiceChecker(systemctlPath string, logger log.Logger, services ...string) *ServiceChecker {
+	if err := CheckExecutables(systemctlPath); err != nil {
+		level.Warn(logger).Log("msg", "Whether the services are enabled won't be checked", "services", strings.Join(services, ","), "err", err)
+		return nil
+	}
+	return &ServiceChecker{
+		systemctlPath: systemctlPath,
+		services:      services,
+		logger:        logger,
+	}
+}
+
+// records whether each service is enabled, with one line per service whose unit file is installed;
+// nothing is recorded by a nil checker, i.e. when the services are not checked
+func (s *ServiceChecker) Record(c *DefaultCollector, ch chan<- prometheus.Metric) {
+	if s == nil {
+		return
+	}
+
+	enabled, checkedAt := s.check()
+	for _, service := range s.services {
+		isEnabled, installed := enabled[service]
+		if !installed {
+			continue
+		}
+		var value float64
+		if isEnabled {
+			value = 1
+		}
+		// the result can be older than the scrape
+		ch <- WithCollectionTime(c.MakeGaugeMetric("service_enabled", value, service), checkedAt)
+	}
+}
+
+func (s *ServiceChecker) check() (map[string]bool, time.Time) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := time.Now()
+	if s.enabled != nil && now.Sub(s.checkedAt) < serviceCheckInterval {
+		return s.enabled, s.checkedAt
+	}
+
+	enabled := make(map[string]bool)
+	failed := false
+	for _, service := range s.services {
+		isEnabled, installed, err := ServiceEnabled(s.systemctlPath, service)
+		if err != nil {
+			level.Warn(s.logger).Log("msg", "Could not check whether a service is enabled", "service", service, "err", err)
+			failed = true
+			continue
+		}
+		if installed {
+			enabled[service] = isEnabled
+		}
+	}
+
+	// a failed check is retried on the next scrape
+	if !failed {
+		s.enabled = enabled
+		s.checkedAt = now
+	}
+	return enabled, now
+}
@@ -0,0 +1,71 @@
+package collector
+
+import (
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServiceEnabled(t *testing.T) {
+	enabled, installed, err := ServiceEnabled("../test/fake_systemctl.sh", "pacemaker")
+	assert.NoError(t, err)
+	assert.True(t, installed)
+	assert.True(t, enabled)
+
+	enabled, installed, err = ServiceEnabled("../test/fake_systemctl.sh", "sbd")
+	assert.NoError(t, err)
+	assert.True(t, installed)
+	assert.False(t, enabled)
+
+	_, installed, err = ServiceEnabled("../test/fake_systemctl.sh", "pacemaker_remote")
+	assert.NoError(t, err)
+	assert.False(t, installed)
+
+	_, _, err = ServiceEnabled("../test/nonexistent", "pacemaker")
+	assert.Error(t, err)
+}
+
+func TestServiceChecker(t *testing.T) {
+	c := NewDefaultCollector("test", false, log.NewNopLogger())
+	c.SetDescriptor("service_enabled", "", []string{"service"})
+
+	record := func(checker *ServiceChecker) map[string]float64 {
+		ch := make(chan prometheus.Metric, 3)
+		checker.Record(&c, ch)
+		close(ch)
+
+		enabled := make(map[string]float64)
+		for metric := range ch {
+			metricDto := &dto.Metric{}
+			metric.Write(metricDto)
+			enabled[metricDto.GetLabel()[0].GetValue()] = metricDto.GetGauge().GetValue()
+		}
+		return enabled
+	}
+
+	// only the installed services are recorded
+	checker := NewServiceChecker("../test/fake_systemctl.sh", log.NewNopLogger(), "pacemaker", "sbd", "pacemaker_remote")
+	assert.NotNil(t, checker)
+	assert.Equal(t, map[string]float64{"pacemaker": 1, "sbd": 0}, record(checker))
+
+	// the result is reused until the interval expires
+	checkedAt := checker.checkedAt
+	assert.False(t, checkedAt.IsZero())
+	checker.check()
+	assert.Equal(t, checkedAt, checker.checkedAt)
+
+	checker.checkedAt = checkedAt.Add(-serviceCheckInterval)
+	checker.check()
+	assert.True(t, checker.checkedAt.After(checkedAt.Add(-serviceCheckInterval)))
+
+	// a failed check is retried on the next scrape
+	failing := &ServiceChecker{systemctlPath: "../test/nonexistent", services: []string{"pacemaker"}, logger: log.NewNopLogger()}
+	assert.Empty(t, record(failing))
+	assert.Nil(t, failing.enabled)
+
+	assert.Nil(t, NewServiceChecker("../test/nonexistent", log.NewNopLogger(), "pacemaker"))
+	assert.Empty(t, record(nil))
+}
@@ -0,0 +1,121 @@
+package collector
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// the versions of a tool whose output the collectors are known to parse correctly, from Min included to Max excluded
+type VersionRange struct {
+	Min string
+	Max string
+}
+
+// tells whether a version is in the range; only the numeric components are compared,
+// so build metadata like in "2.0.5+20201202.ba59be712" is ignored
+func (r VersionRange) Contains(version string) (bool, error) {
+	parsed, err := parseVersion(version)
+	if err != nil {
+		return false, err
+	}
+	min, err := parseVersion(r.Min)
+	if err != nil {
+		return false, err
+	}
+	max, err := parseVersion(r.Max)
+	if err != nil {
+		return false, err
+	}
+	return compareVersions(parsed, min) >= 0 && compareVersions(parsed, max) < 0, nil
+}
+
+func parseVersion(version string) ([]int, error) {
+	numeric := strings.FieldsFunc(version, func(r rune) bool { return r == '-' || r == '+' || r == '~' })
+	if len(numeric) == 0 {
+		return nil, errors.Errorf("could not parse version '%s'", version)
+	}
+
+	var components []int
+	for _, component := range strings.Split(numeric[0], ".") {
+		n, err := strconv.Atoi(component)
+		if err != nil {
+			return nil, errors.Errorf("could not parse version '%s'", version)
+		}
+		components = append(components, n)
+	}
+	return components, nil
+}
+
+// compares two versions component by component, the missing ones counting as 0; returns -1, 0 or 1 like strings.Compare
+func compareVersions(a []int, b []int) int {
+	for i := 0; i < len(a) || i < len(b); i++ {
+		var x, y int
+		if i < len(a) {
+			x = a[i]
+		}
+		if i < len(b) {
+			y = b[i]
+		}
+		if x < y {
+			return -1
+		}
+		if x > y {
+			return 1
+		}
+	}
+	return 0
+}
+
+// finds the first version number, e.g. 2.4.5, in the output of a tool printing its version
+func FindVersion(output []byte) string {
+	return string(regexp.MustCompile(`\d+(\.\d+)+`).Find(output))
+}
+
+// checks the version of the tool a collector parses the output of, and records it as its tool_version_supported metric;
+// the version doesn't change while the exporter runs, so it's only detected, and warned about, once
+type ToolVersion struct {
+	tool      string
+	supported VersionRange
+
+	mutex sync.Mutex
+	// empty until the version is detected; failures are not cached, so that the detection is retried on the next scrape
+	version            string
+	versionIsSupported bool
+}
+
+func NewToolVersion(tool string, supported VersionRange) *ToolVersion {
+	return &ToolVersion{tool: tool, supported: supported}
+}
+
+// records whether the version returned by detect is supported; detect is only called until it succeeds
+func (v *ToolVersion) Record(c *DefaultCollector, ch chan<- prometheus.Metric, detect func() (string, error)) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	if v.version == "" {
+		version, err := detect()
+		if err != nil || version == "" {
+			level.Debug(c.Logger).Log("msg", "Could not detect the "+v.tool+" version", "err", err)
+			return
+		}
+
+		v.version = version
+		ok, err := v.supported.Contains(version)
+		v.versionIsSupported = err == nil && ok
+		if !v.versionIsSupported {
+			level.Warn(c.Logger).Log("msg", v.tool+" "+version+" is not supported, the metrics may be unreliable", "supported", v.supported.Min+" <= version < "+v.supported.Max)
+		}
+	}
+
+	var supported float64
+	if v.versionIsSupported {
+		supported = 1
+	}
+	ch <- c.MakeGaugeMetric("tool_version_supported", supported, v.version)
+}
@@ -0,0 +1,29 @@
+package collector
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVersionRangeContains(t *testing.T) {
+	r := VersionRange{Min: "1.1.18", Max: "3"}
+
+	for version, expected := range map[string]bool{
+		"1.1.18":                   true,
+		"2.0.5+20201202.ba59be712": true,
+		"2.1":                      true,
+		"1.1.17":                   false,
+		"3.0.0":                    false,
+		"3.0.0-rc1":                false,
+	} {
+		contained, err := r.Contains(version)
+		assert.NoError(t, err, version)
+		assert.Equal(t, expected, contained, version)
+	}
+
+	_, err := r.Contains("unknown")
+	assert.Error(t, err)
+	_, err = r.Contains("")
+	assert.Error(t, err)
+}
@@ -0,0 +1,112 @@
+package vip
+
+import (
+	"net"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/ClusterLabs/ha_cluster_exporter/collector"
+	"github.com/ClusterLabs/ha_cluster_exporter/collector/pacemaker"
+	"github.com/ClusterLabs/ha_cluster_exporter/collector/pacemaker/cib"
+	"github.com/ClusterLabs/ha_cluster_exporter/collector/pacemaker/crmmon"
+)
+
+const subsystem = "vip"
+
+// NewCollector creates a new vip collector
+// the virtual IPs are read from the IPaddr2 resources in the CIB, and only checked against the interfaces of the local node,
+// which is named by crm_node once, at startup
+func NewCollector(crmMonPath string, cibAdminPath string, crmNodePath string, timestamps bool, logger log.Logger) (*vipCollector, error) {
+	err := collector.CheckExecutables(crmMonPath, cibAdminPath, crmNodePath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not initialize '%s' collector", subsystem)
+	}
+
+	nodeName, err := pacemaker.LocalNodeName(crmNodePath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not initialize '%s' collector", subsystem)
+	}
+
+	c := &vipCollector{
+		collector.NewDefaultCollector(subsystem, timestamps, logger),
+		crmmon.NewCrmMonParser(crmMonPath, false, logger),
+		cib.NewCibAdminParser(cibAdminPath),
+		nodeName,
+		net.InterfaceAddrs,
+	}
+
+	c.SetDescriptor("bound", "Whether each virtual IP managed by the cluster is assigned to an interface of the local node; 1 means bound, 0 otherwise", []string{"ip", "node"})
+	c.SetDescriptor("started", "Whether the resource managing each virtual IP is started on the local node, as per the cluster status; 1 means started, 0 otherwise", []string{"ip", "node"})
+
+	return c, nil
+}
+
+type vipCollector struct {
+	collector.DefaultCollector
+	crmMonParser   crmmon.Parser
+	cibParser      cib.Parser
+	nodeName       string
+	interfaceAddrs func() ([]net.Addr, error)
+}
+
+func (c *vipCollector) CollectWithError(ch chan<- prometheus.Metric) error {
+	level.Debug(c.Logger).Log("msg", "Collecting vip metrics...")
+
+	crmMon, err := c.crmMonParser.Parse()
+	if err != nil {
+		return errors.Wrap(err, "crm_mon parser error")
+	}
+
+	CIB, err := c.cibParser.Parse()
+	if err != nil {
+		return errors.Wrap(err, "cibadmin parser error")
+	}
+
+	addrs, err := c.interfaceAddrs()
+	if err != nil {
+		return errors.Wrap(err, "could not list the local interface addresses")
+	}
+
+	bound := make(map[string]bool)
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok {
+			bound[ipNet.IP.String()] = true
+		}
+	}
+
+	started := pacemaker.StartedResources(crmMon, c.nodeName)
+	for resource, ip := range pacemaker.VirtualIPs(CIB) {
+		parsed := net.ParseIP(ip)
+		if parsed == nil {
+			level.Warn(c.Logger).Log("msg", "Could not parse the ip of resource "+resource, "ip", ip)
+			continue
+		}
+
+		// the same address can be spelled in different ways, e.g. IPv6 ones, so they're compared in canonical form
+		var isBound float64
+		if bound[parsed.String()] {
+			isBound = 1
+		}
+		var isStarted float64
+		if started[resource] {
+			isStarted = 1
+		}
+
+		ch <- c.MakeGaugeMetric("bound", isBound, ip, c.nodeName)
+		ch <- c.MakeGaugeMetric("started", isStarted, ip, c.nodeName)
+	}
+
+	return nil
+}
+
+func (c *vipCollector) Collect(ch chan<- prometheus.Metric) {
+	level.Debug(c.Logger).Log("msg", "Collecting vip metrics...")
+
+	err := c.CollectWithError(ch)
+	if err != nil {
+		level.Warn(c.Logger).Log("msg", c.GetSubsystem()+" collector scrape failed", "err", err)
+	}
+}
@@ -0,0 +1,63 @@
+package vip
+
+import (
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+
+	assertcustom "github.com/ClusterLabs/ha_cluster_exporter/internal/assert"
+)
+
+func fakeInterfaceAddrs(cidrs ...string) func() ([]net.Addr, error) {
+	return func() ([]net.Addr, error) {
+		var addrs []net.Addr
+		for _, cidr := range cidrs {
+			ip, ipNet, _ := net.ParseCIDR(cidr)
+			ipNet.IP = ip
+			addrs = append(addrs, ipNet)
+		}
+		return addrs, nil
+	}
+}
+
+func TestNewVipCollector(t *testing.T) {
+	collector, err := NewCollector("../../test/fake_crm_mon.sh", "../../test/fake_cibadmin.sh", "../../test/fake_crm_node.sh", false, log.NewNopLogger())
+
+	assert.Nil(t, err)
+	assert.Equal(t, "node01", collector.nodeName)
+}
+
+func TestNewVipCollectorChecksCrmMonExistence(t *testing.T) {
+	_, err := NewCollector("../../test/nonexistent", "../../test/fake_cibadmin.sh", "../../test/fake_crm_node.sh", false, log.NewNopLogger())
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "'../../test/nonexistent' does not exist")
+}
+
+func TestVipCollector(t *testing.T) {
+	collector, err := NewCollector("../../test/fake_crm_mon.sh", "../../test/fake_cibadmin.sh", "../../test/fake_crm_node.sh", false, log.NewNopLogger())
+	assert.Nil(t, err)
+	collector.interfaceAddrs = fakeInterfaceAddrs("127.0.0.1/8", "192.168.123.200/24")
+
+	assertcustom.Metrics(t, collector, "vip.metrics")
+}
+
+func TestVipCollectorStartedButNotBound(t *testing.T) {
+	collector, err := NewCollector("../../test/fake_crm_mon.sh", "../../test/fake_cibadmin.sh", "../../test/fake_crm_node.sh", false, log.NewNopLogger())
+	assert.Nil(t, err)
+	collector.interfaceAddrs = fakeInterfaceAddrs("127.0.0.1/8", "192.168.123.10/24")
+
+	expect := `
+	# HELP ha_cluster_vip_bound Whether each virtual IP managed by the cluster is assigned to an interface of the local node; 1 means bound, 0 otherwise
+	# TYPE ha_cluster_vip_bound gauge
+	ha_cluster_vip_bound{ip="192.168.123.200",node="node01"} 0
+	# HELP ha_cluster_vip_started Whether the resource managing each virtual IP is started on the local node, as per the cluster status; 1 means started, 0 otherwise
+	# TYPE ha_cluster_vip_started gauge
+	ha_cluster_vip_started{ip="192.168.123.200",node="node01"} 1
+	`
+	assert.NoError(t, testutil.CollectAndCompare(collector, strings.NewReader(expect)))
+}
@@ -0,0 +1,108 @@
+package watchdog
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/ClusterLabs/ha_cluster_exporter/collector"
+)
+
+const subsystem = "watchdog"
+
+// NewCollector creates a new watchdog collector
+// the watchdog device is only checked for existence, because opening it could arm it or clash with SBD;
+// further details are read from the watchdog class in sysfs instead.
+func NewCollector(devicePath string, sysfsPath string, timestamps bool, logger log.Logger) (*watchdogCollector, error) {
+	err := checkArguments(sysfsPath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not initialize '%s' collector", subsystem)
+	}
+
+	c := &watchdogCollector{
+		collector.NewDefaultCollector(subsystem, timestamps, logger),
+		devicePath,
+		sysfsPath,
+	}
+
+	c.SetDescriptor("present", "Whether the watchdog device used for fencing is present; 1 means present, 0 otherwise", []string{"device"})
+	c.SetDescriptor("timeout_seconds", "The timeout of each watchdog device known to the kernel", []string{"device", "identity"})
+
+	return c, nil
+}
+
+func checkArguments(sysfsPath string) error {
+	if _, err := os.Stat(sysfsPath); os.IsNotExist(err) {
+		return errors.Errorf("'%s' does not exist", sysfsPath)
+	}
+	return nil
+}
+
+type watchdogCollector struct {
+	collector.DefaultCollector
+	devicePath string
+	sysfsPath  string
+}
+
+func (c *watchdogCollector) CollectWithError(ch chan<- prometheus.Metric) error {
+	level.Debug(c.Logger).Log("msg", "Collecting watchdog metrics...")
+
+	var present float64
+	if _, err := os.Stat(c.devicePath); err == nil {
+		present = 1
+	}
+	ch <- c.MakeGaugeMetric("present", present, c.devicePath)
+
+	watchdogs, err := filepath.Glob(filepath.Join(c.sysfsPath, "watchdog*"))
+	if err != nil {
+		return errors.Wrap(err, "could not list watchdog devices")
+	}
+
+	for _, watchdog := range watchdogs {
+		// a device whose identity can't be read is still reported, so that its timeout isn't lost
+		identity, err := readSysfsAttribute(watchdog, "identity")
+		if err != nil {
+			level.Warn(c.Logger).Log("msg", "Could not read watchdog identity", "device", watchdog, "err", err)
+		}
+
+		// not all the drivers expose the timeout
+		timeout, err := readSysfsAttribute(watchdog, "timeout")
+		if err != nil {
+			level.Debug(c.Logger).Log("msg", "Could not read watchdog timeout", "err", err)
+			continue
+		}
+
+		timeoutSeconds, err := strconv.ParseFloat(timeout, 64)
+		if err != nil {
+			return errors.Wrapf(err, "could not parse timeout of watchdog '%s'", watchdog)
+		}
+
+		ch <- c.MakeGaugeMetric("timeout_seconds", timeoutSeconds, "/dev/"+filepath.Base(watchdog), identity)
+	}
+
+	return nil
+}
+
+func (c *watchdogCollector) Collect(ch chan<- prometheus.Metric) {
+	level.Debug(c.Logger).Log("msg", "Collecting watchdog metrics...")
+
+	err := c.CollectWithError(ch)
+	if err != nil {
+		level.Warn(c.Logger).Log("msg", c.GetSubsystem()+" collector scrape failed", "err", err)
+	}
+}
+
+func readSysfsAttribute(watchdog string, attribute string) (string, error) {
+	content, err := ioutil.ReadFile(filepath.Join(watchdog, attribute))
+	if err != nil {
+		return "", errors.Wrapf(err, "could not read watchdog attribute '%s'", attribute)
+	}
+	return strings.TrimSpace(string(content)), nil
+}
@@ -0,0 +1,68 @@
+package watchdog
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+
+	assertcustom "github.com/ClusterLabs/ha_cluster_exporter/internal/assert"
+)
+
+func TestNewWatchdogCollector(t *testing.T) {
+	_, err := NewCollector("../../test/dummy", "../../test/fake_watchdog", false, log.NewNopLogger())
+
+	assert.Nil(t, err)
+}
+
+func TestNewWatchdogCollectorChecksSysfsExistence(t *testing.T) {
+	_, err := NewCollector("../../test/dummy", "../../test/nonexistent", false, log.NewNopLogger())
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "'../../test/nonexistent' does not exist")
+}
+
+func TestWatchdogCollector(t *testing.T) {
+	collector, err := NewCollector("../../test/dummy", "../../test/fake_watchdog", false, log.NewNopLogger())
+
+	assert.Nil(t, err)
+	assertcustom.Metrics(t, collector, "watchdog.metrics")
+}
+
+func TestWatchdogCollectorMissingDevice(t *testing.T) {
+	collector, _ := NewCollector("../../test/nonexistent", "../../test/fake_watchdog", false, log.NewNopLogger())
+
+	expect := `
+	# HELP ha_cluster_watchdog_present Whether the watchdog device used for fencing is present; 1 means present, 0 otherwise
+	# TYPE ha_cluster_watchdog_present gauge
+	ha_cluster_watchdog_present{device="../../test/nonexistent"} 0
+	`
+
+	err := testutil.CollectAndCompare(collector, strings.NewReader(expect), "ha_cluster_watchdog_present")
+
+	assert.NoError(t, err)
+}
+
+func TestWatchdogCollectorUnreadableIdentity(t *testing.T) {
+	sysfsPath := t.TempDir()
+	watchdog := filepath.Join(sysfsPath, "watchdog1")
+	assert.NoError(t, os.Mkdir(watchdog, 0755))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(watchdog, "timeout"), []byte("30\n"), 0644))
+
+	collector, _ := NewCollector("../../test/dummy", sysfsPath, false, log.NewNopLogger())
+
+	expect := `
+	# HELP ha_cluster_watchdog_timeout_seconds The timeout of each watchdog device known to the kernel
+	# TYPE ha_cluster_watchdog_timeout_seconds gauge
+	ha_cluster_watchdog_timeout_seconds{device="/dev/watchdog1",identity=""} 30
+	`
+
+	err := testutil.CollectAndCompare(collector, strings.NewReader(expect), "ha_cluster_watchdog_timeout_seconds")
+
+	assert.NoError(t, err)
+}
@@ -1,15 +1,27 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
 	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/common/expfmt"
 	"github.com/prometheus/common/promlog"
 	// cannot use as setConfigDefault function will not work here
 	// log.level and log.format flags are set in vars/init
@@ -23,41 +35,96 @@ import (
 	"gopkg.in/alecthomas/kingpin.v2"
 
 	"github.com/ClusterLabs/ha_cluster_exporter/collector"
+	"github.com/ClusterLabs/ha_cluster_exporter/collector/booth"
 	"github.com/ClusterLabs/ha_cluster_exporter/collector/corosync"
 	"github.com/ClusterLabs/ha_cluster_exporter/collector/drbd"
+	"github.com/ClusterLabs/ha_cluster_exporter/collector/lvmlockd"
 	"github.com/ClusterLabs/ha_cluster_exporter/collector/pacemaker"
 	"github.com/ClusterLabs/ha_cluster_exporter/collector/sbd"
+	"github.com/ClusterLabs/ha_cluster_exporter/collector/vip"
+	"github.com/ClusterLabs/ha_cluster_exporter/collector/watchdog"
 )
 
 const (
 	namespace = "ha_cluster_exporter"
+
+	// the first file descriptor passed by systemd socket activation, see sd_listen_fds(3)
+	systemdListenFdsStart = 3
 )
 
 var (
 	config *viper.Viper
 
+	// config flags
+	configFile  *string
+	configPaths *[]string
+	dumpFlags   *bool
+
 	// general flags
-	webListenAddress *string
-	webTelemetryPath *string
-	webConfig        *string
-	logLevel         *string
-	logFormat        *string
+	webListenAddress           *string
+	webTelemetryPath           *string
+	webConfig                  *string
+	webMaxRequests             *int
+	webReadTimeout             *time.Duration
+	webWriteTimeout            *time.Duration
+	webEnableDebug             *bool
+	webIgnoreDeprecatedAddress *bool
+	webMetricsEncoding         *string
+	webAccessLog               *bool
+	metricsCollectionTimestamp *bool
+	logLevel                   *string
+	logFormat                  *string
 
 	// collector flags
+	collectorMaxParallel             *int
+	collectorMaxSeries               *int
 	haClusterCrmMonPath              *string
 	haClusterCibadminPath            *string
+	haClusterCrmVerifyPath           *string
+	haClusterCrmVerifyInterval       *time.Duration
+	haClusterCrmSimulatePath         *string
+	haClusterCrmSimulateInterval     *time.Duration
+	haClusterCibSyncInterval         *time.Duration
+	haClusterCrmNodePath             *string
+	haClusterPacemakerLocalNodeOnly  *bool
+	haClusterPacemakerResourceLabel  *string
+	haClusterPacemakerFenceHistory   *bool
 	haClusterCorosyncCfgtoolpathPath *string
 	haClusterCorosyncQuorumtoolPath  *string
+	haClusterCorosyncCmapctlPath     *string
+	haClusterCorosyncConfigPath      *string
 	haClusterSbdPath                 *string
 	haClusterSbdConfigPath           *string
+	haClusterSystemctlPath           *string
+	haClusterServiceEnabled          *bool
 	haClusterDrbdsetupPath           *string
+	haClusterDrbdadmPath             *string
 	haClusterDrbdsplitbrainPath      *string
+	haClusterDrbdUuidCheck           *bool
+	haClusterWatchdogDevicePath      *string
+	haClusterWatchdogSysfsPath       *string
+	haClusterLvmlockdEnabled         *bool
+	haClusterVipEnabled              *bool
+	haClusterLvmlockctlPath          *string
+	haClusterBoothEnabled            *bool
+	haClusterBoothPath               *string
+
+	// remote flags
+	remoteHost    *string
+	remoteUser    *string
+	remoteKeyFile *string
+	remoteTimeout *time.Duration
+	remoteSshPath *string
 
 	// deprecated flags
 	enableTimestampsDeprecated *bool
 	portDeprecated             *int
 	addressDeprecated          *string
 	logLevelDeprecated         *string
+	deprecatedFlags            []string
+
+	// the parsed command line arguments, to tell the flags set there from those set in the config file or left to their default
+	parseContext *kingpin.ParseContext
 
 	promlogConfig = &promlog.Config{
 		Level:  &promlog.AllowedLevel{},
@@ -67,14 +134,25 @@ var (
 
 func init() {
 
-	config = viper.New()
-	config.SetConfigName("ha_cluster_exporter")
-	config.AddConfigPath("./")
-	config.AddConfigPath("$HOME/.config/")
-	config.AddConfigPath("/etc/")
-	config.AddConfigPath("/usr/etc/")
+	// the config file provides the flag defaults, so we need to know where it is before kingpin parses the flags
+	config = newConfig(configFlagsFromArgs(os.Args[1:]))
 	config.ReadInConfig()
 
+	// config flags; they are parsed in advance by configFlagsFromArgs, they are declared here for validation and help
+	configFile = kingpin.Flag(
+		"config.file",
+		"Path to the configuration file; if set, the configuration file is not searched for and it must exist.",
+	).PlaceHolder("/etc/" + namespace + ".yaml").String()
+	configPaths = kingpin.Flag(
+		"config.path",
+		"Additional directory to search the configuration file in, before the default ones; can be repeated.",
+	).PlaceHolder("/opt/etc/").Strings()
+	// not backed by the config file, since it makes the exporter exit right away
+	dumpFlags = kingpin.Flag(
+		"dump-flags",
+		"Print all the flags as JSON, with their type, default, help and deprecation status, then exit.",
+	).Bool()
+
 	// general flags
 	webListenAddress = kingpin.Flag(
 		"web.listen-address",
@@ -90,8 +168,50 @@ func init() {
 		"web.config.file",
 		"[EXPERIMENTAL] Path to configuration file that can enable TLS or authentication.",
 	).PlaceHolder("/etc/" + namespace + ".web.yaml").Default(setConfigDefault("web.config.file", "/etc/"+namespace+".web.yaml")).String()
+	webMaxRequests = kingpin.Flag(
+		"web.max-requests",
+		"Maximum number of parallel scrape requests; requests exceeding it are rejected with 503. Use 0 to disable.",
+	).PlaceHolder("40").Default(setConfigDefault("web.max-requests", "40")).Int()
+	webReadTimeout = kingpin.Flag(
+		"web.read-timeout",
+		"Maximum duration for reading an entire request. Use 0 to disable.",
+	).PlaceHolder("10s").Default(setConfigDefault("web.read-timeout", "10s")).Duration()
+	webWriteTimeout = kingpin.Flag(
+		"web.write-timeout",
+		"Maximum duration before timing out the writes of a response. Use 0 to disable.",
+	).PlaceHolder("60s").Default(setConfigDefault("web.write-timeout", "60s")).Duration()
+	webEnableDebug = kingpin.Flag(
+		"web.enable-debug-endpoints",
+		"Enable the /debug/ endpoints, e.g. /debug/raw/<collector> returning the raw output of the commands run by a collector.",
+	).PlaceHolder("false").Default(setConfigDefault("web.enable-debug-endpoints", "false")).Bool()
+	webIgnoreDeprecatedAddress = kingpin.Flag(
+		"web.ignore-deprecated-address",
+		"Ignore the deprecated address and port flags, so that web.listen-address is always used.",
+	).PlaceHolder("false").Default(setConfigDefault("web.ignore-deprecated-address", "false")).Bool()
+
+	webMetricsEncoding = kingpin.Flag(
+		"web.metrics-encoding",
+		"Encoding of the metrics endpoint responses. One of: [auto, text, protobuf]; auto negotiates it with each client via the Accept header.",
+	).PlaceHolder("auto").Default(setConfigDefault("web.metrics-encoding", "auto")).Enum("auto", "text", "protobuf")
+	webAccessLog = kingpin.Flag(
+		"web.access-log",
+		"Log the remote address, user agent and path of each request to the metrics endpoint.",
+	).PlaceHolder("false").Default(setConfigDefault("web.access-log", "false")).Bool()
+
+	metricsCollectionTimestamp = kingpin.Flag(
+		"metrics.collection-timestamp",
+		"Timestamp the metrics with the time their data was collected, which may be older than the scrape for the data collected in the background.",
+	).PlaceHolder("false").Default(setConfigDefault("metrics.collection-timestamp", "false")).Bool()
 
 	// collector flags
+	collectorMaxParallel = kingpin.Flag(
+		"collector.max-parallel",
+		"Maximum number of external commands run at the same time, by the collectors and in the background; the others wait up to 10s for their turn. 0 means as many as the enabled collectors.",
+	).PlaceHolder("0").Default(setConfigDefault("collector.max-parallel", "0")).Int()
+	collectorMaxSeries = kingpin.Flag(
+		"collector.max-series",
+		"Maximum number of series each collector can expose in a scrape; beyond it, none of them is exposed and the collector reports its cardinality as capped. 0 means no limit.",
+	).PlaceHolder("0").Default(setConfigDefault("collector.max-series", "0")).Int()
 	haClusterCrmMonPath = kingpin.Flag(
 		"crm-mon-path",
 		"path to crm_mon executable",
@@ -100,6 +220,42 @@ func init() {
 		"cibadmin-path",
 		"path to cibadmin executable",
 	).PlaceHolder("/usr/sbin/cibadmin").Default(setConfigDefault("cibadmin-path", "/usr/sbin/cibadmin")).String()
+	haClusterCrmVerifyPath = kingpin.Flag(
+		"crm-verify-path",
+		"path to crm_verify executable",
+	).PlaceHolder("/usr/sbin/crm_verify").Default(setConfigDefault("crm-verify-path", "/usr/sbin/crm_verify")).String()
+	haClusterCrmVerifyInterval = kingpin.Flag(
+		"crm-verify-interval",
+		"How often to check the cluster configuration with crm_verify, in the background; 0 disables the check.",
+	).PlaceHolder("0s").Default(setConfigDefault("crm-verify-interval", "0s")).Duration()
+	haClusterCrmSimulatePath = kingpin.Flag(
+		"crm-simulate-path",
+		"path to crm_simulate executable",
+	).PlaceHolder("/usr/sbin/crm_simulate").Default(setConfigDefault("crm-simulate-path", "/usr/sbin/crm_simulate")).String()
+	haClusterCrmSimulateInterval = kingpin.Flag(
+		"crm-simulate-interval",
+		"How often to compute the resource placement scores with crm_simulate, in the background; 0 disables the computation.",
+	).PlaceHolder("0s").Default(setConfigDefault("crm-simulate-interval", "0s")).Duration()
+	haClusterCibSyncInterval = kingpin.Flag(
+		"cib-sync-interval",
+		"How often to compare the copies of the CIB held by the cluster nodes with cibadmin, in the background; 0 disables the comparison.",
+	).PlaceHolder("1m").Default(setConfigDefault("cib-sync-interval", "1m")).Duration()
+	haClusterCrmNodePath = kingpin.Flag(
+		"crm-node-path",
+		"path to crm_node executable",
+	).PlaceHolder("/usr/sbin/crm_node").Default(setConfigDefault("crm-node-path", "/usr/sbin/crm_node")).String()
+	haClusterPacemakerLocalNodeOnly = kingpin.Flag(
+		"pacemaker.local-node-only",
+		"Only expose the Pacemaker metrics with a node label, like node statuses, resources and fail counts, for the node the exporter runs on.",
+	).PlaceHolder("false").Default(setConfigDefault("pacemaker.local-node-only", "false")).Bool()
+	haClusterPacemakerResourceLabel = kingpin.Flag(
+		"pacemaker.resource-label",
+		"Which labels identify the resources in the Pacemaker metrics: either 'id', or 'id-and-type' to also have a type label with the agent of each resource, e.g. IPaddr2.",
+	).PlaceHolder("id").Default(setConfigDefault("pacemaker.resource-label", "id")).Enum("id", "id-and-type")
+	haClusterPacemakerFenceHistory = kingpin.Flag(
+		"pacemaker.full-fence-history",
+		"Ask crm_mon for the successful fencing actions too, so that the age of the last one is exposed; it requires Pacemaker 2.0 or later.",
+	).PlaceHolder("false").Default(setConfigDefault("pacemaker.full-fence-history", "false")).Bool()
 	haClusterCorosyncCfgtoolpathPath = kingpin.Flag(
 		"corosync-cfgtoolpath-path",
 		"path to corosync-cfgtool executable",
@@ -108,6 +264,14 @@ func init() {
 		"corosync-quorumtool-path",
 		"path to corosync-quorumtool executable",
 	).PlaceHolder("/usr/sbin/corosync-quorumtool").Default(setConfigDefault("corosync-quorumtool-path", "/usr/sbin/corosync-quorumtool")).String()
+	haClusterCorosyncCmapctlPath = kingpin.Flag(
+		"corosync-cmapctl-path",
+		"path to corosync-cmapctl executable, used to detect the crypto settings",
+	).PlaceHolder("/usr/sbin/corosync-cmapctl").Default(setConfigDefault("corosync-cmapctl-path", "/usr/sbin/corosync-cmapctl")).String()
+	haClusterCorosyncConfigPath = kingpin.Flag(
+		"corosync-config-path",
+		"path to corosync configuration, compared with the running one to detect pending reloads",
+	).PlaceHolder("/etc/corosync/corosync.conf").Default(setConfigDefault("corosync-config-path", "/etc/corosync/corosync.conf")).String()
 	haClusterSbdPath = kingpin.Flag(
 		"sbd-path",
 		"path to sbd executable",
@@ -116,14 +280,81 @@ func init() {
 		"sbd-config-path",
 		"path to sbd configuration",
 	).PlaceHolder("/etc/sysconfig/sbd").Default(setConfigDefault("sbd-config-path", "/etc/sysconfig/sbd")).String()
+	haClusterSystemctlPath = kingpin.Flag(
+		"systemctl-path",
+		"path to systemctl executable, used to detect the sbd service timeouts and whether the cluster services are enabled",
+	).PlaceHolder("/usr/bin/systemctl").Default(setConfigDefault("systemctl-path", "/usr/bin/systemctl")).String()
+	haClusterServiceEnabled = kingpin.Flag(
+		"collector.service-enabled",
+		"Expose whether the pacemaker, pacemaker_remote, corosync and sbd services are enabled to start at boot, via systemctl.",
+	).PlaceHolder("false").Default(setConfigDefault("collector.service-enabled", "false")).Bool()
 	haClusterDrbdsetupPath = kingpin.Flag(
 		"drbdsetup-path",
 		"path to drbdsetup executable",
 	).PlaceHolder("/sbin/drbdsetup").Default(setConfigDefault("drbdsetup-path", "/sbin/drbdsetup")).String()
+	haClusterDrbdadmPath = kingpin.Flag(
+		"drbdadm-path",
+		"path to drbdadm executable, used to detect the replication protocols and the generation identifiers",
+	).PlaceHolder("/sbin/drbdadm").Default(setConfigDefault("drbdadm-path", "/sbin/drbdadm")).String()
 	haClusterDrbdsplitbrainPath = kingpin.Flag(
 		"drbdsplitbrain-path",
 		"path to drbd splitbrain hooks temporary files",
 	).PlaceHolder("/var/run/drbd/splitbrain").Default(setConfigDefault("drbdsplitbrain-path", "/var/run/drbd/splitbrain")).String()
+	haClusterDrbdUuidCheck = kingpin.Flag(
+		"drbd-uuid-check",
+		"Whether to compare the DRBD generation identifiers via drbdadm get-gi, which reads the metadata of each volume on each scrape",
+	).PlaceHolder("false").Default(setConfigDefault("drbd-uuid-check", "false")).Bool()
+	haClusterWatchdogDevicePath = kingpin.Flag(
+		"watchdog-device-path",
+		"path to the watchdog device used by sbd",
+	).PlaceHolder("/dev/watchdog").Default(setConfigDefault("watchdog-device-path", "/dev/watchdog")).String()
+	haClusterWatchdogSysfsPath = kingpin.Flag(
+		"watchdog-sysfs-path",
+		"path to the watchdog class in sysfs",
+	).PlaceHolder("/sys/class/watchdog").Default(setConfigDefault("watchdog-sysfs-path", "/sys/class/watchdog")).String()
+	haClusterLvmlockdEnabled = kingpin.Flag(
+		"collector.lvmlockd",
+		"Enable the lvmlockd collector, for clusters with shared volume groups.",
+	).PlaceHolder("false").Default(setConfigDefault("collector.lvmlockd", "false")).Bool()
+	haClusterLvmlockctlPath = kingpin.Flag(
+		"lvmlockctl-path",
+		"path to lvmlockctl executable",
+	).PlaceHolder("/usr/sbin/lvmlockctl").Default(setConfigDefault("lvmlockctl-path", "/usr/sbin/lvmlockctl")).String()
+	haClusterVipEnabled = kingpin.Flag(
+		"collector.vip",
+		"Enable the vip collector, which checks that the virtual IPs of the IPaddr2 resources are bound to the local interfaces.",
+	).PlaceHolder("false").Default(setConfigDefault("collector.vip", "false")).Bool()
+	haClusterBoothEnabled = kingpin.Flag(
+		"collector.booth",
+		"Enable the booth collector, for geo clusters and their arbitrators.",
+	).PlaceHolder("false").Default(setConfigDefault("collector.booth", "false")).Bool()
+	haClusterBoothPath = kingpin.Flag(
+		"booth-path",
+		"path to booth executable",
+	).PlaceHolder("/usr/sbin/booth").Default(setConfigDefault("booth-path", "/usr/sbin/booth")).String()
+
+	// remote flags
+	remoteHost = kingpin.Flag(
+		"remote.host",
+		"Host to run the collector commands on via SSH, instead of the local one; empty means local.",
+	).PlaceHolder("node01").Default(setConfigDefault("remote.host", "")).String()
+	remoteUser = kingpin.Flag(
+		"remote.user",
+		"User to log into the remote host as; empty means the SSH default.",
+	).PlaceHolder("hacluster").Default(setConfigDefault("remote.user", "")).String()
+	remoteKeyFile = kingpin.Flag(
+		"remote.key-file",
+		"Private key to authenticate on the remote host with; empty means the SSH default.",
+	).PlaceHolder("/etc/ha_cluster_exporter/id_ed25519").Default(setConfigDefault("remote.key-file", "")).String()
+	remoteTimeout = kingpin.Flag(
+		"remote.timeout",
+		"Maximum duration to wait for the remote host to accept the connection, and for each remote command to complete. Use 0 to disable.",
+	).PlaceHolder("10s").Default(setConfigDefault("remote.timeout", "10s")).Duration()
+	remoteSshPath = kingpin.Flag(
+		"remote.ssh-path",
+		"path to ssh executable",
+	).PlaceHolder("/usr/bin/ssh").Default(setConfigDefault("remote.ssh-path", "/usr/bin/ssh")).String()
+
 	enableTimestampsDeprecated = kingpin.Flag(
 		"enable-timestamps",
 		"[DEPRECATED] server-side metric timestamping is discouraged by Prometheus best-practices and should be avoided",
@@ -168,6 +399,23 @@ func init() {
 
 	kingpin.Parse()
 
+	// the flag values can't tell whether a deprecated flag was set to its default value, so we look at the parsed arguments instead
+	parseContext, err = kingpin.CommandLine.ParseContext(os.Args[1:])
+	if err != nil {
+		fmt.Printf("%s: error: %s, try --help\n", namespace, err)
+		os.Exit(1)
+	}
+	deprecatedFlags = deprecatedFlagsUsed(parseContext, config)
+
+	if *dumpFlags {
+		err = writeFlags(os.Stdout, kingpin.CommandLine.Model())
+		if err != nil {
+			fmt.Printf("%s: error: %s\n", namespace, err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
 	// use deprecated log-level parameter if set
 	if *logLevelDeprecated != "info" {
 		*logLevel = *logLevelDeprecated
@@ -183,6 +431,96 @@ func init() {
 		fmt.Printf("%s: error: %s, try --help\n", namespace, err)
 		os.Exit(1)
 	}
+
+	// otherwise, a wrong key file would only show up as every remote command failing to authenticate
+	if *remoteHost != "" {
+		err = checkRemoteKeyFile(*remoteKeyFile)
+		if err != nil {
+			fmt.Printf("%s: error: %s, check --remote.key-file\n", namespace, err)
+			os.Exit(1)
+		}
+	}
+}
+
+// checks that the private key to log into the remote host with, if any, is a file the exporter can read
+func checkRemoteKeyFile(keyFile string) error {
+	if keyFile == "" {
+		return nil
+	}
+	fileInfo, err := os.Stat(keyFile)
+	if err != nil {
+		return errors.Wrap(err, "could not find the remote key file")
+	}
+	if !fileInfo.Mode().IsRegular() {
+		return errors.Errorf("the remote key file '%s' is not a regular file", keyFile)
+	}
+	file, err := os.Open(keyFile)
+	if err != nil {
+		return errors.Wrap(err, "could not read the remote key file")
+	}
+	return file.Close()
+}
+
+// creates the gauge of the modification time of the given config file, with the value it has now
+func newConfigFileMtime(configFile string) (prometheus.Gauge, error) {
+	fileInfo, err := os.Stat(configFile)
+	if err != nil {
+		return nil, err
+	}
+	configFileMtime := prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace:   namespace,
+		Name:        "config_file_mtime_seconds",
+		Help:        "The modification time of the config file in use, as of when it was loaded",
+		ConstLabels: prometheus.Labels{"path": configFile},
+	})
+	configFileMtime.Set(float64(fileInfo.ModTime().Unix()))
+	return configFileMtime, nil
+}
+
+// scans the command line arguments for the config flags, so that the config file can be read before kingpin parses all the flags
+func configFlagsFromArgs(args []string) (configFile string, configPaths []string) {
+	for i := 0; i < len(args); i++ {
+		var name, value string
+		arg := strings.TrimPrefix(args[i], "--")
+		if arg == args[i] {
+			continue
+		}
+		if strings.Contains(arg, "=") {
+			parts := strings.SplitN(arg, "=", 2)
+			name, value = parts[0], parts[1]
+		} else if i+1 < len(args) && !strings.HasPrefix(args[i+1], "--") {
+			// a following flag means the value is missing, which kingpin reports when parsing
+			name, value = arg, args[i+1]
+		}
+
+		switch name {
+		case "config.file":
+			configFile = value
+		case "config.path":
+			configPaths = append(configPaths, value)
+		}
+	}
+	return configFile, configPaths
+}
+
+// creates the viper config; an explicit config file bypasses the search paths,
+// otherwise the extra configPaths are searched before the default ones
+func newConfig(configFile string, configPaths []string) *viper.Viper {
+	config := viper.New()
+	if configFile != "" {
+		config.SetConfigFile(configFile)
+		return config
+	}
+
+	config.SetConfigName("ha_cluster_exporter")
+	for _, configPath := range configPaths {
+		config.AddConfigPath(configPath)
+	}
+	config.AddConfigPath("./")
+	config.AddConfigPath("$HOME/.config/")
+	config.AddConfigPath("/etc/")
+	config.AddConfigPath("/usr/etc/")
+	return config
 }
 
 // looks up if a configName is define in viper config
@@ -197,10 +535,326 @@ func setConfigDefault(configName string, configDefault string) string {
 	return result
 }
 
+// a flag as printed by --dump-flags
+type flagDescription struct {
+	Name       string `json:"name"`
+	Type       string `json:"type"`
+	Default    string `json:"default"`
+	Help       string `json:"help"`
+	Deprecated bool   `json:"deprecated"`
+}
+
+// writes all the visible flags as a JSON array, for tools that validate or generate the exporter configuration;
+// the defaults include the values set in the config file, since the latter provides the flag defaults.
+func writeFlags(w io.Writer, model *kingpin.ApplicationModel) error {
+	flags := []flagDescription{}
+	for _, flag := range model.Flags {
+		if flag.Hidden {
+			continue
+		}
+		flags = append(flags, flagDescription{
+			Name:       flag.Name,
+			Type:       flagType(flag),
+			Default:    strings.Join(flag.Default, ","),
+			Help:       flag.Help,
+			Deprecated: strings.HasPrefix(flag.Help, "[DEPRECATED]"),
+		})
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(flags)
+}
+
+// derives the type of a flag from the kingpin value backing it, e.g. "duration" for *kingpin.durationValue;
+// repeatable flags are reported as "strings", since that is the only kind of them the exporter has.
+func flagType(flag *kingpin.FlagModel) string {
+	if cumulative, ok := flag.Value.(interface{ IsCumulative() bool }); ok && cumulative.IsCumulative() {
+		return "strings"
+	}
+	valueType := fmt.Sprintf("%T", flag.Value)
+	valueType = valueType[strings.LastIndex(valueType, ".")+1:]
+	return strings.TrimSuffix(valueType, "Value")
+}
+
+// resolves the web config file path to an absolute one.
+// relative paths set in the config file, and not overridden on the command line, are resolved against the config file directory instead of the CWD;
+// the exporter-toolkit will then in turn resolve any relative path in the web config against its absolute location.
+func resolveWebConfigPath(parseContext *kingpin.ParseContext, config *viper.Viper, webConfigPath string) (string, error) {
+	configFile := config.ConfigFileUsed()
+	if !filepath.IsAbs(webConfigPath) && configFile != "" && config.IsSet("web.config.file") && !flagsSetOnCommandLine(parseContext)["web.config.file"] {
+		webConfigPath = filepath.Join(filepath.Dir(configFile), webConfigPath)
+	}
+	return filepath.Abs(webConfigPath)
+}
+
+// validates a listen address in the host:port form, where host can be empty, an IP address or a hostname;
+// IPv6 addresses must be enclosed in square brackets, e.g. [::1]:9664.
+// malformed addresses are reported here, instead of later on as a confusing bind failure.
+func validateListenAddress(address string) (string, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return "", errors.Wrapf(err, "invalid listen address '%s', expected host:port, or [host]:port for IPv6", address)
+	}
+
+	if port == "" {
+		return "", errors.Errorf("invalid listen address '%s': missing port", address)
+	}
+	if _, err := net.LookupPort("tcp", port); err != nil {
+		return "", errors.Wrapf(err, "invalid listen address '%s'", address)
+	}
+
+	// the zone of link-local IPv6 addresses, e.g. fe80::1%eth0, is not part of the IP itself
+	ip := strings.SplitN(host, "%", 2)[0]
+	if host != "" && net.ParseIP(ip) == nil && !hostnameRegex.MatchString(host) {
+		return "", errors.Errorf("invalid listen address '%s': '%s' is neither an IP address nor a hostname", address, host)
+	}
+
+	return net.JoinHostPort(host, port), nil
+}
+
+var hostnameRegex = regexp.MustCompile(`^[a-zA-Z0-9_]([a-zA-Z0-9_.-]*[a-zA-Z0-9_])?$`)
+
+// builds the listen address from the deprecated address and port flags;
+// differently from web.listen-address, IPv6 addresses may be set with or without square brackets.
+func deprecatedListenAddress(address string, port int) (string, error) {
+	if strings.HasPrefix(address, "[") && strings.HasSuffix(address, "]") {
+		address = address[1 : len(address)-1]
+	}
+	return validateListenAddress(net.JoinHostPort(address, strconv.Itoa(port)))
+}
+
+// chooses the address to listen on: the deprecated address and port flags still take precedence over web.listen-address when set,
+// unless ignoreDeprecated is true; either way, a warning names both values, because a leftover deprecated setting silently winning is a nasty surprise.
+func listenAddress(webListenAddress string, address string, port int, ignoreDeprecated bool, logger log.Logger) (string, error) {
+	if address == "0.0.0.0" && port == 9664 {
+		return validateListenAddress(webListenAddress)
+	}
+
+	if ignoreDeprecated {
+		level.Warn(logger).Log("msg", "Ignoring the deprecated address and port flags, because web.ignore-deprecated-address is set",
+			"address", address, "port", port, "listen_address", webListenAddress)
+		return validateListenAddress(webListenAddress)
+	}
+
+	level.Warn(logger).Log("msg", "Using the deprecated address and port flags instead of web.listen-address; please migrate to the latter, or set web.ignore-deprecated-address",
+		"address", address, "port", port, "ignored_listen_address", webListenAddress)
+	return deprecatedListenAddress(address, port)
+}
+
+// returns the names of the deprecated flags in use, i.e. set either via CLI or config file, even if to their default value
+func deprecatedFlagsUsed(parseContext *kingpin.ParseContext, config *viper.Viper) []string {
+	setOnCommandLine := flagsSetOnCommandLine(parseContext)
+
+	var flags []string
+	for _, name := range []string{"address", "port", "log-level", "enable-timestamps"} {
+		if setOnCommandLine[name] || config.IsSet(name) {
+			flags = append(flags, name)
+		}
+	}
+	return flags
+}
+
+// returns the names of the flags set on the command line, whatever their value
+func flagsSetOnCommandLine(parseContext *kingpin.ParseContext) map[string]bool {
+	flags := map[string]bool{}
+	for _, element := range parseContext.Elements {
+		if flag, ok := element.Clause.(*kingpin.FlagClause); ok {
+			flags[flag.Model().Name] = true
+		}
+	}
+	return flags
+}
+
+// returns the paths of the executables each of the given collectors is configured to run, keyed by collector name
+func collectorExecutables(collectors []prometheus.Collector) map[string][]string {
+	paths := map[string][]string{
+		"pacemaker": {*haClusterCrmMonPath, *haClusterCibadminPath},
+		"corosync":  {*haClusterCorosyncCfgtoolpathPath, *haClusterCorosyncQuorumtoolPath, *haClusterCorosyncCmapctlPath},
+		"drbd":      {*haClusterDrbdsetupPath, *haClusterDrbdadmPath},
+		"sbd":       {*haClusterSbdPath, *haClusterSystemctlPath},
+		"lvmlockd":  {*haClusterLvmlockctlPath},
+		"vip":       {*haClusterCrmMonPath, *haClusterCibadminPath, *haClusterCrmNodePath},
+		"booth":     {*haClusterBoothPath},
+	}
+	// crm_verify and crm_simulate are only run if the configuration check and the placement scores are enabled
+	if *haClusterCrmVerifyInterval > 0 {
+		paths["pacemaker"] = append(paths["pacemaker"], *haClusterCrmVerifyPath)
+	}
+	if *haClusterCrmSimulateInterval > 0 {
+		paths["pacemaker"] = append(paths["pacemaker"], *haClusterCrmSimulatePath)
+	}
+	// so is systemctl by the pacemaker and corosync collectors if the service check is enabled
+	if systemctlPath := serviceSystemctlPath(); systemctlPath != "" {
+		paths["pacemaker"] = append(paths["pacemaker"], systemctlPath)
+		paths["corosync"] = append(paths["corosync"], systemctlPath)
+	}
+
+	executables := make(map[string][]string)
+	for _, c := range collectors {
+		if c, ok := c.(collector.SubsystemCollector); ok {
+			if subsystemPaths, ok := paths[c.GetSubsystem()]; ok {
+				executables[c.GetSubsystem()] = subsystemPaths
+			}
+		}
+	}
+	return executables
+}
+
+// returns the listener passed by systemd socket activation, or nil if the exporter was not socket activated;
+// when more than one socket is passed, only the first one is used.
+func systemdListener() (net.Listener, error) {
+	if os.Getenv("LISTEN_PID") != strconv.Itoa(os.Getpid()) {
+		return nil, nil
+	}
+
+	fds, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || fds < 1 {
+		return nil, errors.Errorf("invalid LISTEN_FDS value '%s'", os.Getenv("LISTEN_FDS"))
+	}
+
+	// the variables are meant for us only, so we don't pass them on to the commands run by the collectors
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+
+	syscall.CloseOnExec(systemdListenFdsStart)
+	file := os.NewFile(systemdListenFdsStart, "LISTEN_FD_"+strconv.Itoa(systemdListenFdsStart))
+	defer file.Close()
+
+	return net.FileListener(file)
+}
+
+// the Accept headers forcing each of the fixed metrics encodings
+var metricsEncodingAccept = map[string]string{
+	"text":     string(expfmt.FmtText),
+	"protobuf": string(expfmt.FmtProtoDelim),
+}
+
+// serves the metrics of the given gatherer; the encoding is negotiated with each client via the Accept header,
+// e.g. Prometheus asks for protobuf, unless a fixed one is set for clients that can't negotiate it.
+func metricsHandler(gatherer prometheus.Gatherer, encoding string) http.Handler {
+	handler := promhttp.HandlerFor(gatherer, promhttp.HandlerOpts{})
+	accept, fixed := metricsEncodingAccept[encoding]
+	if !fixed {
+		return handler
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r = r.Clone(r.Context())
+		r.Header.Set("Accept", accept)
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// limits the number of requests the given handler serves at the same time;
+// requests exceeding the limit are rejected straight away with 503 and counted, instead of piling up.
+func limitRequests(handler http.Handler, maxRequests int, rejected prometheus.Counter) http.Handler {
+	if maxRequests <= 0 {
+		return handler
+	}
+	inFlight := make(chan struct{}, maxRequests)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case inFlight <- struct{}{}:
+			defer func() { <-inFlight }()
+			handler.ServeHTTP(w, r)
+		default:
+			rejected.Inc()
+			http.Error(w, fmt.Sprintf("Limit of concurrent requests reached (%d), try again later.", maxRequests), http.StatusServiceUnavailable)
+		}
+	})
+}
+
+// logs each request to the given handler before serving it, including the rejected ones, to tell who is scraping the exporter.
+func logRequests(handler http.Handler, logger log.Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		level.Info(logger).Log("msg", "Serving a metrics request", "remote_addr", r.RemoteAddr, "user_agent", r.UserAgent(), "path", r.URL.Path)
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// serves the raw output of the external commands run by the collector named in the request path, e.g. /debug/raw/pacemaker;
+// the commands are killed when the timeout expires, if any, or when the client goes away.
+func debugRawHandler(collectors []prometheus.Collector, timeout time.Duration) http.Handler {
+	rawOutputCollectors := make(map[string]collector.RawOutputCollector)
+	for _, c := range collectors {
+		if c, ok := c.(collector.RawOutputCollector); ok {
+			rawOutputCollectors[c.GetSubsystem()] = c
+		}
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, ok := rawOutputCollectors[strings.TrimPrefix(r.URL.Path, "/debug/raw/")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+
+		ctx := r.Context()
+		if timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+
+		// we buffer the output so that we can still reply with an error status if anything fails halfway
+		var output bytes.Buffer
+		err := c.CollectRawOutput(ctx, &output)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		output.WriteTo(w)
+	})
+}
+
+// the node the per-node Pacemaker metrics are restricted to, if any, as named by crm_node on the host the commands run on
+func pacemakerLocalNode(logger log.Logger) string {
+	if !*haClusterPacemakerLocalNodeOnly {
+		return ""
+	}
+	nodeName, err := pacemaker.LocalNodeName(*haClusterCrmNodePath)
+	if err != nil {
+		level.Warn(logger).Log("msg", "Could not detect the local node, ignoring the pacemaker.local-node-only flag", "err", err)
+		return ""
+	}
+	return nodeName
+}
+
+// the systemctl executable the collectors check their services with, if enabled; empty otherwise
+func serviceSystemctlPath() string {
+	if !*haClusterServiceEnabled {
+		return ""
+	}
+	return *haClusterSystemctlPath
+}
+
+// checks whether the services of a collector are enabled, if enabled; nil otherwise
+func serviceChecker(logger log.Logger, services ...string) *collector.ServiceChecker {
+	if !*haClusterServiceEnabled {
+		return nil
+	}
+	return collector.NewServiceChecker(*haClusterSystemctlPath, logger, services...)
+}
+
 func registerCollectors(logger log.Logger) (collectors []prometheus.Collector, errors []error) {
 	pacemakerCollector, err := pacemaker.NewCollector(
 		*haClusterCrmMonPath,
 		*haClusterCibadminPath,
+		pacemaker.Options{
+			CrmVerifyPath:       *haClusterCrmVerifyPath,
+			CrmVerifyInterval:   *haClusterCrmVerifyInterval,
+			CrmSimulatePath:     *haClusterCrmSimulatePath,
+			CrmSimulateInterval: *haClusterCrmSimulateInterval,
+			CibSyncInterval:     *haClusterCibSyncInterval,
+			// the pacemaker_remote service is only installed on the remote nodes, where it replaces the whole cluster stack
+			Services:          serviceChecker(logger, "pacemaker", "pacemaker_remote"),
+			LocalNode:         pacemakerLocalNode(logger),
+			ResourceTypeLabel: *haClusterPacemakerResourceLabel == "id-and-type",
+			FullFenceHistory:  *haClusterPacemakerFenceHistory,
+		},
 		*enableTimestampsDeprecated,
 		logger,
 	)
@@ -213,6 +867,9 @@ func registerCollectors(logger log.Logger) (collectors []prometheus.Collector, e
 	corosyncCollector, err := corosync.NewCollector(
 		*haClusterCorosyncCfgtoolpathPath,
 		*haClusterCorosyncQuorumtoolPath,
+		*haClusterCorosyncCmapctlPath,
+		*haClusterCorosyncConfigPath,
+		serviceChecker(logger, "corosync"),
 		*enableTimestampsDeprecated,
 		logger,
 	)
@@ -222,21 +879,11 @@ func registerCollectors(logger log.Logger) (collectors []prometheus.Collector, e
 		collectors = append(collectors, corosyncCollector)
 	}
 
-	sbdCollector, err := sbd.NewCollector(
-		*haClusterSbdPath,
-		*haClusterSbdConfigPath,
-		*enableTimestampsDeprecated,
-		logger,
-	)
-	if err != nil {
-		errors = append(errors, err)
-	} else {
-		collectors = append(collectors, sbdCollector)
-	}
-
 	drbdCollector, err := drbd.NewCollector(
 		*haClusterDrbdsetupPath,
+		*haClusterDrbdadmPath,
 		*haClusterDrbdsplitbrainPath,
+		*haClusterDrbdUuidCheck,
 		*enableTimestampsDeprecated,
 		logger,
 	)
@@ -246,9 +893,97 @@ func registerCollectors(logger log.Logger) (collectors []prometheus.Collector, e
 		collectors = append(collectors, drbdCollector)
 	}
 
+	// these collectors also read local files and devices, so they can't inspect a remote host
+	if collector.IsRemote() {
+		level.Info(logger).Log("msg", "The sbd and watchdog collectors are not supported on remote hosts, skipping them")
+	} else {
+		sbdCollector, err := sbd.NewCollector(
+			*haClusterSbdPath,
+			*haClusterSbdConfigPath,
+			*haClusterSystemctlPath,
+			*haClusterWatchdogSysfsPath,
+			serviceChecker(logger, "sbd"),
+			*enableTimestampsDeprecated,
+			logger,
+		)
+		if err != nil {
+			errors = append(errors, err)
+		} else {
+			collectors = append(collectors, sbdCollector)
+		}
+
+		watchdogCollector, err := watchdog.NewCollector(
+			*haClusterWatchdogDevicePath,
+			*haClusterWatchdogSysfsPath,
+			*enableTimestampsDeprecated,
+			logger,
+		)
+		if err != nil {
+			errors = append(errors, err)
+		} else {
+			collectors = append(collectors, watchdogCollector)
+		}
+	}
+
+	if *haClusterLvmlockdEnabled {
+		lvmlockdCollector, err := lvmlockd.NewCollector(
+			*haClusterLvmlockctlPath,
+			*enableTimestampsDeprecated,
+			logger,
+		)
+		if err != nil {
+			errors = append(errors, err)
+		} else {
+			collectors = append(collectors, lvmlockdCollector)
+		}
+	}
+
+	if *haClusterVipEnabled {
+		// the virtual IPs are checked against the local interfaces, so the collector can't inspect a remote host
+		if collector.IsRemote() {
+			level.Info(logger).Log("msg", "The vip collector is not supported on remote hosts, skipping it")
+		} else {
+			vipCollector, err := vip.NewCollector(
+				*haClusterCrmMonPath,
+				*haClusterCibadminPath,
+				*haClusterCrmNodePath,
+				*enableTimestampsDeprecated,
+				logger,
+			)
+			if err != nil {
+				errors = append(errors, err)
+			} else {
+				collectors = append(collectors, vipCollector)
+			}
+		}
+	}
+
+	if *haClusterBoothEnabled {
+		boothCollector, err := booth.NewCollector(
+			*haClusterBoothPath,
+			*enableTimestampsDeprecated,
+			logger,
+		)
+		if err != nil {
+			errors = append(errors, err)
+		} else {
+			collectors = append(collectors, boothCollector)
+		}
+	}
+
+	// by default, each collector can run a command at the same time as the others
+	maxParallel := *collectorMaxParallel
+	if maxParallel <= 0 {
+		maxParallel = len(collectors)
+	}
+	collector.SetMaxParallelCommands(maxParallel)
+
 	for i, c := range collectors {
 		if c, ok := c.(collector.InstrumentableCollector); ok == true {
-			collectors[i] = collector.NewInstrumentedCollector(c, logger)
+			instrumentedCollector := collector.NewInstrumentedCollector(c, logger)
+			instrumentedCollector.CollectionTimestamps = *metricsCollectionTimestamp
+			instrumentedCollector.MaxSeries = *collectorMaxSeries
+			collectors[i] = instrumentedCollector
 		}
 	}
 
@@ -265,15 +1000,30 @@ func main() {
 	level.Info(logger).Log("msg", fmt.Sprintf("Starting %s %s", namespace, version.Info()))
 	level.Info(logger).Log("msg", fmt.Sprintf("Build context %s", version.BuildContext()))
 
-	// re-read only to display Info/Warn
+	// re-read to display Info/Warn, and to fail if the config file was explicitly set but can't be read
 	err = config.ReadInConfig()
-	if err != nil {
+	configLoaded := err == nil
+	if err != nil && *configFile != "" {
+		level.Error(logger).Log("msg", "Reading config file failed", "err", err)
+		os.Exit(1)
+	} else if err != nil {
 		level.Warn(logger).Log("msg", "Reading config file failed", "err", err)
 		level.Info(logger).Log("msg", "Default config values will be used")
 	} else {
 		level.Info(logger).Log("msg", "Using config file: "+config.ConfigFileUsed())
 	}
 
+	if *remoteHost != "" {
+		collector.SetRemoteHost(&collector.RemoteHost{
+			Host:    *remoteHost,
+			User:    *remoteUser,
+			KeyFile: *remoteKeyFile,
+			Timeout: *remoteTimeout,
+			SshPath: *remoteSshPath,
+		})
+		level.Info(logger).Log("msg", "Running the collector commands on "+*remoteHost+" via SSH")
+	}
+
 	// register collectors
 	collectors, errors := registerCollectors(logger)
 	for _, err = range errors {
@@ -295,15 +1045,10 @@ func main() {
 		prometheus.Unregister(prometheus.NewGoCollector())
 	}
 
-	var fullListenAddress string
-	// use deprecated parameters
-	if *addressDeprecated != "0.0.0.0" || *portDeprecated != 9664 {
-		fullListenAddress = fmt.Sprintf("%s:%d", *addressDeprecated, *portDeprecated)
-		// use new parameters
-	} else {
-		fullListenAddress = *webListenAddress
+	serveAddress := &http.Server{
+		ReadTimeout:  *webReadTimeout,
+		WriteTimeout: *webWriteTimeout,
 	}
-	serveAddress := &http.Server{Addr: fullListenAddress}
 	servePath := *webTelemetryPath
 
 	var landingPage = []byte(`<html>
@@ -324,19 +1069,97 @@ func main() {
 	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		w.Write(landingPage)
 	})
-	http.Handle(servePath, promhttp.Handler())
+
+	requestsRejected := prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "requests_rejected_total",
+		Help:      "Total number of scrape requests rejected because too many of them were being served at the same time",
+	})
+	prometheus.MustRegister(requestsRejected)
+	handler := promhttp.InstrumentMetricHandler(prometheus.DefaultRegisterer, metricsHandler(prometheus.DefaultGatherer, *webMetricsEncoding))
+	handler = limitRequests(handler, *webMaxRequests, requestsRejected)
+	if *webAccessLog {
+		handler = logRequests(handler, logger)
+	}
+	http.Handle(servePath, handler)
+
+	deprecatedFlagUsed := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "deprecated_flag_used",
+		Help:      "The deprecated flags in use, which will be removed in a future release; value is always 1",
+	}, []string{"flag"})
+	for _, flag := range deprecatedFlags {
+		level.Warn(logger).Log("msg", "The '"+flag+"' flag is deprecated and will be removed in a future release")
+		deprecatedFlagUsed.WithLabelValues(flag).Set(1)
+	}
+	prometheus.MustRegister(deprecatedFlagUsed)
+
+	executable := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "executable",
+		Help:      "The paths of the executables each collector is configured to run; value is always 1",
+	}, []string{"collector", "path"})
+	for name, paths := range collectorExecutables(collectors) {
+		for _, path := range paths {
+			executable.WithLabelValues(name, path).Set(1)
+		}
+	}
+	prometheus.MustRegister(executable)
+
+	// the config file is only read at startup, so the modification time it had then tells which version of it is in use
+	if configLoaded {
+		configFileMtime, err := newConfigFileMtime(config.ConfigFileUsed())
+		if err != nil {
+			level.Warn(logger).Log("msg", "Could not read the modification time of the config file", "err", err)
+		} else {
+			prometheus.MustRegister(configFileMtime)
+		}
+	}
+
+	// lets the background collection loops be monitored, since scrapes keep succeeding even if they die
+	prometheus.MustRegister(collector.NewHeartbeatCollector())
+
+	if *webEnableDebug {
+		http.Handle("/debug/raw/", debugRawHandler(collectors, *webWriteTimeout))
+		level.Warn(logger).Log("msg", "Debug endpoints are enabled; they expose raw cluster information, so make sure access to them is restricted")
+	}
+
+	listener, err := systemdListener()
+	if err != nil {
+		level.Error(logger).Log("msg", "Could not use the socket passed by systemd", "err", err)
+		os.Exit(1)
+	}
+	var fullListenAddress string
+	if listener != nil {
+		fullListenAddress = listener.Addr().String()
+		level.Info(logger).Log("msg", "Using the socket passed by systemd socket activation; the listen address flags are ignored")
+	} else {
+		fullListenAddress, err = listenAddress(*webListenAddress, *addressDeprecated, *portDeprecated, *webIgnoreDeprecatedAddress, logger)
+		if err != nil {
+			level.Error(logger).Log("msg", "Invalid listen address", "err", err)
+			os.Exit(1)
+		}
+		listener, err = net.Listen("tcp", fullListenAddress)
+		if err != nil {
+			level.Error(logger).Log("msg", "Error starting HTTP server", "err", err)
+			os.Exit(1)
+		}
+	}
 
 	level.Info(logger).Log("msg", "Serving metrics on "+fullListenAddress+servePath)
 
 	var listen error
-	_, err = os.Stat(*webConfig)
+	webConfigPath, err := resolveWebConfigPath(parseContext, config, *webConfig)
+	if err == nil {
+		_, err = os.Stat(webConfigPath)
+	}
 	if err != nil {
 		level.Warn(logger).Log("msg", "Reading web config file failed", "err", err)
 		level.Info(logger).Log("msg", "Default web config or commandline values will be used")
-		listen = web.ListenAndServe(serveAddress, "", logger)
+		listen = web.Serve(listener, serveAddress, "", logger)
 	} else {
-		level.Info(logger).Log("msg", "Using web config file: "+*webConfig)
-		listen = web.ListenAndServe(serveAddress, *webConfig, logger)
+		level.Info(logger).Log("msg", "Using web config file: "+webConfigPath)
+		listen = web.Serve(listener, serveAddress, webConfigPath, logger)
 	}
 
 	if err := listen; err != nil {
@@ -1,14 +1,18 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"reflect"
 	"runtime"
 	"strings"
@@ -18,9 +22,17 @@ import (
 
 	"github.com/go-kit/log"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/exporter-toolkit/web"
+	"github.com/spf13/viper"
 	// We could also mock this but test files alrady exist in test dir
 	//"github.com/spf13/afero"
 	"github.com/stretchr/testify/assert"
+	"gopkg.in/alecthomas/kingpin.v2"
+
+	"github.com/ClusterLabs/ha_cluster_exporter/collector"
+	"github.com/ClusterLabs/ha_cluster_exporter/collector/pacemaker"
+	"github.com/ClusterLabs/ha_cluster_exporter/collector/watchdog"
 )
 
 func TestRegisterCollectors(t *testing.T) {
@@ -39,15 +51,37 @@ func TestRegisterCollectors(t *testing.T) {
 	//afero.WriteFile(fs, "test/bin/drbdsplitbrain-path", []byte(""), 0755)
 	*haClusterCrmMonPath = "test/fake_crm_mon.sh"
 	*haClusterCibadminPath = "test/fake_cibadmin.sh"
+	*haClusterCrmNodePath = "test/fake_crm_node.sh"
 	*haClusterCorosyncCfgtoolpathPath = "test/fake_corosync-cfgtool.sh"
 	*haClusterCorosyncQuorumtoolPath = "test/fake_corosync-quorumtool.sh"
+	*haClusterCorosyncCmapctlPath = "test/fake_corosync-cmapctl.sh"
+	*haClusterCorosyncConfigPath = "test/fake_corosync.conf"
 	*haClusterSbdPath = "test/fake_sbd.sh"
 	*haClusterSbdConfigPath = "test/fake_sbdconfig"
+	*haClusterSystemctlPath = "test/fake_systemctl.sh"
 	*haClusterDrbdsetupPath = "test/fake_drbdsetup.sh"
+	*haClusterDrbdadmPath = "test/fake_drbdadm.sh"
 	*haClusterDrbdsplitbrainPath = "test/fake_drbdsplitbrain"
+	*haClusterWatchdogDevicePath = "test/dummy"
+	*haClusterWatchdogSysfsPath = "test/fake_watchdog"
+	*haClusterLvmlockctlPath = "test/fake_lvmlockctl.sh"
+	*haClusterBoothPath = "test/fake_booth.sh"
 
 	t.Run("success", func(t *testing.T) {
-		wantCollectors := 4
+		wantCollectors := 5
+		wantErrors := 0
+		prometheus.DefaultRegisterer = prometheus.NewRegistry()
+		prometheus.DefaultGatherer = prometheus.NewRegistry()
+		collectors, errors := registerCollectors(log.NewNopLogger())
+		assert.Len(t, collectors, wantCollectors)
+		assert.Len(t, errors, wantErrors)
+	})
+
+	*haClusterLvmlockdEnabled = true
+	*haClusterVipEnabled = true
+	*haClusterBoothEnabled = true
+	t.Run("optional collectors", func(t *testing.T) {
+		wantCollectors := 8
 		wantErrors := 0
 		prometheus.DefaultRegisterer = prometheus.NewRegistry()
 		prometheus.DefaultGatherer = prometheus.NewRegistry()
@@ -55,10 +89,13 @@ func TestRegisterCollectors(t *testing.T) {
 		assert.Len(t, collectors, wantCollectors)
 		assert.Len(t, errors, wantErrors)
 	})
+	*haClusterLvmlockdEnabled = false
+	*haClusterVipEnabled = false
+	*haClusterBoothEnabled = false
 
 	*haClusterCrmMonPath = "does_not_exist"
 	t.Run("1 failure", func(t *testing.T) {
-		wantCollectors := 3
+		wantCollectors := 4
 		wantErrors := 1
 		prometheus.DefaultRegisterer = prometheus.NewRegistry()
 		prometheus.DefaultGatherer = prometheus.NewRegistry()
@@ -69,7 +106,7 @@ func TestRegisterCollectors(t *testing.T) {
 
 	*haClusterCorosyncCfgtoolpathPath = "does_not_exist"
 	t.Run("2 failures", func(t *testing.T) {
-		wantCollectors := 2
+		wantCollectors := 3
 		wantErrors := 2
 		prometheus.DefaultRegisterer = prometheus.NewRegistry()
 		prometheus.DefaultGatherer = prometheus.NewRegistry()
@@ -80,7 +117,7 @@ func TestRegisterCollectors(t *testing.T) {
 
 	*haClusterSbdPath = "does_not_exist"
 	t.Run("3 failures", func(t *testing.T) {
-		wantCollectors := 1
+		wantCollectors := 2
 		wantErrors := 3
 		prometheus.DefaultRegisterer = prometheus.NewRegistry()
 		prometheus.DefaultGatherer = prometheus.NewRegistry()
@@ -91,7 +128,7 @@ func TestRegisterCollectors(t *testing.T) {
 
 	*haClusterDrbdsetupPath = "does_not_exist"
 	t.Run("4 failures", func(t *testing.T) {
-		wantCollectors := 0
+		wantCollectors := 1
 		wantErrors := 4
 		prometheus.DefaultRegisterer = prometheus.NewRegistry()
 		prometheus.DefaultGatherer = prometheus.NewRegistry()
@@ -99,9 +136,414 @@ func TestRegisterCollectors(t *testing.T) {
 		assert.Len(t, collectors, wantCollectors)
 		assert.Len(t, errors, wantErrors)
 	})
+
+	*haClusterWatchdogSysfsPath = "does_not_exist"
+	t.Run("5 failures", func(t *testing.T) {
+		wantCollectors := 0
+		wantErrors := 5
+		prometheus.DefaultRegisterer = prometheus.NewRegistry()
+		prometheus.DefaultGatherer = prometheus.NewRegistry()
+		collectors, errors := registerCollectors(log.NewNopLogger())
+		assert.Len(t, collectors, wantCollectors)
+		assert.Len(t, errors, wantErrors)
+	})
 	//fs.RemoveAll("test/bin")
 }
 
+func TestCollectorExecutables(t *testing.T) {
+	*haClusterCrmMonPath = "test/fake_crm_mon.sh"
+	*haClusterCibadminPath = "test/fake_cibadmin.sh"
+	*haClusterCrmVerifyPath = "test/fake_crm_verify.sh"
+	*haClusterCorosyncCfgtoolpathPath = "does_not_exist"
+	*haClusterWatchdogDevicePath = "test/dummy"
+	*haClusterWatchdogSysfsPath = "test/fake_watchdog"
+	prometheus.DefaultRegisterer = prometheus.NewRegistry()
+	collectors, _ := registerCollectors(log.NewNopLogger())
+
+	// the collectors that couldn't be registered, and the ones not running any executable, are left out
+	*haClusterCrmVerifyInterval = 0
+	assert.Equal(t, []string{"test/fake_crm_mon.sh", "test/fake_cibadmin.sh"}, collectorExecutables(collectors)["pacemaker"])
+	assert.NotContains(t, collectorExecutables(collectors), "corosync")
+	assert.NotContains(t, collectorExecutables(collectors), "watchdog")
+
+	*haClusterCrmVerifyInterval = time.Minute
+	defer func() { *haClusterCrmVerifyInterval = 0 }()
+	assert.Equal(t, []string{"test/fake_crm_mon.sh", "test/fake_cibadmin.sh", "test/fake_crm_verify.sh"}, collectorExecutables(collectors)["pacemaker"])
+}
+
+func TestConfigFlagsFromArgs(t *testing.T) {
+	configFile, configPaths := configFlagsFromArgs([]string{
+		"--log.level=debug",
+		"--config.file", "test/test_config.yaml",
+		"--config.path=/opt/etc/",
+		"--web.listen-address", ":9664",
+		"--config.path", "test/web",
+	})
+	assert.Equal(t, "test/test_config.yaml", configFile)
+	assert.Equal(t, []string{"/opt/etc/", "test/web"}, configPaths)
+
+	configFile, configPaths = configFlagsFromArgs([]string{"--log.level=debug", "--config.file"})
+	assert.Empty(t, configFile)
+	assert.Empty(t, configPaths)
+
+	configFile, configPaths = configFlagsFromArgs([]string{"--config.file", "--web.listen-address", ":9664", "--config.path", "--log.level=debug"})
+	assert.Empty(t, configFile)
+	assert.Empty(t, configPaths)
+}
+
+func TestNewConfig(t *testing.T) {
+	t.Run("explicit config file", func(t *testing.T) {
+		testConfig := newConfig("test/test_config.yaml", []string{"test/web"})
+		assert.NoError(t, testConfig.ReadInConfig())
+		assert.Equal(t, "test/test_config.yaml", testConfig.ConfigFileUsed())
+	})
+
+	t.Run("missing explicit config file", func(t *testing.T) {
+		testConfig := newConfig("test/nonexistent.yaml", nil)
+		assert.Error(t, testConfig.ReadInConfig())
+	})
+
+	t.Run("additional config path", func(t *testing.T) {
+		testConfig := newConfig("", []string{"test/web"})
+		assert.NoError(t, testConfig.ReadInConfig())
+		expected, _ := filepath.Abs("test/web/ha_cluster_exporter.yaml")
+		assert.Equal(t, expected, testConfig.ConfigFileUsed())
+	})
+}
+
+func TestWriteFlags(t *testing.T) {
+	app := kingpin.New("test", "")
+	app.Flag("web.read-timeout", "Maximum duration for reading an entire request.").Default("10s").Duration()
+	app.Flag("config.path", "Additional directory to search.").Strings()
+	app.Flag("port", "[DEPRECATED] please use --web.listen-address").Default("9664").Int()
+	app.Flag("secret", "Not shown.").Hidden().String()
+
+	var output bytes.Buffer
+	err := writeFlags(&output, app.Model())
+	assert.NoError(t, err)
+
+	var flags []flagDescription
+	assert.NoError(t, json.Unmarshal(output.Bytes(), &flags))
+	assert.Equal(t, []flagDescription{
+		{Name: "help", Type: "bool", Help: "Show context-sensitive help (also try --help-long and --help-man)."},
+		{Name: "web.read-timeout", Type: "duration", Default: "10s", Help: "Maximum duration for reading an entire request."},
+		{Name: "config.path", Type: "strings", Help: "Additional directory to search."},
+		{Name: "port", Type: "int", Default: "9664", Help: "[DEPRECATED] please use --web.listen-address", Deprecated: true},
+	}, flags)
+}
+
+func TestNewConfigFileMtime(t *testing.T) {
+	mtime := time.Date(2021, 10, 1, 12, 0, 0, 0, time.UTC)
+	configFile := filepath.Join(t.TempDir(), "ha_cluster_exporter.yaml")
+	assert.NoError(t, ioutil.WriteFile(configFile, []byte("log.level: debug\n"), 0644))
+	assert.NoError(t, os.Chtimes(configFile, mtime, mtime))
+
+	gauge, err := newConfigFileMtime(configFile)
+	assert.NoError(t, err)
+	assert.Equal(t, float64(mtime.Unix()), testutil.ToFloat64(gauge))
+
+	_, err = newConfigFileMtime("test/nonexistent.yaml")
+	assert.Error(t, err)
+}
+
+func TestResolveWebConfigPath(t *testing.T) {
+	testConfig := viper.New()
+	testConfig.SetConfigFile("test/web/ha_cluster_exporter.yaml")
+	err := testConfig.ReadInConfig()
+	assert.NoError(t, err)
+
+	app := kingpin.New("test", "")
+	app.Flag("web.config.file", "").Default("").String()
+	parse := func(args ...string) *kingpin.ParseContext {
+		parseContext, err := app.ParseContext(args)
+		assert.NoError(t, err)
+		return parseContext
+	}
+
+	expected, _ := filepath.Abs("test/web/web.yaml")
+
+	t.Run("relative to config file", func(t *testing.T) {
+		path, err := resolveWebConfigPath(parse(), testConfig, "web.yaml")
+		assert.NoError(t, err)
+		assert.Equal(t, expected, path)
+		// cert_file and key_file in the web config are relative too
+		assert.NoError(t, web.Validate(path))
+	})
+
+	t.Run("relative to CWD when set via CLI", func(t *testing.T) {
+		path, err := resolveWebConfigPath(parse("--web.config.file", "test/web/web.yaml"), testConfig, "test/web/web.yaml")
+		assert.NoError(t, err)
+		assert.Equal(t, expected, path)
+		assert.NoError(t, web.Validate(path))
+	})
+
+	t.Run("relative to CWD when set via CLI to the same value as the config file", func(t *testing.T) {
+		path, err := resolveWebConfigPath(parse("--web.config.file", "web.yaml"), testConfig, "web.yaml")
+		assert.NoError(t, err)
+		cwdPath, _ := filepath.Abs("web.yaml")
+		assert.Equal(t, cwdPath, path)
+	})
+
+	t.Run("absolute", func(t *testing.T) {
+		path, err := resolveWebConfigPath(parse(), testConfig, expected)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, path)
+	})
+}
+
+func TestValidateListenAddress(t *testing.T) {
+	for _, address := range []string{
+		":9664",
+		"0.0.0.0:9664",
+		"192.168.1.10:9664",
+		"[::]:9664",
+		"[::1]:9664",
+		"[fe80::1%eth0]:9664",
+		"localhost:9664",
+		"node01.example.com:9664",
+	} {
+		t.Run(address, func(t *testing.T) {
+			result, err := validateListenAddress(address)
+			assert.NoError(t, err)
+			assert.Equal(t, address, result)
+		})
+	}
+
+	for _, address := range []string{
+		"::1:9664",
+		"192.168.1.10",
+		"[::1]",
+		"localhost:",
+		"localhost:99999",
+		"node 01:9664",
+	} {
+		t.Run(address, func(t *testing.T) {
+			_, err := validateListenAddress(address)
+			assert.Error(t, err)
+			assert.Contains(t, err.Error(), "invalid listen address '"+address+"'")
+		})
+	}
+}
+
+func TestDeprecatedListenAddress(t *testing.T) {
+	testCases := []struct {
+		address  string
+		port     int
+		expected string
+	}{
+		{"0.0.0.0", 9100, "0.0.0.0:9100"},
+		{"localhost", 9100, "localhost:9100"},
+		{"::1", 9100, "[::1]:9100"},
+		{"[::1]", 9100, "[::1]:9100"},
+		{"::", 9664, "[::]:9664"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.address, func(t *testing.T) {
+			result, err := deprecatedListenAddress(tc.address, tc.port)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, result)
+		})
+	}
+
+	_, err := deprecatedListenAddress("::1", 70000)
+	assert.Error(t, err)
+}
+
+func TestListenAddress(t *testing.T) {
+	t.Run("deprecated flags not set", func(t *testing.T) {
+		var logs strings.Builder
+		address, err := listenAddress("[::1]:9100", "0.0.0.0", 9664, false, log.NewLogfmtLogger(&logs))
+		assert.NoError(t, err)
+		assert.Equal(t, "[::1]:9100", address)
+		assert.Empty(t, logs.String())
+	})
+
+	t.Run("deprecated flags set", func(t *testing.T) {
+		var logs strings.Builder
+		address, err := listenAddress("[::1]:9100", "0.0.0.0", 9200, false, log.NewLogfmtLogger(&logs))
+		assert.NoError(t, err)
+		assert.Equal(t, "0.0.0.0:9200", address)
+		assert.Contains(t, logs.String(), "level=warn")
+		assert.Contains(t, logs.String(), "port=9200")
+		assert.Contains(t, logs.String(), "ignored_listen_address=[::1]:9100")
+	})
+
+	t.Run("deprecated flags ignored", func(t *testing.T) {
+		var logs strings.Builder
+		address, err := listenAddress("[::1]:9100", "0.0.0.0", 9200, true, log.NewLogfmtLogger(&logs))
+		assert.NoError(t, err)
+		assert.Equal(t, "[::1]:9100", address)
+		assert.Contains(t, logs.String(), "level=warn")
+		assert.Contains(t, logs.String(), "port=9200")
+		assert.Contains(t, logs.String(), "listen_address=[::1]:9100")
+	})
+}
+
+func TestCheckRemoteKeyFile(t *testing.T) {
+	assert.NoError(t, checkRemoteKeyFile(""))
+	assert.NoError(t, checkRemoteKeyFile("test/web/tls.key"))
+
+	err := checkRemoteKeyFile("test/nonexistent")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "could not find the remote key file")
+
+	err = checkRemoteKeyFile("test/web")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "is not a regular file")
+}
+
+func TestDeprecatedFlagsUsed(t *testing.T) {
+	app := kingpin.New("test", "")
+	app.Flag("address", "").Default("0.0.0.0").String()
+	app.Flag("port", "").Default("9664").Int()
+	app.Flag("log-level", "").Default("info").String()
+	app.Flag("enable-timestamps", "").Default("false").Bool()
+	app.Flag("web.listen-address", "").Default(":9664").String()
+	parse := func(args ...string) *kingpin.ParseContext {
+		parseContext, err := app.ParseContext(args)
+		assert.NoError(t, err)
+		return parseContext
+	}
+
+	assert.Empty(t, deprecatedFlagsUsed(parse(), viper.New()))
+	assert.Empty(t, deprecatedFlagsUsed(parse("--web.listen-address", ":9100"), viper.New()))
+
+	// a deprecated flag set to its default value is still in use
+	assert.Equal(t, []string{"port", "enable-timestamps"}, deprecatedFlagsUsed(parse("--port=9664", "--no-enable-timestamps"), viper.New()))
+
+	testConfig := viper.New()
+	testConfig.Set("log-level", "info")
+	assert.Equal(t, []string{"address", "log-level"}, deprecatedFlagsUsed(parse("--address", "::1"), testConfig))
+}
+
+func TestLimitRequests(t *testing.T) {
+	rejected := prometheus.NewCounter(prometheus.CounterOpts{Name: "test_rejected_total"})
+	started := make(chan struct{})
+	release := make(chan struct{})
+	handler := limitRequests(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+	}), 1, rejected)
+
+	// keep the only allowed request busy
+	done := make(chan struct{})
+	go func() {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/metrics", nil))
+		close(done)
+	}()
+	<-started
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, 1.0, testutil.ToFloat64(rejected))
+
+	close(release)
+	<-done
+
+	// once the busy request is done, new ones are served again
+	go func() { <-started }()
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, 1.0, testutil.ToFloat64(rejected))
+}
+
+func TestMetricsHandler(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(prometheus.NewGauge(prometheus.GaugeOpts{Name: "test_gauge"}))
+
+	protobuf := "application/vnd.google.protobuf; proto=io.prometheus.client.MetricFamily; encoding=delimited"
+	testCases := []struct {
+		name     string
+		encoding string
+		accept   string
+		expected string
+	}{
+		{"negotiated protobuf", "auto", protobuf, "application/vnd.google.protobuf"},
+		{"negotiated text", "auto", "", "text/plain"},
+		{"fixed text", "text", protobuf, "text/plain"},
+		{"fixed protobuf", "protobuf", "text/plain", "application/vnd.google.protobuf"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/metrics", nil)
+			if tc.accept != "" {
+				req.Header.Set("Accept", tc.accept)
+			}
+			rec := httptest.NewRecorder()
+			metricsHandler(registry, tc.encoding).ServeHTTP(rec, req)
+
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Contains(t, rec.Header().Get("Content-Type"), tc.expected)
+			if tc.expected == "text/plain" {
+				assert.Contains(t, rec.Body.String(), "test_gauge 0")
+			}
+		})
+	}
+}
+
+func TestLimitRequestsDisabled(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	rejected := prometheus.NewCounter(prometheus.CounterOpts{Name: "test_rejected_total"})
+
+	assert.Equal(t, reflect.ValueOf(handler).Pointer(), reflect.ValueOf(limitRequests(handler, 0, rejected)).Pointer())
+}
+
+func TestLogRequests(t *testing.T) {
+	var logs strings.Builder
+	served := false
+	handler := logRequests(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		served = true
+	}), log.NewLogfmtLogger(&logs))
+
+	req := httptest.NewRequest("GET", "/metrics", nil)
+	req.RemoteAddr = "192.168.1.10:54321"
+	req.Header.Set("User-Agent", "Prometheus/2.45.0")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.True(t, served)
+	assert.Contains(t, logs.String(), "level=info")
+	assert.Contains(t, logs.String(), "remote_addr=192.168.1.10:54321")
+	assert.Contains(t, logs.String(), "user_agent=Prometheus/2.45.0")
+	assert.Contains(t, logs.String(), "path=/metrics")
+}
+
+func TestDebugRawHandler(t *testing.T) {
+	pacemakerCollector, err := pacemaker.NewCollector("test/fake_crm_mon.sh", "test/fake_cibadmin.sh", pacemaker.Options{}, false, log.NewNopLogger())
+	assert.NoError(t, err)
+	watchdogCollector, err := watchdog.NewCollector("test/dummy", "test/fake_watchdog", false, log.NewNopLogger())
+	assert.NoError(t, err)
+
+	handler := debugRawHandler([]prometheus.Collector{
+		collector.NewInstrumentedCollector(pacemakerCollector, log.NewNopLogger()),
+		collector.NewInstrumentedCollector(watchdogCollector, log.NewNopLogger()),
+	}, time.Second)
+
+	t.Run("collector running commands", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", "/debug/raw/pacemaker", nil))
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), "### test/fake_crm_mon.sh -X --inactive\n")
+		assert.Contains(t, rec.Body.String(), "### test/fake_cibadmin.sh --query --local\n")
+	})
+
+	t.Run("collector not running commands", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", "/debug/raw/watchdog", nil))
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+		assert.Contains(t, rec.Body.String(), "'watchdog' collector doesn't run any external command")
+	})
+
+	t.Run("unknown collector", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", "/debug/raw/foo", nil))
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+}
+
 //// Kudos for the build/run tests to https://github.com/prometheus/mysqld_exporter
 // TestBin builds, runs and tests binary.
 
@@ -154,6 +596,7 @@ func TestBin(t *testing.T) {
 
 	tests := []func(*testing.T, bin){
 		testLandingPage,
+		testSystemdSocketActivation,
 	}
 
 	portStart := 56000
@@ -221,6 +664,46 @@ func testLandingPage(t *testing.T, data bin) {
 	}
 }
 
+func testSystemdSocketActivation(t *testing.T, data bin) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	listener, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", data.port))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	socket, err := listener.(*net.TCPListener).File()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer socket.Close()
+
+	// Run exporter through a shell, so that LISTEN_PID can be set to the PID of the exporter itself, as systemd does.
+	// The listen address is invalid, so the exporter would fail if it tried to bind it on its own.
+	cmd := exec.CommandContext(
+		ctx,
+		"sh", "-c", `LISTEN_PID=$$ LISTEN_FDS=1 exec "$@"`, "sh",
+		data.path,
+		"--web.listen-address", "invalid:address",
+		"--crm-mon-path=test/fake_crm_mon.sh", // needed to register at least one collector
+		"--cibadmin-path=test/fake_cibadmin.sh",
+	)
+	// the first extra file is passed as file descriptor 3
+	cmd.ExtraFiles = []*os.File{socket}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer cmd.Wait()
+	defer cmd.Process.Kill()
+
+	body, err := waitForBody(fmt.Sprintf("http://127.0.0.1:%d", data.port))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Contains(t, string(body), "ClusterLabs Linux HA Cluster Exporter")
+}
+
 // waitForBody is a helper function which makes http calls until http server is up
 // and then returns body of the successful call.
 func waitForBody(urlToGet string) (body []byte, err error) {
@@ -0,0 +1,29 @@
+#!/usr/bin/env bash
+
+# like booth, prints the times in the local time zone, as per TZ
+t() {
+	date -d "@$1" '+%Y-%m-%d %H:%M:%S'
+}
+
+case "$1" in
+status)
+	echo 'booth_state="started" booth_type="site" booth_addr_string="192.168.1.10" booth_port="9929"'
+	;;
+list)
+	cat <<END
+ticket: ticket-nfs, leader: 192.168.1.10, expires: $(t 1614598420)
+ticket: ticket-db, leader: 192.168.2.10, expires: $(t 1614598445), commit: $(t 1614597785)
+ticket: ticket-web, leader: NONE
+END
+	;;
+peers)
+	cat <<END
+site       192.168.2.10, last recv: $(t 1614598392)
+	Sent pkts:121 error:0 resends:0 (0%)
+	Recv pkts:121 error:0 authfail:0 invalid:0
+arbitrator 192.168.3.10, last recv: $(t 1614598395)
+	Sent pkts:98 error:0 resends:0 (0%)
+	Recv pkts:98 error:0 authfail:0 invalid:0
+END
+	;;
+esac
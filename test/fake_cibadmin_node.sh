@@ -0,0 +1,15 @@
+#!/usr/bin/env bash
+
+# the version of the CIB copy of a single node, as queried with --node; node02 lags one configuration change behind, node03 is unreachable
+epoch=6881
+case "$*" in
+*"--node node02"*)
+  epoch=6880
+  ;;
+*"--node node03"*)
+  echo "Could not connect to the CIB: Transport endpoint is not connected" >&2
+  exit 107
+  ;;
+esac
+
+echo "<cib crm_feature_set=\"3.1.0\" validate-with=\"pacemaker-3.0\" epoch=\"$epoch\" num_updates=\"0\" admin_epoch=\"0\" cib-last-written=\"Mon Nov 18 17:48:21 2019\" update-origin=\"node01\" update-client=\"crm_attribute\" update-user=\"root\" have-quorum=\"1\" dc-uuid=\"1084783375\"/>"
@@ -0,0 +1,61 @@
+#!/usr/bin/env bash
+
+if [ "$1" == "-m" ]; then
+cat <<END
+stats.knet.node1084783375.link0.connected (u8) = 1
+stats.knet.node1084783375.link0.rx_total_packets (u64) = 0
+stats.knet.node1084783375.link0.tx_total_errors (u32) = 0
+stats.knet.node1084783375.link0.tx_total_packets (u64) = 0
+stats.knet.node1084783376.link0.connected (u8) = 1
+stats.knet.node1084783376.link0.rx_total_packets (u64) = 18346
+stats.knet.node1084783376.link0.tx_total_errors (u32) = 3
+stats.knet.node1084783376.link0.tx_total_packets (u64) = 18412
+stats.knet.node1084783376.link1.connected (u8) = 1
+stats.knet.node1084783376.link1.rx_total_packets (u64) = 9120
+stats.knet.node1084783376.link1.tx_total_errors (u32) = 0
+stats.knet.node1084783376.link1.tx_total_packets (u64) = 9133
+stats.srp.consensus_timeouts (u64) = 0
+stats.srp.mcast_retx (u64) = 12
+stats.srp.operational_token_lost (u64) = 2
+END
+exit 0
+fi
+
+cat <<END
+totem.cluster_name (str) = hana_cluster
+totem.crypto_cipher (str) = aes256
+totem.crypto_hash (str) = sha256
+totem.token (u32) = 5000
+totem.transport (str) = udpu
+totem.version (u32) = 2
+nodelist.node.0.nodeid (u32) = 1084783375
+nodelist.node.0.ring0_addr (str) = 10.0.0.1
+nodelist.node.1.nodeid (u32) = 1084783376
+nodelist.node.1.ring0_addr (str) = 10.0.0.2
+quorum.expected_votes (u32) = 2
+quorum.provider (str) = corosync_votequorum
+quorum.two_node (u8) = 1
+runtime.members.1084783375.config_version (u64) = 0
+runtime.members.1084783375.ip (str) = r(0) ip(10.0.0.1)
+runtime.members.1084783375.join_count (u32) = 1
+runtime.members.1084783375.status (str) = joined
+runtime.members.1084783376.config_version (u64) = 0
+runtime.members.1084783376.ip (str) = r(0) ip(10.0.0.2)
+runtime.members.1084783376.join_count (u32) = 2
+runtime.members.1084783376.status (str) = joined
+runtime.totem.pg.mrp.srp.consensus_timeouts (u64) = 0
+runtime.totem.pg.mrp.srp.mcast_retx (u64) = 12
+runtime.totem.pg.mrp.srp.mcast_rx (u64) = 1872
+runtime.totem.pg.mrp.srp.mcast_tx (u64) = 1523
+runtime.totem.pg.mrp.srp.operational_entered (u64) = 3
+runtime.totem.pg.mrp.srp.operational_token_lost (u64) = 2
+runtime.totem.pg.mrp.srp.orf_token_rx (u64) = 284637
+runtime.totem.pg.mrp.srp.orf_token_tx (u64) = 2
+runtime.votequorum.expected_votes (u32) = 2
+runtime.votequorum.highest_expected (u32) = 2
+runtime.votequorum.quorate (u8) = 1
+runtime.votequorum.quorum (u32) = 1
+runtime.votequorum.this_node_id (u32) = 1084783375
+runtime.votequorum.total_votes (u32) = 2
+runtime.votequorum.two_node (u8) = 1
+END
@@ -1,5 +1,11 @@
 #!/usr/bin/env bash
 
+if [[ "$1" == "-V" ]]; then
+    echo "Corosync Cluster Engine, version '2.4.5'"
+    echo "Copyright (c) 2006-2009 Red Hat, Inc."
+    exit 0
+fi
+
 cat <<EOF
 Quorum information
 ------------------
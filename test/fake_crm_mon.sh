@@ -10,7 +10,7 @@ cat <<EOF
         <last_change time="Fri Oct 18 11:48:22 2019" user="root" client="crm_attribute" origin="node01" />
         <nodes_configured number="2" />
         <resources_configured number="8" disabled="1" blocked="0" />
-        <cluster_options stonith-enabled="true" symmetric-cluster="true" no-quorum-policy="stop" maintenance-mode="false" />
+        <cluster_options stonith-enabled="true" have-watchdog="true" symmetric-cluster="true" no-quorum-policy="stop" maintenance-mode="false" />
     </summary>
     <nodes>
         <node name="node01" id="1084783375" online="true" standby="false" standby_onfail="false" maintenance="false" pending="false" unclean="false" shutdown="false" expected_up="true" is_dc="true" resources_running="7" type="member" />
@@ -47,7 +47,7 @@ cat <<EOF
             <resource id="clusterfs" resource_agent="ocf::heartbeat:Filesystem" role="Started" active="true" orphaned="false" blocked="false" managed="true" failed="false" failure_ignored="false" nodes_running_on="1">
                 <node name="node01" id="1084783225" cached="true"/>
             </resource>
-            <resource id="clusterfs" resource_agent="ocf::heartbeat:Filesystem" role="Started" active="true" orphaned="false" blocked="false" managed="true" failed="false" failure_ignored="false" nodes_running_on="1">
+            <resource id="clusterfs" resource_agent="ocf::heartbeat:Filesystem" role="Started" active="true" orphaned="false" blocked="true" managed="true" failed="false" failure_ignored="false" nodes_running_on="1">
                 <node name="node02" id="1084783226" cached="true"/>
             </resource>
             <resource id="clusterfs" resource_agent="ocf::heartbeat:Filesystem" role="Stopped" active="false" orphaned="false" blocked="false" managed="true" failed="false" failure_ignored="false" nodes_running_on="0"/>
@@ -113,7 +113,7 @@ cat <<EOF
             </resource_history>
             <resource_history id="rsc_ip_PRD_HDB00" orphan="false" migration-threshold="5000" fail-count="2" last-failure="Wed Oct 23 12:37:22 2019">
                 <operation_history call="21" task="start" last-rc-change="Thu Oct 10 12:57:33 2019" last-run="Thu Oct 10 12:57:33 2019" exec-time="130ms" queue-time="0ms" rc="0" rc_text="ok" />
-                <operation_history call="22" task="monitor" interval="10000ms" last-rc-change="Thu Oct 10 12:57:33 2019" exec-time="78ms" queue-time="0ms" rc="0" rc_text="ok" />
+                <operation_history call="22" task="monitor" interval="10000ms" last-rc-change="Thu Oct 10 12:57:33 2019" last-run="Thu Oct 10 12:57:32 2019" exec-time="78ms" queue-time="0ms" rc="0" rc_text="ok" />
             </resource_history>
             <resource_history id="stonith-sbd" orphan="false" migration-threshold="5000">
                 <operation_history call="6" task="start" last-rc-change="Thu Oct 10 12:57:31 2019" last-run="Thu Oct 10 12:57:31 2019" exec-time="2201ms" queue-time="0ms" rc="0" rc_text="ok" />
@@ -140,6 +140,10 @@ cat <<EOF
             </resource_history>
         </node>
     </node_history>
+    <fence_history>
+        <fence_event action="reboot" target="node02" client="pacemaker-controld.2188" origin="node01" status="pending" delegate="node01"/>
+        <fence_event action="off" target="node03" client="stonith_admin.4032" origin="node01" status="failed" delegate="node01" completed="2019-10-18 11:02:42Z"/>
+    </fence_history>
     <tickets>
     </tickets>
     <bans>
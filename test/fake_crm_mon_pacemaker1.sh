@@ -0,0 +1,11 @@
+#!/usr/bin/env bash
+
+# crm_mon before Pacemaker 2.0 doesn't know the fence history option
+for arg in "$@"; do
+  if [[ "$arg" == --fence-history* ]]; then
+    echo "crm_mon: unrecognized option '$arg'" >&2
+    exit 64
+  fi
+done
+
+exec "$(dirname "$0")/fake_crm_mon.sh" "$@"
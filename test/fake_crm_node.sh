@@ -0,0 +1,4 @@
+#!/usr/bin/env bash
+
+# crm_node -n prints the name of the local node
+echo "node01"
@@ -0,0 +1,19 @@
+#!/usr/bin/env bash
+
+cat <<END
+
+Current cluster status:
+  * Node List:
+    * Online: [ node01 node02 ]
+
+Allocation scores:
+pcmk__clone_allocate: cln_SAPHanaTopology_PRD_HDB00 allocation score on node01: 0
+pcmk__clone_allocate: cln_SAPHanaTopology_PRD_HDB00 allocation score on node02: 0
+pcmk__native_allocate: rsc_SAPHanaTopology_PRD_HDB00:0 allocation score on node01: 1
+pcmk__native_allocate: rsc_SAPHanaTopology_PRD_HDB00:0 allocation score on node02: 0
+pcmk__native_allocate: rsc_ip_PRD_HDB00 allocation score on node01: 2000
+pcmk__native_allocate: rsc_ip_PRD_HDB00 allocation score on node02: -INFINITY
+rsc_SAPHana_PRD_HDB00:0 promotion score on node01: 150
+
+Transition Summary:
+END
@@ -0,0 +1,10 @@
+#!/usr/bin/env bash
+
+cat >&2 <<END
+   error: unpack_resources:	Resource start-up disabled since no STONITH resources have been defined
+   error: unpack_resources:	Either configure some or disable STONITH with the stonith-enabled option
+warning: unpack_rsc_op_failure:	Processing failed monitor of rsc_ip_PRD_HDB00 on node01: not running | rc=7
+Errors found during check: config not valid
+END
+
+exit 78
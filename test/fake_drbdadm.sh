@@ -0,0 +1,79 @@
+#!/usr/bin/env bash
+
+# the first resource is in sync with its peer, while the data of the second one changed since
+if [ "$1" == "get-gi" ]; then
+  case "$2" in
+    1-single-1:*) echo "C8B5E1F2A3D4E5F7:6D1A0B70C5F3A2A8:4B3C2D1E0F1A2B3C:0000000000000000:1:1:1:1:0:0:0:0" ;;
+    *) echo "6D1A0B70C5F3A2A9:0000000000000000:4B3C2D1E0F1A2B3C:0000000000000000:1:1:1:1:0:0:0:0" ;;
+  esac
+  exit 0
+fi
+
+cat <<CONF
+# /etc/drbd.conf
+global {
+    usage-count no;
+}
+
+common {
+    net {
+        protocol C;
+    }
+}
+
+# resource 1-single-0 on SLE15-sp1-gm-drbd1145296-node2: not ignored, not stacked
+# defined at /etc/drbd.d/1-single-0.res:1
+resource 1-single-0 {
+    on SLE15-sp1-gm-drbd1145296-node1 {
+        node-id 1;
+        volume 0 {
+            device       minor 2;
+            disk         /dev/vdb1;
+            meta-disk    internal;
+        }
+        address          ipv4 192.168.124.11:7790;
+    }
+    on SLE15-sp1-gm-drbd1145296-node2 {
+        node-id 2;
+        volume 0 {
+            device       minor 2;
+            disk         /dev/vdb1;
+            meta-disk    internal;
+        }
+        address          ipv4 192.168.124.12:7790;
+    }
+}
+
+# resource 1-single-1 on SLE15-sp1-gm-drbd1145296-node2: not ignored, not stacked
+# defined at /etc/drbd.d/1-single-1.res:1
+resource 1-single-1 {
+    on SLE15-sp1-gm-drbd1145296-node1 {
+        node-id 1;
+        volume 0 {
+            device       minor 3;
+            disk         /dev/vdb2;
+            meta-disk    internal;
+        }
+        address          ipv4 192.168.124.11:7791;
+    }
+    on SLE15-sp1-gm-drbd1145296-node2 {
+        node-id 2;
+        volume 0 {
+            device       minor 3;
+            disk         /dev/vdb2;
+            meta-disk    internal;
+        }
+        address          ipv4 192.168.124.12:7791;
+    }
+    connection {
+        host SLE15-sp1-gm-drbd1145296-node1;
+        host SLE15-sp1-gm-drbd1145296-node2;
+        net {
+            protocol A;
+        }
+    }
+    net {
+        protocol B;
+    }
+}
+CONF
@@ -0,0 +1,136 @@
+#!/usr/bin/env bash
+
+if [ "$1" == "--version" ]; then
+    cat <<EOF
+DRBDADM_BUILDTAG=GIT-hash:\\ 7d2d7f9a\\ build\\ by\\ abuild@sheep14,\\ 2020-04-02\\ 11:23:02
+DRBDADM_API_VERSION=2
+DRBD_KERNEL_VERSION_CODE=0x090016
+DRBD_KERNEL_VERSION=9.0.22
+DRBDADM_VERSION_CODE=0x090d00
+DRBDADM_VERSION=9.13.0
+EOF
+    exit 0
+fi
+
+cat <<EOF
+[
+  {
+    "name": "1-single-0",
+    "node-id": 2,
+    "role": "Secondary",
+    "suspended": false,
+    "write-ordering": "flush",
+    "devices": [
+      {
+        "volume": 0,
+        "minor": 2,
+        "disk-state": "UpToDate",
+        "client": false,
+        "quorum": true,
+        "size": 409600,
+        "read": 654321,
+        "written": 123456,
+        "al-writes": 123,
+        "bm-writes": 321,
+        "upper-pending": 1,
+        "lower-pending": 2,
+        "al-suspended": false
+      }
+    ],
+    "connections": [
+      {
+        "peer-node-id": 1,
+        "name": "SLE15-sp1-gm-drbd1145296-node1",
+        "connection-state": "Connected",
+        "congested": false,
+        "peer-role": "Primary",
+        "ap-in-flight": 0,
+        "rs-in-flight": 0,
+        "peer_devices": [
+          {
+            "volume": 0,
+            "replication-state": "Established",
+            "peer-disk-state": "UpToDate",
+            "peer-client": false,
+            "resync-suspended": "no",
+            "received": 456,
+            "sent": 654,
+            "out-of-sync": 0,
+            "pending": 3,
+            "unacked": 4,
+            "has-sync-details": false,
+            "has-online-verify-details": false,
+            "percent-in-sync": 100
+          }
+        ]
+      }
+    ]
+  },
+  {
+    "name": "1-single-1",
+    "node-id": 2,
+    "role": "Secondary",
+    "suspended": false,
+    "write-ordering": "flush",
+    "devices": [
+      {
+        "volume": 0,
+        "minor": 3,
+        "disk-state": "UpToDate",
+        "client": false,
+        "quorum": false,
+        "size": 10200,
+        "read": 654321,
+        "written": 123456,
+        "al-writes": 123,
+        "bm-writes": 321,
+        "upper-pending": 1,
+        "lower-pending": 2,
+        "al-suspended": false
+      }
+    ],
+    "connections": [
+      {
+        "peer-node-id": 1,
+        "name": "SLE15-sp1-gm-drbd1145296-node1",
+        "connection-state": "Connected",
+        "congested": false,
+        "peer-role": "Primary",
+        "ap-in-flight": 1024,
+        "rs-in-flight": 2048,
+        "peer_devices": [
+          {
+            "volume": 0,
+            "replication-state": "SyncSource",
+            "peer-disk-state": "Inconsistent",
+            "peer-client": false,
+            "resync-suspended": "no",
+            "received": 456,
+            "sent": 654,
+            "out-of-sync": 153,
+            "pending": 3,
+            "unacked": 4,
+            "has-sync-details": true,
+            "has-online-verify-details": false,
+            "percent-in-sync": 98.5,
+            "rs-total": 10200,
+            "rs-dt-start-ms": 3421,
+            "rs-paused-ms": 0,
+            "rs-dt0-ms": 1002,
+            "rs-db0-sectors": 25600,
+            "rs-dt1-ms": 1002,
+            "rs-db1-sectors": 25600,
+            "rs-failed": 0,
+            "rs-same-csum": 0,
+            "percent-resync-done": 98.50,
+            "db/dt [MiB/s]": 11.90,
+            "db0/dt0 [MiB/s]": 12.50,
+            "db1/dt1 [MiB/s]": 12.50,
+            "estimated-seconds-to-finish": 0
+          }
+        ]
+      }
+    ]
+  }
+]
+EOF
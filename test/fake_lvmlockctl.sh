@@ -0,0 +1,14 @@
+#!/usr/bin/env bash
+
+cat <<END
+VG vg_shared lock_type=sanlock 9HvtiD-qmbl-ANVH-9ZyR-AbQn-8UpF-k0Rtn7
+LS sanlock lvm_vg_shared
+LK VG un ver 5
+LK LV ex H5YhVW-Mj6R-4ZAc-EobT-Dvlq-K1Qu-SLZdKX
+LK LV sh 3PuMhZ-2nKo-Yc8c-aQ8p-1gK3-qa9P-4vZtmS
+
+VG vg_data lock_type=dlm wJ0H1g-F8Qz-8Lb1-MH4G-J8Y6-WbLQ-d8bBZ0
+LS dlm lvm_vg_data
+LK VG sh ver 12
+OP update mode sh vg vg_data pid 4242 (lvcreate)
+END
@@ -0,0 +1,4 @@
+#!/usr/bin/env bash
+
+echo "Cannot connect to lvmlockd." >&2
+exit 255
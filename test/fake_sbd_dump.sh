@@ -1,5 +1,14 @@
 #!/usr/bin/env bash
 
+if [[ "$3" == "list" ]]; then
+  if [[ "$2" == "/dev/vdd" ]]; then
+    printf "0\tnode01\tclear\t\n1\tnode02\treset\tnode01\n"
+  else
+    printf "0\tnode01\tclear\t\n1\tnode02\tclear\t\n"
+  fi
+  exit 0
+fi
+
 cat <<EOF
 ==Dumping header on disk /dev/vdc
 Header version     : 2.1
@@ -0,0 +1,16 @@
+#!/usr/bin/env bash
+
+# skip the ssh options
+while [[ "$1" != "--" ]]; do
+  shift
+done
+host="$2"
+command="$3"
+
+if [[ "$host" == "unreachable" ]]; then
+  echo "ssh: connect to host $host port 22: Connection timed out" >&2
+  exit 255
+fi
+
+# run the remote command locally
+exec bash -c "$command"
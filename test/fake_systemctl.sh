@@ -0,0 +1,23 @@
+#!/usr/bin/env bash
+
+if [ "$1" == "is-enabled" ]; then
+	case "$2" in
+		pacemaker.service|corosync.service)
+			echo enabled
+			exit 0
+			;;
+		sbd.service)
+			echo disabled
+			exit 1
+			;;
+		*)
+			echo "Failed to get unit file state for $2: No such file or directory" >&2
+			exit 1
+			;;
+	esac
+fi
+
+cat <<EOF2
+TimeoutStartUSec=1min 30s
+TimeoutStopUSec=1min
+EOF2